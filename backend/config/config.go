@@ -5,6 +5,11 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"strings"
+
+	"fableflow/backend/filemeta"
+	"fableflow/backend/fsmode"
+	"fableflow/backend/importservice"
 
 	"gopkg.in/yaml.v2"
 )
@@ -12,14 +17,37 @@ import (
 // Config represents the application configuration
 type Config struct {
 	Server struct {
-		Host string `yaml:"host"`
-		Port string `yaml:"port"`
+		Host         string `yaml:"host"`
+		Port         string `yaml:"port"`
+		MaxListLimit int    `yaml:"max_list_limit"`
+		ReadOnly     bool   `yaml:"read_only"`
+		PrettyJSON   bool   `yaml:"pretty_json"`
+		APIKey       string `yaml:"api_key"`
 	} `yaml:"server"`
 	Library struct {
-		ScanDirectory       string `yaml:"scan_directory"`
-		AutoScan            bool   `yaml:"auto_scan"`
-		ImportDirectory     string `yaml:"import_directory"`
-		QuarantineDirectory string `yaml:"quarantine_directory"`
+		ScanDirectory       string   `yaml:"scan_directory"`
+		AutoScan            bool     `yaml:"auto_scan"`
+		MountWaitSeconds    int      `yaml:"mount_wait_seconds"`
+		ImportDirectory     string   `yaml:"import_directory"`
+		QuarantineDirectory string   `yaml:"quarantine_directory"`
+		TrashDirectory      string   `yaml:"trash_directory"`
+		ImportOnConflict    string   `yaml:"import_on_conflict"`
+		UnknownAuthorPolicy string   `yaml:"unknown_author_policy"`
+		LeadingArticles     []string `yaml:"leading_articles"`
+		PathTemplate        string   `yaml:"path_template"`
+		AuthorDirStyle      string   `yaml:"author_dir_style"`
+		FilenamePattern     string   `yaml:"filename_pattern"`
+		MaxArchiveBytes     int64    `yaml:"max_archive_bytes"`
+		FollowSymlinks      bool     `yaml:"follow_symlinks"`
+		ScanPDFs            bool     `yaml:"scan_pdfs"`
+		ScanKindle          bool     `yaml:"scan_kindle"`
+		SkipHidden          bool     `yaml:"skip_hidden"`
+		IgnoreNames         []string `yaml:"ignore_names"`
+		GroupEditions       bool     `yaml:"group_editions"`
+		SidecarMetadata     bool     `yaml:"sidecar_metadata"`
+		CleanMetadata       bool     `yaml:"clean_metadata"`
+		DirMode             string   `yaml:"dir_mode"`
+		FileMode            string   `yaml:"file_mode"`
 	} `yaml:"library"`
 	TmpDir        string `yaml:"tmp_dir"`
 	LogDir        string `yaml:"logdir"`
@@ -27,22 +55,120 @@ type Config struct {
 	Database      struct {
 		Path string `yaml:"path"`
 	} `yaml:"database"`
+	Scan struct {
+		UpdateExisting    bool `yaml:"update_existing"`
+		MaxConcurrent     int  `yaml:"max_concurrent"`
+		UseFileMtime      bool `yaml:"use_file_mtime"`
+		MaxRemovalPercent int  `yaml:"max_removal_percent"`
+	} `yaml:"scan"`
+	Jobs struct {
+		Workers int `yaml:"workers"`
+	} `yaml:"jobs"`
+	Covers struct {
+		CacheMaxBytes   int64    `yaml:"cache_max_bytes"`
+		CacheMaxEntries int      `yaml:"cache_max_entries"`
+		JPEGQuality     int      `yaml:"jpeg_quality"`
+		MaxImageBytes   int64    `yaml:"max_image_bytes"`
+		MaxImagePixels  int      `yaml:"max_image_pixels"`
+		MontageColumns  int      `yaml:"montage_columns"`
+		MontageMaxBooks int      `yaml:"montage_max_books"`
+		ProviderOrder   []string `yaml:"provider_order"`
+		CustomDirectory string   `yaml:"custom_directory"`
+		EmbedMaxBytes   int64    `yaml:"embed_max_bytes"`
+	} `yaml:"covers"`
+	Conversion struct {
+		MaxConcurrent    int   `yaml:"max_concurrent"`
+		PersistentCache  bool  `yaml:"persistent_cache"`
+		CacheMaxAgeHours int   `yaml:"cache_max_age_hours"`
+		CacheMaxBytes    int64 `yaml:"cache_max_bytes"`
+	} `yaml:"conversion"`
+	EPUB struct {
+		BackupOnEdit bool `yaml:"backup_on_edit"`
+		MaxBackups   int  `yaml:"max_backups"`
+	} `yaml:"epub"`
+	Metadata struct {
+		MaxDocsExamined int `yaml:"max_docs_examined"`
+	} `yaml:"metadata"`
+	Home struct {
+		Sections        []string `yaml:"sections"`
+		ContinueReading int      `yaml:"continue_reading"`
+		Recent          int      `yaml:"recent"`
+		Random          int      `yaml:"random"`
+		OnThisDay       int      `yaml:"on_this_day"`
+	} `yaml:"home"`
+	Reader struct {
+		ArchiveIdleSeconds int `yaml:"archive_idle_seconds"`
+		MaxRequestsPerBook int `yaml:"max_requests_per_book"`
+	} `yaml:"reader"`
+	Feed struct {
+		Recent int `yaml:"recent"`
+	} `yaml:"feed"`
+	Share struct {
+		Secret        string `yaml:"secret"`
+		ExpirySeconds int    `yaml:"expiry_seconds"`
+	} `yaml:"share"`
 }
 
+// HomeSections are the sections GET /api/home can show
+var HomeSections = []string{"continue_reading", "recent", "random", "on_this_day"}
+
 // LoadConfig loads configuration from YAML file
 func LoadConfig(filename string) (*Config, error) {
 	// Set defaults
 	config := &Config{}
 	config.Server.Host = "localhost"
 	config.Server.Port = "8080"
+	config.Server.MaxListLimit = 100
 	config.Library.ScanDirectory = "/home/user/Books"
 	config.Library.AutoScan = false
+	config.Library.MountWaitSeconds = 60
 	config.Library.ImportDirectory = "/home/user/Import"
 	config.Library.QuarantineDirectory = "/home/user/Quarantine"
+	config.Library.TrashDirectory = "/home/user/Trash"
+	config.Library.UnknownAuthorPolicy = "keep"
+	config.Library.ImportOnConflict = importservice.ConflictSkip
+	config.Library.LeadingArticles = []string{"the", "a", "an"}
+	config.Library.PathTemplate = "{author}/{title}/{title} - {author}"
+	config.Library.AuthorDirStyle = "as_is"
+	config.Library.FilenamePattern = filemeta.TitleAuthor
+	config.Library.MaxArchiveBytes = 2 * 1024 * 1024 * 1024
+	config.Library.SkipHidden = true
+	config.Library.CleanMetadata = true
+	config.Library.IgnoreNames = []string{"@eaDir", ".Trash-1000", "#recycle", "$RECYCLE.BIN", "System Volume Information"}
+	config.Library.DirMode = "0755"
+	config.Library.FileMode = "0644"
 	config.TmpDir = "/tmp/fableflow"
 	config.LogDir = "/tmp/fableflow/logs"
 	config.MaxImportLogs = 10
 	config.Database.Path = "./ebooks.db"
+	config.Scan.UpdateExisting = false
+	config.Scan.MaxConcurrent = 1
+	config.Scan.MaxRemovalPercent = 50
+	config.Jobs.Workers = 2
+	config.Covers.CacheMaxBytes = 100 * 1024 * 1024
+	config.Covers.JPEGQuality = 85
+	config.Covers.MaxImageBytes = 20 * 1024 * 1024
+	config.Covers.MaxImagePixels = 40000000
+	config.Covers.MontageColumns = 3
+	config.Covers.MontageMaxBooks = 9
+	config.Covers.ProviderOrder = []string{"custom", "embedded"}
+	config.Covers.CustomDirectory = "/home/user/Covers"
+	config.Covers.EmbedMaxBytes = 10 * 1024 * 1024
+	config.Conversion.MaxConcurrent = 2
+	config.Conversion.CacheMaxAgeHours = 30 * 24
+	config.Conversion.CacheMaxBytes = 2 * 1024 * 1024 * 1024
+	config.EPUB.BackupOnEdit = false
+	config.EPUB.MaxBackups = 3
+	config.Metadata.MaxDocsExamined = 15
+	config.Home.Sections = append([]string(nil), HomeSections...)
+	config.Home.ContinueReading = 6
+	config.Home.Recent = 12
+	config.Home.Random = 6
+	config.Home.OnThisDay = 6
+	config.Reader.ArchiveIdleSeconds = 30
+	config.Reader.MaxRequestsPerBook = 8
+	config.Feed.Recent = 25
+	config.Share.ExpirySeconds = 24 * 60 * 60
 
 	// Check if config file exists
 	if _, err := os.Stat(filename); os.IsNotExist(err) {
@@ -61,6 +187,60 @@ func LoadConfig(filename string) (*Config, error) {
 		return nil, fmt.Errorf("failed to parse config file: %v", err)
 	}
 
+	if _, err := fsmode.Parse(config.Library.DirMode, fsmode.DefaultDirMode); err != nil {
+		return nil, fmt.Errorf("library.dir_mode: %v", err)
+	}
+	if _, err := fsmode.Parse(config.Library.FileMode, fsmode.DefaultFileMode); err != nil {
+		return nil, fmt.Errorf("library.file_mode: %v", err)
+	}
+	if !importservice.ValidConflictPolicy(config.Library.ImportOnConflict) {
+		return nil, fmt.Errorf("library.import_on_conflict must be %q, %q or %q, got %q", importservice.ConflictSkip, importservice.ConflictOverwrite, importservice.ConflictRename, config.Library.ImportOnConflict)
+	}
+	if !filemeta.ValidPattern(config.Library.FilenamePattern) {
+		return nil, fmt.Errorf("library.filename_pattern must be %q or %q, got %q", filemeta.TitleAuthor, filemeta.AuthorTitle, config.Library.FilenamePattern)
+	}
+	for _, section := range config.Home.Sections {
+		if !validHomeSection(section) {
+			return nil, fmt.Errorf("home.sections: unknown section %q, expected one of %s", section, strings.Join(HomeSections, ", "))
+		}
+	}
+	if config.Scan.MaxRemovalPercent < 0 || config.Scan.MaxRemovalPercent > 100 {
+		return nil, fmt.Errorf("scan.max_removal_percent must be between 0 and 100, got %d", config.Scan.MaxRemovalPercent)
+	}
+	if config.Share.ExpirySeconds <= 0 {
+		return nil, fmt.Errorf("share.expiry_seconds must be positive, got %d", config.Share.ExpirySeconds)
+	}
+
 	log.Printf("Loaded configuration from %s", filename)
 	return config, nil
 }
+
+// validHomeSection reports whether name is one of HomeSections
+func validHomeSection(name string) bool {
+	for _, section := range HomeSections {
+		if section == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LibraryDirMode returns the permissions for directories created in the library
+// (library.dir_mode). LoadConfig has already validated the value.
+func (c *Config) LibraryDirMode() os.FileMode {
+	mode, err := fsmode.Parse(c.Library.DirMode, fsmode.DefaultDirMode)
+	if err != nil {
+		return fsmode.DefaultDirMode
+	}
+	return mode
+}
+
+// LibraryFileMode returns the permissions for book files written to the library
+// (library.file_mode). LoadConfig has already validated the value.
+func (c *Config) LibraryFileMode() os.FileMode {
+	mode, err := fsmode.Parse(c.Library.FileMode, fsmode.DefaultFileMode)
+	if err != nil {
+		return fsmode.DefaultFileMode
+	}
+	return mode
+}
@@ -1,6 +1,7 @@
 package conversion
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,6 +11,12 @@ import (
 // ConvertEPUBToAZW3 is the main conversion function using Amazon's kindlegen tool.
 // This follows FB2Converter's approach for high-quality EPUB to AZW3 conversion.
 func ConvertEPUBToAZW3(inputPath, outputPath string) error {
+	return ConvertEPUBToAZW3Context(context.Background(), inputPath, outputPath)
+}
+
+// ConvertEPUBToAZW3Context is ConvertEPUBToAZW3 with a context; cancelling it kills kindlegen
+// and removes any partial output.
+func ConvertEPUBToAZW3Context(ctx context.Context, inputPath, outputPath string) error {
 	// Validate input file
 	if _, err := os.Stat(inputPath); err != nil {
 		return fmt.Errorf("input file not found: %w", err)
@@ -36,7 +43,7 @@ func ConvertEPUBToAZW3(inputPath, outputPath string) error {
 	converter.SetVerbose(true)
 
 	// Convert EPUB to AZW3 using kindlegen
-	if err := converter.ConvertEPUBToAZW3(inputPath, outputPath); err != nil {
+	if err := converter.ConvertEPUBToAZW3Context(ctx, inputPath, outputPath); err != nil {
 		return fmt.Errorf("kindlegen conversion failed: %w", err)
 	}
 
@@ -8,6 +8,9 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+
+	"fableflow/backend/filemeta"
+	"fableflow/backend/xmlutil"
 )
 
 // EPUBBook represents the parsed content of an EPUB file
@@ -28,6 +31,7 @@ type Chapter struct {
 	Title   string
 	Content string
 	Order   int
+	Href    string // Path of the chapter document inside the archive
 }
 
 // OPF represents the structure of an EPUB OPF file
@@ -40,14 +44,35 @@ type OPF struct {
 
 // Metadata represents the metadata section of an OPF file
 type Metadata struct {
-	Title       []string `xml:"title"`
-	Creator     []string `xml:"creator"`
-	Language    []string `xml:"language"`
-	Description []string `xml:"description"`
-	Publisher   []string `xml:"publisher"`
-	Date        []string `xml:"date"`
-	Subject     []string `xml:"subject"`
-	Rights      []string `xml:"rights"`
+	Title       []string  `xml:"title"`
+	Creator     []Creator `xml:"creator"`
+	Language    []string  `xml:"language"`
+	Description []string  `xml:"description"`
+	Publisher   []string  `xml:"publisher"`
+	Date        []string  `xml:"date"`
+	Subject     []string  `xml:"subject"`
+	Rights      []string  `xml:"rights"`
+	Identifier  []string  `xml:"identifier"`
+	Meta        []Meta    `xml:"meta"`
+}
+
+// Creator is a dc:creator element. EPUB 2 gives the MARC relator code ("aut", "ill",
+// "trl", ...) in an opf:role attribute; EPUB 3 in a role meta refining the creator's id.
+type Creator struct {
+	Name string `xml:",chardata"`
+	Role string `xml:"role,attr"`
+	ID   string `xml:"id,attr"`
+}
+
+// Meta is an OPF <meta> element: EPUB 2 uses name and content attributes, EPUB 3 a
+// property attribute with the value as text, optionally refining another element
+type Meta struct {
+	Name     string `xml:"name,attr"`
+	Content  string `xml:"content,attr"`
+	Property string `xml:"property,attr"`
+	ID       string `xml:"id,attr"`
+	Refines  string `xml:"refines,attr"` // "#id" of the element this meta describes
+	Value    string `xml:",chardata"`
 }
 
 // Manifest represents the manifest section of an OPF file
@@ -241,9 +266,13 @@ func (p *EPUBParser) ParseOPF(opfFile *zip.File) (*OPF, error) {
 		return nil, fmt.Errorf("failed to read OPF file: %v", err)
 	}
 
+	return ParseOPFData(content)
+}
+
+// ParseOPFData parses OPF XML content, e.g. a metadata.opf stored next to a book
+func ParseOPFData(content []byte) (*OPF, error) {
 	var opf OPF
-	err = xml.Unmarshal(content, &opf)
-	if err != nil {
+	if err := xmlutil.Unmarshal(content, &opf); err != nil {
 		return nil, fmt.Errorf("failed to parse OPF XML: %v", err)
 	}
 
@@ -256,7 +285,7 @@ func (p *EPUBParser) extractMetadata(opf *OPF, book *EPUBBook) {
 		book.Title = strings.TrimSpace(opf.Metadata.Title[0])
 	}
 	if len(opf.Metadata.Creator) > 0 {
-		book.Author = strings.TrimSpace(opf.Metadata.Creator[0])
+		book.Author = strings.TrimSpace(opf.Metadata.Creator[0].Name)
 	}
 	if len(opf.Metadata.Language) > 0 {
 		book.Language = strings.TrimSpace(opf.Metadata.Language[0])
@@ -301,6 +330,7 @@ func (p *EPUBParser) extractContent(reader *zip.ReadCloser, opf *OPF, book *EPUB
 				Title:   p.extractTitleFromHTML(content),
 				Content: content,
 				Order:   i,
+				Href:    p.archivePath(reader, item.Href),
 			}
 			book.Chapters = append(book.Chapters, chapter)
 
@@ -321,6 +351,17 @@ func (p *EPUBParser) extractContent(reader *zip.ReadCloser, opf *OPF, book *EPUB
 	return nil
 }
 
+// archivePath returns the name of the archive file an OPF href refers to, matched the
+// same way extractHTMLContent finds it
+func (p *EPUBParser) archivePath(reader *zip.ReadCloser, href string) string {
+	for _, file := range reader.File {
+		if file.Name == href || strings.HasSuffix(file.Name, href) {
+			return file.Name
+		}
+	}
+	return href
+}
+
 // extractHTMLContent extracts HTML content from a file
 func (p *EPUBParser) extractHTMLContent(reader *zip.ReadCloser, href string) (string, error) {
 	for _, file := range reader.File {
@@ -422,24 +463,12 @@ func (p *EPUBParser) extractTitleFromHTML(html string) string {
 	return "Chapter"
 }
 
-// extractMetadataFromFilename extracts metadata from the filename as fallback
+// extractMetadataFromFilename extracts metadata from the filename as fallback, assuming
+// the default "Title - Author" names; library scans read it with metadata.Extractor,
+// which follows library.filename_pattern
 func (p *EPUBParser) extractMetadataFromFilename(filePath string, book *EPUBBook) {
-	filename := filepath.Base(filePath)
-	// Remove extension
-	nameWithoutExt := strings.TrimSuffix(filename, filepath.Ext(filename))
-
-	// Try to parse "Author - Title" format
-	if strings.Contains(nameWithoutExt, " - ") {
-		parts := strings.Split(nameWithoutExt, " - ")
-		if len(parts) >= 2 {
-			book.Author = strings.TrimSpace(parts[0])
-			book.Title = strings.TrimSpace(parts[1])
-		} else {
-			book.Title = nameWithoutExt
-			book.Author = "Unknown Author"
-		}
-	} else {
-		book.Title = nameWithoutExt
+	book.Title, book.Author = filemeta.Parser{}.Parse(filePath)
+	if book.Author == "" {
 		book.Author = "Unknown Author"
 	}
 
@@ -539,6 +568,7 @@ func (p *EPUBParser) extractContentSimple(reader *zip.ReadCloser, book *EPUBBook
 			Title:   p.extractTitleFromHTML(content),
 			Content: content,
 			Order:   i,
+			Href:    file.Name,
 		}
 		book.Chapters = append(book.Chapters, chapter)
 	}
@@ -0,0 +1,185 @@
+package conversion
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"strings"
+)
+
+// FormatInfo describes an ebook format for users choosing what to download or convert to
+type FormatInfo struct {
+	Format      string   `json:"format"`      // Lowercase format name as used in the API, e.g. "azw3"
+	Name        string   `json:"name"`        // Display name, e.g. "AZW3"
+	Description string   `json:"description"` // What the format is, e.g. "Modern Kindle format"
+	Extensions  []string `json:"extensions"`  // Typical file extensions, the usual one first
+	Devices     string   `json:"devices"`     // Which readers and devices open it
+	Lossy       bool     `json:"lossy"`       // Converting an EPUB to it loses layout or features
+}
+
+// formats is the registry of formats the library stores or converts to
+var formats = []FormatInfo{
+	{
+		Format:      "epub",
+		Name:        "EPUB",
+		Description: "Open standard ebook format with reflowable text",
+		Extensions:  []string{".epub"},
+		Devices:     "Kobo, PocketBook, Apple Books, Google Play Books and most reading apps; Kindle via Send to Kindle",
+	},
+	{
+		Format:      "azw3",
+		Name:        "AZW3",
+		Description: "Modern Kindle format (KF8)",
+		Extensions:  []string{".azw3"},
+		Devices:     "Kindle e-readers from 2012 on and the Kindle apps",
+		Lossy:       true,
+	},
+	{
+		Format:      "mobi",
+		Name:        "MOBI",
+		Description: "Older Kindle format (Mobipocket)",
+		Extensions:  []string{".mobi", ".prc"},
+		Devices:     "Older Kindle e-readers; prefer AZW3 for current devices",
+		Lossy:       true,
+	},
+	{
+		Format:      "azw",
+		Name:        "AZW",
+		Description: "Kindle format of books bought from Amazon",
+		Extensions:  []string{".azw"},
+		Devices:     "Kindle e-readers and apps",
+		Lossy:       true,
+	},
+	{
+		Format:      "pdf",
+		Name:        "PDF",
+		Description: "Fixed-layout document; pages do not reflow to the screen size",
+		Extensions:  []string{".pdf"},
+		Devices:     "Computers and tablets; hard to read on small e-reader screens",
+		Lossy:       true,
+	},
+	{
+		Format:      "txt",
+		Name:        "Plain text",
+		Description: "Text only, without formatting or images",
+		Extensions:  []string{".txt"},
+		Devices:     "Any device",
+		Lossy:       true,
+	},
+}
+
+// LookupFormat returns the registry entry for a format name or file extension such as
+// "AZW3" or ".mobi", and false when the format is unknown
+func LookupFormat(format string) (FormatInfo, bool) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	for _, info := range formats {
+		if info.Format == strings.TrimPrefix(format, ".") {
+			return info, true
+		}
+		for _, ext := range info.Extensions {
+			if ext == format || ext == "."+format {
+				return info, true
+			}
+		}
+	}
+	return FormatInfo{}, false
+}
+
+// DescribeFormats returns the registry entries of the given formats, skipping unknown ones
+func DescribeFormats(names ...string) []FormatInfo {
+	infos := []FormatInfo{}
+	for _, name := range names {
+		if info, ok := LookupFormat(name); ok {
+			infos = append(infos, info)
+		}
+	}
+	return infos
+}
+
+// DetectFormat returns the format of a file from its content rather than its name:
+// "epub" for a ZIP archive with an EPUB mimetype entry, "pdf", "azw3" for a KF8-only
+// Kindle book and "mobi" for other Mobipocket books. It returns "" for anything else.
+func DetectFormat(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	// A PDB header is 78 bytes followed by the record list; the first record's offset is enough
+	header := make([]byte, 82)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	header = header[:n]
+
+	switch {
+	case bytes.HasPrefix(header, []byte("%PDF-")):
+		return "pdf", nil
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")):
+		return detectZipFormat(path)
+	case len(header) >= 82 && string(header[60:68]) == "BOOKMOBI":
+		return detectMobiFormat(file, binary.BigEndian.Uint32(header[78:82]))
+	}
+	return "", nil
+}
+
+// detectZipFormat tells EPUBs from other ZIP archives by their mimetype entry
+func detectZipFormat(path string) (string, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		// Truncated or damaged archives still start like one
+		return "", nil
+	}
+	defer reader.Close()
+
+	for _, file := range reader.File {
+		if file.Name != "mimetype" {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return "", nil
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, 64))
+		rc.Close()
+		if err == nil && strings.TrimSpace(string(content)) == "application/epub+zip" {
+			return "epub", nil
+		}
+		break
+	}
+	return "", nil
+}
+
+// detectMobiFormat reads the MOBI header in the first PDB record: file version 8 is a
+// KF8-only book (AZW3), earlier versions are Mobipocket books, possibly with KF8 inside
+func detectMobiFormat(file *os.File, recordOffset uint32) (string, error) {
+	// The 16-byte PalmDOC header precedes "MOBI", the header length, type, encoding,
+	// unique ID and the file version
+	header := make([]byte, 40)
+	if _, err := file.ReadAt(header, int64(recordOffset)); err != nil {
+		return "mobi", nil
+	}
+	if string(header[16:20]) == "MOBI" && binary.BigEndian.Uint32(header[36:40]) == 8 {
+		return "azw3", nil
+	}
+	return "mobi", nil
+}
+
+// SameFormat reports whether a detected format matches a stored one. AZW books are
+// Mobipocket files, either old-style or KF8, and AZW3 files may be combined
+// Mobipocket/KF8 books.
+func SameFormat(stored, detected string) bool {
+	switch strings.ToLower(stored) {
+	case detected:
+		return true
+	case "azw":
+		return detected == "mobi" || detected == "azw3"
+	case "azw3":
+		return detected == "mobi"
+	}
+	return false
+}
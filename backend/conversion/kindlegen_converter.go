@@ -2,12 +2,14 @@ package conversion
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"syscall"
+	"time"
 )
 
 // KindlegenConverter handles EPUB to AZW3 conversion using Amazon's kindlegen tool.
@@ -33,6 +35,12 @@ func NewKindlegenConverter() (*KindlegenConverter, error) {
 // ConvertEPUBToAZW3 converts an EPUB file to AZW3 format using kindlegen.
 // This follows FB2Converter's approach: kindlegen creates MOBI, then we rename it to AZW3.
 func (kc *KindlegenConverter) ConvertEPUBToAZW3(inputPath, outputPath string) error {
+	return kc.ConvertEPUBToAZW3Context(context.Background(), inputPath, outputPath)
+}
+
+// ConvertEPUBToAZW3Context is ConvertEPUBToAZW3 with a context. When the context is
+// cancelled kindlegen is killed and the intermediate MOBI file is removed.
+func (kc *KindlegenConverter) ConvertEPUBToAZW3Context(ctx context.Context, inputPath, outputPath string) error {
 	fmt.Printf("KindlegenConverter: Starting conversion %s -> %s\n", inputPath, outputPath)
 
 	// Create output directory if it doesn't exist
@@ -44,10 +52,14 @@ func (kc *KindlegenConverter) ConvertEPUBToAZW3(inputPath, outputPath string) er
 
 	// Generate intermediate MOBI file using kindlegen
 	fmt.Printf("KindlegenConverter: Generating intermediate MOBI file\n")
-	mobiPath, err := kc.generateIntermediateMOBI(inputPath, outputDir)
+	mobiPath, err := kc.generateIntermediateMOBI(ctx, inputPath, outputDir)
 	if err != nil {
 		return fmt.Errorf("failed to generate intermediate MOBI: %w", err)
 	}
+	if err := ctx.Err(); err != nil {
+		os.Remove(mobiPath)
+		return err
+	}
 
 	// Rename MOBI to AZW3 (AZW3 is essentially MOBI format)
 	fmt.Printf("KindlegenConverter: Renaming %s to %s\n", mobiPath, outputPath)
@@ -61,7 +73,7 @@ func (kc *KindlegenConverter) ConvertEPUBToAZW3(inputPath, outputPath string) er
 
 // generateIntermediateMOBI uses kindlegen to create a MOBI file from EPUB.
 // This follows FB2Converter's approach.
-func (kc *KindlegenConverter) generateIntermediateMOBI(inputPath, outputDir string) (string, error) {
+func (kc *KindlegenConverter) generateIntermediateMOBI(ctx context.Context, inputPath, outputDir string) (string, error) {
 	// Create output filename (MOBI format) - keep original filename, just change extension
 	baseName := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
 	mobiFile := baseName + ".mobi"
@@ -80,8 +92,10 @@ func (kc *KindlegenConverter) generateIntermediateMOBI(inputPath, outputDir stri
 	}
 
 	// Create command and set working directory to output directory
-	cmd := exec.Command(kc.kindlegenPath, args...)
+	cmd := exec.CommandContext(ctx, kc.kindlegenPath, args...)
 	cmd.Dir = outputDir // Set working directory so kindlegen creates file there
+	// Don't wait forever on output pipes after kindlegen has been killed
+	cmd.WaitDelay = 5 * time.Second
 
 	fmt.Printf("Running kindlegen: %s %s\n", kc.kindlegenPath, strings.Join(args, " "))
 	fmt.Printf("Expected output file: %s\n", mobiPath)
@@ -107,6 +121,11 @@ func (kc *KindlegenConverter) generateIntermediateMOBI(inputPath, outputDir stri
 
 	// Wait for command to complete
 	if err := cmd.Wait(); err != nil {
+		if ctx.Err() != nil {
+			// Killed because the conversion was cancelled; drop whatever was written
+			os.Remove(mobiPath)
+			return "", ctx.Err()
+		}
 		if ee, ok := err.(*exec.ExitError); ok {
 			if len(ee.Stderr) > 0 {
 				fmt.Printf("kindlegen stderr: %s\n", string(ee.Stderr))
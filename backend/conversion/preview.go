@@ -0,0 +1,78 @@
+package conversion
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// blockEndPattern matches the markup that ends a paragraph, heading or line
+var blockEndPattern = regexp.MustCompile(`(?i)</(p|div|h[1-6]|li|blockquote|tr)\s*>|<br\s*/?>`)
+
+// PreviewParagraphs returns the opening paragraphs of the first spine document with at
+// least minChars characters of text, so title pages and other short front matter are
+// skipped. Paragraphs are added until maxChars characters have been collected.
+func (p *EPUBParser) PreviewParagraphs(filePath string, minChars, maxChars int) ([]string, error) {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open EPUB file: %v", err)
+	}
+	defer reader.Close()
+
+	opfFile, err := p.FindOPFFile(reader)
+	if err != nil {
+		return nil, err
+	}
+	opf, err := p.ParseOPF(opfFile)
+	if err != nil {
+		return nil, err
+	}
+
+	itemMap := make(map[string]Item)
+	for _, item := range opf.Manifest.Items {
+		itemMap[item.ID] = item
+	}
+
+	for _, itemRef := range opf.Spine.ItemRefs {
+		item, exists := itemMap[itemRef.IDRef]
+		if !exists || !strings.Contains(item.MediaType, "html") {
+			continue
+		}
+		content, err := p.extractHTMLContent(reader, item.Href)
+		if err != nil || countTextChars(content) < minChars {
+			continue
+		}
+
+		var paragraphs []string
+		collected := 0
+		for _, paragraph := range htmlParagraphs(content) {
+			paragraphs = append(paragraphs, paragraph)
+			collected += len(paragraph)
+			if collected >= maxChars {
+				break
+			}
+		}
+		return paragraphs, nil
+	}
+
+	return nil, fmt.Errorf("no spine document has at least %d characters of text", minChars)
+}
+
+// htmlParagraphs splits an HTML document into its non-empty text paragraphs
+func htmlParagraphs(content string) []string {
+	text := headPattern.ReplaceAllString(content, "")
+	// Source line breaks are just whitespace; paragraphs end at block-level tags
+	text = strings.NewReplacer("\r", " ", "\n", " ").Replace(text)
+	text = blockEndPattern.ReplaceAllString(text, "\n")
+	text = html.UnescapeString(tagPattern.ReplaceAllString(text, " "))
+
+	var paragraphs []string
+	for _, line := range strings.Split(text, "\n") {
+		if paragraph := strings.Join(strings.Fields(line), " "); paragraph != "" {
+			paragraphs = append(paragraphs, paragraph)
+		}
+	}
+	return paragraphs
+}
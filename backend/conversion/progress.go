@@ -0,0 +1,90 @@
+package conversion
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// tagPattern matches markup tags so only readable text is counted
+var tagPattern = regexp.MustCompile(`(?s)<[^>]*>`)
+
+// headPattern matches the document head, whose title and styles are not read
+var headPattern = regexp.MustCompile(`(?is)<head[^>]*>.*?</head>`)
+
+// SpineCharCounts returns the number of readable text characters in each spine
+// document, in spine order. Non-HTML spine items count as zero.
+func (p *EPUBParser) SpineCharCounts(filePath string) ([]int, error) {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open EPUB file: %v", err)
+	}
+	defer reader.Close()
+
+	opfFile, err := p.FindOPFFile(reader)
+	if err != nil {
+		return nil, err
+	}
+	opf, err := p.ParseOPF(opfFile)
+	if err != nil {
+		return nil, err
+	}
+
+	itemMap := make(map[string]Item)
+	for _, item := range opf.Manifest.Items {
+		itemMap[item.ID] = item
+	}
+
+	counts := make([]int, len(opf.Spine.ItemRefs))
+	for i, itemRef := range opf.Spine.ItemRefs {
+		item, exists := itemMap[itemRef.IDRef]
+		if !exists || !strings.Contains(item.MediaType, "html") {
+			continue
+		}
+		content, err := p.extractHTMLContent(reader, item.Href)
+		if err != nil {
+			continue
+		}
+		counts[i] = countTextChars(content)
+	}
+
+	return counts, nil
+}
+
+// countTextChars counts the visible characters of an HTML document, ignoring whitespace runs
+func countTextChars(content string) int {
+	text := headPattern.ReplaceAllString(content, "")
+	text = html.UnescapeString(tagPattern.ReplaceAllString(text, " "))
+	return utf8.RuneCountInString(strings.Join(strings.Fields(text), " "))
+}
+
+// ProgressPercent converts a locator (spine index plus character offset into
+// that document) into an overall percentage using per-document character counts.
+func ProgressPercent(counts []int, spineIndex, offset int) float64 {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	if total == 0 || spineIndex < 0 {
+		return 0
+	}
+	if spineIndex >= len(counts) {
+		return 100
+	}
+
+	read := 0
+	for _, count := range counts[:spineIndex] {
+		read += count
+	}
+	if offset > counts[spineIndex] {
+		offset = counts[spineIndex]
+	}
+	if offset > 0 {
+		read += offset
+	}
+
+	return float64(read) / float64(total) * 100
+}
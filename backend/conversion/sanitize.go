@@ -0,0 +1,122 @@
+package conversion
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// unsafeElements are removed from sanitized chapter markup together with their content
+var unsafeElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Iframe:   true,
+	atom.Frame:    true,
+	atom.Frameset: true,
+	atom.Object:   true,
+	atom.Embed:    true,
+	atom.Applet:   true,
+	atom.Form:     true,
+	atom.Input:    true,
+	atom.Button:   true,
+	atom.Select:   true,
+	atom.Textarea: true,
+	atom.Link:     true,
+	atom.Meta:     true,
+	atom.Base:     true,
+}
+
+// selfClosingPattern matches XHTML self-closing tags such as <a id="x"/>, which an HTML
+// parser would take as an opening tag wrapping everything after it
+var selfClosingPattern = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9]*)(\s[^<>]*)?/>`)
+
+// voidElements may stay self-closing since HTML never expects their end tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// SanitizeHTML returns the body markup of a chapter document without scripts, styles,
+// embedded objects, forms, event handler attributes and javascript: URLs. Relative links
+// and image sources are kept as they are, relative to the chapter's href.
+func SanitizeHTML(content string) string {
+	content = selfClosingPattern.ReplaceAllStringFunc(content, func(tag string) string {
+		match := selfClosingPattern.FindStringSubmatch(tag)
+		if voidElements[strings.ToLower(match[1])] {
+			return tag
+		}
+		return "<" + match[1] + match[2] + "></" + match[1] + ">"
+	})
+
+	doc, err := html.Parse(strings.NewReader(content))
+	if err != nil {
+		return ""
+	}
+
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+	sanitizeNode(body)
+
+	var buf bytes.Buffer
+	for child := body.FirstChild; child != nil; child = child.NextSibling {
+		html.Render(&buf, child)
+	}
+	return strings.TrimSpace(buf.String())
+}
+
+// HTMLText returns the plain text of a chapter document with a blank line between paragraphs
+func HTMLText(content string) string {
+	return strings.Join(htmlParagraphs(content), "\n\n")
+}
+
+// findElement returns the first element of type a in document order
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := findElement(child, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// sanitizeNode removes unsafe elements, comments and attributes below n
+func sanitizeNode(n *html.Node) {
+	for child := n.FirstChild; child != nil; {
+		next := child.NextSibling
+		switch {
+		case child.Type == html.CommentNode:
+			n.RemoveChild(child)
+		case child.Type == html.ElementNode && unsafeElements[child.DataAtom]:
+			n.RemoveChild(child)
+		case child.Type == html.ElementNode:
+			child.Attr = safeAttributes(child.Attr)
+			sanitizeNode(child)
+		}
+		child = next
+	}
+}
+
+// safeAttributes drops event handlers and URLs using the javascript: or vbscript: scheme
+func safeAttributes(attrs []html.Attribute) []html.Attribute {
+	kept := attrs[:0]
+	for _, attr := range attrs {
+		key := strings.ToLower(attr.Key)
+		if strings.HasPrefix(key, "on") {
+			continue
+		}
+		value := strings.ToLower(strings.Join(strings.Fields(attr.Val), ""))
+		if strings.HasPrefix(value, "javascript:") || strings.HasPrefix(value, "vbscript:") {
+			continue
+		}
+		kept = append(kept, attr)
+	}
+	return kept
+}
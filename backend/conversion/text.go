@@ -0,0 +1,78 @@
+package conversion
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrChapterNotFound is returned by WriteText when the requested spine index does not exist
+var ErrChapterNotFound = errors.New("chapter not found")
+
+// WriteText writes the plain text of an EPUB to w with a blank line between paragraphs.
+// A chapter of -1 writes every spine document in order, separated by two blank lines;
+// otherwise only the document at that spine index is written. Documents are extracted
+// one at a time, and w is flushed after each one when it supports flushing.
+func (p *EPUBParser) WriteText(filePath string, chapter int, w io.Writer) error {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open EPUB file: %v", err)
+	}
+	defer reader.Close()
+
+	opfFile, err := p.FindOPFFile(reader)
+	if err != nil {
+		return err
+	}
+	opf, err := p.ParseOPF(opfFile)
+	if err != nil {
+		return err
+	}
+
+	itemRefs := opf.Spine.ItemRefs
+	if chapter >= 0 {
+		if chapter >= len(itemRefs) {
+			return fmt.Errorf("%w: spine has %d documents", ErrChapterNotFound, len(itemRefs))
+		}
+		itemRefs = itemRefs[chapter : chapter+1]
+	}
+
+	itemMap := make(map[string]Item)
+	for _, item := range opf.Manifest.Items {
+		itemMap[item.ID] = item
+	}
+
+	flusher, _ := w.(interface{ Flush() })
+	written := false
+	for _, itemRef := range itemRefs {
+		item, exists := itemMap[itemRef.IDRef]
+		if !exists || !strings.Contains(item.MediaType, "html") {
+			continue
+		}
+		content, err := p.extractHTMLContent(reader, item.Href)
+		if err != nil {
+			continue
+		}
+		paragraphs := htmlParagraphs(content)
+		if len(paragraphs) == 0 {
+			continue
+		}
+
+		if written {
+			if _, err := io.WriteString(w, "\n\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, strings.Join(paragraphs, "\n\n")+"\n"); err != nil {
+			return err
+		}
+		written = true
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package covercache
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// entry tracks a single cached file
+type entry struct {
+	size       int64
+	lastAccess time.Time
+}
+
+// Cache is a size-capped disk cache for generated cover thumbnails.
+// When the total size or the number of files exceeds its cap, least-recently-served
+// files are evicted. A nil *Cache is valid and behaves as a disabled cache.
+type Cache struct {
+	dir        string
+	maxBytes   int64
+	maxEntries int // 0 means no limit on the number of files
+	mutex      sync.Mutex
+	entries    map[string]*entry
+	totalBytes int64
+}
+
+// New creates a cache rooted at dir, indexing any files left from a previous run.
+// A maxBytes of zero or less disables the cache and returns nil; a maxEntries of zero
+// or less puts no limit on the number of files.
+func New(dir string, maxBytes int64, maxEntries int) (*Cache, error) {
+	if maxBytes <= 0 {
+		return nil, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cover cache directory: %v", err)
+	}
+
+	c := &Cache{
+		dir:        dir,
+		maxBytes:   maxBytes,
+		maxEntries: max(maxEntries, 0),
+		entries:    make(map[string]*entry),
+	}
+
+	// Seed the index from disk, using the modification time as the last access
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cover cache directory: %v", err)
+	}
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		c.entries[file.Name()] = &entry{size: file.Size(), lastAccess: file.ModTime()}
+		c.totalBytes += file.Size()
+	}
+
+	c.mutex.Lock()
+	c.evictLocked()
+	c.mutex.Unlock()
+
+	return c, nil
+}
+
+// Get returns the cached data for key and marks it as recently used
+func (c *Cache) Get(key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	// Held throughout so eviction cannot delete the file between reading it and
+	// marking it as used
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	e, exists := c.entries[key]
+	if !exists {
+		return nil, false
+	}
+
+	path := filepath.Join(c.dir, key)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		c.removeLocked(key)
+		return nil, false
+	}
+
+	now := time.Now()
+	e.lastAccess = now
+	// Persist recency so the ordering survives restarts
+	os.Chtimes(path, now, now)
+
+	return data, true
+}
+
+// Put stores data under key and evicts old entries if the cache is over its cap
+func (c *Cache) Put(key string, data []byte) error {
+	if c == nil {
+		return nil
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(c.dir, key), data, 0644); err != nil {
+		return fmt.Errorf("failed to write cover cache entry: %v", err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if old, exists := c.entries[key]; exists {
+		c.totalBytes -= old.size
+	}
+	c.entries[key] = &entry{size: int64(len(data)), lastAccess: time.Now()}
+	c.totalBytes += int64(len(data))
+
+	c.evictLocked()
+	return nil
+}
+
+// StartJanitor periodically enforces the size cap in the background
+func (c *Cache) StartJanitor(interval time.Duration) {
+	if c == nil {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			c.mutex.Lock()
+			c.evictLocked()
+			c.mutex.Unlock()
+		}
+	}()
+}
+
+// removeLocked drops a single entry from the index and disk.
+// The caller must hold the mutex.
+func (c *Cache) removeLocked(key string) {
+	if e, exists := c.entries[key]; exists {
+		c.totalBytes -= e.size
+		delete(c.entries, key)
+	}
+	os.Remove(filepath.Join(c.dir, key))
+}
+
+// overLocked reports whether the cache exceeds its size or entry cap.
+// The caller must hold the mutex.
+func (c *Cache) overLocked() bool {
+	return c.totalBytes > c.maxBytes || (c.maxEntries > 0 && len(c.entries) > c.maxEntries)
+}
+
+// evictLocked deletes least-recently-used entries until the cache fits its caps.
+// The caller must hold the mutex.
+func (c *Cache) evictLocked() {
+	if !c.overLocked() {
+		return
+	}
+
+	keys := make([]string, 0, len(c.entries))
+	for key := range c.entries {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return c.entries[keys[i]].lastAccess.Before(c.entries[keys[j]].lastAccess)
+	})
+
+	for _, key := range keys {
+		if !c.overLocked() {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, key)); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to evict cover cache entry %s: %v", key, err)
+			continue
+		}
+		c.totalBytes -= c.entries[key].size
+		delete(c.entries, key)
+	}
+}
@@ -0,0 +1,145 @@
+package covercache
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestCache returns a cache in a temporary directory
+func newTestCache(t *testing.T, maxBytes int64, maxEntries int) *Cache {
+	t.Helper()
+	c, err := New(t.TempDir(), maxBytes, maxEntries)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return c
+}
+
+// put stores a value of size bytes under key
+func put(t *testing.T, c *Cache, key string, size int) {
+	t.Helper()
+	if err := c.Put(key, make([]byte, size)); err != nil {
+		t.Fatalf("Put(%q): %v", key, err)
+	}
+}
+
+// checkCached verifies which of keys are cached, in the index and on disk
+func checkCached(t *testing.T, c *Cache, want map[string]bool) {
+	t.Helper()
+	for key, cached := range want {
+		_, err := os.Stat(filepath.Join(c.dir, key))
+		c.mutex.Lock()
+		_, indexed := c.entries[key]
+		c.mutex.Unlock()
+		if indexed != cached || (err == nil) != cached {
+			t.Errorf("%s: indexed %v, file error %v; want cached %v", key, indexed, err, cached)
+		}
+	}
+}
+
+func TestEvictBySize(t *testing.T) {
+	c := newTestCache(t, 10, 0)
+	put(t, c, "a", 4)
+	put(t, c, "b", 4)
+	checkCached(t, c, map[string]bool{"a": true, "b": true})
+
+	// Going over the cap evicts the least recently stored entries until it fits
+	put(t, c, "c", 8)
+	checkCached(t, c, map[string]bool{"a": false, "b": false, "c": true})
+	if c.totalBytes != 8 {
+		t.Errorf("totalBytes = %d, want 8", c.totalBytes)
+	}
+
+	// Replacing an entry counts only its new size
+	put(t, c, "c", 10)
+	checkCached(t, c, map[string]bool{"c": true})
+	if c.totalBytes != 10 {
+		t.Errorf("totalBytes = %d, want 10", c.totalBytes)
+	}
+}
+
+func TestEvictByCount(t *testing.T) {
+	c := newTestCache(t, 1<<20, 2)
+	put(t, c, "a", 1)
+	put(t, c, "b", 1)
+	put(t, c, "c", 1)
+	checkCached(t, c, map[string]bool{"a": false, "b": true, "c": true})
+
+	// Without an entry cap only the size counts
+	c = newTestCache(t, 1<<20, 0)
+	for _, key := range []string{"a", "b", "c"} {
+		put(t, c, key, 1)
+	}
+	checkCached(t, c, map[string]bool{"a": true, "b": true, "c": true})
+}
+
+func TestGetUpdatesRecency(t *testing.T) {
+	c := newTestCache(t, 10, 2)
+	put(t, c, "a", 4)
+	put(t, c, "b", 4)
+	if data, ok := c.Get("a"); !ok || len(data) != 4 {
+		t.Fatalf("Get(a) = %d bytes, %v", len(data), ok)
+	}
+
+	// b is now the least recently served, by size and by count
+	put(t, c, "c", 4)
+	checkCached(t, c, map[string]bool{"a": true, "b": false, "c": true})
+
+	if info, err := os.Stat(filepath.Join(c.dir, "a")); err != nil || time.Since(info.ModTime()) > time.Minute {
+		t.Errorf("Get did not persist the access time of a: %v", err)
+	}
+}
+
+func TestGetMissing(t *testing.T) {
+	c := newTestCache(t, 10, 0)
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get found a key that was never stored")
+	}
+
+	// A file deleted behind the cache's back is dropped from the index
+	put(t, c, "a", 4)
+	os.Remove(filepath.Join(c.dir, "a"))
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get found a deleted file")
+	}
+	if c.totalBytes != 0 || len(c.entries) != 0 {
+		t.Errorf("index still holds %d entries, %d bytes", len(c.entries), c.totalBytes)
+	}
+}
+
+func TestNewEvictsPreviousRun(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().Add(-time.Hour)
+	for i, key := range []string{"oldest", "older", "newest"} {
+		path := filepath.Join(dir, key)
+		if err := os.WriteFile(path, make([]byte, 4), 0644); err != nil {
+			t.Fatal(err)
+		}
+		modified := old.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Files left from a previous run are evicted by their modification time
+	c, err := New(dir, 8, 0)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	checkCached(t, c, map[string]bool{"oldest": false, "older": true, "newest": true})
+}
+
+func TestDisabled(t *testing.T) {
+	c, err := New(t.TempDir(), 0, 10)
+	if c != nil || err != nil {
+		t.Fatalf("New with no size = %v, %v; want a nil cache", c, err)
+	}
+	if err := c.Put("a", []byte("data")); err != nil {
+		t.Errorf("Put on a disabled cache: %v", err)
+	}
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get on a disabled cache found an entry")
+	}
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fableflow/backend/models"
+)
+
+func TestBackfillPublishedDates(t *testing.T) {
+	dm := newTestManager(t)
+	epub := copyFixture(t, t.TempDir(), "frankenstein.epub")
+	for _, path := range []string{epub, "/library/legacy.epub", "/library/undated.epub"} {
+		if err := dm.AddBook(models.BookRequest{Title: "Title", Author: "Author", FilePath: path, Format: "epub"}); err != nil {
+			t.Fatalf("AddBook: %v", err)
+		}
+	}
+
+	// A library from before publication dates were read, and a date stored without its year
+	if _, err := dm.db.Exec(`UPDATE books SET published_date = CASE id WHEN 2 THEN '1831-10-31' END, year = NULL`); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := dm.BackfillPublishedDates(context.Background())
+	if err != nil {
+		t.Fatalf("BackfillPublishedDates: %v", err)
+	}
+	if found != 2 {
+		t.Errorf("found %d years, want 2", found)
+	}
+	years, err := dm.CountByPublicationYear()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The sample EPUB is dated 1993
+	want := []models.YearCount{{Year: 1831, Count: 1}, {Year: 1993, Count: 1}}
+	if len(years) != len(want) || years[0] != want[0] || years[1] != want[1] {
+		t.Errorf("years = %+v, want %+v", years, want)
+	}
+
+	// Books without a date are not read again
+	if found, err := dm.BackfillPublishedDates(context.Background()); err != nil || found != 0 {
+		t.Errorf("second backfill found %d, %v; want 0", found, err)
+	}
+	undated, _ := dm.GetBookByID(3)
+	if undated.PublishedDate != "" || undated.Year != 0 {
+		t.Errorf("undated book got %q, %d", undated.PublishedDate, undated.Year)
+	}
+}
+
+// writeEPUB writes a minimal EPUB holding the named files, with empty contents
+func writeEPUB(t *testing.T, path string, names ...string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zw := zip.NewWriter(file)
+	for _, name := range append([]string{"mimetype"}, names...) {
+		if _, err := zw.Create(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBackfillDRM(t *testing.T) {
+	dm := newTestManager(t)
+	dir := t.TempDir()
+	protected := filepath.Join(dir, "protected.epub")
+	writeEPUB(t, protected, "META-INF/rights.xml")
+	books := []models.BookRequest{
+		{Title: "Protected", Author: "Author", FilePath: protected, Format: "epub"},
+		{Title: "Open", Author: "Author", FilePath: copyFixture(t, dir, "open.epub"), Format: "epub"},
+		{Title: "Missing", Author: "Author", FilePath: filepath.Join(dir, "missing.epub"), Format: "epub"},
+	}
+	for _, book := range books {
+		if err := dm.AddBook(book); err != nil {
+			t.Fatalf("AddBook: %v", err)
+		}
+	}
+
+	// Books stored before DRM detection, which the migration marks as unchecked
+	if _, err := dm.db.Exec(`UPDATE books SET drm = 0; PRAGMA user_version = 0`); err != nil {
+		t.Fatal(err)
+	}
+	if err := dm.initDatabase(); err != nil {
+		t.Fatalf("initDatabase: %v", err)
+	}
+
+	found, err := dm.BackfillDRM(context.Background())
+	if err != nil || found != 1 {
+		t.Fatalf("BackfillDRM = %d, %v; want 1", found, err)
+	}
+	for id, want := range map[int]bool{1: true, 2: false, 3: false} {
+		if book, _ := dm.GetBookByID(id); book.DRM != want {
+			t.Errorf("book %d: DRM = %v, want %v", id, book.DRM, want)
+		}
+	}
+
+	// Checked books are not checked again, even once the migration ran before
+	if err := dm.initDatabase(); err != nil {
+		t.Fatalf("initDatabase: %v", err)
+	}
+	var unchecked int
+	if err := dm.db.QueryRow(`SELECT COUNT(*) FROM books WHERE drm IS NULL`).Scan(&unchecked); err != nil || unchecked != 0 {
+		t.Errorf("%d books left unchecked, %v", unchecked, err)
+	}
+}
@@ -1,7 +1,9 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -9,142 +11,1143 @@ import (
 	"strings"
 	"time"
 
+	"fableflow/backend/conversion"
+	"fableflow/backend/epub"
+	"fableflow/backend/filemeta"
+	"fableflow/backend/fswalk"
 	"fableflow/backend/metadata"
 	"fableflow/backend/models"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
+// ErrDuplicatePath is returned by AddBook when a book with the same file path already exists
+var ErrDuplicatePath = errors.New("a book with this file path already exists")
+
+// ErrLibraryUnavailable is returned by RescanDirectory when the library directory looks
+// unmounted, so that books are not removed just because their volume is missing
+var ErrLibraryUnavailable = errors.New("library directory is missing or empty")
+
+// ErrTooManyRemovals is returned by RescanDirectory when more books are missing than
+// the configured share of the library, which usually means a partial mount or a wrong path
+var ErrTooManyRemovals = errors.New("rescan would remove too many books")
+
+// LibraryAvailable reports whether dir exists and contains at least one entry. An empty
+// mount point looks the same as a volume that has not been mounted yet.
+func LibraryAvailable(dir string) bool {
+	f, err := os.Open(dir)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(1)
+	return err == nil && len(names) > 0
+}
+
+// bookColumns is the column list selected for every models.Book query, in scanBook order
+const bookColumns = `id, title, author, file_path, file_size, format, isbn, publisher, COALESCE(published_date, ''), COALESCE(year, 0), COALESCE(drm, 0), COALESCE(description, ''), COALESCE(sort_title, title), COALESCE(sort_author, author), added_at, updated_at, COALESCE(uid, ''), epub_modified, COALESCE(series, ''), COALESCE(series_index, 0), ` + authorsColumn
+
+// authorsColumn selects everyone credited on a book in credit order, joined by authorsSeparator
+const authorsColumn = `(SELECT group_concat(name, char(31)) FROM (SELECT a.name FROM book_authors ba JOIN authors a ON a.id = ba.author_id WHERE ba.book_id = books.id ORDER BY ba.position))`
+
+// authorsSeparator is the ASCII unit separator, which can't occur in a cleaned author name
+const authorsSeparator = "\x1f"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanBook reads a single book selected with bookColumns
+func scanBook(row rowScanner) (models.Book, error) {
+	var book models.Book
+	var authors sql.NullString
+	err := row.Scan(&book.ID, &book.Title, &book.Author, &book.FilePath, &book.FileSize, &book.Format, &book.ISBN, &book.Publisher, &book.PublishedDate, &book.Year, &book.DRM, &book.Description, &book.SortTitle, &book.SortAuthor, &book.AddedAt, &book.UpdatedAt, &book.UID, &book.EPUBModified, &book.Series, &book.SeriesIndex, &authors)
+	if authors.String != "" {
+		book.Authors = strings.Split(authors.String, authorsSeparator)
+	}
+	return book, err
+}
+
 // Manager handles all database operations
 type Manager struct {
-	db        *sql.DB
-	extractor *metadata.Extractor
+	db                  *sql.DB
+	extractor           *metadata.Extractor
+	unknownAuthorPolicy string
+	updateExisting      bool
+	leadingArticles     []string
+	walk                fswalk.Options
+	scanPDFs            bool
+	scanKindle          bool
+	useFileMtime        bool
+	maxRemovalPercent   int
+	fullTextSearch      bool // The books_fts index is available and kept in sync
 }
 
-// NewManager creates a new database manager
+// MemoryPath is a database path that keeps the library in memory, e.g. for tests
+const MemoryPath = ":memory:"
+
+// NewManager creates a new database manager. The parent directory of dbPath is
+// created if needed; dbPath may also be MemoryPath for a throwaway database.
 func NewManager(dbPath string) (*Manager, error) {
+	if dbPath != MemoryPath {
+		if err := prepareDatabasePath(dbPath); err != nil {
+			return nil, err
+		}
+	}
+
 	db, err := sql.Open("sqlite3", dbPath)
 	if err != nil {
 		return nil, err
 	}
+	if dbPath == MemoryPath {
+		// Every connection to :memory: gets its own empty database, so keep to one
+		db.SetMaxOpenConns(1)
+	}
+
+	dm := &Manager{
+		db:                  db,
+		extractor:           metadata.NewExtractor(),
+		unknownAuthorPolicy: metadata.UnknownAuthorKeep,
+		leadingArticles:     metadata.DefaultLeadingArticles,
+		maxRemovalPercent:   50,
+	}
+	err = dm.initDatabase()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return dm, nil
+}
+
+// prepareDatabasePath creates the database file's directory and checks that the
+// file can be written, so a bad path fails at startup with a clear error rather than
+// on the first query
+func prepareDatabasePath(dbPath string) error {
+	dir := filepath.Dir(dbPath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create database directory %s: %v", dir, err)
+	}
+
+	if _, err := os.Stat(dbPath); err == nil {
+		f, err := os.OpenFile(dbPath, os.O_RDWR, 0)
+		if err != nil {
+			return fmt.Errorf("database file %s is not writable: %v", dbPath, err)
+		}
+		return f.Close()
+	}
+
+	// SQLite also needs to create journal files next to the database
+	f, err := os.CreateTemp(dir, ".fableflow-write-test-*")
+	if err != nil {
+		return fmt.Errorf("database directory %s is not writable: %v", dir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// SetUnknownAuthorPolicy sets how scans treat books without a usable author
+func (dm *Manager) SetUnknownAuthorPolicy(policy string) {
+	dm.unknownAuthorPolicy = policy
+}
+
+// SetCleanMetadata sets whether metadata read from book files is normalized with
+// metadata.CleanText (library.clean_metadata); when off it is only trimmed
+func (dm *Manager) SetCleanMetadata(clean bool) {
+	dm.extractor.SetCleanText(clean)
+}
+
+// SetSidecarMetadata sets whether scans prefer metadata stored in a .opf or .json file
+// next to the book (library.sidecar_metadata)
+func (dm *Manager) SetSidecarMetadata(enabled bool) {
+	dm.extractor.SetSidecarMetadata(enabled)
+}
+
+// SetFilenamePattern sets the file name convention (library.filename_pattern) used for
+// books without usable metadata. Names whose other side is an author already in the
+// library are taken to be swapped.
+func (dm *Manager) SetFilenamePattern(pattern string) {
+	dm.extractor.SetFilenameParser(filemeta.Parser{Pattern: pattern, KnownAuthor: dm.IsKnownAuthor})
+}
+
+// Extractor returns the metadata extractor scans use, configured by the Manager's setters
+func (dm *Manager) Extractor() *metadata.Extractor {
+	return dm.extractor
+}
+
+// SetUpdateExisting makes AddBook update the existing row instead of failing
+// with ErrDuplicatePath when the file path is already in the library
+func (dm *Manager) SetUpdateExisting(update bool) {
+	dm.updateExisting = update
+}
+
+// SetFollowSymlinks makes scans descend into symlinked directories
+func (dm *Manager) SetFollowSymlinks(follow bool) {
+	dm.walk.FollowSymlinks = follow
+}
+
+// SetIgnored sets which entries scans leave out: with hidden set, names starting with
+// ".", plus any name in names, compared case-insensitively
+func (dm *Manager) SetIgnored(hidden bool, names []string) {
+	dm.walk.SkipHidden = hidden
+	dm.walk.IgnoreNames = append([]string(nil), names...)
+}
+
+// SetScanPDFs makes scans add PDF files to the library next to EPUBs
+func (dm *Manager) SetScanPDFs(scan bool) {
+	dm.scanPDFs = scan
+}
+
+// SetScanKindle makes scans add MOBI, AZW and AZW3 files to the library next to EPUBs
+func (dm *Manager) SetScanKindle(scan bool) {
+	dm.scanKindle = scan
+}
+
+// scanFormats returns the file extensions scans add to the library
+func (dm *Manager) scanFormats() map[string]bool {
+	// Only EPUBs (and PDFs or Kindle books when enabled), to avoid importing converted files
+	formats := map[string]bool{".epub": true}
+	if dm.scanPDFs {
+		formats[".pdf"] = true
+	}
+	if dm.scanKindle {
+		formats[".mobi"] = true
+		formats[".azw"] = true
+		formats[".azw3"] = true
+	}
+	return formats
+}
+
+// SetUseFileMtime makes scans record a new book's file modification time as its
+// added_at instead of the time of the scan
+func (dm *Manager) SetUseFileMtime(use bool) {
+	dm.useFileMtime = use
+}
+
+// SetMaxRemovalPercent sets the largest percentage of the library a single rescan
+// may remove; 100 allows removing everything that is missing
+func (dm *Manager) SetMaxRemovalPercent(percent int) {
+	dm.maxRemovalPercent = percent
+}
+
+// scanAddedAt returns the added_at time for a book found by a scan
+func (dm *Manager) scanAddedAt(info os.FileInfo) time.Time {
+	if dm.useFileMtime {
+		return info.ModTime()
+	}
+	return time.Now()
+}
+
+// SetLeadingArticles sets the articles ignored when sorting titles and authors,
+// recomputing the stored sort keys of existing books
+func (dm *Manager) SetLeadingArticles(articles []string) error {
+	dm.leadingArticles = articles
+	return dm.refreshSortKeys(false)
+}
+
+// sortKeys returns the sort title and sort author for a book
+func (dm *Manager) sortKeys(title, author string) (string, string) {
+	return metadata.SortKey(title, dm.leadingArticles), metadata.AuthorSortKey(author, dm.leadingArticles)
+}
+
+// refreshSortKeys recomputes stored sort keys that differ from the current article list.
+// With onlyMissing set, only books without sort keys are filled in.
+func (dm *Manager) refreshSortKeys(onlyMissing bool) error {
+	query := "SELECT id, title, COALESCE(author, ''), COALESCE(sort_title, ''), COALESCE(sort_author, '') FROM books"
+	if onlyMissing {
+		query += " WHERE sort_title IS NULL OR sort_author IS NULL"
+	}
+	rows, err := dm.db.Query(query)
+	if err != nil {
+		return fmt.Errorf("failed to read sort keys: %v", err)
+	}
+
+	type sortUpdate struct {
+		id                    int
+		sortTitle, sortAuthor string
+	}
+	var updates []sortUpdate
+	for rows.Next() {
+		var id int
+		var title, author, sortTitle, sortAuthor string
+		if err := rows.Scan(&id, &title, &author, &sortTitle, &sortAuthor); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to read sort keys: %v", err)
+		}
+		newTitle, newAuthor := dm.sortKeys(title, author)
+		if onlyMissing || newTitle != sortTitle || newAuthor != sortAuthor {
+			updates = append(updates, sortUpdate{id, newTitle, newAuthor})
+		}
+	}
+	rows.Close()
+
+	if len(updates) == 0 {
+		return nil
+	}
+
+	tx, err := dm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to update sort keys: %v", err)
+	}
+	for _, update := range updates {
+		if _, err := tx.Exec("UPDATE books SET sort_title = ?, sort_author = ? WHERE id = ?", update.sortTitle, update.sortAuthor, update.id); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to update sort keys: %v", err)
+		}
+	}
+	log.Printf("Updated sort keys for %d books", len(updates))
+	return tx.Commit()
+}
+
+// Close closes the database connection
+func (dm *Manager) Close() error {
+	return dm.db.Close()
+}
+
+// initDatabase creates the books table if it doesn't exist
+func (dm *Manager) initDatabase() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS books (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		title TEXT NOT NULL,
+		author TEXT,
+		file_path TEXT UNIQUE NOT NULL,
+		file_size INTEGER,
+		format TEXT,
+		isbn TEXT,
+		publisher TEXT,
+		added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	_, err := dm.db.Exec(query)
+	if err != nil {
+		return err
+	}
+
+	// Add publisher column if it doesn't exist (migration)
+	_, err = dm.db.Exec(`ALTER TABLE books ADD COLUMN publisher TEXT;`)
+	if err != nil {
+		// Column might already exist, ignore the error
+		// In a production app, you'd check if the column exists first
+	}
+
+	// Add updated_at column if it doesn't exist (migration)
+	_, err = dm.db.Exec(`ALTER TABLE books ADD COLUMN updated_at DATETIME DEFAULT CURRENT_TIMESTAMP;`)
+	if err != nil {
+		// Column might already exist, ignore the error
+		// In a production app, you'd check if the column exists first
+	}
+
+	// Add published_date column if it doesn't exist (migration)
+	_, err = dm.db.Exec(`ALTER TABLE books ADD COLUMN published_date TEXT;`)
+	if err != nil {
+		// Column might already exist, ignore the error
+	}
+
+	// Add year column derived from published_date if it doesn't exist (migration)
+	_, err = dm.db.Exec(`ALTER TABLE books ADD COLUMN year INTEGER;`)
+	if err != nil {
+		// Column might already exist, ignore the error
+	}
+
+	// Add DRM flag column if it doesn't exist (migration)
+	_, err = dm.db.Exec(`ALTER TABLE books ADD COLUMN drm INTEGER DEFAULT 0;`)
+	if err != nil {
+		// Column might already exist, ignore the error
+	}
+	// Books stored before DRM detection got a drm of 0 without being checked: mark them
+	// with NULL for BackfillDRM, once (migration). user_version counts these one-time steps.
+	var version int
+	if err := dm.db.QueryRow(`PRAGMA user_version;`).Scan(&version); err != nil {
+		return err
+	}
+	if version < 1 {
+		if _, err := dm.db.Exec(`UPDATE books SET drm = NULL WHERE drm = 0;`); err != nil {
+			return err
+		}
+		if _, err := dm.db.Exec(`PRAGMA user_version = 1;`); err != nil {
+			return err
+		}
+	}
+
+	// Add sort key columns (title/author without leading articles) if they don't exist (migration)
+	_, err = dm.db.Exec(`ALTER TABLE books ADD COLUMN sort_title TEXT;`)
+	if err != nil {
+		// Column might already exist, ignore the error
+	}
+	_, err = dm.db.Exec(`ALTER TABLE books ADD COLUMN sort_author TEXT;`)
+	if err != nil {
+		// Column might already exist, ignore the error
+	}
+	// Pages of the book list are read in title order
+	_, err = dm.db.Exec(`CREATE INDEX IF NOT EXISTS idx_books_sort_title ON books (sort_title, id);`)
+	if err != nil {
+		return err
+	}
+
+	// Add description column if it doesn't exist (migration)
+	_, err = dm.db.Exec(`ALTER TABLE books ADD COLUMN description TEXT;`)
+	if err != nil {
+		// Column might already exist, ignore the error
+	}
+
+	// Add the EPUB package unique identifier column if it doesn't exist (migration).
+	// NULL means not read yet, an empty string that the book has none.
+	_, err = dm.db.Exec(`ALTER TABLE books ADD COLUMN uid TEXT;`)
+	if err != nil {
+		// Column might already exist, ignore the error
+	}
+	_, err = dm.db.Exec(`CREATE INDEX IF NOT EXISTS idx_books_uid ON books (uid);`)
+	if err != nil {
+		return err
+	}
+
+	// Add the EPUB's own modification date (dcterms:modified) column if it doesn't exist (migration)
+	_, err = dm.db.Exec(`ALTER TABLE books ADD COLUMN epub_modified DATETIME;`)
+	if err != nil {
+		// Column might already exist, ignore the error
+	}
+
+	// Add the series columns if they don't exist (migration). A NULL series means not
+	// read yet, an empty string that the book is not part of one.
+	_, err = dm.db.Exec(`ALTER TABLE books ADD COLUMN series TEXT;`)
+	if err != nil {
+		// Column might already exist, ignore the error
+	}
+	_, err = dm.db.Exec(`ALTER TABLE books ADD COLUMN series_index REAL;`)
+	if err != nil {
+		// Column might already exist, ignore the error
+	}
+
+	// Download counters per book and format
+	_, err = dm.db.Exec(`
+	CREATE TABLE IF NOT EXISTS downloads (
+		book_id INTEGER NOT NULL,
+		format TEXT NOT NULL,
+		count INTEGER NOT NULL DEFAULT 0,
+		last_downloaded_at DATETIME,
+		PRIMARY KEY (book_id, format)
+	);`)
+	if err != nil {
+		return err
+	}
+
+	// Converted files kept in tmp_dir when conversion.persistent_cache is on
+	_, err = dm.db.Exec(`
+	CREATE TABLE IF NOT EXISTS conversions (
+		book_id INTEGER NOT NULL,
+		format TEXT NOT NULL,
+		path TEXT NOT NULL,
+		size INTEGER NOT NULL DEFAULT 0,
+		source_mtime INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_used_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (book_id, format)
+	);`)
+	if err != nil {
+		return err
+	}
+
+	// Tags (genres from dc:subject) and the books they are applied to
+	_, err = dm.db.Exec(`
+	CREATE TABLE IF NOT EXISTS tags (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT UNIQUE NOT NULL COLLATE NOCASE
+	);
+	CREATE TABLE IF NOT EXISTS book_tags (
+		book_id INTEGER NOT NULL,
+		tag_id INTEGER NOT NULL,
+		PRIMARY KEY (book_id, tag_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_book_tags_tag ON book_tags (tag_id);`)
+	if err != nil {
+		return err
+	}
+
+	// Authors as individual people, so co-authored books can be found under each of them.
+	// The books.author column keeps the credit as displayed.
+	_, err = dm.db.Exec(`
+	CREATE TABLE IF NOT EXISTS authors (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT UNIQUE NOT NULL COLLATE NOCASE
+	);
+	CREATE TABLE IF NOT EXISTS book_authors (
+		book_id INTEGER NOT NULL,
+		author_id INTEGER NOT NULL,
+		position INTEGER NOT NULL DEFAULT 0,
+		role TEXT NOT NULL DEFAULT 'aut',
+		PRIMARY KEY (book_id, author_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_book_authors_author ON book_authors (author_id);`)
+	if err != nil {
+		return err
+	}
+
+	// MARC relator code of each credit, e.g. "ill" for an illustrator
+	_, err = dm.db.Exec(`ALTER TABLE book_authors ADD COLUMN role TEXT NOT NULL DEFAULT 'aut'`)
+	if err != nil {
+		// Column might already exist, ignore the error
+	}
+
+	// Fill in the authors of books added before the tables existed
+	if err := dm.backfillBookAuthors(); err != nil {
+		return err
+	}
+
+	// User-defined key-value fields per book ("shelf location", "lent to", ...)
+	_, err = dm.db.Exec(`
+	CREATE TABLE IF NOT EXISTS book_metadata (
+		book_id INTEGER NOT NULL,
+		key TEXT NOT NULL COLLATE NOCASE,
+		value TEXT NOT NULL,
+		PRIMARY KEY (book_id, key)
+	);`)
+	if err != nil {
+		return err
+	}
+
+	// Reader layout preferences as a JSON object per book; book ID 0 holds the global ones
+	_, err = dm.db.Exec(`
+	CREATE TABLE IF NOT EXISTS reader_settings (
+		book_id INTEGER PRIMARY KEY,
+		settings TEXT NOT NULL,
+		updated_at DATETIME NOT NULL
+	);`)
+	if err != nil {
+		return err
+	}
+
+	// Last reading position per book: an EPUB CFI or other reader locator and how far
+	// into the book it is
+	_, err = dm.db.Exec(`
+	CREATE TABLE IF NOT EXISTS reading_progress (
+		book_id INTEGER PRIMARY KEY,
+		locator TEXT NOT NULL,
+		percent REAL NOT NULL,
+		updated_at DATETIME NOT NULL
+	);`)
+	if err != nil {
+		return err
+	}
+
+	// Fill in sort keys for books added before the columns existed
+	if err := dm.refreshSortKeys(true); err != nil {
+		return err
+	}
+
+	if err := dm.initFullTextSearch(); err != nil {
+		log.Printf("Full-text search is unavailable, searches use LIKE matching: %v", err)
+	}
+
+	return nil
+}
+
+// initFullTextSearch creates the FTS5 index over book titles, authors, publishers and
+// descriptions, kept in sync with the books table by triggers. SQLite needs FTS5 compiled
+// in (go build -tags sqlite_fts5); without it the triggers are dropped so that books
+// can still be written, and the index is rebuilt once FTS5 is back.
+func (dm *Manager) initFullTextSearch() error {
+	var available bool
+	if err := dm.db.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&available); err != nil {
+		return err
+	}
+	if !available {
+		for _, trigger := range []string{"books_fts_insert", "books_fts_delete", "books_fts_update"} {
+			if _, err := dm.db.Exec(`DROP TRIGGER IF EXISTS ` + trigger); err != nil {
+				return err
+			}
+		}
+		return fmt.Errorf("SQLite was built without FTS5")
+	}
+
+	// Without the triggers, books changed since the index was last maintained
+	var synced int
+	if err := dm.db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger' AND name = 'books_fts_insert'`).Scan(&synced); err != nil {
+		return err
+	}
+
+	_, err := dm.db.Exec(`
+	CREATE VIRTUAL TABLE IF NOT EXISTS books_fts USING fts5(
+		title, author, publisher, description,
+		content = 'books', content_rowid = 'id', tokenize = 'unicode61 remove_diacritics 2'
+	);
+	CREATE TRIGGER IF NOT EXISTS books_fts_insert AFTER INSERT ON books BEGIN
+		INSERT INTO books_fts (rowid, title, author, publisher, description)
+		VALUES (new.id, new.title, new.author, new.publisher, new.description);
+	END;
+	CREATE TRIGGER IF NOT EXISTS books_fts_delete AFTER DELETE ON books BEGIN
+		INSERT INTO books_fts (books_fts, rowid, title, author, publisher, description)
+		VALUES ('delete', old.id, old.title, old.author, old.publisher, old.description);
+	END;
+	CREATE TRIGGER IF NOT EXISTS books_fts_update AFTER UPDATE OF title, author, publisher, description ON books BEGIN
+		INSERT INTO books_fts (books_fts, rowid, title, author, publisher, description)
+		VALUES ('delete', old.id, old.title, old.author, old.publisher, old.description);
+		INSERT INTO books_fts (rowid, title, author, publisher, description)
+		VALUES (new.id, new.title, new.author, new.publisher, new.description);
+	END;`)
+	if err != nil {
+		return err
+	}
+
+	if synced == 0 {
+		if _, err := dm.db.Exec(`INSERT INTO books_fts (books_fts) VALUES ('rebuild')`); err != nil {
+			return err
+		}
+	}
+
+	dm.fullTextSearch = true
+	return nil
+}
+
+// GetAllBooks returns all books from the database
+func (dm *Manager) GetAllBooks() ([]models.Book, error) {
+	query := "SELECT " + bookColumns + " FROM books ORDER BY sort_title"
+	rows, err := dm.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []models.Book
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+
+	return books, nil
+}
+
+// GetBooksPaginated returns up to limit books ordered by title, skipping the first
+// offset, and the total number of books
+func (dm *Manager) GetBooksPaginated(limit, offset int) ([]models.Book, int, error) {
+	var total int
+	if err := dm.db.QueryRow("SELECT COUNT(*) FROM books").Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := "SELECT " + bookColumns + " FROM books ORDER BY sort_title, id LIMIT ? OFFSET ?"
+	rows, err := dm.db.Query(query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var books []models.Book
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		books = append(books, book)
+	}
+
+	return books, total, nil
+}
+
+// SearchBooks searches for books by title or author
+func (dm *Manager) SearchBooks(query string) ([]models.Book, error) {
+	searchTerm := "%" + query + "%"
+	return dm.searchBooks("title LIKE ? OR author LIKE ?", searchTerm, searchTerm)
+}
+
+// SearchBooksFTS searches titles, authors, publishers and descriptions with the FTS5
+// query syntax, best matches first (title matches weigh most, then author, publisher
+// and description). Queries FTS5 cannot parse, and libraries without the index, fall
+// back to SearchBooks.
+func (dm *Manager) SearchBooksFTS(query string) ([]models.Book, error) {
+	if !dm.fullTextSearch {
+		return dm.SearchBooks(query)
+	}
+
+	searchQuery := `SELECT ` + bookColumns + `
+					FROM books
+					JOIN (SELECT rowid AS match_id, bm25(books_fts, 10.0, 5.0, 2.0, 1.0) AS rank
+						  FROM books_fts WHERE books_fts MATCH ?) AS matches ON matches.match_id = books.id
+					ORDER BY matches.rank, sort_title`
+
+	rows, err := dm.db.Query(searchQuery, query)
+	if err == nil {
+		defer rows.Close()
+		var books []models.Book
+		for rows.Next() {
+			book, err := scanBook(rows)
+			if err != nil {
+				return nil, err
+			}
+			books = append(books, book)
+		}
+		err = rows.Err()
+		if err == nil {
+			return books, nil
+		}
+	}
+	// Mostly FTS5 syntax errors such as unbalanced quotes; a broken database fails the
+	// LIKE search as well
+	log.Printf("Full-text query %q failed, falling back to LIKE search: %v", query, err)
+	return dm.SearchBooks(query)
+}
+
+// SearchBooksByTitle searches for books whose title matches the query
+func (dm *Manager) SearchBooksByTitle(query string) ([]models.Book, error) {
+	return dm.searchBooks("title LIKE ?", "%"+query+"%")
+}
+
+// SearchBooksByAuthor searches for books whose author matches the query
+func (dm *Manager) SearchBooksByAuthor(query string) ([]models.Book, error) {
+	return dm.searchBooks("author LIKE ?", "%"+query+"%")
+}
+
+// seriesOrder orders books of a series together by their position in it, series by name
+// first and books in no series after them by title
+const seriesOrder = `COALESCE(series, '') = '', series COLLATE NOCASE, series_index, sort_title`
+
+// searchBooks runs a book search with the given WHERE clause, ordered by title
+func (dm *Manager) searchBooks(where string, args ...interface{}) ([]models.Book, error) {
+	return dm.searchBooksOrdered(where, "sort_title", args...)
+}
+
+// searchBooksOrdered runs a book search with the given WHERE and ORDER BY clauses
+func (dm *Manager) searchBooksOrdered(where, order string, args ...interface{}) ([]models.Book, error) {
+	searchQuery := `SELECT ` + bookColumns + ` 
+					FROM books 
+					WHERE ` + where + ` 
+					ORDER BY ` + order
+
+	rows, err := dm.db.Query(searchQuery, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []models.Book
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+
+	return books, nil
+}
+
+// AddBook adds a new book to the database
+func (dm *Manager) AddBook(book models.BookRequest) error {
+	return dm.AddBookAt(book, time.Now())
+}
+
+// AddBookAt adds a new book to the database like AddBook, recording addedAt as the time
+// it was added
+func (dm *Manager) AddBookAt(book models.BookRequest, addedAt time.Time) error {
+	query := `INSERT INTO books (title, author, file_path, file_size, format, isbn, publisher, published_date, year, drm, description, sort_title, sort_author, uid, epub_modified, series, series_index, added_at) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	if dm.updateExisting {
+		query += ` ON CONFLICT(file_path) DO UPDATE SET 
+			  title = excluded.title, author = excluded.author, file_size = excluded.file_size, format = excluded.format, 
+			  isbn = excluded.isbn, publisher = excluded.publisher, published_date = excluded.published_date, 
+			  year = excluded.year, drm = excluded.drm, description = excluded.description, sort_title = excluded.sort_title, 
+			  sort_author = excluded.sort_author, uid = excluded.uid, epub_modified = excluded.epub_modified, 
+			  series = excluded.series, series_index = excluded.series_index, updated_at = CURRENT_TIMESTAMP`
+	}
+	sortTitle, sortAuthor := dm.sortKeys(book.Title, book.Author)
+	_, err := dm.db.Exec(query, book.Title, book.Author, book.FilePath, book.FileSize, book.Format, book.ISBN, book.Publisher, book.PublishedDate, metadata.ParseYear(book.PublishedDate), book.DRM, book.Description, sortTitle, sortAuthor, book.UID, book.EPUBModified, book.Series, book.SeriesIndex, addedAt)
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return ErrDuplicatePath
+	}
+	if err != nil {
+		return err
+	}
+
+	added, err := dm.GetBookByFilePath(book.FilePath)
+	if err != nil {
+		return err
+	}
+	if err := dm.SetBookAuthors(added.ID, bookAuthors(book), book.Roles); err != nil {
+		return err
+	}
+	if len(book.Tags) == 0 {
+		return nil
+	}
+	return dm.AddBookTags(added.ID, book.Tags)
+}
+
+// bookAuthors returns the people credited on a book, skipping the unknown author placeholder
+func bookAuthors(book models.BookRequest) []string {
+	if len(book.Authors) > 0 {
+		return book.Authors
+	}
+	if metadata.IsUnknownAuthor(book.Author) {
+		return nil
+	}
+	return metadata.SplitAuthors(book.Author)
+}
+
+// SetBookAuthors replaces the people credited on a book, in order, creating authors
+// that don't exist yet. roles maps names to MARC relator codes; names not in it are
+// credited as authors ("aut").
+func (dm *Manager) SetBookAuthors(bookID int, authors []string, roles map[string]string) error {
+	tx, err := dm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to set authors: %v", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM book_authors WHERE book_id = ?`, bookID); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to set authors: %v", err)
+	}
+	for position, author := range authors {
+		author = strings.TrimSpace(author)
+		if author == "" {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO authors (name) VALUES (?)`, author); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to add author %q: %v", author, err)
+		}
+		role := roles[author]
+		if role == "" {
+			role = "aut"
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO book_authors (book_id, author_id, position, role) SELECT ?, id, ?, ? FROM authors WHERE name = ?`, bookID, position, role, author); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to add author %q: %v", author, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetBookRoles returns the MARC relator codes of the people credited on a book other than
+// as author ("aut"), e.g. {"Pauline Baynes": "ill"}
+func (dm *Manager) GetBookRoles(bookID int) (map[string]string, error) {
+	rows, err := dm.db.Query(`SELECT a.name, ba.role FROM authors a JOIN book_authors ba ON ba.author_id = a.id WHERE ba.book_id = ? AND ba.role != 'aut'`, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles map[string]string
+	for rows.Next() {
+		var name, role string
+		if err := rows.Scan(&name, &role); err != nil {
+			return nil, err
+		}
+		if roles == nil {
+			roles = make(map[string]string)
+		}
+		roles[name] = role
+	}
+
+	return roles, nil
+}
+
+// IsKnownAuthor reports whether anyone by this name is credited on a book in the library
+func (dm *Manager) IsKnownAuthor(name string) bool {
+	var known bool
+	err := dm.db.QueryRow(`SELECT EXISTS(SELECT 1 FROM authors WHERE name = ?) OR EXISTS(SELECT 1 FROM books WHERE author = ? COLLATE NOCASE)`, name, name).Scan(&known)
+	return err == nil && known
+}
+
+// backfillBookAuthors splits the author column of books without book_authors rows
+func (dm *Manager) backfillBookAuthors() error {
+	rows, err := dm.db.Query(`SELECT id, COALESCE(author, '') FROM books WHERE id NOT IN (SELECT book_id FROM book_authors)`)
+	if err != nil {
+		return err
+	}
+	pending := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var author string
+		if err := rows.Scan(&id, &author); err != nil {
+			rows.Close()
+			return err
+		}
+		pending[id] = author
+	}
+	rows.Close()
+
+	for id, author := range pending {
+		if err := dm.SetBookAuthors(id, bookAuthors(models.BookRequest{Author: author}), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveBook removes a book from the database by ID
+func (dm *Manager) RemoveBook(bookID int) error {
+	query := `DELETE FROM books WHERE id = ?`
+	_, err := dm.db.Exec(query, bookID)
+	if err != nil {
+		return err
+	}
+	_, err = dm.db.Exec(`DELETE FROM book_tags WHERE book_id = ?`, bookID)
+	if err != nil {
+		return err
+	}
+	_, err = dm.db.Exec(`DELETE FROM book_metadata WHERE book_id = ?`, bookID)
+	if err != nil {
+		return err
+	}
+	_, err = dm.db.Exec(`DELETE FROM reader_settings WHERE book_id = ?`, bookID)
+	if err != nil {
+		return err
+	}
+	_, err = dm.db.Exec(`DELETE FROM reading_progress WHERE book_id = ?`, bookID)
+	if err != nil {
+		return err
+	}
+	_, err = dm.db.Exec(`DELETE FROM book_authors WHERE book_id = ?`, bookID)
+	return err
+}
+
+// GetReaderSettings returns the reader settings stored for a book (0 for the global
+// ones) as a JSON object, and when they were saved. The object is empty when nothing
+// is stored.
+func (dm *Manager) GetReaderSettings(bookID int) (string, *time.Time, error) {
+	var settings string
+	var updatedAt time.Time
+	err := dm.db.QueryRow(`SELECT settings, updated_at FROM reader_settings WHERE book_id = ?`, bookID).Scan(&settings, &updatedAt)
+	if err == sql.ErrNoRows {
+		return "{}", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return settings, &updatedAt, nil
+}
+
+// SetReaderSettings stores the reader settings of a book (0 for the global ones),
+// replacing what was stored before
+func (dm *Manager) SetReaderSettings(bookID int, settings string) error {
+	_, err := dm.db.Exec(`INSERT OR REPLACE INTO reader_settings (book_id, settings, updated_at) VALUES (?, ?, ?)`, bookID, settings, time.Now())
+	return err
+}
+
+// DeleteReaderSettings removes the reader settings of a book (0 for the global ones)
+func (dm *Manager) DeleteReaderSettings(bookID int) error {
+	_, err := dm.db.Exec(`DELETE FROM reader_settings WHERE book_id = ?`, bookID)
+	return err
+}
+
+// GetProgress returns the last reading position saved for a book, or nil when none was
+func (dm *Manager) GetProgress(bookID int) (*models.ReadingProgress, error) {
+	progress := models.ReadingProgress{BookID: bookID}
+	err := dm.db.QueryRow(`SELECT locator, percent, updated_at FROM reading_progress WHERE book_id = ?`, bookID).
+		Scan(&progress.Locator, &progress.Percent, &progress.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
+
+// SaveProgress stores the reading position of a book, replacing the previous one
+func (dm *Manager) SaveProgress(bookID int, locator string, percent float64) error {
+	_, err := dm.db.Exec(`INSERT OR REPLACE INTO reading_progress (book_id, locator, percent, updated_at) VALUES (?, ?, ?, ?)`, bookID, locator, percent, time.Now())
+	return err
+}
+
+// GetCustomFields returns a book's custom metadata fields
+func (dm *Manager) GetCustomFields(bookID int) (map[string]string, error) {
+	rows, err := dm.db.Query(`SELECT key, value FROM book_metadata WHERE book_id = ?`, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
 
-	dm := &Manager{
-		db:        db,
-		extractor: metadata.NewExtractor(),
-	}
-	err = dm.initDatabase()
-	if err != nil {
-		return nil, err
+	fields := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		fields[key] = value
 	}
 
-	return dm, nil
+	return fields, rows.Err()
 }
 
-// Close closes the database connection
-func (dm *Manager) Close() error {
-	return dm.db.Close()
+// SetCustomFields adds or replaces custom metadata fields on a book. Keys match
+// case-insensitively; a replaced field takes the key's new spelling.
+func (dm *Manager) SetCustomFields(bookID int, fields map[string]string) error {
+	tx, err := dm.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to set custom fields: %v", err)
+	}
+	for key, value := range fields {
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO book_metadata (book_id, key, value) VALUES (?, ?, ?)`, bookID, key, value); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to set custom field %q: %v", key, err)
+		}
+	}
+	return tx.Commit()
 }
 
-// initDatabase creates the books table if it doesn't exist
-func (dm *Manager) initDatabase() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS books (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		title TEXT NOT NULL,
-		author TEXT,
-		file_path TEXT UNIQUE NOT NULL,
-		file_size INTEGER,
-		format TEXT,
-		isbn TEXT,
-		publisher TEXT,
-		added_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	_, err := dm.db.Exec(query)
+// DeleteCustomField removes a custom metadata field from a book, reporting whether it existed
+func (dm *Manager) DeleteCustomField(bookID int, key string) (bool, error) {
+	result, err := dm.db.Exec(`DELETE FROM book_metadata WHERE book_id = ? AND key = ?`, bookID, key)
 	if err != nil {
-		return err
+		return false, err
 	}
+	deleted, err := result.RowsAffected()
+	return deleted > 0, err
+}
 
-	// Add publisher column if it doesn't exist (migration)
-	_, err = dm.db.Exec(`ALTER TABLE books ADD COLUMN publisher TEXT;`)
+// AddBookTags applies tags to a book, creating tags that don't exist yet.
+// Tags the book already has (compared case-insensitively) are left alone.
+func (dm *Manager) AddBookTags(bookID int, tags []string) error {
+	tx, err := dm.db.Begin()
 	if err != nil {
-		// Column might already exist, ignore the error
-		// In a production app, you'd check if the column exists first
+		return fmt.Errorf("failed to add tags: %v", err)
 	}
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO tags (name) VALUES (?)`, tag); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to add tag %q: %v", tag, err)
+		}
+		if _, err := tx.Exec(`INSERT OR IGNORE INTO book_tags (book_id, tag_id) SELECT ?, id FROM tags WHERE name = ?`, bookID, tag); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to add tag %q: %v", tag, err)
+		}
+	}
+	return tx.Commit()
+}
 
-	// Add updated_at column if it doesn't exist (migration)
-	_, err = dm.db.Exec(`ALTER TABLE books ADD COLUMN updated_at DATETIME DEFAULT CURRENT_TIMESTAMP;`)
+// GetBookTags returns the tags applied to a book, alphabetically
+func (dm *Manager) GetBookTags(bookID int) ([]string, error) {
+	rows, err := dm.db.Query(`SELECT t.name FROM tags t JOIN book_tags bt ON bt.tag_id = t.id WHERE bt.book_id = ? ORDER BY t.name`, bookID)
 	if err != nil {
-		// Column might already exist, ignore the error
-		// In a production app, you'd check if the column exists first
+		return nil, err
 	}
+	defer rows.Close()
 
-	return nil
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, nil
 }
 
-// GetAllBooks returns all books from the database
-func (dm *Manager) GetAllBooks() ([]models.Book, error) {
-	query := "SELECT id, title, author, file_path, file_size, format, isbn, publisher, added_at, updated_at FROM books ORDER BY title"
-	rows, err := dm.db.Query(query)
+// GetAllTags returns the names of all tags applied to at least one book, alphabetically
+func (dm *Manager) GetAllTags() ([]string, error) {
+	rows, err := dm.db.Query(`SELECT name FROM tags WHERE id IN (SELECT tag_id FROM book_tags) ORDER BY name`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var books []models.Book
+	var tags []string
 	for rows.Next() {
-		var book models.Book
-		err := rows.Scan(&book.ID, &book.Title, &book.Author, &book.FilePath, &book.FileSize, &book.Format, &book.ISBN, &book.Publisher, &book.AddedAt, &book.UpdatedAt)
-		if err != nil {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
 			return nil, err
 		}
-		books = append(books, book)
+		tags = append(tags, tag)
 	}
 
-	return books, nil
+	return tags, nil
 }
 
-// SearchBooks searches for books by title or author
-func (dm *Manager) SearchBooks(query string) ([]models.Book, error) {
-	searchQuery := `SELECT id, title, author, file_path, file_size, format, isbn, publisher, added_at, updated_at 
-					FROM books 
-					WHERE title LIKE ? OR author LIKE ? 
-					ORDER BY title`
-	searchTerm := "%" + query + "%"
-
-	rows, err := dm.db.Query(searchQuery, searchTerm, searchTerm)
+// GetTagsWithCounts returns the tags applied to at least one book with their book
+// counts, most used first
+func (dm *Manager) GetTagsWithCounts() ([]models.TagCount, error) {
+	rows, err := dm.db.Query(`SELECT t.name, COUNT(*) FROM tags t JOIN book_tags bt ON bt.tag_id = t.id GROUP BY t.id ORDER BY COUNT(*) DESC, t.name`)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var books []models.Book
+	var tags []models.TagCount
 	for rows.Next() {
-		var book models.Book
-		err := rows.Scan(&book.ID, &book.Title, &book.Author, &book.FilePath, &book.FileSize, &book.Format, &book.ISBN, &book.Publisher, &book.AddedAt, &book.UpdatedAt)
-		if err != nil {
+		var tag models.TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
 			return nil, err
 		}
-		books = append(books, book)
+		tags = append(tags, tag)
 	}
 
-	return books, nil
+	return tags, nil
 }
 
-// AddBook adds a new book to the database
-func (dm *Manager) AddBook(book models.BookRequest) error {
-	query := `INSERT INTO books (title, author, file_path, file_size, format, isbn, publisher, added_at) 
-			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := dm.db.Exec(query, book.Title, book.Author, book.FilePath, book.FileSize, book.Format, book.ISBN, book.Publisher, time.Now())
-	return err
+// GetBooksByFormat returns all books in the given format, e.g. "pdf" (case-insensitive)
+func (dm *Manager) GetBooksByFormat(format string) ([]models.Book, error) {
+	return dm.searchBooks("LOWER(format) = LOWER(?)", strings.TrimPrefix(format, "."))
 }
 
-// RemoveBook removes a book from the database by ID
-func (dm *Manager) RemoveBook(bookID int) error {
-	query := `DELETE FROM books WHERE id = ?`
-	_, err := dm.db.Exec(query, bookID)
-	return err
+// GetBooksByTag returns all books with the given tag (case-insensitive)
+func (dm *Manager) GetBooksByTag(tag string) ([]models.Book, error) {
+	return dm.searchBooks("id IN (SELECT bt.book_id FROM book_tags bt JOIN tags t ON t.id = bt.tag_id WHERE t.name = ?)", tag)
+}
+
+// ApplyTag applies a tag to many books in one transaction, creating the tag if needed,
+// and returns how many of them did not have it yet
+func (dm *Manager) ApplyTag(tag string, bookIDs []int) (int, error) {
+	tx, err := dm.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to apply tag: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT OR IGNORE INTO tags (name) VALUES (?)`, tag); err != nil {
+		return 0, fmt.Errorf("failed to add tag %q: %v", tag, err)
+	}
+	var tagID int
+	if err := tx.QueryRow(`SELECT id FROM tags WHERE name = ?`, tag).Scan(&tagID); err != nil {
+		return 0, fmt.Errorf("failed to add tag %q: %v", tag, err)
+	}
+
+	affected := 0
+	for _, bookID := range bookIDs {
+		result, err := tx.Exec(`INSERT OR IGNORE INTO book_tags (book_id, tag_id) VALUES (?, ?)`, bookID, tagID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to tag book %d: %v", bookID, err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			affected += int(n)
+		}
+	}
+
+	return affected, tx.Commit()
+}
+
+// RemoveTag removes a tag from many books in one transaction and returns how many of
+// them had it
+func (dm *Manager) RemoveTag(tag string, bookIDs []int) (int, error) {
+	tx, err := dm.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to remove tag: %v", err)
+	}
+	defer tx.Rollback()
+
+	affected := 0
+	for _, bookID := range bookIDs {
+		result, err := tx.Exec(`DELETE FROM book_tags WHERE book_id = ? AND tag_id IN (SELECT id FROM tags WHERE name = ?)`, bookID, tag)
+		if err != nil {
+			return 0, fmt.Errorf("failed to untag book %d: %v", bookID, err)
+		}
+		if n, err := result.RowsAffected(); err == nil {
+			affected += int(n)
+		}
+	}
+
+	return affected, tx.Commit()
 }
 
 // BookExists checks if a book with the given file path already exists
@@ -154,14 +1157,15 @@ func (dm *Manager) BookExists(filePath string) (bool, error) {
 	return count > 0, err
 }
 
-// ScanDirectory recursively scans a directory for ebook files
-func (dm *Manager) ScanDirectory(rootPath string) error {
-	supportedFormats := map[string]bool{
-		".epub": true,
-		// Only scan for EPUB files to avoid importing converted files
-	}
+// ScanDirectory recursively scans a directory for ebook files. It stops with ctx.Err()
+// once ctx is cancelled, keeping the books added so far.
+func (dm *Manager) ScanDirectory(ctx context.Context, rootPath string) error {
+	supportedFormats := dm.scanFormats()
 
-	return filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+	return fswalk.Walk(rootPath, dm.walk, func(path string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if err != nil {
 			return nil // Skip files we can't access
 		}
@@ -171,51 +1175,220 @@ func (dm *Manager) ScanDirectory(rootPath string) error {
 			return nil // Skip unsupported files
 		}
 
-		// Check if book already exists in database
-		exists, err := dm.BookExists(path)
-		if err != nil || exists {
+		// Check if book already exists in database, refreshing it if the file changed
+		if existing, err := dm.GetBookByFilePath(path); err == nil {
+			dm.refreshChangedBook(existing, path, info)
+			return nil
+		} else if err != sql.ErrNoRows {
 			return nil
 		}
 
-		// Extract metadata from the ebook file
-		bookMetadata, err := dm.extractor.ExtractMetadata(path)
-		if err != nil {
-			log.Printf("Failed to extract metadata from %s: %v", path, err)
-			// Fallback to filename parsing
-			bookMetadata = dm.extractor.ExtractFromFilename(path)
-		}
-
-		title := bookMetadata.Title
-		author := bookMetadata.Author
-		isbn := bookMetadata.ISBN
-
-		book := models.BookRequest{
-			Title:     title,
-			Author:    author,
-			FilePath:  path,
-			FileSize:  info.Size(),
-			Format:    strings.TrimPrefix(ext, "."),
-			ISBN:      isbn,
-			Publisher: bookMetadata.Publisher,
+		book, ok := dm.bookRequestFromFile(path, info)
+		if !ok {
+			log.Printf("Skipping book with unknown author (policy %q): %s", dm.unknownAuthorPolicy, path)
+			return nil
 		}
 
-		err = dm.AddBook(book)
-		if err != nil {
+		err = dm.AddBookAt(book, dm.scanAddedAt(info))
+		if err == ErrDuplicatePath {
+			log.Printf("Book already in library, skipping: %s", path)
+		} else if err != nil {
 			log.Printf("Error adding book %s: %v", path, err)
 		} else {
-			log.Printf("Added book: %s by %s", title, author)
+			log.Printf("Added book: %s by %s", book.Title, book.Author)
 		}
 
 		return nil
 	})
 }
 
-// RescanDirectory performs a rescan that adds new books and removes unavailable ones
-func (dm *Manager) RescanDirectory(rootPath string) (int, int, error) {
-	supportedFormats := map[string]bool{
-		".epub": true,
-		// Only scan for EPUB files to avoid importing converted files
+// ScanFile adds a single ebook file to the library and returns the created book.
+// It returns ErrDuplicatePath if the file is already in the library.
+func (dm *Manager) ScanFile(path string) (models.Book, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return models.Book{}, fmt.Errorf("failed to stat file: %v", err)
+	}
+	if info.IsDir() {
+		return models.Book{}, fmt.Errorf("%s is a directory", path)
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); !dm.scanFormats()[ext] {
+		return models.Book{}, fmt.Errorf("unsupported format: %s", ext)
+	}
+
+	if _, err := dm.GetBookByFilePath(path); err == nil {
+		return models.Book{}, ErrDuplicatePath
+	} else if err != sql.ErrNoRows {
+		return models.Book{}, err
+	}
+
+	book, ok := dm.bookRequestFromFile(path, info)
+	if !ok {
+		return models.Book{}, fmt.Errorf("book has no author and the unknown author policy is %q", dm.unknownAuthorPolicy)
+	}
+	if err := dm.AddBookAt(book, dm.scanAddedAt(info)); err != nil {
+		return models.Book{}, err
+	}
+	log.Printf("Added book: %s by %s", book.Title, book.Author)
+
+	return dm.GetBookByFilePath(path)
+}
+
+// bookRequestFromFile extracts metadata from an ebook file, falling back to filename parsing.
+// It returns false when the unknown author policy says the book should not be added.
+func (dm *Manager) bookRequestFromFile(path string, info os.FileInfo) (models.BookRequest, bool) {
+	// Extract metadata from the ebook file
+	bookMetadata, err := dm.extractor.ExtractMetadata(path)
+	if err != nil {
+		log.Printf("Failed to extract metadata from %s: %v", path, err)
+		// Fallback to filename parsing
+		bookMetadata = dm.extractor.ExtractFromFilename(path)
+	}
+
+	if !dm.extractor.ResolveUnknownAuthor(path, bookMetadata, dm.unknownAuthorPolicy) {
+		return models.BookRequest{}, false
+	}
+
+	return models.BookRequest{
+		Title:         bookMetadata.Title,
+		Author:        bookMetadata.Author,
+		FilePath:      path,
+		FileSize:      info.Size(),
+		Format:        fileFormat(path),
+		ISBN:          bookMetadata.ISBN,
+		Publisher:     bookMetadata.Publisher,
+		PublishedDate: bookMetadata.Date,
+		DRM:           bookMetadata.DRM,
+		Description:   bookMetadata.Description,
+		UID:           bookMetadata.UID,
+		EPUBModified:  optionalTime(bookMetadata.Modified),
+		Series:        bookMetadata.Series,
+		SeriesIndex:   bookMetadata.SeriesIndex,
+		Authors:       bookMetadata.Authors,
+		Roles:         bookMetadata.Roles,
+		Tags:          bookMetadata.Subjects,
+	}, true
+}
+
+// fileFormat returns a book file's format from its content, or from its extension when
+// the content is not recognized. A PDF named .epub is stored as a PDF.
+func fileFormat(path string) string {
+	extFormat := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	detected, err := conversion.DetectFormat(path)
+	if err != nil || detected == "" {
+		return extFormat
+	}
+	if conversion.SameFormat(extFormat, detected) {
+		return extFormat
+	}
+	log.Printf("File %s is %s rather than %s, storing it as %s", path, detected, extFormat, detected)
+	return detected
+}
+
+// fileChanged reports whether a known book's file changed on disk since it was stored
+func fileChanged(existing models.Book, path string, info os.FileInfo) bool {
+	// CURRENT_TIMESTAMP has second precision, so allow a second of slack
+	if info.Size() != existing.FileSize || info.ModTime().After(existing.UpdatedAt.Add(time.Second)) {
+		return true
+	}
+
+	// Copies that keep an older modification time (rsync -t, restores) still carry the
+	// EPUB's own modified date. Only books that had one are opened.
+	if existing.EPUBModified != nil && existing.Format == "epub" {
+		return metadata.EPUBModified(path).After(*existing.EPUBModified)
+	}
+	return false
+}
+
+// optionalTime returns nil for the zero time, which is stored as NULL
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// refreshChangedBook re-extracts metadata for a known book whose file changed on disk
+func (dm *Manager) refreshChangedBook(existing models.Book, path string, info os.FileInfo) {
+	if !fileChanged(existing, path, info) {
+		return
+	}
+	if err := dm.reloadBook(existing, path, info); err != nil {
+		log.Printf("Error updating changed book %s: %v", path, err)
+	}
+}
+
+// RefreshBookFile re-extracts the metadata of the book stored at path, whether or not the
+// file looks changed, e.g. after an import replaced it. A file not in the library yet is
+// left to the next scan.
+func (dm *Manager) RefreshBookFile(path string) error {
+	existing, err := dm.GetBookByFilePath(path)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %v", err)
+	}
+	return dm.reloadBook(existing, path, info)
+}
+
+// reloadBook updates a known book from the metadata of its file
+func (dm *Manager) reloadBook(existing models.Book, path string, info os.FileInfo) error {
+	bookMetadata, err := dm.extractor.ExtractMetadata(path)
+	if err != nil {
+		return fmt.Errorf("failed to re-extract metadata: %v", err)
+	}
+
+	book := models.BookRequest{
+		Title:         bookMetadata.Title,
+		Author:        bookMetadata.Author,
+		FilePath:      path,
+		FileSize:      info.Size(),
+		ISBN:          bookMetadata.ISBN,
+		Publisher:     bookMetadata.Publisher,
+		PublishedDate: bookMetadata.Date,
+		DRM:           bookMetadata.DRM,
+		Description:   bookMetadata.Description,
+		UID:           bookMetadata.UID,
+		EPUBModified:  optionalTime(bookMetadata.Modified),
+		Series:        bookMetadata.Series,
+		SeriesIndex:   bookMetadata.SeriesIndex,
+		Authors:       bookMetadata.Authors,
+		Roles:         bookMetadata.Roles,
+		Tags:          bookMetadata.Subjects,
+	}
+
+	if err := dm.UpdateBookFromScan(existing.ID, book); err != nil {
+		return err
 	}
+	log.Printf("Updated changed book #%d: %s by %s", existing.ID, book.Title, book.Author)
+	return nil
+}
+
+// RescanDirectory performs a rescan that adds new books and removes unavailable ones.
+// Cancelling ctx stops the walk; no books are removed then, since the walk did not
+// see the whole library.
+func (dm *Manager) RescanDirectory(ctx context.Context, rootPath string) (int, int, error) {
+	return dm.rescan(ctx, rootPath, nil)
+}
+
+// PreviewRescan works out what RescanDirectory would add, update and remove without
+// changing the database. It fails the same way a rescan would, e.g. with
+// ErrTooManyRemovals.
+func (dm *Manager) PreviewRescan(ctx context.Context, rootPath string) (models.RescanPlan, error) {
+	plan := models.RescanPlan{Add: []models.RescanAction{}, Update: []models.RescanAction{}, Remove: []models.RescanAction{}}
+	_, _, err := dm.rescan(ctx, rootPath, &plan)
+	return plan, err
+}
+
+// rescan adds new books and removes unavailable ones. With a plan, changes are only
+// recorded in it.
+func (dm *Manager) rescan(ctx context.Context, rootPath string, plan *models.RescanPlan) (int, int, error) {
+	supportedFormats := dm.scanFormats()
 
 	// Get all current books from database
 	currentBooks, err := dm.GetAllBooks()
@@ -223,6 +1396,10 @@ func (dm *Manager) RescanDirectory(rootPath string) (int, int, error) {
 		return 0, 0, err
 	}
 
+	if len(currentBooks) > 0 && !LibraryAvailable(rootPath) {
+		return 0, 0, fmt.Errorf("%w: %s (is the volume mounted?)", ErrLibraryUnavailable, rootPath)
+	}
+
 	// Create a map of current file paths for quick lookup
 	currentPaths := make(map[string]bool)
 	for _, book := range currentBooks {
@@ -235,7 +1412,10 @@ func (dm *Manager) RescanDirectory(rootPath string) (int, int, error) {
 	removed := 0
 
 	// Scan directory for new books
-	err = filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+	err = fswalk.Walk(rootPath, dm.walk, func(path string, info os.FileInfo, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		if err != nil {
 			return nil // Skip files we can't access
 		}
@@ -247,39 +1427,36 @@ func (dm *Manager) RescanDirectory(rootPath string) (int, int, error) {
 
 		foundPaths[path] = true
 
-		// Check if book already exists in database
-		exists, err := dm.BookExists(path)
-		if err != nil || exists {
+		// Check if book already exists in database, refreshing it if the file changed
+		if existing, err := dm.GetBookByFilePath(path); err == nil {
+			if plan == nil {
+				dm.refreshChangedBook(existing, path, info)
+			} else if fileChanged(existing, path, info) {
+				plan.Update = append(plan.Update, models.RescanAction{BookID: existing.ID, FilePath: path, Title: existing.Title, Author: existing.Author})
+			}
+			return nil
+		} else if err != sql.ErrNoRows {
 			return nil
 		}
 
-		// Extract metadata from the ebook file
-		bookMetadata, err := dm.extractor.ExtractMetadata(path)
-		if err != nil {
-			log.Printf("Failed to extract metadata from %s: %v", path, err)
-			// Fallback to filename parsing
-			bookMetadata = dm.extractor.ExtractFromFilename(path)
+		book, ok := dm.bookRequestFromFile(path, info)
+		if !ok {
+			log.Printf("Skipping book with unknown author (policy %q): %s", dm.unknownAuthorPolicy, path)
+			return nil
 		}
-
-		title := bookMetadata.Title
-		author := bookMetadata.Author
-		isbn := bookMetadata.ISBN
-
-		book := models.BookRequest{
-			Title:     title,
-			Author:    author,
-			FilePath:  path,
-			FileSize:  info.Size(),
-			Format:    strings.TrimPrefix(ext, "."),
-			ISBN:      isbn,
-			Publisher: bookMetadata.Publisher,
+		if plan != nil {
+			plan.Add = append(plan.Add, models.RescanAction{FilePath: path, Title: book.Title, Author: book.Author})
+			added++
+			return nil
 		}
 
-		err = dm.AddBook(book)
-		if err != nil {
+		err = dm.AddBookAt(book, dm.scanAddedAt(info))
+		if err == ErrDuplicatePath {
+			log.Printf("Book already in library, skipping: %s", path)
+		} else if err != nil {
 			log.Printf("Error adding book %s: %v", path, err)
 		} else {
-			log.Printf("Added book: %s by %s", title, author)
+			log.Printf("Added book: %s by %s", book.Title, book.Author)
 			added++
 		}
 
@@ -290,35 +1467,61 @@ func (dm *Manager) RescanDirectory(rootPath string) (int, int, error) {
 		return added, removed, err
 	}
 
-	// Remove books that are no longer available
+	// A library that had books but now has no ebooks at all is more likely unmounted than emptied
+	if len(currentBooks) > 0 && len(foundPaths) == 0 {
+		return added, removed, fmt.Errorf("%w: no ebooks found in %s, refusing to remove %d books", ErrLibraryUnavailable, rootPath, len(currentBooks))
+	}
+
+	var missing []models.Book
 	for _, book := range currentBooks {
 		if !foundPaths[book.FilePath] {
-			err := dm.RemoveBook(book.ID)
-			if err != nil {
-				log.Printf("Error removing book %s: %v", book.FilePath, err)
-			} else {
-				log.Printf("Removed book: %s by %s", book.Title, book.Author)
-				removed++
-			}
+			missing = append(missing, book)
+		}
+	}
+	if len(missing)*100 > dm.maxRemovalPercent*len(currentBooks) {
+		return added, removed, fmt.Errorf("%w: %d of %d books are missing from %s, more than the %d%% allowed (scan.max_removal_percent); none were removed",
+			ErrTooManyRemovals, len(missing), len(currentBooks), rootPath, dm.maxRemovalPercent)
+	}
+
+	// Remove books that are no longer available
+	for _, book := range missing {
+		if plan != nil {
+			plan.Remove = append(plan.Remove, models.RescanAction{BookID: book.ID, FilePath: book.FilePath, Title: book.Title, Author: book.Author})
+			removed++
+			continue
+		}
+		err := dm.RemoveBook(book.ID)
+		if err != nil {
+			log.Printf("Error removing book %s: %v", book.FilePath, err)
+		} else {
+			log.Printf("Removed book: %s by %s", book.Title, book.Author)
+			removed++
 		}
 	}
 
 	return added, removed, nil
 }
 
-// GetAllAuthors returns all unique authors
-func (dm *Manager) GetAllAuthors() ([]string, error) {
-	query := "SELECT DISTINCT author FROM books ORDER BY author"
-	rows, err := dm.db.Query(query)
+// authorCredits lists everyone credited on a book with their book counts: the people in
+// book_authors, plus the author column of books without any (e.g. "Unknown")
+const authorCredits = `
+	SELECT a.name AS author, COUNT(DISTINCT ba.book_id) AS books FROM authors a JOIN book_authors ba ON ba.author_id = a.id GROUP BY a.id
+	UNION ALL
+	SELECT author, COUNT(*) FROM books WHERE id NOT IN (SELECT book_id FROM book_authors) GROUP BY author`
+
+// queryAuthors returns the authors in authorCredits matching where, ordered by name
+func (dm *Manager) queryAuthors(where string, args ...interface{}) ([]models.AuthorCount, error) {
+	query := "SELECT author, SUM(books) FROM (" + authorCredits + ") WHERE " + where + " GROUP BY author COLLATE NOCASE ORDER BY author COLLATE NOCASE"
+	rows, err := dm.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var authors []string
+	var authors []models.AuthorCount
 	for rows.Next() {
-		var author string
-		err := rows.Scan(&author)
+		var author models.AuthorCount
+		err := rows.Scan(&author.Author, &author.Count)
 		if err != nil {
 			return nil, err
 		}
@@ -328,9 +1531,118 @@ func (dm *Manager) GetAllAuthors() ([]string, error) {
 	return authors, nil
 }
 
+// authorNames returns the names of counted authors
+func authorNames(authors []models.AuthorCount, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, author := range authors {
+		names = append(names, author.Author)
+	}
+	return names, nil
+}
+
+// GetAllAuthors returns everyone credited on a book in the library, co-authors and
+// other contributors each on their own
+func (dm *Manager) GetAllAuthors() ([]string, error) {
+	return authorNames(dm.queryAuthors("1"))
+}
+
+// GetAuthorsWithCounts returns all unique authors with their book counts
+func (dm *Manager) GetAuthorsWithCounts() ([]models.AuthorCount, error) {
+	return dm.queryAuthors("1")
+}
+
 // GetAuthorsByLetter returns authors starting with a specific letter
 func (dm *Manager) GetAuthorsByLetter(letter string) ([]string, error) {
-	query := "SELECT DISTINCT author FROM books WHERE author LIKE ? ORDER BY author"
+	where, args := letterCondition("author", letter)
+	return authorNames(dm.queryAuthors(where, args...))
+}
+
+// GetBooksByAuthor returns all books by a specific author: books credited to exactly that
+// author string, and books listing the person among their authors (so "Neil Gaiman" also
+// finds "Neil Gaiman, Terry Pratchett", and "Gaiman, Neil" matches too). Books are in
+// series order: each series by position, then the books in no series by title.
+func (dm *Manager) GetBooksByAuthor(author string) ([]models.Book, error) {
+	person := author
+	if names := metadata.SplitAuthors(author); len(names) == 1 {
+		person = names[0]
+	}
+	return dm.searchBooksOrdered(`author = ? OR id IN (SELECT ba.book_id FROM book_authors ba JOIN authors a ON a.id = ba.author_id WHERE a.name = ?)`, seriesOrder, author, person)
+}
+
+// GetAuthorLetters returns the initial letters that have at least one author
+func (dm *Manager) GetAuthorLetters() ([]string, error) {
+	return dm.queryLetters("sort_author")
+}
+
+// GetTitleLetters returns the initial letters that have at least one title
+func (dm *Manager) GetTitleLetters() ([]string, error) {
+	return dm.queryLetters("sort_title")
+}
+
+// queryLetters returns the distinct upper-case initials A-Z of a sort column, plus "#"
+// when some values start with anything else. "#" sorts first.
+func (dm *Manager) queryLetters(column string) ([]string, error) {
+	query := `SELECT DISTINCT CASE 
+				WHEN UPPER(SUBSTR(` + column + `, 1, 1)) BETWEEN 'A' AND 'Z' THEN UPPER(SUBSTR(` + column + `, 1, 1)) 
+				ELSE '#' END AS initial 
+			  FROM books 
+			  WHERE ` + column + ` IS NOT NULL AND ` + column + ` != '' 
+			  ORDER BY initial`
+	rows, err := dm.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var letters []string
+	for rows.Next() {
+		var letter string
+		if err := rows.Scan(&letter); err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+
+	return letters, nil
+}
+
+// letterCondition builds the WHERE clause for a letter index lookup on a sort column.
+// "#" matches values that don't start with A-Z.
+func letterCondition(column, letter string) (string, []interface{}) {
+	if letter == "#" {
+		return "UPPER(SUBSTR(" + column + ", 1, 1)) NOT BETWEEN 'A' AND 'Z'", nil
+	}
+	return column + " LIKE ?", []interface{}{letter + "%"}
+}
+
+// GetAllPublishers returns all non-empty publishers with their book counts
+func (dm *Manager) GetAllPublishers() ([]models.PublisherCount, error) {
+	query := "SELECT publisher, COUNT(*) FROM books WHERE publisher IS NOT NULL AND publisher != '' GROUP BY publisher ORDER BY publisher"
+	rows, err := dm.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var publishers []models.PublisherCount
+	for rows.Next() {
+		var publisher models.PublisherCount
+		err := rows.Scan(&publisher.Publisher, &publisher.Count)
+		if err != nil {
+			return nil, err
+		}
+		publishers = append(publishers, publisher)
+	}
+
+	return publishers, nil
+}
+
+// GetPublishersByLetter returns publishers starting with a specific letter, with their book counts
+func (dm *Manager) GetPublishersByLetter(letter string) ([]models.PublisherCount, error) {
+	query := "SELECT publisher, COUNT(*) FROM books WHERE publisher LIKE ? GROUP BY publisher ORDER BY publisher"
 	searchTerm := letter + "%"
 
 	rows, err := dm.db.Query(query, searchTerm)
@@ -339,23 +1651,23 @@ func (dm *Manager) GetAuthorsByLetter(letter string) ([]string, error) {
 	}
 	defer rows.Close()
 
-	var authors []string
+	var publishers []models.PublisherCount
 	for rows.Next() {
-		var author string
-		err := rows.Scan(&author)
+		var publisher models.PublisherCount
+		err := rows.Scan(&publisher.Publisher, &publisher.Count)
 		if err != nil {
 			return nil, err
 		}
-		authors = append(authors, author)
+		publishers = append(publishers, publisher)
 	}
 
-	return authors, nil
+	return publishers, nil
 }
 
-// GetBooksByAuthor returns all books by a specific author
-func (dm *Manager) GetBooksByAuthor(author string) ([]models.Book, error) {
-	query := "SELECT id, title, author, file_path, file_size, format, isbn, publisher, added_at, updated_at FROM books WHERE author = ? ORDER BY title"
-	rows, err := dm.db.Query(query, author)
+// GetBooksByPublisher returns all books from a specific publisher
+func (dm *Manager) GetBooksByPublisher(publisher string) ([]models.Book, error) {
+	query := "SELECT " + bookColumns + " FROM books WHERE publisher = ? ORDER BY sort_title"
+	rows, err := dm.db.Query(query, publisher)
 	if err != nil {
 		return nil, err
 	}
@@ -363,8 +1675,7 @@ func (dm *Manager) GetBooksByAuthor(author string) ([]models.Book, error) {
 
 	var books []models.Book
 	for rows.Next() {
-		var book models.Book
-		err := rows.Scan(&book.ID, &book.Title, &book.Author, &book.FilePath, &book.FileSize, &book.Format, &book.ISBN, &book.Publisher, &book.AddedAt, &book.UpdatedAt)
+		book, err := scanBook(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -376,7 +1687,7 @@ func (dm *Manager) GetBooksByAuthor(author string) ([]models.Book, error) {
 
 // GetAllTitles returns all unique titles
 func (dm *Manager) GetAllTitles() ([]string, error) {
-	query := "SELECT DISTINCT title FROM books ORDER BY title"
+	query := "SELECT title FROM books GROUP BY title ORDER BY MIN(sort_title)"
 	rows, err := dm.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -396,12 +1707,48 @@ func (dm *Manager) GetAllTitles() ([]string, error) {
 	return titles, nil
 }
 
+// GetTitlesWithCounts returns all unique titles with the number of books sharing each
+func (dm *Manager) GetTitlesWithCounts() ([]models.TitleCount, error) {
+	return dm.queryTitleCounts("")
+}
+
+// GetTitlesByLetterWithCounts returns titles starting with a specific letter with their book counts
+func (dm *Manager) GetTitlesByLetterWithCounts(letter string) ([]models.TitleCount, error) {
+	where, args := letterCondition("sort_title", letter)
+	return dm.queryTitleCounts(where, args...)
+}
+
+// queryTitleCounts groups the books matching an optional WHERE clause by title
+func (dm *Manager) queryTitleCounts(where string, args ...interface{}) ([]models.TitleCount, error) {
+	query := "SELECT title, COUNT(*) FROM books"
+	if where != "" {
+		query += " WHERE " + where
+	}
+	query += " GROUP BY title ORDER BY MIN(sort_title)"
+	rows, err := dm.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var titles []models.TitleCount
+	for rows.Next() {
+		var title models.TitleCount
+		if err := rows.Scan(&title.Title, &title.Count); err != nil {
+			return nil, err
+		}
+		titles = append(titles, title)
+	}
+
+	return titles, nil
+}
+
 // GetTitlesByLetter returns titles starting with a specific letter
 func (dm *Manager) GetTitlesByLetter(letter string) ([]string, error) {
-	query := "SELECT DISTINCT title FROM books WHERE title LIKE ? ORDER BY title"
-	searchTerm := letter + "%"
+	where, args := letterCondition("sort_title", letter)
+	query := "SELECT title FROM books WHERE " + where + " GROUP BY title ORDER BY MIN(sort_title)"
 
-	rows, err := dm.db.Query(query, searchTerm)
+	rows, err := dm.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -422,7 +1769,7 @@ func (dm *Manager) GetTitlesByLetter(letter string) ([]string, error) {
 
 // GetBooksByTitle returns all books with a specific title
 func (dm *Manager) GetBooksByTitle(title string) ([]models.Book, error) {
-	query := "SELECT id, title, author, file_path, file_size, format, isbn, publisher, added_at, updated_at FROM books WHERE title = ? ORDER BY author"
+	query := "SELECT " + bookColumns + " FROM books WHERE title = ? ORDER BY sort_author"
 	rows, err := dm.db.Query(query, title)
 	if err != nil {
 		return nil, err
@@ -431,8 +1778,7 @@ func (dm *Manager) GetBooksByTitle(title string) ([]models.Book, error) {
 
 	var books []models.Book
 	for rows.Next() {
-		var book models.Book
-		err := rows.Scan(&book.ID, &book.Title, &book.Author, &book.FilePath, &book.FileSize, &book.Format, &book.ISBN, &book.Publisher, &book.AddedAt, &book.UpdatedAt)
+		book, err := scanBook(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -444,7 +1790,7 @@ func (dm *Manager) GetBooksByTitle(title string) ([]models.Book, error) {
 
 // GetRecentBooks returns the most recently added books
 func (dm *Manager) GetRecentBooks(limit int) ([]models.Book, error) {
-	query := "SELECT id, title, author, file_path, file_size, format, isbn, publisher, added_at, updated_at FROM books ORDER BY added_at DESC LIMIT ?"
+	query := "SELECT " + bookColumns + " FROM books ORDER BY added_at DESC LIMIT ?"
 	rows, err := dm.db.Query(query, limit)
 	if err != nil {
 		return nil, err
@@ -453,8 +1799,7 @@ func (dm *Manager) GetRecentBooks(limit int) ([]models.Book, error) {
 
 	var books []models.Book
 	for rows.Next() {
-		var book models.Book
-		err := rows.Scan(&book.ID, &book.Title, &book.Author, &book.FilePath, &book.FileSize, &book.Format, &book.ISBN, &book.Publisher, &book.AddedAt, &book.UpdatedAt)
+		book, err := scanBook(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -466,7 +1811,31 @@ func (dm *Manager) GetRecentBooks(limit int) ([]models.Book, error) {
 
 // GetRandomBooks returns a random selection of books
 func (dm *Manager) GetRandomBooks(limit int) ([]models.Book, error) {
-	query := "SELECT id, title, author, file_path, file_size, format, isbn, publisher, added_at, updated_at FROM books ORDER BY RANDOM() LIMIT ?"
+	query := "SELECT " + bookColumns + " FROM books ORDER BY RANDOM() LIMIT ?"
+	rows, err := dm.db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []models.Book
+	for rows.Next() {
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+
+	return books, nil
+}
+
+// GetBooksInProgress returns the books with a saved reading position short of the
+// end, the most recently read first
+func (dm *Manager) GetBooksInProgress(limit int) ([]models.Book, error) {
+	query := "SELECT " + bookColumns + ` FROM books 
+			  JOIN (SELECT book_id, updated_at AS read_at FROM reading_progress WHERE percent < 100) p ON p.book_id = books.id 
+			  ORDER BY p.read_at DESC LIMIT ?`
 	rows, err := dm.db.Query(query, limit)
 	if err != nil {
 		return nil, err
@@ -475,8 +1844,30 @@ func (dm *Manager) GetRandomBooks(limit int) ([]models.Book, error) {
 
 	var books []models.Book
 	for rows.Next() {
-		var book models.Book
-		err := rows.Scan(&book.ID, &book.Title, &book.Author, &book.FilePath, &book.FileSize, &book.Format, &book.ISBN, &book.Publisher, &book.AddedAt, &book.UpdatedAt)
+		book, err := scanBook(rows)
+		if err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+
+	return books, nil
+}
+
+// GetBooksAddedOnThisDay returns books added on the same month and day as date in earlier years, newest first
+func (dm *Manager) GetBooksAddedOnThisDay(date time.Time, limit int) ([]models.Book, error) {
+	query := "SELECT " + bookColumns + ` FROM books 
+			  WHERE substr(added_at, 6, 5) = ? AND CAST(substr(added_at, 1, 4) AS INTEGER) < ? 
+			  ORDER BY added_at DESC LIMIT ?`
+	rows, err := dm.db.Query(query, date.Format("01-02"), date.Year(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []models.Book
+	for rows.Next() {
+		book, err := scanBook(rows)
 		if err != nil {
 			return nil, err
 		}
@@ -488,11 +1879,249 @@ func (dm *Manager) GetRandomBooks(limit int) ([]models.Book, error) {
 
 // GetBookByID returns a book by its ID
 func (dm *Manager) GetBookByID(id int) (models.Book, error) {
-	query := "SELECT id, title, author, file_path, file_size, format, isbn, publisher, added_at, updated_at FROM books WHERE id = ?"
+	query := "SELECT " + bookColumns + " FROM books WHERE id = ?"
 	row := dm.db.QueryRow(query, id)
 
-	var book models.Book
-	err := row.Scan(&book.ID, &book.Title, &book.Author, &book.FilePath, &book.FileSize, &book.Format, &book.ISBN, &book.Publisher, &book.AddedAt, &book.UpdatedAt)
+	book, err := scanBook(row)
+	if err != nil {
+		return models.Book{}, err
+	}
+
+	return book, nil
+}
+
+// GetBooksByIDs returns the books with the given IDs in the order requested.
+// IDs that don't exist are omitted.
+func (dm *Manager) GetBooksByIDs(ids []int) ([]models.Book, error) {
+	// Stay well below SQLite's bound parameter limit
+	const chunkSize = 500
+
+	found := make(map[int]models.Book, len(ids))
+	for start := 0; start < len(ids); start += chunkSize {
+		end := start + chunkSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		chunk := ids[start:end]
+
+		args := make([]interface{}, len(chunk))
+		for i, id := range chunk {
+			args[i] = id
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(chunk)), ", ")
+
+		query := "SELECT " + bookColumns + " FROM books WHERE id IN (" + placeholders + ")"
+		rows, err := dm.db.Query(query, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			book, err := scanBook(rows)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			found[book.ID] = book
+		}
+		rows.Close()
+	}
+
+	books := make([]models.Book, 0, len(found))
+	for _, id := range ids {
+		if book, ok := found[id]; ok {
+			books = append(books, book)
+		}
+	}
+	return books, nil
+}
+
+// GetBookByUID returns the book whose EPUB has the given package unique identifier.
+// If several copies share it, the one added first is returned.
+func (dm *Manager) GetBookByUID(uid string) (models.Book, error) {
+	query := "SELECT " + bookColumns + " FROM books WHERE uid = ? ORDER BY id LIMIT 1"
+	return scanBook(dm.db.QueryRow(query, uid))
+}
+
+// GetEditions returns the other library entries of the same book, such as another format
+// or a second copy elsewhere: books sharing its unique identifier, or its title and author
+// compared by sort key and ignoring case
+func (dm *Manager) GetEditions(bookID int) ([]models.Book, error) {
+	book, err := dm.GetBookByID(bookID)
+	if err != nil {
+		return nil, err
+	}
+	return dm.searchBooks(`id != ? AND ((uid != '' AND uid = ?)
+		OR (sort_title = ? COLLATE NOCASE AND sort_author = ? COLLATE NOCASE))`,
+		book.ID, book.UID, book.SortTitle, book.SortAuthor)
+}
+
+// BackfillUIDs reads the unique identifier of EPUBs added before the uid column existed.
+// It returns the number of books that got one.
+func (dm *Manager) BackfillUIDs() (int, error) {
+	rows, err := dm.db.Query(`SELECT id, file_path FROM books WHERE uid IS NULL AND format = 'epub'`)
+	if err != nil {
+		return 0, err
+	}
+	pending := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var path string
+		if err := rows.Scan(&id, &path); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending[id] = path
+	}
+	rows.Close()
+
+	found := 0
+	for id, path := range pending {
+		uid := metadata.UniqueIdentifier(path)
+		if _, err := dm.db.Exec(`UPDATE books SET uid = ? WHERE id = ?`, uid, id); err != nil {
+			return found, err
+		}
+		if uid != "" {
+			found++
+		}
+	}
+	return found, nil
+}
+
+// BackfillSeries reads the series of EPUBs added before the series columns existed.
+// It returns the number of books found to be part of a series.
+func (dm *Manager) BackfillSeries(ctx context.Context) (int, error) {
+	rows, err := dm.db.Query(`SELECT id, file_path FROM books WHERE series IS NULL AND format = 'epub'`)
+	if err != nil {
+		return 0, err
+	}
+	pending := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var path string
+		if err := rows.Scan(&id, &path); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending[id] = path
+	}
+	rows.Close()
+
+	found := 0
+	for id, path := range pending {
+		if err := ctx.Err(); err != nil {
+			return found, err
+		}
+		series, index := dm.extractor.EPUBSeries(path)
+		if _, err := dm.db.Exec(`UPDATE books SET series = ?, series_index = ? WHERE id = ?`, series, index, id); err != nil {
+			return found, err
+		}
+		if series != "" {
+			found++
+		}
+	}
+	return found, nil
+}
+
+// BackfillPublishedDates reads the publication date of books added before it was
+// stored, and derives the year of dates stored without one, so they count in the
+// books-by-year statistics. It returns the number of books that got a year.
+func (dm *Manager) BackfillPublishedDates(ctx context.Context) (int, error) {
+	rows, err := dm.db.Query(`SELECT id, file_path, published_date FROM books WHERE published_date IS NULL OR (year IS NULL AND published_date != '')`)
+	if err != nil {
+		return 0, err
+	}
+	pending := make(map[int]string)
+	stored := make(map[int]string)
+	for rows.Next() {
+		var id int
+		var path string
+		var publishedDate sql.NullString
+		if err := rows.Scan(&id, &path, &publishedDate); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		if publishedDate.Valid {
+			stored[id] = publishedDate.String
+		} else {
+			pending[id] = path
+		}
+	}
+	rows.Close()
+
+	for id, path := range pending {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		// An empty date marks books whose file has none, so they are not read again
+		date := ""
+		if bookMetadata, err := dm.extractor.ExtractMetadata(path); err == nil {
+			date = bookMetadata.Date
+		}
+		stored[id] = date
+	}
+
+	found := 0
+	for id, date := range stored {
+		year := metadata.ParseYear(date)
+		if _, err := dm.db.Exec(`UPDATE books SET published_date = ?, year = ? WHERE id = ?`, date, year, id); err != nil {
+			return found, err
+		}
+		if year > 0 {
+			found++
+		}
+	}
+	return found, nil
+}
+
+// BackfillDRM checks the books stored before DRM detection, flagging the protected
+// ones so they are not offered for reading or conversion. It returns the number of
+// protected books found.
+func (dm *Manager) BackfillDRM(ctx context.Context) (int, error) {
+	rows, err := dm.db.Query(`SELECT id, file_path, format FROM books WHERE drm IS NULL`)
+	if err != nil {
+		return 0, err
+	}
+	type pendingBook struct{ path, format string }
+	pending := make(map[int]pendingBook)
+	for rows.Next() {
+		var id int
+		var book pendingBook
+		if err := rows.Scan(&id, &book.path, &book.format); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pending[id] = book
+	}
+	rows.Close()
+
+	found := 0
+	for id, book := range pending {
+		if err := ctx.Err(); err != nil {
+			return found, err
+		}
+		// Unreadable files count as unprotected, as they would when scanned
+		hasDRM := false
+		if book.format == "epub" {
+			hasDRM, _ = epub.DetectDRM(book.path)
+		} else if bookMetadata, err := dm.extractor.ExtractMetadata(book.path); err == nil {
+			hasDRM = bookMetadata.DRM
+		}
+		if _, err := dm.db.Exec(`UPDATE books SET drm = ? WHERE id = ?`, hasDRM, id); err != nil {
+			return found, err
+		}
+		if hasDRM {
+			found++
+		}
+	}
+	return found, nil
+}
+
+// GetBookByFilePath returns the book stored at the given file path
+func (dm *Manager) GetBookByFilePath(filePath string) (models.Book, error) {
+	query := "SELECT " + bookColumns + " FROM books WHERE file_path = ?"
+	row := dm.db.QueryRow(query, filePath)
+
+	book, err := scanBook(row)
 	if err != nil {
 		return models.Book{}, err
 	}
@@ -504,34 +2133,128 @@ func (dm *Manager) GetBookByID(id int) (models.Book, error) {
 func (m *Manager) UpdateBook(id int, title, author, isbn, publisher string) error {
 	query := `
 		UPDATE books 
-		SET title = ?, author = ?, isbn = ?, publisher = ?, updated_at = CURRENT_TIMESTAMP 
+		SET title = ?, author = ?, isbn = ?, publisher = ?, sort_title = ?, sort_author = ?, updated_at = CURRENT_TIMESTAMP 
 		WHERE id = ?
 	`
 
-	_, err := m.db.Exec(query, title, author, isbn, publisher, id)
+	sortTitle, sortAuthor := m.sortKeys(title, author)
+	_, err := m.db.Exec(query, title, author, isbn, publisher, sortTitle, sortAuthor, id)
 	if err != nil {
 		return fmt.Errorf("failed to update book: %v", err)
 	}
 
-	return nil
+	return m.SetBookAuthors(id, bookAuthors(models.BookRequest{Author: author}), nil)
+}
+
+// UpdateAuthors replaces the author of a book and the people credited on it, keeping
+// the rest of its metadata
+func (m *Manager) UpdateAuthors(id int, author string, authors []string, roles map[string]string) error {
+	var title string
+	if err := m.db.QueryRow(`SELECT title FROM books WHERE id = ?`, id).Scan(&title); err != nil {
+		return fmt.Errorf("failed to update authors: %v", err)
+	}
+	_, sortAuthor := m.sortKeys(title, author)
+	_, err := m.db.Exec(`UPDATE books SET author = ?, sort_author = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, author, sortAuthor, id)
+	if err != nil {
+		return fmt.Errorf("failed to update authors: %v", err)
+	}
+
+	return m.SetBookAuthors(id, authors, roles)
 }
 
 // UpdateBookWithPath updates book metadata and file path in the database
 func (m *Manager) UpdateBookWithPath(id int, title, author, isbn, publisher, filePath string) error {
 	query := `
 		UPDATE books 
-		SET title = ?, author = ?, isbn = ?, publisher = ?, file_path = ?, updated_at = CURRENT_TIMESTAMP 
+		SET title = ?, author = ?, isbn = ?, publisher = ?, file_path = ?, sort_title = ?, sort_author = ?, updated_at = CURRENT_TIMESTAMP 
+		WHERE id = ?
+	`
+
+	sortTitle, sortAuthor := m.sortKeys(title, author)
+	_, err := m.db.Exec(query, title, author, isbn, publisher, filePath, sortTitle, sortAuthor, id)
+	if err != nil {
+		return fmt.Errorf("failed to update book: %v", err)
+	}
+
+	return m.SetBookAuthors(id, bookAuthors(models.BookRequest{Author: author}), nil)
+}
+
+// UpdateFilePath records a book's new location after its file was moved
+func (m *Manager) UpdateFilePath(id int, filePath string) error {
+	_, err := m.db.Exec(`UPDATE books SET file_path = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, filePath, id)
+	if err != nil {
+		return fmt.Errorf("failed to update file path: %v", err)
+	}
+	return nil
+}
+
+// UpdateFormat corrects the stored format of a book, e.g. after detecting it from the file
+func (m *Manager) UpdateFormat(id int, format string) error {
+	_, err := m.db.Exec(`UPDATE books SET format = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, format, id)
+	if err != nil {
+		return fmt.Errorf("failed to update format: %v", err)
+	}
+	return nil
+}
+
+// UpdateBookFromScan refreshes a book row from freshly extracted file metadata.
+// Empty ISBN, publisher, published date and description values keep what is already stored, since
+// those are often only known from manual edits. Tags are added, never removed.
+func (m *Manager) UpdateBookFromScan(id int, book models.BookRequest) error {
+	query := `
+		UPDATE books 
+		SET title = ?, author = ?, sort_title = ?, sort_author = ?, file_size = ?, 
+			isbn = COALESCE(NULLIF(?, ''), isbn), 
+			publisher = COALESCE(NULLIF(?, ''), publisher), 
+			published_date = COALESCE(NULLIF(?, ''), published_date), 
+			year = COALESCE(NULLIF(?, 0), year), 
+			description = COALESCE(NULLIF(?, ''), description), 
+			drm = ?, 
+			uid = ?, 
+			epub_modified = ?, 
+			series = ?, 
+			series_index = ?, 
+			updated_at = CURRENT_TIMESTAMP 
 		WHERE id = ?
 	`
 
-	_, err := m.db.Exec(query, title, author, isbn, publisher, filePath, id)
+	sortTitle, sortAuthor := m.sortKeys(book.Title, book.Author)
+	_, err := m.db.Exec(query, book.Title, book.Author, sortTitle, sortAuthor, book.FileSize, book.ISBN, book.Publisher, book.PublishedDate, metadata.ParseYear(book.PublishedDate), book.Description, book.DRM, book.UID, book.EPUBModified, book.Series, book.SeriesIndex, id)
 	if err != nil {
 		return fmt.Errorf("failed to update book: %v", err)
 	}
+	if err := m.SetBookAuthors(id, bookAuthors(book), book.Roles); err != nil {
+		return err
+	}
+
+	return m.AddBookTags(id, book.Tags)
+}
+
+// UpdatePublishedDate sets a book's publication date and its derived year
+func (m *Manager) UpdatePublishedDate(id int, publishedDate string) error {
+	query := `
+		UPDATE books 
+		SET published_date = ?, year = ?, updated_at = CURRENT_TIMESTAMP 
+		WHERE id = ?
+	`
+
+	_, err := m.db.Exec(query, publishedDate, metadata.ParseYear(publishedDate), id)
+	if err != nil {
+		return fmt.Errorf("failed to update published date: %v", err)
+	}
 
 	return nil
 }
 
+// UpdateDescription sets a book's description
+func (m *Manager) UpdateDescription(id int, description string) error {
+	_, err := m.db.Exec(`UPDATE books SET description = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?`, description, id)
+	if err != nil {
+		return fmt.Errorf("failed to update description: %v", err)
+	}
+	return nil
+}
+
 // GetTotalBooksCount returns the total number of books in the library
 func (m *Manager) GetTotalBooksCount() (int, error) {
 	var count int
@@ -582,6 +2305,153 @@ func (m *Manager) GetLibrarySizeInfo() (int64, int64, error) {
 	return total, avg, nil
 }
 
+// CountByPublicationYear returns book counts grouped by publication year
+func (m *Manager) CountByPublicationYear() ([]models.YearCount, error) {
+	query := `SELECT year, COUNT(*) 
+			  FROM books 
+			  WHERE year > 0 
+			  GROUP BY year 
+			  ORDER BY year`
+	return m.queryYearCounts(query)
+}
+
+// CountByAddedYear returns book counts grouped by the year they were added to the library
+func (m *Manager) CountByAddedYear() ([]models.YearCount, error) {
+	query := `SELECT CAST(substr(added_at, 1, 4) AS INTEGER) AS year, COUNT(*) 
+			  FROM books 
+			  WHERE added_at IS NOT NULL 
+			  GROUP BY year 
+			  ORDER BY year`
+	return m.queryYearCounts(query)
+}
+
+// IncrementDownloadCount records one download of a book in the given format
+func (m *Manager) IncrementDownloadCount(bookID int, format string) error {
+	query := `INSERT INTO downloads (book_id, format, count, last_downloaded_at) 
+			  VALUES (?, ?, 1, CURRENT_TIMESTAMP) 
+			  ON CONFLICT(book_id, format) DO UPDATE SET 
+			  count = count + 1, last_downloaded_at = CURRENT_TIMESTAMP`
+	if _, err := m.db.Exec(query, bookID, strings.ToLower(format)); err != nil {
+		return fmt.Errorf("failed to increment download count: %v", err)
+	}
+	return nil
+}
+
+// GetMostDownloadedBooks returns the books with the most downloads across all formats
+func (m *Manager) GetMostDownloadedBooks(limit int) ([]models.BookDownloadCount, error) {
+	query := `SELECT b.id, b.title, COALESCE(b.author, ''), SUM(d.count) AS total 
+			  FROM downloads d 
+			  JOIN books b ON b.id = d.book_id 
+			  GROUP BY b.id 
+			  ORDER BY total DESC, b.sort_title 
+			  LIMIT ?`
+	rows, err := m.db.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var books []models.BookDownloadCount
+	for rows.Next() {
+		var book models.BookDownloadCount
+		if err := rows.Scan(&book.BookID, &book.Title, &book.Author, &book.Count); err != nil {
+			return nil, err
+		}
+		books = append(books, book)
+	}
+
+	return books, nil
+}
+
+// CountDownloadsByFormat returns the total number of downloads per format
+func (m *Manager) CountDownloadsByFormat() ([]models.FormatCount, error) {
+	rows, err := m.db.Query(`SELECT format, SUM(count) AS total FROM downloads GROUP BY format ORDER BY total DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var formats []models.FormatCount
+	for rows.Next() {
+		var format models.FormatCount
+		if err := rows.Scan(&format.Format, &format.Count); err != nil {
+			return nil, err
+		}
+		formats = append(formats, format)
+	}
+
+	return formats, nil
+}
+
+// SaveConversion records a cached conversion, replacing any earlier one of the same book and format
+func (m *Manager) SaveConversion(conversion models.CachedConversion) error {
+	query := `INSERT OR REPLACE INTO conversions (book_id, format, path, size, source_mtime, created_at, last_used_at) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?)`
+	_, err := m.db.Exec(query, conversion.BookID, conversion.Format, conversion.Path, conversion.Size,
+		conversion.SourceMtime.Unix(), conversion.CreatedAt, conversion.LastUsedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save conversion: %v", err)
+	}
+	return nil
+}
+
+// GetConversions returns every cached conversion
+func (m *Manager) GetConversions() ([]models.CachedConversion, error) {
+	rows, err := m.db.Query(`SELECT book_id, format, path, size, source_mtime, created_at, last_used_at FROM conversions`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var conversions []models.CachedConversion
+	for rows.Next() {
+		var conversion models.CachedConversion
+		var sourceMtime int64
+		err := rows.Scan(&conversion.BookID, &conversion.Format, &conversion.Path, &conversion.Size,
+			&sourceMtime, &conversion.CreatedAt, &conversion.LastUsedAt)
+		if err != nil {
+			return nil, err
+		}
+		conversion.SourceMtime = time.Unix(sourceMtime, 0)
+		conversions = append(conversions, conversion)
+	}
+
+	return conversions, rows.Err()
+}
+
+// TouchConversion marks a cached conversion as used now
+func (m *Manager) TouchConversion(bookID int, format string) error {
+	_, err := m.db.Exec(`UPDATE conversions SET last_used_at = ? WHERE book_id = ? AND format = ?`, time.Now(), bookID, format)
+	return err
+}
+
+// DeleteConversion forgets a cached conversion; the caller removes the file
+func (m *Manager) DeleteConversion(bookID int, format string) error {
+	_, err := m.db.Exec(`DELETE FROM conversions WHERE book_id = ? AND format = ?`, bookID, format)
+	return err
+}
+
+// queryYearCounts runs a (year, count) query and collects the buckets
+func (m *Manager) queryYearCounts(query string) ([]models.YearCount, error) {
+	rows, err := m.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []models.YearCount
+	for rows.Next() {
+		var bucket models.YearCount
+		err := rows.Scan(&bucket.Year, &bucket.Count)
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	return buckets, nil
+}
+
 // GetLastActivityDates returns the last import and scan dates
 func (m *Manager) GetLastActivityDates() (string, string, error) {
 	var lastImport, lastScan sql.NullString
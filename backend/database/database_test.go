@@ -0,0 +1,188 @@
+package database
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"fableflow/backend/filemeta"
+	"fableflow/backend/models"
+)
+
+func TestAddBookAtDuplicatePath(t *testing.T) {
+	addedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	original := models.BookRequest{Title: "Old Title", Author: "Old Author", FilePath: "/library/book.epub", Format: "epub", Tags: []string{"fiction"}}
+	again := models.BookRequest{Title: "New Title", Author: "New Author", FilePath: "/library/book.epub", Format: "epub"}
+
+	for _, updateExisting := range []bool{false, true} {
+		dm := newTestManager(t)
+		dm.SetUpdateExisting(updateExisting)
+		if err := dm.AddBookAt(original, addedAt); err != nil {
+			t.Fatalf("update_existing %v: AddBookAt: %v", updateExisting, err)
+		}
+		err := dm.AddBookAt(again, addedAt.Add(time.Hour))
+
+		want := original
+		if updateExisting {
+			want = again
+			if err != nil {
+				t.Errorf("update_existing: second AddBookAt: %v, want the book updated", err)
+			}
+		} else if err != ErrDuplicatePath {
+			t.Errorf("second AddBookAt err = %v, want %v", err, ErrDuplicatePath)
+		}
+
+		books, err := dm.GetAllBooks()
+		if err != nil || len(books) != 1 {
+			t.Fatalf("update_existing %v: %d books, %v; want 1", updateExisting, len(books), err)
+		}
+		book := books[0]
+		if book.ID != 1 || book.Title != want.Title || book.Author != want.Author {
+			t.Errorf("update_existing %v: book %d is %q by %q, want book 1 %q by %q", updateExisting, book.ID, book.Title, book.Author, want.Title, want.Author)
+		}
+		if len(book.Authors) != 1 || book.Authors[0] != want.Author {
+			t.Errorf("update_existing %v: authors = %v, want [%s]", updateExisting, book.Authors, want.Author)
+		}
+		// An update keeps the book where it was in the list of recent additions
+		if !book.AddedAt.Equal(addedAt) {
+			t.Errorf("update_existing %v: added_at = %v, want %v", updateExisting, book.AddedAt, addedAt)
+		}
+
+		// Only the file path conflicts: the same book at another path is added
+		elsewhere := again
+		elsewhere.FilePath = "/library/copy.epub"
+		if err := dm.AddBookAt(elsewhere, addedAt); err != nil {
+			t.Errorf("update_existing %v: AddBookAt at another path: %v", updateExisting, err)
+		}
+	}
+}
+
+func TestScanDuplicatePath(t *testing.T) {
+	for _, updateExisting := range []bool{false, true} {
+		dm := newTestManager(t)
+		dm.SetUpdateExisting(updateExisting)
+		dir := t.TempDir()
+		path := copyFixture(t, dir, "a.epub")
+
+		// Scanning again leaves one book either way
+		for i := 0; i < 2; i++ {
+			if err := dm.ScanDirectory(context.Background(), dir); err != nil {
+				t.Fatalf("update_existing %v: ScanDirectory: %v", updateExisting, err)
+			}
+		}
+		if books, _ := dm.GetAllBooks(); len(books) != 1 {
+			t.Errorf("update_existing %v: %d books after scanning twice, want 1", updateExisting, len(books))
+		}
+
+		// Adding a single file that is already in the library is always refused
+		if _, err := dm.ScanFile(path); err != ErrDuplicatePath {
+			t.Errorf("update_existing %v: ScanFile err = %v, want %v", updateExisting, err, ErrDuplicatePath)
+		}
+	}
+}
+
+func TestSortAuthorKeepsInitials(t *testing.T) {
+	dm := newTestManager(t)
+	authors := map[string]string{
+		"/library/a.epub": "A J Smith",
+		"/library/b.epub": "The Beatles",
+		"/library/c.epub": "Jane Austen",
+	}
+	for path, author := range authors {
+		if err := dm.AddBook(models.BookRequest{Title: "Title", Author: author, FilePath: path, Format: "epub"}); err != nil {
+			t.Fatalf("AddBook: %v", err)
+		}
+	}
+	want := map[string]string{"A J Smith": "A J Smith", "The Beatles": "Beatles", "Jane Austen": "Jane Austen"}
+	check := func(when string) {
+		t.Helper()
+		books, err := dm.GetAllBooks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, book := range books {
+			if book.SortAuthor != want[book.Author] {
+				t.Errorf("%s: sort_author of %q = %q, want %q", when, book.Author, book.SortAuthor, want[book.Author])
+			}
+		}
+	}
+	check("added")
+
+	// A key stored by the old rule is corrected when the articles are loaded at startup
+	if _, err := dm.db.Exec("UPDATE books SET sort_author = 'J Smith' WHERE author = 'A J Smith'"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dm.SetLeadingArticles([]string{"the", "a", "an"}); err != nil {
+		t.Fatalf("SetLeadingArticles: %v", err)
+	}
+	check("refreshed")
+}
+
+// seriesBooks are books by one author in two series and none, added out of order
+var seriesBooks = []models.BookRequest{
+	{Title: "Zebra Stories", Author: "Terry Pratchett", FilePath: "/library/zebra.epub", Format: "epub"},
+	{Title: "Sourcery", Author: "Terry Pratchett", FilePath: "/library/sourcery.epub", Format: "epub", Series: "Discworld", SeriesIndex: 5},
+	{Title: "Truckers", Author: "Terry Pratchett", FilePath: "/library/truckers.epub", Format: "epub", Series: "Bromeliad", SeriesIndex: 1},
+	{Title: "Equal Rites", Author: "Terry Pratchett", FilePath: "/library/equal-rites.epub", Format: "epub", Series: "discworld", SeriesIndex: 3},
+	{Title: "The Carpet People", Author: "Terry Pratchett", FilePath: "/library/carpet.epub", Format: "epub"},
+	{Title: "The Colour of Magic", Author: "Terry Pratchett", FilePath: "/library/colour.epub", Format: "epub", Series: "Discworld", SeriesIndex: 1},
+	{Title: "Wings", Author: "Terry Pratchett", FilePath: "/library/wings.epub", Format: "epub", Series: "Bromeliad", SeriesIndex: 3},
+	{Title: "Mort", Author: "Terry Pratchett", FilePath: "/library/mort.epub", Format: "epub", Series: "Discworld", SeriesIndex: 4},
+	{Title: "Diggers", Author: "Terry Pratchett", FilePath: "/library/diggers.epub", Format: "epub", Series: "Bromeliad", SeriesIndex: 2},
+	{Title: "The Light Fantastic", Author: "Terry Pratchett", FilePath: "/library/light.epub", Format: "epub", Series: "Discworld", SeriesIndex: 2},
+	{Title: "Good Omens", Author: "Neil Gaiman", FilePath: "/library/omens.epub", Format: "epub"},
+}
+
+func TestGetBooksByAuthorSeriesOrder(t *testing.T) {
+	dm := newTestManager(t)
+	for _, book := range seriesBooks {
+		if err := dm.AddBook(book); err != nil {
+			t.Fatalf("AddBook(%s): %v", book.Title, err)
+		}
+	}
+
+	books, err := dm.GetBooksByAuthor("Terry Pratchett")
+	if err != nil {
+		t.Fatalf("GetBooksByAuthor: %v", err)
+	}
+	// Each series by position, series by name whatever their case, then the rest by title
+	want := []string{
+		"Truckers", "Diggers", "Wings",
+		"The Colour of Magic", "The Light Fantastic", "Equal Rites", "Mort", "Sourcery",
+		"The Carpet People", "Zebra Stories",
+	}
+	var got []string
+	for _, book := range books {
+		got = append(got, book.Title)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GetBooksByAuthor = %q, want %q", got, want)
+	}
+}
+
+func TestSetFilenamePattern(t *testing.T) {
+	dm := newTestManager(t)
+	if err := dm.AddBook(models.BookRequest{Title: "Frankenstein", Author: "Mary Shelley", FilePath: "/library/frankenstein.epub", Format: "epub"}); err != nil {
+		t.Fatalf("AddBook: %v", err)
+	}
+	dm.SetFilenamePattern(filemeta.AuthorTitle)
+
+	tests := []struct {
+		path, title, author string
+	}{
+		{"/import/Frank Herbert - Dune.epub", "Dune", "Frank Herbert"},
+		// An author already in the library on the other side swaps the parts
+		{"/import/The Last Man - Mary Shelley.epub", "The Last Man", "Mary Shelley"},
+	}
+	for _, tt := range tests {
+		if got := dm.Extractor().ExtractFromFilename(tt.path); got.Title != tt.title || got.Author != tt.author {
+			t.Errorf("ExtractFromFilename(%q) = %q by %q, want %q by %q", tt.path, got.Title, got.Author, tt.title, tt.author)
+		}
+	}
+
+	// Other managers keep the default pattern
+	if got := newTestManager(t).Extractor().ExtractFromFilename(tests[0].path); got.Title != "Frank Herbert" {
+		t.Errorf("another manager read %q by %q", got.Title, got.Author)
+	}
+}
@@ -0,0 +1,96 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestManager returns a manager of an empty in-memory library
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+	dm, err := NewManager(MemoryPath)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(func() { dm.Close() })
+	return dm
+}
+
+// copyFixture copies the sample EPUB into dir under name
+func copyFixture(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "data", "import", "pg84-images-3.epub"))
+	if err != nil {
+		t.Fatalf("reading sample EPUB: %v", err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestScanDirectoryCancelled(t *testing.T) {
+	dm := newTestManager(t)
+	dir := t.TempDir()
+	copyFixture(t, dir, "a.epub")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := dm.ScanDirectory(ctx, dir); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ScanDirectory err = %v, want %v", err, context.Canceled)
+	}
+	if books, _ := dm.GetAllBooks(); len(books) != 0 {
+		t.Errorf("cancelled scan added %d books", len(books))
+	}
+}
+
+func TestRescanDirectoryCancelledRemovesNothing(t *testing.T) {
+	dm := newTestManager(t)
+	dir := t.TempDir()
+	copyFixture(t, dir, "a.epub")
+	if err := dm.ScanDirectory(context.Background(), dir); err != nil {
+		t.Fatalf("ScanDirectory: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, removed, err := dm.RescanDirectory(ctx, dir); !errors.Is(err, context.Canceled) || removed != 0 {
+		t.Fatalf("RescanDirectory removed %d, err = %v, want 0 and %v", removed, err, context.Canceled)
+	}
+	if books, _ := dm.GetAllBooks(); len(books) != 1 {
+		t.Errorf("library has %d books after a cancelled rescan, want 1", len(books))
+	}
+}
+
+func TestScanDirectorySkipsIgnored(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.epub", ".hidden.epub", ".git/b.epub", "@eaDir/c.epub", "Shelf/@EADIR/d.epub", "Shelf/e.epub"} {
+		copyFixture(t, dir, name)
+	}
+
+	dm := newTestManager(t)
+	dm.SetIgnored(true, []string{"@eaDir"})
+	if err := dm.ScanDirectory(context.Background(), dir); err != nil {
+		t.Fatalf("ScanDirectory: %v", err)
+	}
+	books, _ := dm.GetAllBooks()
+	if len(books) != 2 {
+		t.Errorf("scan added %d books, want a.epub and Shelf/e.epub", len(books))
+	}
+
+	// Another manager without the setting finds every book
+	other := newTestManager(t)
+	if err := other.ScanDirectory(context.Background(), dir); err != nil {
+		t.Fatalf("ScanDirectory: %v", err)
+	}
+	if books, _ := other.GetAllBooks(); len(books) != 6 {
+		t.Errorf("scan without ignored names added %d books, want 6", len(books))
+	}
+}
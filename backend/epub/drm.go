@@ -0,0 +1,39 @@
+package epub
+
+import (
+	"archive/zip"
+)
+
+// HasDRM reports whether an EPUB is DRM-protected: it carries a
+// META-INF/rights.xml (Adobe ADEPT and similar schemes), or its
+// encryption.xml encrypts anything beyond obfuscated fonts.
+func HasDRM(files []*zip.File) bool {
+	for _, file := range files {
+		if file.Name == "META-INF/rights.xml" {
+			return true
+		}
+	}
+
+	encrypted, err := EncryptedResources(files)
+	if err != nil {
+		// An unreadable encryption.xml is treated as protected content
+		return true
+	}
+	for _, algorithm := range encrypted {
+		if !IsFontObfuscation(algorithm) {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectDRM opens an EPUB file and reports whether it is DRM-protected
+func DetectDRM(filePath string) (bool, error) {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+
+	return HasDRM(reader.File), nil
+}
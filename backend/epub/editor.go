@@ -7,6 +7,9 @@ import (
 	"io"
 	"os"
 	"strings"
+
+	"fableflow/backend/fsmode"
+	"fableflow/backend/xmlutil"
 )
 
 // EPUBEditor handles loading, editing, and saving EPUB files
@@ -14,6 +17,7 @@ type EPUBEditor struct {
 	filePath string
 	opfData  *OPFDocument
 	zipFiles map[string][]byte // Store all files from the EPUB
+	fileMode os.FileMode       // Permissions of the saved file; 0 uses fsmode.DefaultFileMode
 }
 
 // OPFDocument represents the structure of an EPUB OPF file
@@ -76,6 +80,11 @@ func NewEPUBEditor(filePath string) *EPUBEditor {
 	}
 }
 
+// SetFileMode sets the permissions the EPUB file is saved with
+func (e *EPUBEditor) SetFileMode(mode os.FileMode) {
+	e.fileMode = mode
+}
+
 // Load loads an existing EPUB file for editing
 func (e *EPUBEditor) Load() error {
 	// Open EPUB file (which is a ZIP archive)
@@ -153,14 +162,14 @@ func (e *EPUBEditor) findOPFFile() ([]byte, error) {
 // parseOPF parses the OPF XML content
 func (e *EPUBEditor) parseOPF(opfData []byte) (*OPFDocument, error) {
 	var opf OPFDocument
-	if err := xml.Unmarshal(opfData, &opf); err != nil {
+	if err := xmlutil.Unmarshal(opfData, &opf); err != nil {
 		return nil, fmt.Errorf("failed to parse OPF XML: %v", err)
 	}
 	return &opf, nil
 }
 
-// UpdateMetadata updates the metadata in the OPF document
-func (e *EPUBEditor) UpdateMetadata(title, author, isbn, publisher string) error {
+// UpdateMetadata updates the metadata in the OPF document. Empty values leave a field unchanged.
+func (e *EPUBEditor) UpdateMetadata(title, author, isbn, publisher, description string) error {
 	if e.opfData == nil {
 		return fmt.Errorf("no OPF data loaded")
 	}
@@ -192,6 +201,15 @@ func (e *EPUBEditor) UpdateMetadata(title, author, isbn, publisher string) error
 		}
 	}
 
+	// Update description
+	if description != "" {
+		if len(e.opfData.Metadata.Description) == 0 {
+			e.opfData.Metadata.Description = []DCElement{{Value: description}}
+		} else {
+			e.opfData.Metadata.Description[0].Value = description
+		}
+	}
+
 	// Update ISBN (identifier)
 	if isbn != "" {
 		// Find existing ISBN identifier
@@ -217,6 +235,24 @@ func (e *EPUBEditor) UpdateMetadata(title, author, isbn, publisher string) error
 	return nil
 }
 
+// UpdateDate sets the publication date (dc:date) in the OPF document.
+// An empty date removes it.
+func (e *EPUBEditor) UpdateDate(date string) error {
+	if e.opfData == nil {
+		return fmt.Errorf("no OPF data loaded")
+	}
+
+	if date == "" {
+		e.opfData.Metadata.Date = nil
+	} else if len(e.opfData.Metadata.Date) == 0 {
+		e.opfData.Metadata.Date = []DCElement{{Value: date}}
+	} else {
+		e.opfData.Metadata.Date[0].Value = date
+	}
+
+	return nil
+}
+
 // Save saves the modified EPUB file
 func (e *EPUBEditor) Save() error {
 	if e.opfData == nil {
@@ -256,7 +292,7 @@ func (e *EPUBEditor) Save() error {
 // writeEPUB writes the EPUB file with all stored files
 func (e *EPUBEditor) writeEPUB() error {
 	// Create new EPUB file
-	file, err := os.Create(e.filePath)
+	file, err := fsmode.Create(e.filePath, e.fileMode)
 	if err != nil {
 		return fmt.Errorf("failed to create EPUB file: %v", err)
 	}
@@ -312,3 +348,11 @@ func (e *EPUBEditor) GetCurrentMetadata() (title, author, isbn, publisher string
 
 	return title, author, isbn, publisher
 }
+
+// GetCurrentDate returns the publication date (dc:date) from the EPUB
+func (e *EPUBEditor) GetCurrentDate() string {
+	if e.opfData == nil || len(e.opfData.Metadata.Date) == 0 {
+		return ""
+	}
+	return e.opfData.Metadata.Date[0].Value
+}
@@ -0,0 +1,152 @@
+package epub
+
+import (
+	"archive/zip"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"fableflow/backend/xmlutil"
+)
+
+// Font obfuscation algorithms that may appear in META-INF/encryption.xml
+const (
+	IDPFFontObfuscation  = "http://www.idpf.org/2008/embedding"
+	AdobeFontObfuscation = "http://ns.adobe.com/pdf/enc#RC"
+)
+
+// encryptionDocument represents META-INF/encryption.xml
+type encryptionDocument struct {
+	EncryptedData []struct {
+		EncryptionMethod struct {
+			Algorithm string `xml:"Algorithm,attr"`
+		} `xml:"EncryptionMethod"`
+		CipherData struct {
+			CipherReference struct {
+				URI string `xml:"URI,attr"`
+			} `xml:"CipherReference"`
+		} `xml:"CipherData"`
+	} `xml:"EncryptedData"`
+}
+
+// EncryptedResources reads META-INF/encryption.xml and returns the encryption
+// algorithm for each listed archive path. A book without encryption.xml returns an empty map.
+func EncryptedResources(files []*zip.File) (map[string]string, error) {
+	resources := make(map[string]string)
+
+	var encryptionFile *zip.File
+	for _, file := range files {
+		if file.Name == "META-INF/encryption.xml" {
+			encryptionFile = file
+			break
+		}
+	}
+	if encryptionFile == nil {
+		return resources, nil
+	}
+
+	data, err := readZipFile(encryptionFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption.xml: %v", err)
+	}
+	var doc encryptionDocument
+	if err := xmlutil.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse encryption.xml: %v", err)
+	}
+
+	for _, entry := range doc.EncryptedData {
+		uri := entry.CipherData.CipherReference.URI
+		if uri == "" {
+			continue
+		}
+		// URIs are relative to the container root
+		resources[resolveHref(".", uri)] = entry.EncryptionMethod.Algorithm
+	}
+	return resources, nil
+}
+
+// IsFontObfuscation reports whether an encryption algorithm is one of the reversible font obfuscations
+func IsFontObfuscation(algorithm string) bool {
+	return algorithm == IDPFFontObfuscation || algorithm == AdobeFontObfuscation
+}
+
+// PackageUniqueIdentifier returns the value of the dc:identifier referenced by the
+// OPF package's unique-identifier attribute, which keys font obfuscation
+func PackageUniqueIdentifier(files []*zip.File) (string, error) {
+	fileMap := make(map[string]*zip.File, len(files))
+	for _, file := range files {
+		fileMap[file.Name] = file
+	}
+
+	opfPath, err := opfPathFromContainer(fileMap)
+	if err != nil {
+		return "", err
+	}
+	opfFile, exists := fileMap[opfPath]
+	if !exists {
+		return "", fmt.Errorf("OPF file not found: %s", opfPath)
+	}
+	data, err := readZipFile(opfFile)
+	if err != nil {
+		return "", err
+	}
+
+	var opf struct {
+		UniqueIdentifier string `xml:"unique-identifier,attr"`
+		Identifiers      []struct {
+			ID    string `xml:"id,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"metadata>identifier"`
+	}
+	if err := xmlutil.Unmarshal(data, &opf); err != nil {
+		return "", fmt.Errorf("failed to parse OPF XML: %v", err)
+	}
+
+	for _, identifier := range opf.Identifiers {
+		if identifier.ID == opf.UniqueIdentifier {
+			return strings.TrimSpace(identifier.Value), nil
+		}
+	}
+	return "", fmt.Errorf("unique identifier %q not found in OPF", opf.UniqueIdentifier)
+}
+
+// DeobfuscateFont reverses IDPF or Adobe font obfuscation of an embedded font.
+// The data is modified in place and returned.
+func DeobfuscateFont(data []byte, algorithm, uniqueID string) ([]byte, error) {
+	var key []byte
+	var length int
+
+	switch algorithm {
+	case IDPFFontObfuscation:
+		// SHA-1 of the identifier with all whitespace removed, applied to the first 1040 bytes
+		cleaned := strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+				return -1
+			}
+			return r
+		}, uniqueID)
+		sum := sha1.Sum([]byte(cleaned))
+		key = sum[:]
+		length = 1040
+	case AdobeFontObfuscation:
+		// The 16 bytes of the identifier's UUID, applied to the first 1024 bytes
+		uuid := strings.TrimPrefix(strings.ToLower(uniqueID), "urn:uuid:")
+		decoded, err := hex.DecodeString(strings.ReplaceAll(uuid, "-", ""))
+		if err != nil || len(decoded) != 16 {
+			return nil, fmt.Errorf("identifier %q is not a UUID", uniqueID)
+		}
+		key = decoded
+		length = 1024
+	default:
+		return nil, fmt.Errorf("unsupported font obfuscation algorithm: %s", algorithm)
+	}
+
+	if length > len(data) {
+		length = len(data)
+	}
+	for i := 0; i < length; i++ {
+		data[i] ^= key[i%len(key)]
+	}
+	return data, nil
+}
@@ -0,0 +1,139 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/image/font/gofont/gomono"
+)
+
+// fontBookID is the unique identifier of the test book, spread over lines the way
+// some tools write it; IDPF obfuscation keys on it without the whitespace
+const fontBookID = "\n\t\turn:uuid:0a9f1c3e-5b7d-4e2f-8a6c-1d3b5f7a9c2e\n\t"
+
+// writeFontEPUB writes an EPUB whose two embedded fonts are obfuscated, one with each
+// algorithm, and returns its path
+func writeFontEPUB(t *testing.T) string {
+	t.Helper()
+	idpfKey := sha1.Sum([]byte("urn:uuid:0a9f1c3e-5b7d-4e2f-8a6c-1d3b5f7a9c2e"))
+	adobeKey := []byte{0x0a, 0x9f, 0x1c, 0x3e, 0x5b, 0x7d, 0x4e, 0x2f, 0x8a, 0x6c, 0x1d, 0x3b, 0x5f, 0x7a, 0x9c, 0x2e}
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"mimetype", []byte("application/epub+zip")},
+		{"META-INF/container.xml", []byte(`<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`)},
+		{"META-INF/encryption.xml", []byte(`<?xml version="1.0"?>
+<encryption xmlns="urn:oasis:names:tc:opendocument:xmlns:container" xmlns:enc="http://www.w3.org/2001/04/xmlenc#">
+  <enc:EncryptedData>
+    <enc:EncryptionMethod Algorithm="http://www.idpf.org/2008/embedding"/>
+    <enc:CipherData><enc:CipherReference URI="OEBPS/fonts/idpf.ttf"/></enc:CipherData>
+  </enc:EncryptedData>
+  <enc:EncryptedData>
+    <enc:EncryptionMethod Algorithm="http://ns.adobe.com/pdf/enc#RC"/>
+    <enc:CipherData><enc:CipherReference URI="OEBPS/fonts/adobe.ttf"/></enc:CipherData>
+  </enc:EncryptedData>
+</encryption>`)},
+		{"OEBPS/content.opf", []byte(`<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="isbn">9780000000000</dc:identifier>
+    <dc:identifier id="book-id">` + fontBookID + `</dc:identifier>
+    <dc:title>Fonts</dc:title>
+  </metadata>
+  <manifest>
+    <item id="idpf" href="fonts/idpf.ttf" media-type="font/ttf"/>
+    <item id="adobe" href="fonts/adobe.ttf" media-type="font/ttf"/>
+  </manifest>
+</package>`)},
+		{"OEBPS/fonts/idpf.ttf", xorPrefix(gomono.TTF, idpfKey[:], 1040)},
+		{"OEBPS/fonts/adobe.ttf", xorPrefix(gomono.TTF, adobeKey, 1024)},
+	}
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, file := range files {
+		w, err := writer.Create(file.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(file.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "fonts.epub")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// xorPrefix returns a copy of data with its first length bytes XORed with key, the
+// way both obfuscation algorithms work
+func xorPrefix(data, key []byte, length int) []byte {
+	obfuscated := append([]byte{}, data...)
+	for i := 0; i < length; i++ {
+		obfuscated[i] ^= key[i%len(key)]
+	}
+	return obfuscated
+}
+
+func TestDeobfuscateFonts(t *testing.T) {
+	reader, err := zip.OpenReader(writeFontEPUB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	encrypted, err := EncryptedResources(reader.File)
+	if err != nil {
+		t.Fatalf("EncryptedResources: %v", err)
+	}
+	if len(encrypted) != 2 || encrypted["OEBPS/fonts/idpf.ttf"] != IDPFFontObfuscation || encrypted["OEBPS/fonts/adobe.ttf"] != AdobeFontObfuscation {
+		t.Fatalf("EncryptedResources = %v, want both fonts with their algorithms", encrypted)
+	}
+	uniqueID, err := PackageUniqueIdentifier(reader.File)
+	if err != nil {
+		t.Fatalf("PackageUniqueIdentifier: %v", err)
+	}
+	if HasDRM(reader.File) {
+		t.Error("HasDRM = true for a book with only obfuscated fonts")
+	}
+
+	for _, file := range reader.File {
+		algorithm, ok := encrypted[file.Name]
+		if !ok {
+			continue
+		}
+		data, err := readZipFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Equal(data, gomono.TTF) {
+			t.Fatalf("%s is not obfuscated", file.Name)
+		}
+		font, err := DeobfuscateFont(data, algorithm, uniqueID)
+		if err != nil {
+			t.Fatalf("DeobfuscateFont(%s): %v", file.Name, err)
+		}
+		if !bytes.Equal(font, gomono.TTF) {
+			t.Errorf("%s: deobfuscated font differs from the original", file.Name)
+		}
+	}
+
+	// Adobe obfuscation needs a UUID to key on
+	if _, err := DeobfuscateFont([]byte("font"), AdobeFontObfuscation, "9780000000000"); err == nil {
+		t.Error("DeobfuscateFont accepted an Adobe font keyed on an ISBN")
+	}
+}
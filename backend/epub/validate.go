@@ -0,0 +1,341 @@
+package epub
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"fableflow/backend/xmlutil"
+)
+
+// Check statuses used in a ValidationReport
+const (
+	CheckOK      = "ok"
+	CheckWarning = "warning"
+	CheckError   = "error"
+)
+
+// ValidationCheck is the result of a single structural check
+type ValidationCheck struct {
+	Name    string   `json:"name"`
+	Status  string   `json:"status"`
+	Message string   `json:"message"`
+	Details []string `json:"details,omitempty"`
+}
+
+// ValidationReport describes the structural health of an EPUB file.
+// The book is valid when no check has an error status; warnings are
+// problems most readers tolerate.
+type ValidationReport struct {
+	Valid   bool              `json:"valid"`
+	OPFPath string            `json:"opf_path,omitempty"`
+	Checks  []ValidationCheck `json:"checks"`
+}
+
+// validationOPF holds the parts of the OPF needed for validation
+type validationOPF struct {
+	XMLName  xml.Name `xml:"package"`
+	Metadata struct {
+		Meta []struct {
+			Name    string `xml:"name,attr"`
+			Content string `xml:"content,attr"`
+		} `xml:"meta"`
+	} `xml:"metadata"`
+	Manifest struct {
+		Items []struct {
+			ID         string `xml:"id,attr"`
+			Href       string `xml:"href,attr"`
+			MediaType  string `xml:"media-type,attr"`
+			Properties string `xml:"properties,attr"`
+		} `xml:"item"`
+	} `xml:"manifest"`
+	Spine struct {
+		ItemRefs []ItemRef `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+// Validate inspects an EPUB and reports on its mimetype, container.xml,
+// OPF, spine, manifest and cover. An error is returned only when the file
+// cannot be opened as a ZIP archive at all.
+func Validate(filePath string) (*ValidationReport, error) {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open EPUB file: %v", err)
+	}
+	defer reader.Close()
+
+	files := make(map[string]*zip.File, len(reader.File))
+	for _, file := range reader.File {
+		files[file.Name] = file
+	}
+
+	report := &ValidationReport{}
+	report.add(checkMimetype(reader.File))
+	report.add(checkDRM(reader.File))
+
+	// Locate the OPF through container.xml
+	containerCheck, opfPath := checkContainer(files)
+	report.add(containerCheck)
+	if opfPath == "" {
+		return report.finish(), nil
+	}
+	report.OPFPath = opfPath
+
+	opfFile, exists := files[opfPath]
+	if !exists {
+		report.add(ValidationCheck{Name: "opf", Status: CheckError, Message: fmt.Sprintf("OPF file %s referenced by container.xml does not exist", opfPath)})
+		return report.finish(), nil
+	}
+	opfData, err := readZipFile(opfFile)
+	if err != nil {
+		report.add(ValidationCheck{Name: "opf", Status: CheckError, Message: fmt.Sprintf("Failed to read OPF file: %v", err)})
+		return report.finish(), nil
+	}
+	var opf validationOPF
+	if err := xmlutil.Unmarshal(opfData, &opf); err != nil {
+		report.add(ValidationCheck{Name: "opf", Status: CheckError, Message: fmt.Sprintf("Failed to parse OPF file: %v", err)})
+		return report.finish(), nil
+	}
+	report.add(ValidationCheck{Name: "opf", Status: CheckOK, Message: fmt.Sprintf("Found OPF at %s", opfPath)})
+
+	// Resolve manifest hrefs relative to the OPF
+	opfDir := path.Dir(opfPath)
+	manifestPaths := make(map[string]string, len(opf.Manifest.Items))
+	for _, item := range opf.Manifest.Items {
+		manifestPaths[item.ID] = resolveHref(opfDir, item.Href)
+	}
+
+	report.add(checkSpine(opf, manifestPaths, files))
+	report.add(checkManifest(opf, manifestPaths, files))
+	report.add(checkCover(opf, manifestPaths, files))
+
+	return report.finish(), nil
+}
+
+// add appends a check to the report
+func (r *ValidationReport) add(check ValidationCheck) {
+	r.Checks = append(r.Checks, check)
+}
+
+// finish computes the overall validity from the individual checks
+func (r *ValidationReport) finish() *ValidationReport {
+	r.Valid = true
+	for _, check := range r.Checks {
+		if check.Status == CheckError {
+			r.Valid = false
+			break
+		}
+	}
+	return r
+}
+
+// Errors returns the messages of all failed checks
+func (r *ValidationReport) Errors() []string {
+	var errors []string
+	for _, check := range r.Checks {
+		if check.Status == CheckError {
+			errors = append(errors, check.Message)
+		}
+	}
+	return errors
+}
+
+// checkMimetype verifies the mimetype entry is first, stored uncompressed and correct
+func checkMimetype(zipFiles []*zip.File) ValidationCheck {
+	check := ValidationCheck{Name: "mimetype"}
+
+	var mimetype *zip.File
+	for i, file := range zipFiles {
+		if file.Name == "mimetype" {
+			mimetype = file
+			if i != 0 {
+				check.Details = append(check.Details, "mimetype is not the first file in the archive")
+			}
+			break
+		}
+	}
+	if mimetype == nil {
+		check.Status = CheckWarning
+		check.Message = "mimetype file is missing"
+		return check
+	}
+
+	if mimetype.Method != zip.Store {
+		check.Details = append(check.Details, "mimetype is compressed")
+	}
+	data, err := readZipFile(mimetype)
+	if err != nil {
+		check.Status = CheckWarning
+		check.Message = fmt.Sprintf("Failed to read mimetype: %v", err)
+		return check
+	}
+	if content := string(bytes.TrimSpace(data)); content != "application/epub+zip" {
+		check.Details = append(check.Details, fmt.Sprintf("mimetype contains %q", content))
+	}
+
+	if len(check.Details) > 0 {
+		check.Status = CheckWarning
+		check.Message = "mimetype does not follow the EPUB specification"
+		return check
+	}
+	check.Status = CheckOK
+	check.Message = "mimetype is correct"
+	return check
+}
+
+// checkDRM flags DRM-protected books, which the reader and converter cannot open
+func checkDRM(zipFiles []*zip.File) ValidationCheck {
+	if HasDRM(zipFiles) {
+		return ValidationCheck{Name: "drm", Status: CheckWarning, Message: "Book is DRM-protected and cannot be read or converted"}
+	}
+	return ValidationCheck{Name: "drm", Status: CheckOK, Message: "No DRM detected"}
+}
+
+// checkContainer parses META-INF/container.xml and returns the OPF path it points to
+func checkContainer(files map[string]*zip.File) (ValidationCheck, string) {
+	opfPath, err := opfPathFromContainer(files)
+	if err != nil {
+		return ValidationCheck{Name: "container", Status: CheckError, Message: err.Error()}, ""
+	}
+	return ValidationCheck{Name: "container", Status: CheckOK, Message: "container.xml is valid"}, opfPath
+}
+
+// checkSpine verifies every spine itemref resolves to an existing manifest file
+func checkSpine(opf validationOPF, manifestPaths map[string]string, files map[string]*zip.File) ValidationCheck {
+	check := ValidationCheck{Name: "spine"}
+
+	if len(opf.Spine.ItemRefs) == 0 {
+		check.Status = CheckError
+		check.Message = "spine is empty"
+		return check
+	}
+
+	for _, ref := range opf.Spine.ItemRefs {
+		filePath, exists := manifestPaths[ref.IDRef]
+		if !exists {
+			check.Details = append(check.Details, fmt.Sprintf("itemref %q is not in the manifest", ref.IDRef))
+		} else if files[filePath] == nil {
+			check.Details = append(check.Details, fmt.Sprintf("itemref %q points to missing file %s", ref.IDRef, filePath))
+		}
+	}
+
+	if len(check.Details) > 0 {
+		check.Status = CheckError
+		check.Message = fmt.Sprintf("%d of %d spine items cannot be resolved", len(check.Details), len(opf.Spine.ItemRefs))
+		return check
+	}
+	check.Status = CheckOK
+	check.Message = fmt.Sprintf("All %d spine items resolve", len(opf.Spine.ItemRefs))
+	return check
+}
+
+// checkManifest reports manifest entries whose files are missing from the archive
+func checkManifest(opf validationOPF, manifestPaths map[string]string, files map[string]*zip.File) ValidationCheck {
+	check := ValidationCheck{Name: "manifest"}
+
+	for _, item := range opf.Manifest.Items {
+		if files[manifestPaths[item.ID]] == nil {
+			check.Details = append(check.Details, manifestPaths[item.ID])
+		}
+	}
+
+	if len(check.Details) > 0 {
+		check.Status = CheckWarning
+		check.Message = fmt.Sprintf("%d manifest files are missing", len(check.Details))
+		return check
+	}
+	check.Status = CheckOK
+	check.Message = fmt.Sprintf("All %d manifest files are present", len(opf.Manifest.Items))
+	return check
+}
+
+// checkCover looks for a cover image declared in the OPF
+func checkCover(opf validationOPF, manifestPaths map[string]string, files map[string]*zip.File) ValidationCheck {
+	check := ValidationCheck{Name: "cover", Status: CheckWarning}
+
+	// EPUB 3 cover-image property, then the EPUB 2 meta element
+	coverID := ""
+	for _, item := range opf.Manifest.Items {
+		if strings.Contains(" "+item.Properties+" ", " cover-image ") {
+			coverID = item.ID
+			break
+		}
+	}
+	if coverID == "" {
+		for _, meta := range opf.Metadata.Meta {
+			if meta.Name == "cover" {
+				coverID = meta.Content
+				break
+			}
+		}
+	}
+	if coverID == "" {
+		check.Message = "No cover image is declared"
+		return check
+	}
+
+	coverPath, exists := manifestPaths[coverID]
+	if !exists {
+		check.Message = fmt.Sprintf("Cover item %q is not in the manifest", coverID)
+		return check
+	}
+	if files[coverPath] == nil {
+		check.Message = fmt.Sprintf("Cover image %s is missing", coverPath)
+		return check
+	}
+
+	check.Status = CheckOK
+	check.Message = fmt.Sprintf("Cover image found at %s", coverPath)
+	return check
+}
+
+// resolveHref turns a manifest href into an archive path relative to the OPF directory
+func resolveHref(opfDir, href string) string {
+	if i := strings.IndexByte(href, '#'); i >= 0 {
+		href = href[:i]
+	}
+	if unescaped, err := url.PathUnescape(href); err == nil {
+		href = unescaped
+	}
+	return path.Join(opfDir, href)
+}
+
+// opfPathFromContainer returns the OPF path declared in META-INF/container.xml
+func opfPathFromContainer(files map[string]*zip.File) (string, error) {
+	containerFile, exists := files["META-INF/container.xml"]
+	if !exists {
+		return "", fmt.Errorf("META-INF/container.xml not found")
+	}
+	data, err := readZipFile(containerFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read container.xml: %v", err)
+	}
+
+	var container struct {
+		RootFiles []struct {
+			FullPath string `xml:"full-path,attr"`
+		} `xml:"rootfiles>rootfile"`
+	}
+	if err := xmlutil.Unmarshal(data, &container); err != nil {
+		return "", fmt.Errorf("failed to parse container.xml: %v", err)
+	}
+	if len(container.RootFiles) == 0 || container.RootFiles[0].FullPath == "" {
+		return "", fmt.Errorf("container.xml has no rootfile")
+	}
+	return path.Clean(container.RootFiles[0].FullPath), nil
+}
+
+// readZipFile reads the full content of a ZIP entry
+func readZipFile(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
@@ -0,0 +1,63 @@
+// Package filemeta parses a book's title and author out of its file name, for books
+// whose embedded metadata is missing or unreadable.
+package filemeta
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// File name conventions (library.filename_pattern)
+const (
+	TitleAuthor = "title_author" // "Title - Author", as written by the default path template
+	AuthorTitle = "author_title" // "Author - Title"
+)
+
+// separator splits the title from the author
+const separator = " - "
+
+// idSuffixPattern matches a numeric ID appended to the name, e.g. "_1234"
+var idSuffixPattern = regexp.MustCompile(`_\d+$`)
+
+// ValidPattern reports whether p is a supported file name convention
+func ValidPattern(p string) bool {
+	return p == TitleAuthor || p == AuthorTitle
+}
+
+// Parser splits file names following one convention. The zero value expects
+// "Title - Author" names and recognises no authors.
+type Parser struct {
+	Pattern     string                 // TitleAuthor or AuthorTitle (library.filename_pattern)
+	KnownAuthor func(name string) bool // Recognises an author on the "wrong" side of the name; nil disables the check
+}
+
+// Parse splits a file name into title and author following the parser's pattern.
+// If the side the pattern assigns to the author is not a known author but the other
+// side is, the parts are taken to be swapped. The author is empty when the name has no
+// " - " separator; the title then is the whole name.
+func (p Parser) Parse(filePath string) (title, author string) {
+	name := filepath.Base(filePath)
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	if !strings.Contains(name, separator) {
+		return strings.TrimSpace(name), ""
+	}
+	name = idSuffixPattern.ReplaceAllString(name, "")
+
+	// The author rarely contains the separator while titles with subtitles often do,
+	// so the author is the part before the first or after the last one
+	first := strings.SplitN(name, separator, 2)
+	last := strings.LastIndex(name, separator)
+	titleFirst := [2]string{strings.TrimSpace(name[:last]), strings.TrimSpace(name[last+len(separator):])}
+	authorFirst := [2]string{strings.TrimSpace(first[1]), strings.TrimSpace(first[0])}
+
+	preferred, swapped := titleFirst, authorFirst
+	if p.Pattern == AuthorTitle {
+		preferred, swapped = authorFirst, titleFirst
+	}
+	if p.KnownAuthor != nil && !p.KnownAuthor(preferred[1]) && p.KnownAuthor(swapped[1]) {
+		preferred = swapped
+	}
+
+	return preferred[0], preferred[1]
+}
@@ -0,0 +1,58 @@
+package filemeta
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		title, author string
+	}{
+		{TitleAuthor, "/library/Frankenstein - Mary Shelley.epub", "Frankenstein", "Mary Shelley"},
+		{TitleAuthor, "Dune - Messiah - Frank Herbert.epub", "Dune - Messiah", "Frank Herbert"},
+		{TitleAuthor, "Frankenstein - Mary Shelley_1234.epub", "Frankenstein", "Mary Shelley"},
+		{AuthorTitle, "/library/Mary Shelley - Frankenstein.epub", "Frankenstein", "Mary Shelley"},
+		{AuthorTitle, "Frank Herbert - Dune - Messiah.epub", "Dune - Messiah", "Frank Herbert"},
+		{AuthorTitle, "Mary Shelley - Frankenstein_1234.epub", "Frankenstein", "Mary Shelley"},
+		{TitleAuthor, "Frankenstein.epub", "Frankenstein", ""},
+		{AuthorTitle, "Frankenstein_1234.epub", "Frankenstein_1234", ""},
+	}
+	for _, tt := range tests {
+		if title, author := (Parser{Pattern: tt.pattern}).Parse(tt.path); title != tt.title || author != tt.author {
+			t.Errorf("%s: Parse(%q) = %q, %q; want %q, %q", tt.pattern, tt.path, title, author, tt.title, tt.author)
+		}
+	}
+}
+
+func TestParseKnownAuthor(t *testing.T) {
+	known := func(name string) bool { return name == "Mary Shelley" }
+	tests := []struct {
+		pattern, path string
+		title, author string
+	}{
+		// A known author on the other side swaps the parts
+		{TitleAuthor, "Mary Shelley - Frankenstein.epub", "Frankenstein", "Mary Shelley"},
+		{AuthorTitle, "Frankenstein - Mary Shelley.epub", "Frankenstein", "Mary Shelley"},
+		// Already in order
+		{TitleAuthor, "Frankenstein - Mary Shelley.epub", "Frankenstein", "Mary Shelley"},
+		{AuthorTitle, "Mary Shelley - Frankenstein.epub", "Frankenstein", "Mary Shelley"},
+		// Without a known author the pattern decides
+		{TitleAuthor, "Frank Herbert - Dune.epub", "Frank Herbert", "Dune"},
+		{AuthorTitle, "Dune - Frank Herbert.epub", "Frank Herbert", "Dune"},
+	}
+	for _, tt := range tests {
+		if title, author := (Parser{Pattern: tt.pattern, KnownAuthor: known}).Parse(tt.path); title != tt.title || author != tt.author {
+			t.Errorf("%s: Parse(%q) = %q, %q; want %q, %q", tt.pattern, tt.path, title, author, tt.title, tt.author)
+		}
+	}
+}
+
+func TestValidPattern(t *testing.T) {
+	for _, p := range []string{TitleAuthor, AuthorTitle} {
+		if !ValidPattern(p) {
+			t.Errorf("ValidPattern(%q) = false", p)
+		}
+	}
+	if ValidPattern("title-author") {
+		t.Error("ValidPattern accepted an unknown pattern")
+	}
+}
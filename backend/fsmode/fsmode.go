@@ -0,0 +1,78 @@
+// Package fsmode creates directories and files with configured permissions.
+package fsmode
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Permissions used when none are configured. They match what os.MkdirAll(path, 0755)
+// and os.Create produce under the usual 022 umask.
+const (
+	DefaultDirMode  os.FileMode = 0755
+	DefaultFileMode os.FileMode = 0644
+)
+
+// Parse reads an octal permission string such as "0775" or "664". An empty string
+// returns fallback.
+func Parse(mode string, fallback os.FileMode) (os.FileMode, error) {
+	if mode == "" {
+		return fallback, nil
+	}
+	value, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || value > 0777 {
+		return 0, fmt.Errorf("invalid permission mode %q: expected an octal value like 0755", mode)
+	}
+	return os.FileMode(value), nil
+}
+
+// MkdirAll creates a directory and any missing parents. Every directory it creates is
+// given mode exactly, regardless of the process umask; existing ones are left alone.
+// A zero mode means DefaultDirMode.
+func MkdirAll(path string, mode os.FileMode) error {
+	if mode == 0 {
+		mode = DefaultDirMode
+	}
+
+	// Collect the directories that don't exist yet, deepest first
+	var missing []string
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		missing = append(missing, dir)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+
+	if err := os.MkdirAll(path, mode); err != nil {
+		return err
+	}
+	for _, dir := range missing {
+		if err := os.Chmod(dir, mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Create creates or truncates a file for writing, like os.Create, and gives it mode
+// exactly, regardless of the process umask. A zero mode means DefaultFileMode.
+func Create(path string, mode os.FileMode) (*os.File, error) {
+	if mode == 0 {
+		mode = DefaultFileMode
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return nil, err
+	}
+	if err := file.Chmod(mode); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
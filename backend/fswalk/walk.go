@@ -0,0 +1,147 @@
+// Package fswalk walks directory trees, optionally descending into symlinked directories.
+package fswalk
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// Options configures Walk
+type Options struct {
+	FollowSymlinks bool     // Descend into symlinked directories
+	SkipHidden     bool     // Leave out names starting with "." (such as .git, .DS_Store or macOS "._" resource forks)
+	IgnoreNames    []string // Leave out these names, compared case-insensitively (such as NAS metadata directories like @eaDir)
+}
+
+// Ignored reports whether Walk leaves out an entry with this base name. Ignored
+// directories are not descended into.
+func (o Options) Ignored(name string) bool {
+	if o.SkipHidden && strings.HasPrefix(name, ".") && name != "." && name != ".." {
+		return true
+	}
+	for _, ignored := range o.IgnoreNames {
+		if strings.EqualFold(name, ignored) {
+			return true
+		}
+	}
+	return false
+}
+
+// Walk walks the file tree rooted at root, calling fn for each file or directory, like
+// filepath.Walk. Without opts.FollowSymlinks it is exactly filepath.Walk.
+//
+// With opts.FollowSymlinks, symbolic links are resolved: linked files are reported with
+// the info of their target and linked directories are descended into, with paths reported
+// below the link. Every directory is visited at most once (tracked by device and inode),
+// so symlink cycles terminate and a directory reachable through several links is only
+// walked the first time it is seen.
+//
+// Entries below root that opts ignores are never passed to fn.
+func Walk(root string, opts Options, fn filepath.WalkFunc) error {
+	fn = skipIgnored(root, opts, fn)
+	if !opts.FollowSymlinks {
+		return filepath.Walk(root, fn)
+	}
+
+	w := &walker{fn: fn, visited: make(map[inode]bool)}
+	info, err := os.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = w.walk(root, info)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// skipIgnored wraps fn so ignored entries below root are passed over, and ignored
+// directories skipped as a whole
+func skipIgnored(root string, opts Options, fn filepath.WalkFunc) filepath.WalkFunc {
+	return func(path string, info os.FileInfo, err error) error {
+		if path != root && opts.Ignored(filepath.Base(path)) {
+			if info != nil && info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		return fn(path, info, err)
+	}
+}
+
+// inode identifies a directory independently of the path it was reached through
+type inode struct {
+	dev uint64
+	ino uint64
+}
+
+type walker struct {
+	fn      filepath.WalkFunc
+	visited map[inode]bool
+}
+
+// walk visits path and, for directories, everything below it. It follows the
+// filepath.Walk conventions for SkipDir and errors.
+func (w *walker) walk(path string, info os.FileInfo) error {
+	if !info.IsDir() {
+		return w.fn(path, info, nil)
+	}
+
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		id := inode{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+		if w.visited[id] {
+			return nil // Already walked through another path (or a cycle)
+		}
+		w.visited[id] = true
+	}
+
+	if err := w.fn(path, info, nil); err != nil {
+		return err
+	}
+
+	names, err := readDirNames(path)
+	if err != nil {
+		return w.fn(path, info, err)
+	}
+
+	for _, name := range names {
+		child := filepath.Join(path, name)
+		childInfo, err := os.Stat(child)
+		if err != nil {
+			// Broken links are reported as the link itself, like filepath.Walk does
+			childInfo, err = os.Lstat(child)
+		}
+		if err != nil {
+			if err := w.fn(child, nil, err); err != nil && err != filepath.SkipDir {
+				return err
+			}
+			continue
+		}
+		if err := w.walk(child, childInfo); err != nil {
+			if !childInfo.IsDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// readDirNames returns the sorted entry names of a directory
+func readDirNames(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
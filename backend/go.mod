@@ -1,8 +1,12 @@
 module fableflow/backend
 
-go 1.21
+go 1.23
 
 require (
+	github.com/go-chi/chi/v5 v5.3.2
 	github.com/mattn/go-sqlite3 v1.14.32
+	golang.org/x/image v0.24.0
+	golang.org/x/net v0.35.0
+	golang.org/x/text v0.22.0
 	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// hasAPIKey reports whether a request carries the server.api_key: in an X-API-Key
+// header, an api_key query parameter, or as the basic auth password, which is what
+// OPDS readers can send. Every request passes when no key is configured.
+func hasAPIKey(r *http.Request, key string) bool {
+	if key == "" {
+		return true
+	}
+	candidates := []string{r.Header.Get("X-API-Key"), r.URL.Query().Get("api_key")}
+	if _, password, ok := r.BasicAuth(); ok {
+		candidates = append(candidates, password)
+	}
+	for _, candidate := range candidates {
+		if candidate != "" && subtle.ConstantTimeCompare([]byte(candidate), []byte(key)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// requireAPIKey reports whether a request carries the server.api_key, responding with
+// 401 when it does not
+func requireAPIKey(w http.ResponseWriter, r *http.Request, key string) bool {
+	if hasAPIKey(r, key) {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", `Basic realm="fableflow"`)
+	http.Error(w, "API key required", http.StatusUnauthorized)
+	return false
+}
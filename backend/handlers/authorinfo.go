@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"fableflow/backend/models"
+)
+
+// How long Open Library author lookups are cached. Authors Open Library doesn't know
+// are retried sooner, in case the name was added or corrected there.
+const (
+	authorInfoTTL     = 24 * time.Hour
+	authorInfoMissTTL = time.Hour
+)
+
+// GetAuthorInfo returns an author's bio, photo and works count from Open Library
+// (GET /api/authors/info?author=). Unknown authors get found=false rather than an error.
+func (h *BooksHandler) GetAuthorInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	author := strings.TrimSpace(r.URL.Query().Get("author"))
+	if author == "" {
+		http.Error(w, "Author parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	info, ok := h.cachedAuthorInfo(author)
+	if !ok {
+		var err error
+		info, err = fetchOpenLibraryAuthor(author)
+		if err != nil {
+			log.Printf("Author lookup failed for %q: %v", author, err)
+			http.Error(w, fmt.Sprintf("Failed to look up author: %v", err), http.StatusBadGateway)
+			return
+		}
+		h.storeAuthorInfo(info)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, info)
+}
+
+// authorInfoKey normalizes an author name for the lookup cache
+func authorInfoKey(author string) string {
+	return strings.ToLower(strings.Join(strings.Fields(author), " "))
+}
+
+// cachedAuthorInfo returns an unexpired cached lookup for an author
+func (h *BooksHandler) cachedAuthorInfo(author string) (models.AuthorInfo, bool) {
+	h.authorInfoMutex.Lock()
+	defer h.authorInfoMutex.Unlock()
+
+	info, ok := h.authorInfo[authorInfoKey(author)]
+	if !ok || authorInfoExpired(info) {
+		return models.AuthorInfo{}, false
+	}
+	return info, true
+}
+
+// storeAuthorInfo caches a lookup, dropping any expired entries
+func (h *BooksHandler) storeAuthorInfo(info models.AuthorInfo) {
+	h.authorInfoMutex.Lock()
+	defer h.authorInfoMutex.Unlock()
+
+	for key, cached := range h.authorInfo {
+		if authorInfoExpired(cached) {
+			delete(h.authorInfo, key)
+		}
+	}
+	h.authorInfo[authorInfoKey(info.Author)] = info
+}
+
+func authorInfoExpired(info models.AuthorInfo) bool {
+	ttl := authorInfoTTL
+	if !info.Found {
+		ttl = authorInfoMissTTL
+	}
+	return time.Since(info.FetchedAt) > ttl
+}
+
+// fetchOpenLibraryAuthor finds the best matching author in Open Library's author search
+// and fetches their bio and photos from the author record
+func fetchOpenLibraryAuthor(author string) (models.AuthorInfo, error) {
+	info := models.AuthorInfo{Author: author, Source: "Open Library", FetchedAt: time.Now()}
+
+	var search struct {
+		Docs []struct {
+			Key       string `json:"key"`
+			Name      string `json:"name"`
+			BirthDate string `json:"birth_date"`
+			DeathDate string `json:"death_date"`
+			TopWork   string `json:"top_work"`
+			WorkCount int    `json:"work_count"`
+		} `json:"docs"`
+	}
+	searchURL := "https://openlibrary.org/search/authors.json?q=" + url.QueryEscape(author)
+	if err := getOpenLibraryJSON(searchURL, &search); err != nil {
+		return info, err
+	}
+
+	// Results are ordered by relevance; prefer an exact name match with the most works
+	best := -1
+	for i, doc := range search.Docs {
+		if authorInfoKey(doc.Name) != authorInfoKey(author) {
+			continue
+		}
+		if best < 0 || doc.WorkCount > search.Docs[best].WorkCount {
+			best = i
+		}
+	}
+	if best < 0 && len(search.Docs) > 0 {
+		best = 0
+	}
+	if best < 0 {
+		return info, nil
+	}
+
+	doc := search.Docs[best]
+	info.Found = true
+	info.Key = doc.Key
+	info.Name = doc.Name
+	info.BirthDate = doc.BirthDate
+	info.DeathDate = doc.DeathDate
+	info.TopWork = doc.TopWork
+	info.WorksCount = doc.WorkCount
+
+	var record struct {
+		Bio    json.RawMessage `json:"bio"`
+		Photos []int           `json:"photos"`
+	}
+	if err := getOpenLibraryJSON("https://openlibrary.org/authors/"+url.PathEscape(doc.Key)+".json", &record); err != nil {
+		// The search result alone is still worth returning
+		log.Printf("Failed to fetch Open Library author %s: %v", doc.Key, err)
+		return info, nil
+	}
+	info.Bio = openLibraryText(record.Bio)
+	for _, photoID := range record.Photos {
+		// Negative IDs mark deleted images
+		if photoID > 0 {
+			info.PhotoURL = fmt.Sprintf("https://covers.openlibrary.org/a/id/%d-L.jpg", photoID)
+			break
+		}
+	}
+
+	return info, nil
+}
+
+// getOpenLibraryJSON sends a rate-limited GET request to Open Library and decodes the response
+func getOpenLibraryJSON(requestURL string, v interface{}) error {
+	openLibraryLimiter.Wait()
+	resp, err := coverHTTPClient.Get(requestURL)
+	if err != nil {
+		return fmt.Errorf("failed to query Open Library: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Open Library returned status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to parse Open Library response: %v", err)
+	}
+	return nil
+}
+
+// openLibraryText reads an Open Library text field, which is either a plain string
+// or an object like {"type": "/type/text", "value": "..."}
+func openLibraryText(raw json.RawMessage) string {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return strings.TrimSpace(text)
+	}
+	var typed struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal(raw, &typed); err == nil {
+		return strings.TrimSpace(typed.Value)
+	}
+	return ""
+}
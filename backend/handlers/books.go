@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"archive/zip"
+	"bytes"
+	"database/sql"
 	"encoding/json"
-	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -16,97 +18,245 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"fableflow/backend/config"
+	"fableflow/backend/conversion"
 	"fableflow/backend/database"
 	"fableflow/backend/epub"
+	"fableflow/backend/fsmode"
+	"fableflow/backend/fswalk"
+	"fableflow/backend/importservice"
+	"fableflow/backend/jobs"
+	"fableflow/backend/metadata"
 	"fableflow/backend/models"
 )
 
 // BooksHandler handles book-related HTTP requests
 type BooksHandler struct {
 	db     *database.Manager
+	jobs   *jobs.Manager // Runs library reorganizations
 	config *config.Config
+
+	// Progress of the last library reorganization job
+	reorganizeMutex  sync.Mutex
+	reorganizeStatus ReorganizeStatus
+
+	// Open Library author lookups, keyed by normalized author name
+	authorInfoMutex sync.Mutex
+	authorInfo      map[string]models.AuthorInfo
+
+	// EPUBs kept open for the reader's asset requests
+	archives *epubArchives
+
+	// Key signing share links (share.secret)
+	shareSecret []byte
 }
 
 // NewBooksHandler creates a new books handler
-func NewBooksHandler(db *database.Manager, config *config.Config) *BooksHandler {
-	return &BooksHandler{db: db, config: config}
+func NewBooksHandler(db *database.Manager, jobManager *jobs.Manager, config *config.Config) *BooksHandler {
+	return &BooksHandler{
+		db:               db,
+		jobs:             jobManager,
+		config:           config,
+		reorganizeStatus: ReorganizeStatus{Status: "idle", Moves: []ReorganizeMove{}, Conflicts: []ReorganizeMove{}, Errors: []string{}},
+		authorInfo:       make(map[string]models.AuthorInfo),
+		archives:         newEPUBArchives(time.Duration(config.Reader.ArchiveIdleSeconds)*time.Second, config.Reader.MaxRequestsPerBook),
+		shareSecret:      shareSecret(config.Share.Secret),
+	}
+}
+
+// Paging of the book list (GET /api/books)
+const (
+	defaultBooksPageLimit = 50
+	maxBooksPageLimit     = 500
+)
+
+// booksPage reads the limit (default 50, at most 500) and offset query parameters of
+// a page of the book list, responding with 400 and reporting false when one is invalid
+func booksPage(w http.ResponseWriter, r *http.Request) (limit, offset int, ok bool) {
+	limit = defaultBooksPageLimit
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsed, err := strconv.Atoi(limitStr)
+		if err != nil || parsed < 1 {
+			http.Error(w, "Invalid limit, must be a positive number", http.StatusBadRequest)
+			return 0, 0, false
+		}
+		limit = parsed
+	}
+	if limit > maxBooksPageLimit {
+		limit = maxBooksPageLimit
+	}
+
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		parsed, err := strconv.Atoi(offsetStr)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid offset, must be zero or more", http.StatusBadRequest)
+			return 0, 0, false
+		}
+		offset = parsed
+	}
+	return limit, offset, true
 }
 
-// GetAllBooks returns all books
+// GetAllBooks returns one page of books ordered by title, with the total number of
+// books, selected by the limit (default 50, at most 500) and offset query parameters
 func (h *BooksHandler) GetAllBooks(w http.ResponseWriter, r *http.Request) {
-	books, err := h.db.GetAllBooks()
+	limit, offset, ok := booksPage(w, r)
+	if !ok {
+		return
+	}
+
+	books, total, err := h.db.GetBooksPaginated(limit, offset)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// Ensure we return an empty array instead of null
+	if books == nil {
+		books = []models.Book{}
+	}
+	shortenDescriptions(books)
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(books)
+	encodeJSON(w, r, map[string]interface{}{
+		"books":  books,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
 }
 
-// SearchBooks searches for books by title or author
+// SearchBooks searches for books by title or author, returning every book when no
+// query is given. With mode=fts the query is a full-text search over titles, authors,
+// publishers and descriptions, best matches first.
 func (h *BooksHandler) SearchBooks(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query().Get("q")
-	if query == "" {
-		// If no query, return all books
-		h.GetAllBooks(w, r)
+	mode := r.URL.Query().Get("mode")
+	if mode != "" && mode != "like" && mode != "fts" {
+		http.Error(w, "Invalid mode, must be one of: like, fts", http.StatusBadRequest)
 		return
 	}
 
-	books, err := h.db.SearchBooks(query)
+	// Optionally scope the search to a single field
+	var books []models.Book
+	var err error
+	switch field := r.URL.Query().Get("field"); {
+	case query == "":
+		// If no query, return all books
+		books, err = h.db.GetAllBooks()
+	case mode == "fts" && (field == "" || field == "all"):
+		books, err = h.db.SearchBooksFTS(query)
+	case mode == "fts":
+		http.Error(w, "Full-text search covers all fields, field must be all", http.StatusBadRequest)
+		return
+	case field == "" || field == "all":
+		books, err = h.db.SearchBooks(query)
+	case field == "title":
+		books, err = h.db.SearchBooksByTitle(query)
+	case field == "author":
+		books, err = h.db.SearchBooksByAuthor(query)
+	default:
+		http.Error(w, "Invalid field, must be one of: title, author, all", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// Ensure we return an empty array instead of null
+	if books == nil {
+		books = []models.Book{}
+	}
+	shortenDescriptions(books)
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(books)
+	encodeJSON(w, r, books)
 }
 
-// GetBookByID returns a specific book by ID
+// GetBookByID returns a specific book by ID (GET /api/books/{id})
 func (h *BooksHandler) GetBookByID(w http.ResponseWriter, r *http.Request) {
-	// Handle different HTTP methods
-	if r.Method == "PUT" {
-		// This is an edit request, delegate to EditBookMetadata
-		h.EditBookMetadata(w, r)
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Extract ID from URL path (assuming /api/books/{id})
-	// This is a simplified version - in a real app you'd use a router
-	idStr := r.URL.Path[len("/api/books/"):]
-
-	// Handle cases where the path might be /api/books/{id}/edit
-	if strings.Contains(idStr, "/") {
-		idStr = strings.Split(idStr, "/")[0]
-	}
-
-	id, err := strconv.Atoi(idStr)
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		http.Error(w, "Invalid book ID", http.StatusBadRequest)
 		return
 	}
 
-	// For now, we'll get all books and find the one with matching ID
-	// In a real app, you'd have a GetBookByID method in the database layer
-	books, err := h.db.GetAllBooks()
+	book, err := h.db.GetBookByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeBookNotFound(w, r, id)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	for _, book := range books {
-		if book.ID == id {
-			w.Header().Set("Content-Type", "application/json")
-			json.NewEncoder(w).Encode(book)
-			return
+	if book.Roles, err = h.db.GetBookRoles(book.ID); err != nil {
+		log.Printf("Failed to load author roles for book %d: %v", book.ID, err)
+	}
+	if book.Tags, err = h.db.GetBookTags(book.ID); err != nil {
+		log.Printf("Failed to load tags for book %d: %v", book.ID, err)
+	}
+	if book.CustomFields, err = h.db.GetCustomFields(book.ID); err != nil {
+		log.Printf("Failed to load custom fields for book %d: %v", book.ID, err)
+	}
+	if info, err := os.Stat(book.FilePath); err == nil {
+		modTime := info.ModTime()
+		book.FileModified = &modTime
+	}
+	if h.config.Library.GroupEditions {
+		editions, err := h.db.GetEditions(book.ID)
+		if err != nil {
+			log.Printf("Failed to load editions for book %d: %v", book.ID, err)
 		}
+		for _, edition := range editions {
+			book.Editions = append(book.Editions, models.Edition{
+				ID:       edition.ID,
+				Format:   edition.Format,
+				FilePath: edition.FilePath,
+				FileSize: edition.FileSize,
+			})
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, book)
+}
+
+// GetBooksBatch returns the books for a list of IDs, in the order given
+func (h *BooksHandler) GetBooksBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		IDs []int `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	books, err := h.db.GetBooksByIDs(req.IDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	http.Error(w, "Book not found", http.StatusNotFound)
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, books)
 }
 
 // AddBook adds a new book
@@ -126,15 +276,24 @@ func (h *BooksHandler) AddBook(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Title and file path are required", http.StatusBadRequest)
 		return
 	}
+	if book.Format == "epub" {
+		if hasDRM, err := epub.DetectDRM(book.FilePath); err == nil {
+			book.DRM = hasDRM
+		}
+	}
 
 	err := h.db.AddBook(book)
+	if err == database.ErrDuplicatePath {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "book added"})
+	encodeJSON(w, r, map[string]string{"status": "book added"})
 }
 
 // RemoveBook removes a book by ID
@@ -144,9 +303,7 @@ func (h *BooksHandler) RemoveBook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Extract ID from URL path
-	idStr := r.URL.Path[len("/api/books/"):]
-	id, err := strconv.Atoi(idStr)
+	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		http.Error(w, "Invalid book ID", http.StatusBadRequest)
 		return
@@ -159,11 +316,28 @@ func (h *BooksHandler) RemoveBook(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "book removed"})
+	encodeJSON(w, r, map[string]string{"status": "book removed"})
 }
 
-// GetAuthors returns all unique authors
+// GetAuthors returns all unique authors, with book counts when with_counts=true
 func (h *BooksHandler) GetAuthors(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("with_counts") == "true" {
+		authors, err := h.db.GetAuthorsWithCounts()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Ensure we return an empty array instead of null
+		if authors == nil {
+			authors = []models.AuthorCount{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		encodeJSON(w, r, authors)
+		return
+	}
+
 	authors, err := h.db.GetAllAuthors()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -176,7 +350,7 @@ func (h *BooksHandler) GetAuthors(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(authors)
+	encodeJSON(w, r, authors)
 }
 
 // GetAuthorsByLetter returns authors starting with a specific letter
@@ -193,11 +367,35 @@ func (h *BooksHandler) GetAuthorsByLetter(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Ensure we return an empty array instead of null
+	if authors == nil {
+		authors = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, authors)
+}
+
+// GetAuthorLetters returns the initial letters (plus "#") that have at least one author
+func (h *BooksHandler) GetAuthorLetters(w http.ResponseWriter, r *http.Request) {
+	letters, err := h.db.GetAuthorLetters()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Ensure we return an empty array instead of null
+	if letters == nil {
+		letters = []string{}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(authors)
+	encodeJSON(w, r, letters)
 }
 
-// GetBooksByAuthor returns all books by a specific author
+// GetBooksByAuthor returns all books by a specific author, each series in order followed by
+// the books in no series (?order=title sorts by title only). With ?group_by=series the books
+// are returned grouped per series, with the standalone books separately.
 func (h *BooksHandler) GetBooksByAuthor(w http.ResponseWriter, r *http.Request) {
 	author := r.URL.Query().Get("author")
 	if author == "" {
@@ -205,63 +403,115 @@ func (h *BooksHandler) GetBooksByAuthor(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	order := r.URL.Query().Get("order")
+	groupBy := r.URL.Query().Get("group_by")
+	if order != "" && order != "series" && order != "title" {
+		http.Error(w, "Invalid order, must be series or title", http.StatusBadRequest)
+		return
+	}
+	if groupBy != "" && groupBy != "series" {
+		http.Error(w, "Invalid group_by, must be series", http.StatusBadRequest)
+		return
+	}
+
 	books, err := h.db.GetBooksByAuthor(author)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// Ensure we return an empty array instead of null
+	if books == nil {
+		books = []models.Book{}
+	}
+	shortenDescriptions(books)
+
+	if groupBy == "series" {
+		w.Header().Set("Content-Type", "application/json")
+		encodeJSON(w, r, groupBooksBySeries(author, books))
+		return
+	}
+	if order == "title" {
+		sort.SliceStable(books, func(i, j int) bool { return books[i].SortTitle < books[j].SortTitle })
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(books)
+	encodeJSON(w, r, books)
 }
 
-// GetTitles returns all unique titles
-func (h *BooksHandler) GetTitles(w http.ResponseWriter, r *http.Request) {
-	titles, err := h.db.GetAllTitles()
+// groupBooksBySeries splits books in series order into one group per series and the
+// books that are in none
+func groupBooksBySeries(author string, books []models.Book) map[string]interface{} {
+	series := []models.SeriesGroup{}
+	standalone := []models.Book{}
+	for _, book := range books {
+		if book.Series == "" {
+			standalone = append(standalone, book)
+			continue
+		}
+		if last := len(series) - 1; last >= 0 && strings.EqualFold(series[last].Series, book.Series) {
+			series[last].Books = append(series[last].Books, book)
+			continue
+		}
+		series = append(series, models.SeriesGroup{Series: book.Series, Books: []models.Book{book}})
+	}
+
+	return map[string]interface{}{
+		"author":     author,
+		"series":     series,
+		"standalone": standalone,
+	}
+}
+
+// GetPublishers returns all publishers with their book counts
+func (h *BooksHandler) GetPublishers(w http.ResponseWriter, r *http.Request) {
+	publishers, err := h.db.GetAllPublishers()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Ensure we return an empty array instead of null
-	if titles == nil {
-		titles = []string{}
+	if publishers == nil {
+		publishers = []models.PublisherCount{}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(titles)
+	encodeJSON(w, r, publishers)
 }
 
-// GetTitlesByLetter returns titles starting with a specific letter
-func (h *BooksHandler) GetTitlesByLetter(w http.ResponseWriter, r *http.Request) {
+// GetPublishersByLetter returns publishers starting with a specific letter
+func (h *BooksHandler) GetPublishersByLetter(w http.ResponseWriter, r *http.Request) {
 	letter := r.URL.Query().Get("letter")
 	if letter == "" {
 		http.Error(w, "Letter parameter is required", http.StatusBadRequest)
 		return
 	}
 
-	titles, err := h.db.GetTitlesByLetter(letter)
+	publishers, err := h.db.GetPublishersByLetter(letter)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// Ensure we return an empty array instead of null
+	if publishers == nil {
+		publishers = []models.PublisherCount{}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(titles)
+	encodeJSON(w, r, publishers)
 }
 
-// GetRecentBooks returns the most recently added books
-func (h *BooksHandler) GetRecentBooks(w http.ResponseWriter, r *http.Request) {
-	// Get limit from query parameter, default to 12
-	limitStr := r.URL.Query().Get("limit")
-	limit := 12
-	if limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
-		}
+// GetBooksByPublisher returns all books from a specific publisher
+func (h *BooksHandler) GetBooksByPublisher(w http.ResponseWriter, r *http.Request) {
+	publisher := r.URL.Query().Get("publisher")
+	if publisher == "" {
+		http.Error(w, "Publisher parameter is required", http.StatusBadRequest)
+		return
 	}
 
-	books, err := h.db.GetRecentBooks(limit)
+	books, err := h.db.GetBooksByPublisher(publisher)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -271,161 +521,549 @@ func (h *BooksHandler) GetRecentBooks(w http.ResponseWriter, r *http.Request) {
 	if books == nil {
 		books = []models.Book{}
 	}
+	shortenDescriptions(books)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(books)
+	encodeJSON(w, r, books)
 }
 
-// GetRandomBooks returns a random selection of books
-func (h *BooksHandler) GetRandomBooks(w http.ResponseWriter, r *http.Request) {
-	// Get limit from query parameter, default to 12
-	limitStr := r.URL.Query().Get("limit")
-	limit := 12
-	if limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-			limit = parsedLimit
+// GetTags returns all tags that are applied to at least one book, with book counts
+// ordered by popularity when with_counts=true
+func (h *BooksHandler) GetTags(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("with_counts") == "true" {
+		tags, err := h.db.GetTagsWithCounts()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Ensure we return an empty array instead of null
+		if tags == nil {
+			tags = []models.TagCount{}
 		}
+
+		w.Header().Set("Content-Type", "application/json")
+		encodeJSON(w, r, tags)
+		return
 	}
 
-	books, err := h.db.GetRandomBooks(limit)
+	tags, err := h.db.GetAllTags()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	// Ensure we return an empty array instead of null
-	if books == nil {
-		books = []models.Book{}
+	if tags == nil {
+		tags = []string{}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(books)
+	encodeJSON(w, r, tags)
 }
 
-// GetBooksByTitle returns all books with a specific title
-func (h *BooksHandler) GetBooksByTitle(w http.ResponseWriter, r *http.Request) {
-	title := r.URL.Query().Get("title")
-	if title == "" {
-		http.Error(w, "Title parameter is required", http.StatusBadRequest)
+// GetBooksByTag returns all books with a specific tag
+func (h *BooksHandler) GetBooksByTag(w http.ResponseWriter, r *http.Request) {
+	tag := r.URL.Query().Get("tag")
+	if tag == "" {
+		http.Error(w, "Tag parameter is required", http.StatusBadRequest)
 		return
 	}
 
-	books, err := h.db.GetBooksByTitle(title)
+	books, err := h.db.GetBooksByTag(tag)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// Ensure we return an empty array instead of null
+	if books == nil {
+		books = []models.Book{}
+	}
+	shortenDescriptions(books)
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(books)
+	encodeJSON(w, r, books)
 }
 
-// DownloadBook downloads a book file by ID
-func (h *BooksHandler) DownloadBook(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+// BookFilter selects the books a bulk operation applies to. Exactly one of Author,
+// Query, Format and BookIDs must be given.
+type BookFilter struct {
+	Author  string `json:"author"`   // Books by this author, as in /api/authors/books
+	Query   string `json:"query"`    // Books whose title or author contains this, as in /api/search
+	Format  string `json:"format"`   // Books in this format, e.g. "pdf"
+	BookIDs []int  `json:"book_ids"` // These books
+}
 
-	// Extract ID from URL path (remove .epub extension if present)
-	idStr := r.URL.Path[len("/api/download/"):]
+// valid reports whether exactly one filter is set
+func (f BookFilter) valid() bool {
+	filters := 0
+	for _, set := range []bool{strings.TrimSpace(f.Author) != "", strings.TrimSpace(f.Query) != "", strings.TrimSpace(f.Format) != "", len(f.BookIDs) > 0} {
+		if set {
+			filters++
+		}
+	}
+	return filters == 1
+}
 
-	// Remove .epub extension if present
-	if strings.HasSuffix(idStr, ".epub") {
-		idStr = idStr[:len(idStr)-5] // Remove ".epub" (5 characters)
+// filterBooks returns the books matching a validated filter. Unknown book IDs are left
+// out so bulk operations never touch a book that doesn't exist.
+func (h *BooksHandler) filterBooks(f BookFilter) ([]models.Book, error) {
+	switch {
+	case strings.TrimSpace(f.Author) != "":
+		return h.db.GetBooksByAuthor(strings.TrimSpace(f.Author))
+	case strings.TrimSpace(f.Query) != "":
+		return h.db.SearchBooks(strings.TrimSpace(f.Query))
+	case strings.TrimSpace(f.Format) != "":
+		return h.db.GetBooksByFormat(strings.TrimSpace(f.Format))
+	default:
+		return h.db.GetBooksByIDs(f.BookIDs)
 	}
+}
 
-	// Log for debugging
-	fmt.Printf("URL: %s, ID string: %s\n", r.URL.Path, idStr)
+// BulkTagRequest is a tag and the books a bulk tag operation applies it to (or removes
+// it from)
+type BulkTagRequest struct {
+	Tag string `json:"tag"`
+	BookFilter
+}
 
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+// BulkApplyTag applies a tag to every book matching a filter (POST /api/tags/bulk-apply)
+func (h *BooksHandler) BulkApplyTag(w http.ResponseWriter, r *http.Request) {
+	h.bulkTag(w, r, false)
+}
+
+// BulkRemoveTag removes a tag from every book matching a filter (POST /api/tags/bulk-remove)
+func (h *BooksHandler) BulkRemoveTag(w http.ResponseWriter, r *http.Request) {
+	h.bulkTag(w, r, true)
+}
+
+// bulkTag applies or removes a tag on the books a BulkTagRequest selects, in one transaction
+func (h *BooksHandler) bulkTag(w http.ResponseWriter, r *http.Request, remove bool) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Get book details
-	book, err := h.db.GetBookByID(id)
-	if err != nil {
-		http.Error(w, "Book not found", http.StatusNotFound)
+	var req BulkTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Tag = strings.TrimSpace(req.Tag)
+	if req.Tag == "" {
+		http.Error(w, "Tag is required", http.StatusBadRequest)
 		return
 	}
 
-	// Check if file exists
-	if _, err := os.Stat(book.FilePath); os.IsNotExist(err) {
-		http.Error(w, "File not found", http.StatusNotFound)
+	if !req.valid() {
+		http.Error(w, "Exactly one of author, query, format or book_ids is required", http.StatusBadRequest)
 		return
 	}
 
-	// Set headers for EPUB file serving
-	filename := filepath.Base(book.FilePath)
-	w.Header().Set("Content-Type", "application/epub+zip")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", filename))
+	books, err := h.filterBooks(req.BookFilter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	// Open and serve the file
-	file, err := os.Open(book.FilePath)
+	bookIDs := make([]int, len(books))
+	for i, book := range books {
+		bookIDs[i] = book.ID
+	}
+
+	var affected int
+	if remove {
+		affected, err = h.db.RemoveTag(req.Tag, bookIDs)
+	} else {
+		affected, err = h.db.ApplyTag(req.Tag, bookIDs)
+	}
 	if err != nil {
-		http.Error(w, "Error opening file", http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	defer file.Close()
 
-	// Copy file to response
-	io.Copy(w, file)
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, map[string]interface{}{
+		"tag":      req.Tag,
+		"matched":  len(bookIDs),
+		"affected": affected,
+	})
 }
 
-// ServeReader serves the EPUB reader page
-func (h *BooksHandler) ServeReader(w http.ResponseWriter, r *http.Request) {
-	// Extract book ID from URL path
-	bookIDStr := r.URL.Path[len("/read/"):]
-	bookID, err := strconv.Atoi(bookIDStr)
-	if err != nil {
-		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+// GetTitles returns all unique titles, with book counts when with_counts=true
+func (h *BooksHandler) GetTitles(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("with_counts") == "true" {
+		titles, err := h.db.GetTitlesWithCounts()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// Ensure we return an empty array instead of null
+		if titles == nil {
+			titles = []models.TitleCount{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		encodeJSON(w, r, titles)
 		return
 	}
 
-	// Get book from database
-	book, err := h.db.GetBookByID(bookID)
+	titles, err := h.db.GetAllTitles()
 	if err != nil {
-		http.Error(w, "Book not found", http.StatusNotFound)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Check if it's an EPUB file
-	if book.Format != "epub" {
-		http.Error(w, "Only EPUB files can be read", http.StatusBadRequest)
-		return
+	// Ensure we return an empty array instead of null
+	if titles == nil {
+		titles = []string{}
 	}
 
-	// Serve the reader HTML page
-	readerPath := filepath.Join("..", "frontend", "templates", "reader.html")
-	http.ServeFile(w, r, readerPath)
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, titles)
 }
 
-// ServeEPUBFile serves internal EPUB files (like META-INF/container.xml)
-func (h *BooksHandler) ServeEPUBFile(w http.ResponseWriter, r *http.Request) {
-	// Extract book ID and file path from URL
-	// URL format: /api/epub/{bookID}/{filepath}
-	path := r.URL.Path[len("/api/epub/"):]
-	parts := strings.SplitN(path, "/", 2)
-	if len(parts) != 2 {
-		http.Error(w, "Invalid EPUB file path", http.StatusBadRequest)
+// GetTitlesByLetter returns titles starting with a specific letter, with book counts when with_counts=true
+func (h *BooksHandler) GetTitlesByLetter(w http.ResponseWriter, r *http.Request) {
+	letter := r.URL.Query().Get("letter")
+	if letter == "" {
+		http.Error(w, "Letter parameter is required", http.StatusBadRequest)
 		return
 	}
 
-	bookIDStr := parts[0]
-	filePath := parts[1]
+	if r.URL.Query().Get("with_counts") == "true" {
+		titles, err := h.db.GetTitlesByLetterWithCounts(letter)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 
-	bookID, err := strconv.Atoi(bookIDStr)
-	if err != nil {
-		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		// Ensure we return an empty array instead of null
+		if titles == nil {
+			titles = []models.TitleCount{}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		encodeJSON(w, r, titles)
 		return
 	}
 
-	// Get book from database
-	book, err := h.db.GetBookByID(bookID)
+	titles, err := h.db.GetTitlesByLetter(letter)
 	if err != nil {
-		http.Error(w, "Book not found", http.StatusNotFound)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Ensure we return an empty array instead of null
+	if titles == nil {
+		titles = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, titles)
+}
+
+// GetRecentBooks returns the most recently added books
+func (h *BooksHandler) GetRecentBooks(w http.ResponseWriter, r *http.Request) {
+	limit := h.parseLimit(r)
+
+	books, err := h.db.GetRecentBooks(limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Ensure we return an empty array instead of null
+	if books == nil {
+		books = []models.Book{}
+	}
+	shortenDescriptions(books)
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, books)
+}
+
+// parseLimit reads the limit query parameter, defaulting to 12 and clamping to server.max_list_limit
+func (h *BooksHandler) parseLimit(r *http.Request) int {
+	limit := 12
+	if parsedLimit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && parsedLimit > 0 {
+		limit = parsedLimit
+	}
+	if maxLimit := h.config.Server.MaxListLimit; maxLimit > 0 && limit > maxLimit {
+		limit = maxLimit
+	}
+	return limit
+}
+
+// GetRandomBooks returns a random selection of books
+func (h *BooksHandler) GetRandomBooks(w http.ResponseWriter, r *http.Request) {
+	limit := h.parseLimit(r)
+
+	books, err := h.db.GetRandomBooks(limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Ensure we return an empty array instead of null
+	if books == nil {
+		books = []models.Book{}
+	}
+	shortenDescriptions(books)
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, books)
+}
+
+// GetBookByUID looks a book up by its EPUB package unique identifier
+// (GET /api/books/by-uid/{uid}). Identifiers containing "//", such as URLs, must be
+// passed as /api/books/by-uid/?uid=... instead since the server would collapse the
+// slashes and redirect.
+func (h *BooksHandler) GetBookByUID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	uid := r.URL.Query().Get("uid")
+	if uid == "" {
+		uid = pathRemainder(r)
+	}
+	if strings.TrimSpace(uid) == "" {
+		http.Error(w, "Invalid unique identifier", http.StatusBadRequest)
+		return
+	}
+
+	book, err := h.db.GetBookByUID(uid)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("No book with unique identifier %q", uid), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, book)
+}
+
+// GetTitleLetters returns the initial letters (plus "#") that have at least one title
+func (h *BooksHandler) GetTitleLetters(w http.ResponseWriter, r *http.Request) {
+	letters, err := h.db.GetTitleLetters()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Ensure we return an empty array instead of null
+	if letters == nil {
+		letters = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, letters)
+}
+
+// GetBooksByTitle returns all books with a specific title
+func (h *BooksHandler) GetBooksByTitle(w http.ResponseWriter, r *http.Request) {
+	title := r.URL.Query().Get("title")
+	if title == "" {
+		http.Error(w, "Title parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	books, err := h.db.GetBooksByTitle(title)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Ensure we return an empty array instead of null
+	if books == nil {
+		books = []models.Book{}
+	}
+	shortenDescriptions(books)
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, books)
+}
+
+// DownloadBook downloads a book file by ID
+func (h *BooksHandler) DownloadBook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract ID from URL path (remove .epub extension if present)
+	idStr := r.URL.Path[len("/api/download/"):]
+
+	// Remove .epub extension if present
+	if strings.HasSuffix(idStr, ".epub") {
+		idStr = idStr[:len(idStr)-5] // Remove ".epub" (5 characters)
+	}
+
+	// Log for debugging
+	fmt.Printf("URL: %s, ID string: %s\n", r.URL.Path, idStr)
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+	if !h.checkDownloadAccess(w, r, id) {
+		return
+	}
+
+	// Get book details
+	book, err := h.db.GetBookByID(id)
+	if err != nil {
+		writeBookNotFound(w, r, id)
+		return
+	}
+
+	// Check if file exists
+	info, err := os.Stat(book.FilePath)
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+
+	// Serve inline for the reader unless the client asks for a download
+	disposition := "inline"
+	if r.URL.Query().Get("download") == "true" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Type", bookContentType(book.Format))
+	w.Header().Set("Content-Disposition", contentDisposition(disposition, h.downloadFilename(book)))
+	w.Header().Set("ETag", fileETag(info))
+
+	// Open and serve the file
+	file, err := os.Open(book.FilePath)
+	if err != nil {
+		http.Error(w, "Error opening file", http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	// ServeContent answers HEAD, conditional and range requests
+	http.ServeContent(w, r, "", info.ModTime(), file)
+
+	// The reader opens books through /api/download/{id}.epub; only count real downloads,
+	// and a download resumed or split into ranges only once
+	rangeHeader := r.Header.Get("Range")
+	if r.Method == "GET" && (rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-")) &&
+		(!strings.HasSuffix(r.URL.Path, ".epub") || disposition == "attachment") {
+		recordDownload(h.db, book.ID, book.Format)
+	}
+}
+
+// fileETag derives an ETag from a file's modification time and size
+func fileETag(info os.FileInfo) string {
+	return fmt.Sprintf("\"%x-%x\"", info.ModTime().UnixNano(), info.Size())
+}
+
+// recordDownload increments a book's download counter in the background so serving isn't delayed
+func recordDownload(db *database.Manager, bookID int, format string) {
+	go func() {
+		if err := db.IncrementDownloadCount(bookID, format); err != nil {
+			log.Printf("Failed to record download of book %d: %v", bookID, err)
+		}
+	}()
+}
+
+// bookContentTypes maps book formats to the MIME type used when serving them
+var bookContentTypes = map[string]string{
+	"epub": "application/epub+zip",
+	"pdf":  "application/pdf",
+	"mobi": "application/x-mobipocket-ebook",
+	"azw":  "application/vnd.amazon.ebook",
+	"azw3": "application/vnd.amazon.ebook",
+	"txt":  "text/plain; charset=utf-8",
+}
+
+// bookContentType returns the MIME type for a book format
+func bookContentType(format string) string {
+	if contentType, ok := bookContentTypes[strings.TrimPrefix(strings.ToLower(format), ".")]; ok {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// downloadFilename returns the filename offered for a book download.
+// The stored name is used when it is readable and mentions the title,
+// otherwise a "Title - Author.ext" name is derived from the metadata.
+func (h *BooksHandler) downloadFilename(book models.Book) string {
+	filename := filepath.Base(book.FilePath)
+	ext := filepath.Ext(filename)
+	stem := strings.TrimSuffix(filename, ext)
+
+	readable := utf8.ValidString(filename) && strings.IndexFunc(filename, unicode.IsControl) < 0
+	if readable && strings.Contains(strings.ToLower(stem), strings.ToLower(h.cleanForFilesystem(book.Title))) {
+		return filename
+	}
+
+	if ext == "" && book.Format != "" {
+		ext = "." + strings.TrimPrefix(strings.ToLower(book.Format), ".")
+	}
+	return fmt.Sprintf("%s - %s%s", h.cleanForFilesystem(book.Title), h.cleanForFilesystem(book.Author), ext)
+}
+
+// contentDisposition builds a Content-Disposition header value.
+// Quotes, backslashes and control characters are dropped from the plain filename,
+// and non-ASCII names get an ASCII fallback plus an RFC 5987 filename* parameter.
+func contentDisposition(disposition, filename string) string {
+	var fallback strings.Builder
+	ascii := true
+	for _, r := range filename {
+		switch {
+		case r == '"' || r == '\\' || unicode.IsControl(r):
+			continue
+		case r > unicode.MaxASCII:
+			ascii = false
+			fallback.WriteRune('_')
+		default:
+			fallback.WriteRune(r)
+		}
+	}
+
+	header := fmt.Sprintf("%s; filename=\"%s\"", disposition, fallback.String())
+	if !ascii {
+		header += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+	return header
+}
+
+// encodeRFC5987 percent-encodes a value for use in an RFC 5987 extended parameter
+func encodeRFC5987(value string) string {
+	const attrChars = "!#$&+-.^_`|~"
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || strings.IndexByte(attrChars, c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// drmErrorMessage is returned when a DRM-protected book is opened in the reader or converter
+const drmErrorMessage = "This book is DRM-protected and cannot be read or converted"
+
+// ServeReader serves the EPUB reader page
+func (h *BooksHandler) ServeReader(w http.ResponseWriter, r *http.Request) {
+	// Extract book ID from URL path
+	bookIDStr := r.URL.Path[len("/read/"):]
+	bookID, err := strconv.Atoi(bookIDStr)
+	if err != nil {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+
+	// Get book from database
+	book, err := h.db.GetBookByID(bookID)
+	if err != nil {
+		writeBookNotFound(w, r, bookID)
 		return
 	}
 
@@ -435,165 +1073,910 @@ func (h *BooksHandler) ServeEPUBFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Open the EPUB file as a ZIP archive
-	reader, err := zip.OpenReader(book.FilePath)
-	if err != nil {
-		http.Error(w, "Failed to open EPUB file", http.StatusInternalServerError)
-		return
+	if book.DRM {
+		http.Error(w, drmErrorMessage, http.StatusUnprocessableEntity)
+		return
+	}
+
+	// Serve the reader HTML page
+	if _, err := os.Stat(readerTemplatePath); err != nil {
+		log.Printf("Cannot serve the reader for book %d: %v", bookID, err)
+		http.Error(w, fmt.Sprintf("The reader page template is missing (%s). Run the server from the backend directory next to frontend/templates.", readerTemplatePath), http.StatusInternalServerError)
+		return
+	}
+	http.ServeFile(w, r, readerTemplatePath)
+}
+
+// readerTemplatePath is the reader page, relative to the backend directory the server runs from
+var readerTemplatePath = filepath.Join("..", "frontend", "templates", "reader.html")
+
+// CheckReaderTemplate reports whether the reader page template can be read, so a
+// misplaced frontend is noticed at startup instead of on the first /read/ request
+func CheckReaderTemplate() error {
+	file, err := os.Open(readerTemplatePath)
+	if err != nil {
+		absPath, _ := filepath.Abs(readerTemplatePath)
+		return fmt.Errorf("reader template %s not found, /read/ pages will fail: %v", absPath, err)
+	}
+	return file.Close()
+}
+
+// ServeEPUBFile serves internal EPUB files (like META-INF/container.xml)
+func (h *BooksHandler) ServeEPUBFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// URL format: /api/epub/{id}/*
+	filePath := pathRemainder(r)
+	bookID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+	if !requireAPIKey(w, r, h.config.Server.APIKey) {
+		return
+	}
+
+	// Get book from database
+	book, err := h.db.GetBookByID(bookID)
+	if err != nil {
+		writeBookNotFound(w, r, bookID)
+		return
+	}
+
+	// Check if it's an EPUB file
+	if book.Format != "epub" {
+		http.Error(w, "Only EPUB files can be read", http.StatusBadRequest)
+		return
+	}
+
+	if book.DRM {
+		http.Error(w, drmErrorMessage, http.StatusUnprocessableEntity)
+		return
+	}
+
+	// The reader requests many files of the same book, so the archive stays open between them
+	archive, release, err := h.archives.acquire(r.Context(), book.FilePath)
+	if err != nil {
+		http.Error(w, "Failed to open EPUB file", http.StatusInternalServerError)
+		return
+	}
+	defer release()
+
+	// Find the requested file in the EPUB
+	if file, exists := archive.files[filePath]; exists {
+		// Open the file
+		rc, err := file.Open()
+		if err != nil {
+			http.Error(w, "Failed to open file in EPUB", http.StatusInternalServerError)
+			return
+		}
+		defer rc.Close()
+
+		// Set appropriate content type; the entry's checksum identifies its content
+		w.Header().Set("Content-Type", epubContentType(filePath))
+		w.Header().Set("ETag", fmt.Sprintf("\"%08x-%x\"", file.CRC32, file.UncompressedSize64))
+
+		// Embedded fonts may be obfuscated and must be restored before the browser can use them
+		if algorithm := archive.encrypted[filePath]; epub.IsFontObfuscation(algorithm) {
+			h.serveDeobfuscatedFont(w, r, archive.reader, rc, algorithm, file.Modified)
+			return
+		}
+
+		// Entries are compressed, so read them whole to answer range and HEAD requests
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			http.Error(w, "Failed to serve file content", http.StatusInternalServerError)
+			return
+		}
+		http.ServeContent(w, r, "", file.Modified, bytes.NewReader(data))
+		return
+	}
+
+	// File not found in EPUB
+	http.Error(w, "File not found in EPUB", http.StatusNotFound)
+}
+
+// serveDeobfuscatedFont writes an obfuscated embedded font in its original form
+func (h *BooksHandler) serveDeobfuscatedFont(w http.ResponseWriter, r *http.Request, reader *zip.ReadCloser, rc io.Reader, algorithm string, modTime time.Time) {
+	uniqueID, err := epub.PackageUniqueIdentifier(reader.File)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read unique identifier: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		http.Error(w, "Failed to serve file content", http.StatusInternalServerError)
+		return
+	}
+
+	font, err := epub.DeobfuscateFont(data, algorithm, uniqueID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to deobfuscate font: %v", err), http.StatusInternalServerError)
+		return
+	}
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(font))
+}
+
+// epubContentType guesses the media type of a file inside an EPUB from its extension
+func epubContentType(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".xml":
+		return "application/xml"
+	case ".xhtml", ".html":
+		return "application/xhtml+xml"
+	case ".opf":
+		return "application/oebps-package+xml"
+	case ".ncx":
+		return "application/x-dtbncx+xml"
+	case ".css":
+		return "text/css"
+	case ".js":
+		return "application/javascript"
+	case ".png":
+		return "image/png"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".gif":
+		return "image/gif"
+	case ".svg":
+		return "image/svg+xml"
+	case ".otf":
+		return "font/otf"
+	case ".ttf":
+		return "font/ttf"
+	case ".woff":
+		return "font/woff"
+	case ".woff2":
+		return "font/woff2"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// ValidateBook runs the EPUB structure checks on a book and returns the report
+func (h *BooksHandler) ValidateBook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract book ID from URL path
+	// URL format: /api/books/{id}/validate
+	bookID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+
+	book, err := h.db.GetBookByID(bookID)
+	if err != nil {
+		writeBookNotFound(w, r, bookID)
+		return
+	}
+
+	if book.Format != "epub" {
+		http.Error(w, "Only EPUB files can be validated", http.StatusBadRequest)
+		return
+	}
+
+	report, err := epub.Validate(book.FilePath)
+	if err != nil {
+		// An unreadable archive is still a validation result
+		report = &epub.ValidationReport{Checks: []epub.ValidationCheck{{
+			Name:    "archive",
+			Status:  epub.CheckError,
+			Message: err.Error(),
+		}}}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, report)
+}
+
+// DetectBookFormat reports the format of a book's file as sniffed from its content next
+// to the stored format and the one its extension suggests (GET /api/books/{id}/detect-format).
+// POST also stores the detected format when it differs from the stored one.
+func (h *BooksHandler) DetectBookFormat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract book ID from URL path
+	// URL format: /api/books/{id}/detect-format
+	bookID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+
+	book, err := h.db.GetBookByID(bookID)
+	if err != nil {
+		writeBookNotFound(w, r, bookID)
+		return
+	}
+
+	detected, err := conversion.DetectFormat(book.FilePath)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read book file: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	extFormat := strings.TrimPrefix(strings.ToLower(filepath.Ext(book.FilePath)), ".")
+	mismatch := detected != "" && !conversion.SameFormat(book.Format, detected)
+	updated := false
+	if r.Method == "POST" && mismatch {
+		if err := h.db.UpdateFormat(book.ID, detected); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		updated = true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, map[string]interface{}{
+		"book_id":            book.ID,
+		"stored_format":      book.Format,
+		"extension_format":   extFormat,
+		"detected_format":    detected, // Empty when the content is not a known format
+		"mismatch":           mismatch,
+		"extension_mismatch": detected != "" && !conversion.SameFormat(extFormat, detected),
+		"updated":            updated,
+	})
+}
+
+// spineCounts caches the per-document character counts of a book file
+type spineCounts struct {
+	modTime int64
+	counts  []int
+}
+
+// Global cache of spine character counts, keyed by book ID
+var (
+	spineCountsMutex sync.Mutex
+	spineCountsCache = make(map[int]spineCounts)
+)
+
+// getSpineCounts returns the spine character counts for a book, recomputing them when the file changed
+func (h *BooksHandler) getSpineCounts(book models.Book) ([]int, error) {
+	info, err := os.Stat(book.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	modTime := info.ModTime().Unix()
+
+	spineCountsMutex.Lock()
+	cached, ok := spineCountsCache[book.ID]
+	spineCountsMutex.Unlock()
+	if ok && cached.modTime == modTime {
+		return cached.counts, nil
+	}
+
+	counts, err := conversion.NewEPUBParser().SpineCharCounts(book.FilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	spineCountsMutex.Lock()
+	spineCountsCache[book.ID] = spineCounts{modTime: modTime, counts: counts}
+	spineCountsMutex.Unlock()
+
+	return counts, nil
+}
+
+// GetBookText streams the plain text of an EPUB for text-to-speech and accessibility tools,
+// either the whole book or the single spine document given by ?chapter={index}
+func (h *BooksHandler) GetBookText(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract book ID from URL path
+	// URL format: /api/books/{id}/text?chapter={index}
+	bookID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+	if !requireAPIKey(w, r, h.config.Server.APIKey) {
+		return
+	}
+
+	chapter := -1
+	if chapterStr := r.URL.Query().Get("chapter"); chapterStr != "" {
+		if chapter, err = strconv.Atoi(chapterStr); err != nil || chapter < 0 {
+			http.Error(w, "Invalid chapter index", http.StatusBadRequest)
+			return
+		}
+	}
+
+	book, err := h.db.GetBookByID(bookID)
+	if err != nil {
+		writeBookNotFound(w, r, bookID)
+		return
+	}
+
+	if book.Format != "epub" {
+		http.Error(w, "Text can only be extracted from EPUB files", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	tw := &trackingWriter{ResponseWriter: w}
+	err = conversion.NewEPUBParser().WriteText(book.FilePath, chapter, tw)
+	switch {
+	case err == nil:
+	case tw.written:
+		// The status is already sent, so all that is left is to stop
+		log.Printf("Error streaming text of book %d: %v", book.ID, err)
+	case errors.Is(err, conversion.ErrChapterNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, fmt.Sprintf("Failed to read EPUB: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// chapterEntry is one chapter in the table of contents of /api/books/{id}/chapters
+type chapterEntry struct {
+	Index int    `json:"index"`
+	Title string `json:"title"`
+	Href  string `json:"href"` // Relative to /api/epub/{id}/, which also resolves the chapter's assets
+}
+
+// GetBookChapters returns an EPUB's chapters in reading order with the cleaned content of
+// one of them, so clients can render a book without unzipping it. A response holds a
+// single chapter to bound its size; ?page={index} selects it and ?format=text returns
+// plain text instead of sanitized HTML.
+func (h *BooksHandler) GetBookChapters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract book ID from URL path
+	// URL format: /api/books/{id}/chapters?page={index}&format={html|text}
+	bookID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+	if !requireAPIKey(w, r, h.config.Server.APIKey) {
+		return
+	}
+
+	page := 0
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if page, err = strconv.Atoi(pageStr); err != nil || page < 0 {
+			http.Error(w, "Invalid page", http.StatusBadRequest)
+			return
+		}
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "html"
+	}
+	if format != "html" && format != "text" {
+		http.Error(w, "Format must be html or text", http.StatusBadRequest)
+		return
+	}
+
+	book, err := h.db.GetBookByID(bookID)
+	if err != nil {
+		writeBookNotFound(w, r, bookID)
+		return
+	}
+
+	if book.Format != "epub" {
+		http.Error(w, "Chapters can only be extracted from EPUB files", http.StatusBadRequest)
+		return
+	}
+	if book.DRM {
+		http.Error(w, drmErrorMessage, http.StatusUnprocessableEntity)
+		return
+	}
+
+	epubBook, err := conversion.NewEPUBParser().ParseEPUB(book.FilePath)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to read EPUB: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	chapters := []chapterEntry{}
+	for i, chapter := range epubBook.Chapters {
+		chapters = append(chapters, chapterEntry{
+			Index: i,
+			Title: conversion.HTMLText(chapter.Title),
+			Href:  chapter.Href,
+		})
+	}
+	if page >= len(chapters) {
+		http.Error(w, fmt.Sprintf("Chapter %d not found, the book has %d chapters", page, len(chapters)), http.StatusNotFound)
+		return
+	}
+
+	content := epubBook.Chapters[page].Content
+	if format == "text" {
+		content = conversion.HTMLText(content)
+	} else {
+		content = conversion.SanitizeHTML(content)
+	}
+
+	response := map[string]interface{}{
+		"book_id":     book.ID,
+		"format":      format,
+		"page":        page,
+		"total_pages": len(chapters),
+		"chapters":    chapters,
+		"chapter": map[string]interface{}{
+			"index":   page,
+			"title":   chapters[page].Title,
+			"href":    chapters[page].Href,
+			"content": content,
+		},
+	}
+	if page+1 < len(chapters) {
+		response["next_page"] = page + 1
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, response)
+}
+
+// trackingWriter records whether any of the response body has been written
+type trackingWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (tw *trackingWriter) Write(p []byte) (int, error) {
+	tw.written = true
+	return tw.ResponseWriter.Write(p)
+}
+
+func (tw *trackingWriter) Flush() {
+	if flusher, ok := tw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// GetBookFiles lists the entries inside a book's EPUB archive
+func (h *BooksHandler) GetBookFiles(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract book ID from URL path
+	// URL format: /api/books/{id}/files
+	bookID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+
+	book, err := h.db.GetBookByID(bookID)
+	if err != nil {
+		writeBookNotFound(w, r, bookID)
+		return
+	}
+
+	if book.Format != "epub" {
+		http.Error(w, "Only EPUB files can be listed", http.StatusBadRequest)
+		return
+	}
+
+	reader, err := zip.OpenReader(book.FilePath)
+	if err != nil {
+		http.Error(w, "Failed to open EPUB file", http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+
+	files := []models.EPUBFileEntry{}
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		files = append(files, models.EPUBFileEntry{
+			Name:           file.Name,
+			Size:           int64(file.UncompressedSize64),
+			CompressedSize: int64(file.CompressedSize64),
+			MediaType:      epubContentType(file.Name),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, files)
+}
+
+// GetBookFormats lists the formats a book can currently be downloaded in: the stored file
+// plus any converted files that are still in the conversion cache
+func (h *BooksHandler) GetBookFormats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract book ID from URL path
+	// URL format: /api/books/{id}/formats
+	bookID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+
+	book, err := h.db.GetBookByID(bookID)
+	if err != nil {
+		writeBookNotFound(w, r, bookID)
+		return
+	}
+
+	formats := []models.BookFormat{}
+	if info, err := os.Stat(book.FilePath); err == nil {
+		formats = append(formats, models.BookFormat{
+			Format: book.Format,
+			Source: "library",
+			URL:    fmt.Sprintf("/api/download/%d", book.ID),
+			Size:   info.Size(),
+		})
+	}
+
+	conversions := cachedConversions(book.ID)
+	sort.Slice(conversions, func(i, j int) bool { return conversions[i].Format < conversions[j].Format })
+	for _, converted := range conversions {
+		format := models.BookFormat{
+			Format: converted.Format,
+			Source: "conversion",
+			URL:    fmt.Sprintf("/api/convert/%d/%s", book.ID, converted.Format),
+		}
+		if info, err := os.Stat(converted.Path); err == nil {
+			format.Size = info.Size()
+		}
+		if !converted.Persistent {
+			expiresAt := converted.CreatedAt.Add(convertedFileTTL)
+			format.ExpiresAt = &expiresAt
+		}
+		formats = append(formats, format)
+	}
+
+	for i := range formats {
+		if info, ok := conversion.LookupFormat(formats[i].Format); ok {
+			formats[i].Name = info.Name
+			formats[i].Description = info.Description
+			formats[i].Extensions = info.Extensions
+			formats[i].Devices = info.Devices
+			formats[i].Lossy = info.Lossy
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, formats)
+}
+
+// EditBookMetadata handles editing book metadata
+func (h *BooksHandler) EditBookMetadata(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "PUT" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Extract book ID from URL path
+	// URL format: /api/books/{id}/edit
+	bookID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+
+	// Parse request body
+	var editRequest struct {
+		Title         string  `json:"title"`
+		Author        string  `json:"author"`
+		ISBN          string  `json:"isbn"`
+		Publisher     string  `json:"publisher"`
+		PublishedDate *string `json:"published_date"` // Left unchanged when omitted
+		Description   string  `json:"description"`    // Left unchanged when empty
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&editRequest); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+	clean := h.db.Extractor().CleanText
+	editRequest.Title = clean(editRequest.Title)
+	editRequest.Author = clean(editRequest.Author)
+	editRequest.Publisher = clean(editRequest.Publisher)
+	editRequest.ISBN = strings.TrimSpace(editRequest.ISBN)
+
+	// Get book from database
+	book, err := h.db.GetBookByID(bookID)
+	if err != nil {
+		writeBookNotFound(w, r, bookID)
+		return
+	}
+
+	// Check if it's an EPUB file
+	if book.Format != "epub" {
+		http.Error(w, "Only EPUB files can be edited", http.StatusBadRequest)
+		return
+	}
+
+	// Check if author or title changed to determine if file needs to be moved
+	needsFileMove := (book.Author != editRequest.Author) || (book.Title != editRequest.Title)
+	var newFilePath string
+
+	if needsFileMove {
+		// Generate new file path based on new author/title
+		newFilePath = h.generateNewFilePath(editRequest.Author, editRequest.Title, book.Format)
+
+		// Refuse to overwrite another book before touching the EPUB
+		if conflictID, conflict := h.findPathConflict(newFilePath, book.FilePath); conflict {
+			h.writePathConflict(w, r, newFilePath, conflictID)
+			return
+		}
+	}
+
+	// Create EPUB editor and load the file
+	editor := epub.NewEPUBEditor(book.FilePath)
+	editor.SetFileMode(h.config.LibraryFileMode())
+	if err := editor.Load(); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to load EPUB file: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Update metadata in the EPUB file
+	description := strings.TrimSpace(editRequest.Description)
+	if err := editor.UpdateMetadata(editRequest.Title, editRequest.Author, editRequest.ISBN, editRequest.Publisher, description); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update EPUB metadata: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if editRequest.PublishedDate != nil {
+		if err := editor.UpdateDate(strings.TrimSpace(*editRequest.PublishedDate)); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to update EPUB metadata: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Keep a copy of the original so the edit can be rolled back
+	if h.config.EPUB.BackupOnEdit {
+		if err := h.backupBookFile(book); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to back up EPUB file: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Save the modified EPUB file
+	if err := editor.Save(); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save EPUB file: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	if needsFileMove {
+		// Move the file to new location
+		if err := h.moveBookFile(book.FilePath, newFilePath); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to move file: %v", err), http.StatusInternalServerError)
+			return
+		}
+	} else {
+		// Keep the same file path
+		newFilePath = book.FilePath
+	}
+
+	// Update database with new metadata and file path
+	if needsFileMove {
+		if err := h.db.UpdateBookWithPath(bookID, editRequest.Title, editRequest.Author, editRequest.ISBN, editRequest.Publisher, newFilePath); err != nil {
+			http.Error(w, "Failed to update database", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		if err := h.db.UpdateBook(bookID, editRequest.Title, editRequest.Author, editRequest.ISBN, editRequest.Publisher); err != nil {
+			http.Error(w, "Failed to update database", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if editRequest.PublishedDate != nil {
+		if err := h.db.UpdatePublishedDate(bookID, strings.TrimSpace(*editRequest.PublishedDate)); err != nil {
+			http.Error(w, "Failed to update database", http.StatusInternalServerError)
+			return
+		}
 	}
-	defer reader.Close()
-
-	// Find the requested file in the EPUB
-	for _, file := range reader.File {
-		if file.Name == filePath {
-			// Open the file
-			rc, err := file.Open()
-			if err != nil {
-				http.Error(w, "Failed to open file in EPUB", http.StatusInternalServerError)
-				return
-			}
-			defer rc.Close()
-
-			// Set appropriate content type
-			ext := strings.ToLower(filepath.Ext(filePath))
-			switch ext {
-			case ".xml":
-				w.Header().Set("Content-Type", "application/xml")
-			case ".xhtml", ".html":
-				w.Header().Set("Content-Type", "application/xhtml+xml")
-			case ".css":
-				w.Header().Set("Content-Type", "text/css")
-			case ".js":
-				w.Header().Set("Content-Type", "application/javascript")
-			case ".png":
-				w.Header().Set("Content-Type", "image/png")
-			case ".jpg", ".jpeg":
-				w.Header().Set("Content-Type", "image/jpeg")
-			case ".gif":
-				w.Header().Set("Content-Type", "image/gif")
-			case ".svg":
-				w.Header().Set("Content-Type", "image/svg+xml")
-			default:
-				w.Header().Set("Content-Type", "application/octet-stream")
-			}
 
-			// Copy file content to response
-			_, err = io.Copy(w, rc)
-			if err != nil {
-				http.Error(w, "Failed to serve file content", http.StatusInternalServerError)
-				return
-			}
+	if description != "" {
+		if err := h.db.UpdateDescription(bookID, description); err != nil {
+			http.Error(w, "Failed to update database", http.StatusInternalServerError)
 			return
 		}
 	}
 
-	// File not found in EPUB
-	http.Error(w, "File not found in EPUB", http.StatusNotFound)
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, map[string]string{
+		"message": "Book metadata updated successfully",
+	})
 }
 
-// EditBookMetadata handles editing book metadata
-func (h *BooksHandler) EditBookMetadata(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "PUT" {
+// RestoreBackup rolls a book back to its most recent pre-edit backup
+func (h *BooksHandler) RestoreBackup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Extract book ID from URL path
-	// URL format: /api/books/{id}/edit
-	pathParts := strings.Split(r.URL.Path, "/")
-	if len(pathParts) < 5 || pathParts[4] != "edit" {
-		http.Error(w, "Invalid URL format", http.StatusBadRequest)
-		return
-	}
-
-	bookID, err := strconv.Atoi(pathParts[3])
+	// URL format: /api/books/{id}/restore-backup
+	bookID, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		http.Error(w, "Invalid book ID", http.StatusBadRequest)
 		return
 	}
 
-	// Parse request body
-	var editRequest struct {
-		Title     string `json:"title"`
-		Author    string `json:"author"`
-		ISBN      string `json:"isbn"`
-		Publisher string `json:"publisher"`
-	}
-
-	if err := json.NewDecoder(r.Body).Decode(&editRequest); err != nil {
-		http.Error(w, "Invalid JSON", http.StatusBadRequest)
-		return
-	}
-
-	// Get book from database
 	book, err := h.db.GetBookByID(bookID)
 	if err != nil {
-		http.Error(w, "Book not found", http.StatusNotFound)
+		writeBookNotFound(w, r, bookID)
 		return
 	}
 
-	// Check if it's an EPUB file
-	if book.Format != "epub" {
-		http.Error(w, "Only EPUB files can be edited", http.StatusBadRequest)
+	backupPath, err := h.latestBackup(bookID)
+	if err != nil {
+		http.Error(w, "No backup available for this book", http.StatusNotFound)
 		return
 	}
 
-	// Create EPUB editor and load the file
-	editor := epub.NewEPUBEditor(book.FilePath)
+	// Read the metadata stored in the backup to know where the book belongs
+	editor := epub.NewEPUBEditor(backupPath)
 	if err := editor.Load(); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to load EPUB file: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Failed to load backup: %v", err), http.StatusInternalServerError)
 		return
 	}
+	title, author, isbn, publisher := editor.GetCurrentMetadata()
+	if title == "" {
+		title = book.Title
+	}
+	if author == "" {
+		author = book.Author
+	}
+	if isbn == "" {
+		isbn = book.ISBN
+	}
+	if publisher == "" {
+		publisher = book.Publisher
+	}
 
-	// Update metadata in the EPUB file
-	if err := editor.UpdateMetadata(editRequest.Title, editRequest.Author, editRequest.ISBN, editRequest.Publisher); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update EPUB metadata: %v", err), http.StatusInternalServerError)
-		return
+	needsFileMove := (book.Author != author) || (book.Title != title)
+	newFilePath := book.FilePath
+	if needsFileMove {
+		newFilePath = h.generateNewFilePath(author, title, book.Format)
+		if conflictID, conflict := h.findPathConflict(newFilePath, book.FilePath); conflict {
+			h.writePathConflict(w, r, newFilePath, conflictID)
+			return
+		}
 	}
 
-	// Save the modified EPUB file
-	if err := editor.Save(); err != nil {
-		http.Error(w, fmt.Sprintf("Failed to save EPUB file: %v", err), http.StatusInternalServerError)
+	// Put the original content back, then move it to match the restored metadata
+	if err := replaceFile(backupPath, book.FilePath, h.config.LibraryFileMode()); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to restore backup: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	// Check if author or title changed to determine if file needs to be moved
-	needsFileMove := (book.Author != editRequest.Author) || (book.Title != editRequest.Title)
-	var newFilePath string
-
 	if needsFileMove {
-		// Generate new file path based on new author/title
-		newFilePath = h.generateNewFilePath(editRequest.Author, editRequest.Title, book.Format)
-
-		// Move the file to new location
 		if err := h.moveBookFile(book.FilePath, newFilePath); err != nil {
 			http.Error(w, fmt.Sprintf("Failed to move file: %v", err), http.StatusInternalServerError)
 			return
 		}
-	} else {
-		// Keep the same file path
-		newFilePath = book.FilePath
-	}
-
-	// Update database with new metadata and file path
-	if needsFileMove {
-		if err := h.db.UpdateBookWithPath(bookID, editRequest.Title, editRequest.Author, editRequest.ISBN, editRequest.Publisher, newFilePath); err != nil {
+		if err := h.db.UpdateBookWithPath(bookID, title, author, isbn, publisher, newFilePath); err != nil {
 			http.Error(w, "Failed to update database", http.StatusInternalServerError)
 			return
 		}
 	} else {
-		if err := h.db.UpdateBook(bookID, editRequest.Title, editRequest.Author, editRequest.ISBN, editRequest.Publisher); err != nil {
+		if err := h.db.UpdateBook(bookID, title, author, isbn, publisher); err != nil {
 			http.Error(w, "Failed to update database", http.StatusInternalServerError)
 			return
 		}
 	}
 
+	if err := h.db.UpdatePublishedDate(bookID, editor.GetCurrentDate()); err != nil {
+		http.Error(w, "Failed to update database", http.StatusInternalServerError)
+		return
+	}
+
+	// Consume the backup so repeated restores walk further back
+	if err := os.Remove(backupPath); err != nil {
+		log.Printf("Warning: failed to remove restored backup %s: %v", backupPath, err)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"message": "Book metadata updated successfully",
+	encodeJSON(w, r, map[string]string{
+		"message":   "Book restored from backup",
+		"file_path": newFilePath,
 	})
 }
 
+// backupDir returns the directory holding pre-edit backups for a book
+func (h *BooksHandler) backupDir(bookID int) string {
+	return filepath.Join(h.config.TmpDir, "backups", strconv.Itoa(bookID))
+}
+
+// backupBookFile copies a book's current file into its backup directory,
+// keeping at most epub.max_backups copies per book
+func (h *BooksHandler) backupBookFile(book models.Book) error {
+	dir := h.backupDir(book.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory %s: %v", dir, err)
+	}
+
+	backupPath := filepath.Join(dir, fmt.Sprintf("%d.epub", time.Now().UnixNano()))
+	if err := importservice.CopyFile(book.FilePath, backupPath, 0, false); err != nil {
+		return fmt.Errorf("failed to copy %s to %s: %v", book.FilePath, backupPath, err)
+	}
+
+	// Remove the oldest backups beyond the retention limit
+	backups, err := h.listBackups(book.ID)
+	if err != nil {
+		return nil // The backup itself succeeded
+	}
+	maxBackups := h.config.EPUB.MaxBackups
+	if maxBackups < 1 {
+		maxBackups = 1
+	}
+	for i := 0; i < len(backups)-maxBackups; i++ {
+		os.Remove(backups[i])
+	}
+
+	return nil
+}
+
+// listBackups returns a book's backup files, oldest first
+func (h *BooksHandler) listBackups(bookID int) ([]string, error) {
+	entries, err := os.ReadDir(h.backupDir(bookID))
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".epub" {
+			backups = append(backups, filepath.Join(h.backupDir(bookID), entry.Name()))
+		}
+	}
+
+	// Names are nanosecond timestamps, so lexical order is chronological
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// latestBackup returns the most recent backup for a book
+func (h *BooksHandler) latestBackup(bookID int) (string, error) {
+	backups, err := h.listBackups(bookID)
+	if err != nil {
+		return "", err
+	}
+	if len(backups) == 0 {
+		return "", fmt.Errorf("no backups found for book %d", bookID)
+	}
+	return backups[len(backups)-1], nil
+}
+
+// replaceFile overwrites dst with a copy of src. The copy is written next to dst and
+// renamed over it once complete, so an interrupted copy leaves dst intact.
+func replaceFile(src, dst string, mode os.FileMode) error {
+	temp := dst + ".restoring"
+	if err := importservice.CopyFile(src, temp, mode, false); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	if err := os.Rename(temp, dst); err != nil {
+		os.Remove(temp)
+		return err
+	}
+	return nil
+}
+
 // LookupISBN handles ISBN lookup requests
 func (h *BooksHandler) LookupISBN(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
@@ -623,7 +2006,7 @@ func (h *BooksHandler) LookupISBN(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(metadata)
+	encodeJSON(w, r, metadata)
 }
 
 // lookupGoogleBooks queries Google Books API for book metadata
@@ -725,47 +2108,131 @@ func (h *BooksHandler) lookupGoogleBooks(isbn string) (map[string]interface{}, e
 	}, nil
 }
 
-// generateNewFilePath creates a new file path based on author and title
+// generateNewFilePath creates a new file path in the scan directory from library.path_template
 func (h *BooksHandler) generateNewFilePath(author, title, format string) string {
-	// Clean author and title for filesystem
-	cleanAuthor := h.cleanForFilesystem(author)
-	cleanTitle := h.cleanForFilesystem(title)
+	relPath := metadata.RenderPathTemplate(h.config.Library.PathTemplate, author, title, format, h.config.Library.LeadingArticles, h.config.Library.AuthorDirStyle)
+	return filepath.Join(h.config.Library.ScanDirectory, relPath)
+}
 
-	// Create directory structure: Author/Title/
-	// Use scan directory from config
-	dirPath := filepath.Join(h.config.Library.ScanDirectory, cleanAuthor, cleanTitle)
+// PreviewPath returns the library path an edit or import would produce for the given metadata
+func (h *BooksHandler) PreviewPath(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	author := r.URL.Query().Get("author")
+	title := r.URL.Query().Get("title")
+	if author == "" || title == "" {
+		http.Error(w, "Author and title parameters are required", http.StatusBadRequest)
+		return
+	}
+
+	format := strings.TrimPrefix(strings.ToLower(r.URL.Query().Get("format")), ".")
+	if format == "" {
+		format = "epub"
+	}
+
+	targetPath := h.generateNewFilePath(author, title, format)
 
-	// Generate filename: Title - Author.epub
-	filename := fmt.Sprintf("%s - %s.%s", cleanTitle, cleanAuthor, format)
+	// Report whether something already lives at the target so the UI can warn
+	conflictID, exists := h.findPathConflict(targetPath, "")
 
-	return filepath.Join(dirPath, filename)
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, map[string]interface{}{
+		"path":                targetPath,
+		"author":              h.cleanForFilesystem(author),
+		"title":               h.cleanForFilesystem(title),
+		"format":              format,
+		"exists":              exists,
+		"conflicting_book_id": conflictID,
+	})
 }
 
 // cleanForFilesystem removes invalid characters for filesystem paths
 func (h *BooksHandler) cleanForFilesystem(s string) string {
-	// Remove or replace invalid characters
-	invalid := []string{"/", "\\", ":", "*", "?", "\"", "<", ">", "|"}
-	result := s
-	for _, char := range invalid {
-		result = strings.ReplaceAll(result, char, "")
+	return metadata.CleanPathComponent(s)
+}
+
+// findPathConflict reports whether targetPath is already occupied by something other than currentPath.
+// The returned ID is the conflicting book's database ID, or 0 if the file is not tracked.
+func (h *BooksHandler) findPathConflict(targetPath, currentPath string) (int, bool) {
+	targetInfo, err := os.Stat(targetPath)
+	if err != nil {
+		return 0, false // Nothing at the target, safe to move
+	}
+
+	// A case-only rename can resolve to the same file on case-insensitive filesystems
+	if currentPath != "" {
+		if currentInfo, err := os.Stat(currentPath); err == nil && os.SameFile(targetInfo, currentInfo) {
+			return 0, false
+		}
 	}
 
-	// Trim whitespace
-	result = strings.TrimSpace(result)
+	if existing, err := h.db.GetBookByFilePath(targetPath); err == nil {
+		return existing.ID, true
+	}
+
+	return 0, true
+}
+
+// listDescriptionLength is the number of characters of a description included in book lists
+const listDescriptionLength = 300
 
-	// Ensure it's not empty
-	if result == "" {
-		result = "Unknown"
+// shortenDescriptions truncates book descriptions for list responses; the full text is
+// returned by the book details endpoint
+func shortenDescriptions(books []models.Book) {
+	for i := range books {
+		description := []rune(books[i].Description)
+		if len(description) > listDescriptionLength {
+			books[i].Description = strings.TrimSpace(string(description[:listDescriptionLength])) + "…"
+		}
+	}
+}
+
+// pathRemainder returns the rest of the path matched by a route's final "*", which
+// the router leaves escaped when the request path has escaped slashes
+func pathRemainder(r *http.Request) string {
+	rest := r.PathValue("*")
+	if r.URL.RawPath != "" {
+		if unescaped, err := url.PathUnescape(rest); err == nil {
+			return unescaped
+		}
 	}
+	return rest
+}
+
+// writeBookNotFound writes a 404 response naming the requested book ID
+func writeBookNotFound(w http.ResponseWriter, r *http.Request, id int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusNotFound)
+	encodeJSON(w, r, models.NotFoundResponse{
+		Error: "Book not found",
+		ID:    id,
+	})
+}
 
-	return result
+// writePathConflict writes a 409 response describing the occupied target path
+func (h *BooksHandler) writePathConflict(w http.ResponseWriter, r *http.Request, targetPath string, conflictID int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusConflict)
+	encodeJSON(w, r, models.PathConflictResponse{
+		Error:             "Target path is already occupied by another book",
+		TargetPath:        targetPath,
+		ConflictingBookID: conflictID,
+	})
 }
 
 // moveBookFile moves a book file to a new location
 func (h *BooksHandler) moveBookFile(oldPath, newPath string) error {
+	// Never overwrite a different file at the destination
+	if _, conflict := h.findPathConflict(newPath, oldPath); conflict {
+		return fmt.Errorf("target path %s is already occupied", newPath)
+	}
+
 	// Create the new directory if it doesn't exist
 	newDir := filepath.Dir(newPath)
-	if err := os.MkdirAll(newDir, 0755); err != nil {
+	if err := fsmode.MkdirAll(newDir, h.config.LibraryDirMode()); err != nil {
 		return fmt.Errorf("failed to create directory %s: %v", newDir, err)
 	}
 
@@ -783,6 +2250,16 @@ func (h *BooksHandler) moveBookFile(oldPath, newPath string) error {
 	return nil
 }
 
+// walkOptions returns how walks of the quarantine directory treat symlinks and
+// ignored names, following the library settings
+func (h *BooksHandler) walkOptions() fswalk.Options {
+	return fswalk.Options{
+		FollowSymlinks: h.config.Library.FollowSymlinks,
+		SkipHidden:     h.config.Library.SkipHidden,
+		IgnoreNames:    h.config.Library.IgnoreNames,
+	}
+}
+
 // GetQuarantineBooks returns all books in the quarantine directory
 func (h *BooksHandler) GetQuarantineBooks(w http.ResponseWriter, r *http.Request) {
 	// Get quarantine directory from config
@@ -801,7 +2278,7 @@ func (h *BooksHandler) GetQuarantineBooks(w http.ResponseWriter, r *http.Request
 
 	// Scan quarantine directory for EPUB files
 	var quarantineBooks []models.QuarantineBook
-	err = filepath.Walk(quarantineDir, func(path string, info os.FileInfo, err error) error {
+	err = fswalk.Walk(quarantineDir, h.walkOptions(), func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil // Skip files we can't access
 		}
@@ -848,8 +2325,13 @@ func (h *BooksHandler) GetQuarantineBooks(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	// Ensure we return an empty array instead of null
+	if quarantineBooks == nil {
+		quarantineBooks = []models.QuarantineBook{}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(quarantineBooks)
+	encodeJSON(w, r, quarantineBooks)
 }
 
 // ServeQuarantineCover serves cover images for quarantine books using the same logic as main library
@@ -873,7 +2355,7 @@ func (h *BooksHandler) ServeQuarantineCover(w http.ResponseWriter, r *http.Reque
 	var quarantineBook *models.QuarantineBook
 	quarantineDir := h.config.Library.QuarantineDirectory
 
-	err := filepath.Walk(quarantineDir, func(path string, info os.FileInfo, err error) error {
+	err := fswalk.Walk(quarantineDir, h.walkOptions(), func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -916,7 +2398,7 @@ func (h *BooksHandler) ServeQuarantineCover(w http.ResponseWriter, r *http.Reque
 	defer reader.Close()
 
 	// Find cover image using the same logic as CoversHandler
-	coverPath, err := h.findCoverInOPF(reader)
+	coverPath, err := findCoverInOPF(reader)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Cover not found: %v", err), http.StatusNotFound)
 		return
@@ -943,111 +2425,6 @@ func (h *BooksHandler) ServeQuarantineCover(w http.ResponseWriter, r *http.Reque
 	w.Write(imageData)
 }
 
-// findCoverInOPF finds the cover image path in the OPF file using XML parsing
-// This is a copy of the logic from CoversHandler to avoid circular dependencies
-func (h *BooksHandler) findCoverInOPF(reader *zip.ReadCloser) (string, error) {
-	// Find the OPF file
-	var opfPath string
-	for _, file := range reader.File {
-		if strings.HasSuffix(file.Name, ".opf") {
-			opfPath = file.Name
-			break
-		}
-	}
-
-	if opfPath == "" {
-		return "", fmt.Errorf("no OPF file found")
-	}
-
-	// Read and parse the OPF file
-	opfFile, err := reader.Open(opfPath)
-	if err != nil {
-		return "", err
-	}
-	defer opfFile.Close()
-
-	// Parse XML using Go's standard library
-	opfData, err := io.ReadAll(opfFile)
-	if err != nil {
-		return "", err
-	}
-
-	// Define OPF structures locally to avoid conflicts
-	type OPFDocument struct {
-		XMLName  xml.Name `xml:"package"`
-		Metadata struct {
-			Meta []MetaTag `xml:"meta"`
-		} `xml:"metadata"`
-		Manifest struct {
-			Items []ManifestItem `xml:"item"`
-		} `xml:"manifest"`
-	}
-
-	type MetaTag struct {
-		Name    string `xml:"name,attr"`
-		Content string `xml:"content,attr"`
-	}
-
-	type ManifestItem struct {
-		ID   string `xml:"id,attr"`
-		Href string `xml:"href,attr"`
-	}
-
-	var opf OPFDocument
-	if err := xml.Unmarshal(opfData, &opf); err != nil {
-		return "", fmt.Errorf("failed to parse OPF XML: %v", err)
-	}
-
-	// Step 1: Find cover metadata
-	var coverID string
-	for _, meta := range opf.Metadata.Meta {
-		if meta.Name == "cover" {
-			coverID = meta.Content
-			fmt.Printf("Found cover metadata: <meta name=\"cover\" content=\"%s\"/>\n", coverID)
-			break
-		}
-	}
-
-	if coverID == "" {
-		// Fallback: look for direct cover references in manifest
-		for _, item := range opf.Manifest.Items {
-			if item.ID == "cover" || strings.Contains(item.ID, "cover") {
-				fmt.Printf("Found direct cover reference: %s\n", item.Href)
-				// Make path relative to OPF file location
-				opfDir := filepath.Dir(opfPath)
-				if opfDir != "." {
-					return filepath.Join(opfDir, item.Href), nil
-				}
-				return item.Href, nil
-			}
-		}
-		return "", fmt.Errorf("no cover metadata found in OPF")
-	}
-
-	// Step 2: Find manifest item by cover ID
-	var coverPath string
-	for _, item := range opf.Manifest.Items {
-		if item.ID == coverID {
-			coverPath = item.Href
-			fmt.Printf("Found cover image in manifest: %s\n", coverPath)
-			break
-		}
-	}
-
-	if coverPath == "" {
-		return "", fmt.Errorf("cover ID '%s' not found in manifest", coverID)
-	}
-
-	// Step 3: Make path relative to OPF file location
-	opfDir := filepath.Dir(opfPath)
-	if opfDir != "." {
-		coverPath = filepath.Join(opfDir, coverPath)
-	}
-
-	fmt.Printf("Resolved cover path: %s\n", coverPath)
-	return coverPath, nil
-}
-
 // SearchMetadata searches for book metadata using Open Library API
 func (h *BooksHandler) SearchMetadata(w http.ResponseWriter, r *http.Request) {
 	fmt.Printf("🚀 SearchMetadata API called\n")
@@ -1097,7 +2474,7 @@ func (h *BooksHandler) SearchMetadata(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	encodeJSON(w, r, response)
 }
 
 // normalizeSearchText cleans and normalizes text for search
@@ -1124,9 +2501,15 @@ func (h *BooksHandler) searchOpenLibrary(title, author string) ([]models.Metadat
 		searchQuery += " " + h.normalizeSearchText(author)
 	}
 
+	// Each result costs a work details request, so only ask for as many as will be scored
+	maxDocs := h.config.Metadata.MaxDocsExamined
+	if maxDocs < 1 {
+		maxDocs = 15
+	}
+
 	// Build URL with generic q parameter
 	baseURL := "https://openlibrary.org/search.json"
-	searchURL := fmt.Sprintf("%s?q=%s", baseURL, url.QueryEscape(searchQuery))
+	searchURL := fmt.Sprintf("%s?q=%s&limit=%d", baseURL, url.QueryEscape(searchQuery), maxDocs)
 
 	// Debug logging
 	fmt.Printf("🔍 Open Library Search Request:\n")
@@ -1192,6 +2575,10 @@ func (h *BooksHandler) searchOpenLibrary(title, author string) ([]models.Metadat
 	}
 
 	fmt.Printf("📚 Found %d documents in Open Library response\n", len(searchResponse.Docs))
+	if len(searchResponse.Docs) > maxDocs {
+		searchResponse.Docs = searchResponse.Docs[:maxDocs]
+		fmt.Printf("   Examining the first %d documents\n", maxDocs)
+	}
 
 	// Process results and calculate confidence scores
 	var suggestions []models.MetadataSuggestion
@@ -1434,7 +2821,9 @@ func (h *BooksHandler) loadQuarantineReasons() (map[string]QuarantineReason, err
 		return quarantineReasons, err
 	}
 
-	// Process log files in reverse chronological order (newest first)
+	// Process log files in reverse chronological order (newest first), so a quarantine
+	// retry's reason replaces the one from the original import
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().After(files[j].ModTime()) })
 	for _, file := range files {
 		if filepath.Ext(file.Name()) == ".json" {
 			logPath := filepath.Join(logDir, file.Name())
@@ -1498,23 +2887,15 @@ func (h *BooksHandler) extractMetadata(filePath string) (models.BookRequest, err
 
 // extractFromFilename extracts basic metadata from filename
 func (h *BooksHandler) extractFromFilename(filePath string) models.BookRequest {
-	filename := filepath.Base(filePath)
-	// Remove extension
-	name := strings.TrimSuffix(filename, filepath.Ext(filename))
-
-	// Try to parse "Title - Author" format
-	parts := strings.Split(name, " - ")
-	if len(parts) >= 2 {
-		return models.BookRequest{
-			Title:  strings.TrimSpace(parts[0]),
-			Author: strings.TrimSpace(parts[1]),
-		}
+	title, author := h.db.Extractor().FilenameParser().Parse(filePath)
+	if author == "" {
+		// Fallback to filename as title
+		author = "Unknown"
 	}
 
-	// Fallback to filename as title
 	return models.BookRequest{
-		Title:  name,
-		Author: "Unknown",
+		Title:  title,
+		Author: author,
 	}
 }
 
@@ -1537,6 +2918,11 @@ func (h *BooksHandler) EditQuarantineBook(w http.ResponseWriter, r *http.Request
 		http.Error(w, "Invalid JSON", http.StatusBadRequest)
 		return
 	}
+	clean := h.db.Extractor().CleanText
+	editRequest.Title = clean(editRequest.Title)
+	editRequest.Author = clean(editRequest.Author)
+	editRequest.Publisher = clean(editRequest.Publisher)
+	editRequest.ISBN = strings.TrimSpace(editRequest.ISBN)
 
 	// Validate required fields
 	if editRequest.Title == "" || editRequest.Author == "" {
@@ -1553,9 +2939,15 @@ func (h *BooksHandler) EditQuarantineBook(w http.ResponseWriter, r *http.Request
 	// Generate new file path in scan directory
 	newFilePath := h.generateNewFilePath(editRequest.Author, editRequest.Title, "epub")
 
+	// Refuse to overwrite a book already in the library
+	if conflictID, conflict := h.findPathConflict(newFilePath, editRequest.FilePath); conflict {
+		h.writePathConflict(w, r, newFilePath, conflictID)
+		return
+	}
+
 	// Create the new directory structure
 	newDir := filepath.Dir(newFilePath)
-	if err := os.MkdirAll(newDir, 0755); err != nil {
+	if err := fsmode.MkdirAll(newDir, h.config.LibraryDirMode()); err != nil {
 		http.Error(w, fmt.Sprintf("Failed to create directory: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -1582,6 +2974,10 @@ func (h *BooksHandler) EditQuarantineBook(w http.ResponseWriter, r *http.Request
 		Format:    "epub",
 		ISBN:      editRequest.ISBN,
 		Publisher: editRequest.Publisher,
+		UID:       metadata.UniqueIdentifier(newFilePath),
+	}
+	if hasDRM, err := epub.DetectDRM(newFilePath); err == nil {
+		book.DRM = hasDRM
 	}
 
 	if err := h.db.AddBook(book); err != nil {
@@ -1598,7 +2994,7 @@ func (h *BooksHandler) EditQuarantineBook(w http.ResponseWriter, r *http.Request
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
+	encodeJSON(w, r, map[string]string{
 		"message": "Quarantine book processed successfully",
 	})
 }
@@ -1699,7 +3095,71 @@ func (h *BooksHandler) GetLibraryStats(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(stats)
+	encodeJSON(w, r, stats)
+}
+
+// GetBooksByYear returns book counts bucketed by publication year and by year added
+func (h *BooksHandler) GetBooksByYear(w http.ResponseWriter, r *http.Request) {
+	publicationYears, err := h.db.CountByPublicationYear()
+	if err != nil {
+		http.Error(w, "Failed to count books by publication year", http.StatusInternalServerError)
+		return
+	}
+
+	addedYears, err := h.db.CountByAddedYear()
+	if err != nil {
+		http.Error(w, "Failed to count books by added year", http.StatusInternalServerError)
+		return
+	}
+
+	// Ensure we return empty arrays instead of null
+	if publicationYears == nil {
+		publicationYears = []models.YearCount{}
+	}
+	if addedYears == nil {
+		addedYears = []models.YearCount{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, map[string]interface{}{
+		"publication_year": publicationYears,
+		"added_year":       addedYears,
+	})
+}
+
+// GetDownloadStats returns the most downloaded books and download totals per format
+func (h *BooksHandler) GetDownloadStats(w http.ResponseWriter, r *http.Request) {
+	mostDownloaded, err := h.db.GetMostDownloadedBooks(h.parseLimit(r))
+	if err != nil {
+		http.Error(w, "Failed to get most downloaded books", http.StatusInternalServerError)
+		return
+	}
+
+	byFormat, err := h.db.CountDownloadsByFormat()
+	if err != nil {
+		http.Error(w, "Failed to count downloads by format", http.StatusInternalServerError)
+		return
+	}
+
+	// Ensure we return empty arrays instead of null
+	if mostDownloaded == nil {
+		mostDownloaded = []models.BookDownloadCount{}
+	}
+	if byFormat == nil {
+		byFormat = []models.FormatCount{}
+	}
+
+	total := 0
+	for _, format := range byFormat {
+		total += format.Count
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, map[string]interface{}{
+		"total_downloads": total,
+		"most_downloaded": mostDownloaded,
+		"by_format":       byFormat,
+	})
 }
 
 // getQuarantineBooksCount returns the number of books in quarantine directory
@@ -1717,7 +3177,7 @@ func (h *BooksHandler) getQuarantineBooksCount() (int, error) {
 
 	// Count EPUB files in quarantine directory
 	count := 0
-	err := filepath.Walk(quarantineDir, func(path string, info os.FileInfo, err error) error {
+	err := fswalk.Walk(quarantineDir, h.walkOptions(), func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
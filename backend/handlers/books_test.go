@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"fableflow/backend/config"
+	"fableflow/backend/models"
+)
+
+func TestAddBookIgnoresClientDRM(t *testing.T) {
+	h := newTestBooksHandler(t, &config.Config{})
+	body := `{"title": "Frankenstein", "author": "Mary Shelley", "file_path": "` + sampleEPUB + `", "format": "epub", "drm": true}`
+	w := httptest.NewRecorder()
+	h.AddBook(w, httptest.NewRequest("POST", "/api/books", strings.NewReader(body)))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	book, err := h.db.GetBookByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.DRM {
+		t.Error("a client flagged an unprotected book as DRM-protected")
+	}
+}
+
+func TestFindPathConflict(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Library.ScanDirectory = t.TempDir()
+	h := newTestBooksHandler(t, cfg)
+
+	// Two titles that clean to the same library path
+	first := h.generateNewFilePath("Mary Shelley", "Frankenstein", "epub")
+	if target := h.generateNewFilePath("Mary Shelley", "Frankenstein?", "epub"); target != first {
+		t.Fatalf("paths %q and %q differ, want the same", first, target)
+	}
+	second := filepath.Join(cfg.Library.ScanDirectory, "Mary Shelley", "The Modern Prometheus.epub")
+	untracked := filepath.Join(cfg.Library.ScanDirectory, "Untracked.epub")
+	for _, path := range []string{first, second, untracked} {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, path := range []string{first, second} {
+		book := testBook(path)
+		book.Author = "Mary Shelley"
+		if err := h.db.AddBook(book); err != nil {
+			t.Fatalf("AddBook: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name            string
+		target, current string
+		wantID          int
+		wantConflict    bool
+	}{
+		{"another book", first, second, 1, true},
+		{"the book itself", first, first, 0, false},
+		{"untracked file", untracked, second, 0, true},
+		{"nothing there", filepath.Join(cfg.Library.ScanDirectory, "Free.epub"), second, 0, false},
+	}
+	for _, tt := range tests {
+		if id, conflict := h.findPathConflict(tt.target, tt.current); id != tt.wantID || conflict != tt.wantConflict {
+			t.Errorf("%s: findPathConflict = %d, %v; want %d, %v", tt.name, id, conflict, tt.wantID, tt.wantConflict)
+		}
+	}
+
+	// Renaming the second book to the first one's title is refused before anything moves
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("PUT", "/api/books/2/edit", strings.NewReader(`{"title": "Frankenstein?", "author": "Mary Shelley"}`))
+	r.SetPathValue("id", "2")
+	h.EditBookMetadata(w, r)
+	var response models.PathConflictResponse
+	if w.Code != http.StatusConflict || json.Unmarshal(w.Body.Bytes(), &response) != nil || response.ConflictingBookID != 1 {
+		t.Fatalf("edit: status %d: %s; want a conflict with book 1", w.Code, w.Body)
+	}
+	for _, path := range []string{first, second} {
+		if data, err := os.ReadFile(path); err != nil || string(data) != path {
+			t.Errorf("%s was changed: %q, %v", path, data, err)
+		}
+	}
+}
+
+func TestGetBooksByAuthorSeries(t *testing.T) {
+	h := newTestBooksHandler(t, &config.Config{})
+	books := []models.BookRequest{
+		{Title: "Zebra Stories"},
+		{Title: "Sourcery", Series: "Discworld", SeriesIndex: 5},
+		{Title: "Truckers", Series: "Bromeliad", SeriesIndex: 1},
+		{Title: "The Carpet People"},
+		{Title: "The Colour of Magic", Series: "Discworld", SeriesIndex: 1},
+		{Title: "Wings", Series: "Bromeliad", SeriesIndex: 3},
+		{Title: "Diggers", Series: "Bromeliad", SeriesIndex: 2},
+	}
+	for i, book := range books {
+		book.Author, book.Format = "Terry Pratchett", "epub"
+		book.FilePath = filepath.Join("/library", strconv.Itoa(i)+".epub")
+		if err := h.db.AddBook(book); err != nil {
+			t.Fatalf("AddBook: %v", err)
+		}
+	}
+	titles := func(books []models.Book) []string {
+		var titles []string
+		for _, book := range books {
+			titles = append(titles, book.Title)
+		}
+		return titles
+	}
+
+	w := httptest.NewRecorder()
+	h.GetBooksByAuthor(w, httptest.NewRequest("GET", "/api/authors/books?author=Terry+Pratchett", nil))
+	var list []models.Book
+	if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	want := []string{"Truckers", "Diggers", "Wings", "The Colour of Magic", "Sourcery", "The Carpet People", "Zebra Stories"}
+	if got := titles(list); !reflect.DeepEqual(got, want) {
+		t.Errorf("books = %q, want %q", got, want)
+	}
+
+	w = httptest.NewRecorder()
+	h.GetBooksByAuthor(w, httptest.NewRequest("GET", "/api/authors/books?author=Terry+Pratchett&group_by=series", nil))
+	var grouped struct {
+		Series     []models.SeriesGroup `json:"series"`
+		Standalone []models.Book        `json:"standalone"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &grouped); err != nil {
+		t.Fatalf("group_by=series: status %d: %s", w.Code, w.Body)
+	}
+	if len(grouped.Series) != 2 {
+		t.Fatalf("series = %+v, want Bromeliad and Discworld", grouped.Series)
+	}
+	for i, want := range []struct {
+		series string
+		titles []string
+	}{
+		{"Bromeliad", []string{"Truckers", "Diggers", "Wings"}},
+		{"Discworld", []string{"The Colour of Magic", "Sourcery"}},
+	} {
+		if got := grouped.Series[i]; got.Series != want.series || !reflect.DeepEqual(titles(got.Books), want.titles) {
+			t.Errorf("series %d = %q %q, want %q %q", i, got.Series, titles(got.Books), want.series, want.titles)
+		}
+	}
+	if got, want := titles(grouped.Standalone), []string{"The Carpet People", "Zebra Stories"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("standalone = %q, want %q", got, want)
+	}
+}
+
+func TestReplaceFile(t *testing.T) {
+	dir := t.TempDir()
+	backup, book := filepath.Join(dir, "backup.epub"), filepath.Join(dir, "book.epub")
+	for path, content := range map[string]string{backup: "original", book: "edited"} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := replaceFile(backup, book, 0640); err != nil {
+		t.Fatalf("replaceFile: %v", err)
+	}
+	if data, _ := os.ReadFile(book); string(data) != "original" {
+		t.Errorf("book holds %q, want the backup", data)
+	}
+	if info, _ := os.Stat(book); info.Mode().Perm() != 0640 {
+		t.Errorf("mode = %v, want 0640", info.Mode().Perm())
+	}
+
+	// A copy that fails part way leaves the book as it was, without a stray temp file
+	if err := replaceFile(dir, book, 0); err == nil {
+		t.Fatal("replaceFile copied a directory")
+	}
+	if data, _ := os.ReadFile(book); string(data) != "original" {
+		t.Errorf("failed replace left %q", data)
+	}
+	if _, err := os.Stat(book + ".restoring"); !os.IsNotExist(err) {
+		t.Errorf("temp file left behind: %v", err)
+	}
+}
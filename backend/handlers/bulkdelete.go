@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fableflow/backend/fsmode"
+	"fableflow/backend/importservice"
+)
+
+// deleteTokenLifetime is how long a bulk delete confirmation token stays valid
+const deleteTokenLifetime = 5 * time.Minute
+
+// bulkDeleteAction is what a bulk delete does with one book's file: "trash" moves it
+// to target, "delete" removes it and "missing" only removes the book, whose file is gone
+type bulkDeleteAction struct {
+	BookID   int    `json:"book_id"`
+	FilePath string `json:"file_path"`
+	Action   string `json:"action"`
+	Target   string `json:"target,omitempty"`
+}
+
+// bulkDeleteFailure is a book a bulk delete could not remove
+type bulkDeleteFailure struct {
+	BookID int    `json:"book_id"`
+	Error  string `json:"error"`
+}
+
+// BulkDeleteBooks deletes every book matching a filter in two steps
+// (DELETE /api/books?author=|query=|format=|book_ids=1,2,3). Without a token it only
+// returns the matching books and a confirmation token; repeating the request with
+// &token= deletes them, provided the filter still matches exactly the same books.
+// Book files are moved to library.trash_directory, or deleted with permanent=true.
+// With dry_run=true nothing is deleted, token or not: the response is the preview
+// with what would happen to each file.
+func (h *BooksHandler) BulkDeleteBooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := BookFilter{
+		Author: query.Get("author"),
+		Query:  query.Get("query"),
+		Format: query.Get("format"),
+	}
+	if idsParam := strings.TrimSpace(query.Get("book_ids")); idsParam != "" {
+		for _, idStr := range strings.Split(idsParam, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(idStr))
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid book ID: %s", idStr), http.StatusBadRequest)
+				return
+			}
+			filter.BookIDs = append(filter.BookIDs, id)
+		}
+	}
+	if !filter.valid() {
+		http.Error(w, "Exactly one of author, query, format or book_ids is required", http.StatusBadRequest)
+		return
+	}
+	permanent := query.Get("permanent") == "true"
+	dryRun := query.Get("dry_run") == "true"
+
+	books, err := h.filterBooks(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	bookIDs := make([]int, len(books))
+	for i, book := range books {
+		bookIDs[i] = book.ID
+	}
+	sort.Ints(bookIDs)
+
+	token := query.Get("token")
+	if token == "" || dryRun {
+		response := map[string]interface{}{
+			"matched":   len(bookIDs),
+			"book_ids":  bookIDs,
+			"permanent": permanent,
+		}
+		if dryRun {
+			actions := make([]bulkDeleteAction, 0, len(books))
+			for _, book := range books {
+				actions = append(actions, h.planBookFileDeletion(book.ID, book.FilePath, permanent))
+			}
+			response["dry_run"] = true
+			response["actions"] = actions
+		}
+		if len(bookIDs) > 0 {
+			expiresAt := time.Now().Add(deleteTokenLifetime).UTC()
+			response["token"] = fmt.Sprintf("%d.%s", expiresAt.Unix(), h.deleteSignature(bookIDs, permanent, expiresAt.Unix()))
+			response["expires_at"] = expiresAt
+		}
+		w.Header().Set("Content-Type", "application/json")
+		encodeJSON(w, r, response)
+		return
+	}
+
+	expiresStr, signature, _ := strings.Cut(token, ".")
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid confirmation token", http.StatusBadRequest)
+		return
+	}
+	if time.Now().Unix() > expires {
+		http.Error(w, "Confirmation token has expired, request a new one", http.StatusBadRequest)
+		return
+	}
+	if !hmac.Equal([]byte(signature), []byte(h.deleteSignature(bookIDs, permanent, expires))) {
+		http.Error(w, "The books matching the filter changed since the token was issued, request a new one", http.StatusConflict)
+		return
+	}
+
+	deleted := 0
+	failed := []bulkDeleteFailure{}
+	for _, book := range books {
+		if err := h.deleteBookFile(book.FilePath, permanent); err != nil {
+			failed = append(failed, bulkDeleteFailure{BookID: book.ID, Error: err.Error()})
+			continue
+		}
+		if err := h.db.RemoveBook(book.ID); err != nil {
+			failed = append(failed, bulkDeleteFailure{BookID: book.ID, Error: err.Error()})
+			continue
+		}
+		deleted++
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, map[string]interface{}{
+		"matched":   len(bookIDs),
+		"deleted":   deleted,
+		"failed":    failed,
+		"permanent": permanent,
+	})
+}
+
+// deleteSignature signs the sorted IDs of the books a bulk delete would remove
+func (h *BooksHandler) deleteSignature(bookIDs []int, permanent bool, expires int64) string {
+	mac := hmac.New(sha256.New, h.shareSecret)
+	fmt.Fprintf(mac, "delete:%d:%t:", expires, permanent)
+	for _, id := range bookIDs {
+		fmt.Fprintf(mac, "%d,", id)
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// planBookFileDeletion returns what deleteBookFile would do with a book's file
+func (h *BooksHandler) planBookFileDeletion(bookID int, path string, permanent bool) bulkDeleteAction {
+	action := bulkDeleteAction{BookID: bookID, FilePath: path}
+	_, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+		action.Action = "missing"
+	case permanent:
+		action.Action = "delete"
+	default:
+		action.Action = "trash"
+		action.Target = h.trashTarget(path)
+	}
+	return action
+}
+
+// trashTarget returns where a book file goes under library.trash_directory: its path
+// relative to the library, with a timestamp when an earlier deletion is already there.
+// It is "" when no trash directory is configured.
+func (h *BooksHandler) trashTarget(path string) string {
+	trashDir := h.config.Library.TrashDirectory
+	if trashDir == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(h.config.Library.ScanDirectory, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		rel = filepath.Base(path)
+	}
+	target := filepath.Join(trashDir, rel)
+	if _, err := os.Stat(target); err == nil {
+		// Keep the earlier deletion of a book at the same path
+		ext := filepath.Ext(target)
+		target = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(target, ext), time.Now().Unix(), ext)
+	}
+	return target
+}
+
+// deleteBookFile removes a book's file from the library: permanently, or by moving it
+// under library.trash_directory at its path relative to the library. A file that is
+// already gone is not an error.
+func (h *BooksHandler) deleteBookFile(path string, permanent bool) error {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	if permanent {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	} else {
+		target := h.trashTarget(path)
+		if target == "" {
+			return errors.New("trash directory not configured")
+		}
+		if err := fsmode.MkdirAll(filepath.Dir(target), h.config.LibraryDirMode()); err != nil {
+			return fmt.Errorf("failed to create directory %s: %v", filepath.Dir(target), err)
+		}
+		if err := os.Rename(path, target); err != nil {
+			// The trash may be on another filesystem
+			if err := importservice.CopyFile(path, target, h.config.LibraryFileMode(), false); err != nil {
+				return fmt.Errorf("failed to move %s to the trash: %v", path, err)
+			}
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Clean up directories the book leaves empty, but never the library itself
+	if dir := filepath.Dir(path); filepath.Clean(dir) != filepath.Clean(h.config.Library.ScanDirectory) {
+		if err := h.cleanupEmptyDirectories(dir); err != nil {
+			// Log the error but don't fail the operation
+			fmt.Printf("Warning: failed to cleanup empty directories: %v\n", err)
+		}
+	}
+	return nil
+}
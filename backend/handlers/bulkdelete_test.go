@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fableflow/backend/config"
+)
+
+func TestBulkDeleteDryRun(t *testing.T) {
+	library, trash := t.TempDir(), t.TempDir()
+	cfg := &config.Config{}
+	cfg.Library.ScanDirectory = library
+	cfg.Library.TrashDirectory = trash
+	h := newTestBooksHandler(t, cfg)
+
+	kept := filepath.Join(library, "Author", "Title.epub")
+	if err := os.MkdirAll(filepath.Dir(kept), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(kept, []byte("epub"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{kept, filepath.Join(library, "Gone.epub")} {
+		if err := h.db.AddBook(testBook(path)); err != nil {
+			t.Fatalf("AddBook: %v", err)
+		}
+	}
+
+	deleteBooks := func(query string) map[string]interface{} {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.BulkDeleteBooks(w, httptest.NewRequest("DELETE", "/api/books?book_ids=1,2"+query, nil))
+		var response map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		return response
+	}
+
+	preview := deleteBooks("&dry_run=true")
+	actions, _ := preview["actions"].([]interface{})
+	if len(actions) != 2 {
+		t.Fatalf("actions = %v, want 2", preview["actions"])
+	}
+	trashed := actions[0].(map[string]interface{})
+	if trashed["action"] != "trash" || trashed["target"] != filepath.Join(trash, "Author", "Title.epub") {
+		t.Errorf("first action = %v, want a move to the trash", trashed)
+	}
+	if missing := actions[1].(map[string]interface{}); missing["action"] != "missing" {
+		t.Errorf("second action = %v, want missing", missing)
+	}
+
+	// A dry run does nothing even with a valid token
+	token, _ := preview["token"].(string)
+	deleteBooks("&dry_run=true&token=" + token)
+	if _, err := os.Stat(kept); err != nil {
+		t.Fatalf("dry run removed the book file: %v", err)
+	}
+	if books, _ := h.db.GetAllBooks(); len(books) != 2 {
+		t.Fatalf("dry run left %d books, want 2", len(books))
+	}
+
+	if result := deleteBooks("&token=" + token); result["deleted"] != 2.0 {
+		t.Fatalf("deleted = %v, want 2: %v", result["deleted"], result)
+	}
+	if _, err := os.Stat(trashed["target"].(string)); err != nil {
+		t.Errorf("book file is not where the dry run said: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// CleanupEmptyDirectories removes every empty directory under the library, including
+// directories left holding only empty directories (POST /api/library/cleanup-dirs).
+// The library itself and the configured import, quarantine, trash, covers and temporary
+// directories are kept. With ?dry_run=true nothing is removed and the response lists what
+// would be.
+func (h *BooksHandler) CleanupEmptyDirectories(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	root := filepath.Clean(h.config.Library.ScanDirectory)
+	if _, err := os.Stat(root); err != nil {
+		http.Error(w, fmt.Sprintf("Library directory is not available: %v", err), http.StatusInternalServerError)
+		return
+	}
+	keep := map[string]bool{root: true}
+	for _, dir := range []string{
+		h.config.Library.ImportDirectory,
+		h.config.Library.QuarantineDirectory,
+		h.config.Library.TrashDirectory,
+		h.config.Covers.CustomDirectory,
+		h.config.TmpDir,
+	} {
+		if dir != "" {
+			keep[filepath.Clean(dir)] = true
+		}
+	}
+
+	removed := []string{}
+	failed := []string{}
+	var cleanup func(dir string) bool
+	// cleanup removes the empty directories below dir bottom-up and reports whether dir
+	// is (or in a dry run would be) empty afterwards
+	cleanup = func(dir string) bool {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", dir, err))
+			return false
+		}
+		empty := true
+		for _, entry := range entries {
+			// Symlinks are left alone, even to directories
+			if !entry.IsDir() {
+				empty = false
+				continue
+			}
+			sub := filepath.Join(dir, entry.Name())
+			if !cleanup(sub) || keep[sub] {
+				empty = false
+				continue
+			}
+			if !dryRun {
+				if err := os.Remove(sub); err != nil {
+					failed = append(failed, fmt.Sprintf("%s: %v", sub, err))
+					empty = false
+					continue
+				}
+			}
+			rel, err := filepath.Rel(root, sub)
+			if err != nil {
+				rel = sub
+			}
+			removed = append(removed, rel)
+		}
+		return empty
+	}
+	cleanup(root)
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, map[string]interface{}{
+		"removed":     len(removed),
+		"directories": removed,
+		"failed":      failed,
+		"dry_run":     dryRun,
+	})
+}
@@ -1,47 +1,226 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"fableflow/backend/conversion"
 	"fableflow/backend/database"
+	"fableflow/backend/jobs"
+	"fableflow/backend/models"
 )
 
 // ConversionHandler handles ebook conversion requests
 type ConversionHandler struct {
-	db     *database.Manager
-	tmpDir string
+	db            *database.Manager
+	jobs          *jobs.Manager // Runs conversions and batches and tracks them as jobs
+	tmpDir        string
+	convert       func(ctx context.Context, inputPath, outputPath string) error // Converts an EPUB to AZW3
+	maxConcurrent int
+	slots         chan struct{} // Semaphore bounding concurrent conversions
+	countsMutex   sync.Mutex
+	running       int
+	queued        int
+	activeMutex   sync.Mutex
+	active        map[string]*activeConversion // Queued and running conversions by "{book_id}_{format}"
+	batchesMutex  sync.Mutex
+	batches       map[string]*conversionBatch // Batch conversions by job ID
+	persistent    bool                        // Keep converted files across restarts (conversion.persistent_cache)
+	cacheMaxAge   time.Duration
+	cacheMaxBytes int64
+	apiKey        string // server.api_key, needed to download converted books
+}
+
+// activeConversion is a conversion that is waiting for a slot or running
+type activeConversion struct {
+	BookID     int       `json:"book_id"`
+	Format     string    `json:"format"`
+	Status     string    `json:"status"` // "queued", "running" or "cancelled"
+	StartedAt  time.Time `json:"started_at"`
+	cancel     context.CancelFunc
+	outputPath string
 }
 
 // TempFileInfo tracks temporary conversion files
 type TempFileInfo struct {
-	Path       string
-	CreatedAt  time.Time
-	Downloaded bool
-	BookID     int
-	Format     string
+	Path        string
+	CreatedAt   time.Time
+	Downloaded  bool
+	BookID      int
+	Format      string
+	Persistent  bool      // Kept in the conversions table until evicted, not deleted after download
+	SourcePath  string    // Book file the conversion was made from
+	SourceMtime time.Time // Its modification time then; a persistent conversion is stale once it changes
+}
+
+// Global map to track temporary files, guarded by tempFilesMutex
+var (
+	tempFiles      = make(map[string]*TempFileInfo)
+	tempFilesMutex sync.Mutex
+)
+
+// convertedFileTTL is how long a converted file stays available if it isn't downloaded
+const convertedFileTTL = 1 * time.Hour
+
+// usable reports whether a converted file can still be served: a temporary one until it
+// is downloaded or expires, a persistent one while its source file is unchanged
+func (t TempFileInfo) usable() bool {
+	if _, err := os.Stat(t.Path); err != nil {
+		return false
+	}
+	if t.Persistent {
+		info, err := os.Stat(t.SourcePath)
+		return err == nil && info.ModTime().Unix() == t.SourceMtime.Unix()
+	}
+	return !t.Downloaded && time.Since(t.CreatedAt) <= convertedFileTTL
 }
 
-// Global map to track temporary files
-var tempFiles = make(map[string]*TempFileInfo)
+// cachedConversions returns the converted files of a book that can still be downloaded
+func cachedConversions(bookID int) []TempFileInfo {
+	tempFilesMutex.Lock()
+	defer tempFilesMutex.Unlock()
 
-// NewConversionHandler creates a new conversion handler
-func NewConversionHandler(db *database.Manager, tmpDir string) *ConversionHandler {
+	var files []TempFileInfo
+	for _, tempFile := range tempFiles {
+		if tempFile.BookID == bookID && tempFile.usable() {
+			files = append(files, *tempFile)
+		}
+	}
+	return files
+}
+
+// NewConversionHandler creates a new conversion handler that runs its conversions as
+// jobs of jobManager, at most maxConcurrent of them converting at once
+func NewConversionHandler(db *database.Manager, jobManager *jobs.Manager, tmpDir string, maxConcurrent int) *ConversionHandler {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
 	return &ConversionHandler{
-		db:     db,
-		tmpDir: tmpDir,
+		db:            db,
+		jobs:          jobManager,
+		tmpDir:        tmpDir,
+		convert:       conversion.ConvertEPUBToAZW3Context,
+		maxConcurrent: maxConcurrent,
+		slots:         make(chan struct{}, maxConcurrent),
+		active:        make(map[string]*activeConversion),
+		batches:       make(map[string]*conversionBatch),
+	}
+}
+
+// RequireAPIKey makes downloads of converted books need the server.api_key
+func (h *ConversionHandler) RequireAPIKey(key string) {
+	h.apiKey = key
+}
+
+// acquireSlot waits for a free conversion slot, counting the caller as queued meanwhile.
+// It gives up when ctx is cancelled.
+func (h *ConversionHandler) acquireSlot(ctx context.Context) error {
+	h.countsMutex.Lock()
+	h.queued++
+	h.countsMutex.Unlock()
+
+	select {
+	case h.slots <- struct{}{}:
+	case <-ctx.Done():
+		h.countsMutex.Lock()
+		h.queued--
+		h.countsMutex.Unlock()
+		return ctx.Err()
+	}
+
+	h.countsMutex.Lock()
+	h.queued--
+	h.running++
+	h.countsMutex.Unlock()
+	return nil
+}
+
+// releaseSlot frees a conversion slot
+func (h *ConversionHandler) releaseSlot() {
+	h.countsMutex.Lock()
+	h.running--
+	h.countsMutex.Unlock()
+
+	<-h.slots
+}
+
+// startConversion registers a conversion so it can be cancelled. ok is false when
+// the same book is already being converted to the same format.
+func (h *ConversionHandler) startConversion(key string, bookID int, format, outputPath string) (context.Context, bool) {
+	h.activeMutex.Lock()
+	defer h.activeMutex.Unlock()
+
+	if _, exists := h.active[key]; exists {
+		return nil, false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	h.active[key] = &activeConversion{
+		BookID:     bookID,
+		Format:     format,
+		Status:     "queued",
+		StartedAt:  time.Now(),
+		cancel:     cancel,
+		outputPath: outputPath,
+	}
+	return ctx, true
+}
+
+// setConversionStatus updates the status of a registered conversion unless it was cancelled
+func (h *ConversionHandler) setConversionStatus(key, status string) {
+	h.activeMutex.Lock()
+	defer h.activeMutex.Unlock()
+
+	if job, exists := h.active[key]; exists && job.Status != "cancelled" {
+		job.Status = status
 	}
 }
 
-// ConvertBook converts a book to a different format
+// finishConversion unregisters a conversion once its request is done
+func (h *ConversionHandler) finishConversion(key string) {
+	h.activeMutex.Lock()
+	defer h.activeMutex.Unlock()
+
+	if job, exists := h.active[key]; exists {
+		job.cancel()
+		delete(h.active, key)
+	}
+}
+
+// activeConversions returns a snapshot of the queued and running conversions, oldest first
+func (h *ConversionHandler) activeConversions() []activeConversion {
+	h.activeMutex.Lock()
+	defer h.activeMutex.Unlock()
+
+	jobs := []activeConversion{}
+	for _, job := range h.active {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].StartedAt.Before(jobs[j].StartedAt) })
+	return jobs
+}
+
+// conversionCounts returns the number of running and queued conversions
+func (h *ConversionHandler) conversionCounts() (int, int) {
+	h.countsMutex.Lock()
+	defer h.countsMutex.Unlock()
+	return h.running, h.queued
+}
+
+// ConvertBook queues the conversion of a book to a different format and responds with
+// its job ID right away; GET /api/convert/job/{job_id} reports when it is done
 func (h *ConversionHandler) ConvertBook(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -68,74 +247,159 @@ func (h *ConversionHandler) ConvertBook(w http.ResponseWriter, r *http.Request)
 	// Get book details
 	book, err := h.db.GetBookByID(req.BookID)
 	if err != nil {
-		http.Error(w, "Book not found", http.StatusNotFound)
+		writeBookNotFound(w, r, req.BookID)
 		return
 	}
 
-	// Check if file exists
-	if _, err := os.Stat(book.FilePath); os.IsNotExist(err) {
-		http.Error(w, "Source file not found", http.StatusNotFound)
+	if status, problem := conversionProblem(book); status != 0 {
+		http.Error(w, problem, status)
 		return
 	}
 
-	// Check if it's an EPUB file
-	if !strings.HasSuffix(strings.ToLower(book.FilePath), ".epub") {
-		http.Error(w, "Only EPUB files can be converted to AZW3", http.StatusBadRequest)
+	// Register the conversion so POST /api/convert/{book_id}/{format}/cancel can abort it
+	tempFileKey := fmt.Sprintf("%d_%s", req.BookID, req.OutputFormat)
+	if h.persistent && hasCachedConversion(tempFileKey) {
+		w.Header().Set("Content-Type", "application/json")
+		encodeJSON(w, r, map[string]interface{}{
+			"success":       true,
+			"cached":        true,
+			"status":        "done",
+			"output_format": req.OutputFormat,
+			"message":       "Book was already converted and is unchanged. File is available for download.",
+		})
 		return
 	}
 
-	// Generate temporary output path using config tmp_dir
-	tempDir := filepath.Join(h.tmpDir, "conversions")
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
+	outputPath, err := h.conversionOutputPath(book, req.OutputFormat)
+	if err != nil {
 		http.Error(w, "Failed to create temp directory", http.StatusInternalServerError)
 		return
 	}
 
-	// Create filename based on original book filename
+	ctx, ok := h.startConversion(tempFileKey, req.BookID, req.OutputFormat, outputPath)
+	if !ok {
+		http.Error(w, "This book is already being converted to this format", http.StatusConflict)
+		return
+	}
+
+	job := h.startConversionJob(ctx, tempFileKey, book, req.OutputFormat, outputPath)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	encodeJSON(w, r, map[string]interface{}{
+		"success":       true,
+		"job_id":        job.ID,
+		"status":        job.Status,
+		"output_format": req.OutputFormat,
+		"status_url":    "/api/convert/job/" + job.ID,
+		"message":       "Conversion queued. Poll status_url until it is done, then download the file.",
+	})
+}
+
+// errConversionCancelled is returned by runConversion when the conversion was cancelled
+var errConversionCancelled = errors.New("conversion was cancelled")
+
+// conversionProblem returns why a book cannot be converted to AZW3 and the HTTP status
+// to report it with, or a zero status when it can be converted
+func conversionProblem(book models.Book) (int, string) {
+	if _, err := os.Stat(book.FilePath); os.IsNotExist(err) {
+		return http.StatusNotFound, "Source file not found"
+	}
+	if !strings.HasSuffix(strings.ToLower(book.FilePath), ".epub") {
+		return http.StatusBadRequest, "Only EPUB files can be converted to AZW3"
+	}
+	if book.DRM {
+		return http.StatusUnprocessableEntity, drmErrorMessage
+	}
+	return 0, ""
+}
+
+// conversionOutputPath returns the temporary file a book is converted into, named
+// after the original file, creating the conversions directory under tmp_dir if needed.
+// Persistent conversions are named by book ID and source modification time instead,
+// so a conversion of a changed book never overwrites the one being served.
+func (h *ConversionHandler) conversionOutputPath(book models.Book, format string) (string, error) {
+	tempDir := filepath.Join(h.tmpDir, "conversions")
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return "", err
+	}
+
+	if h.persistent {
+		info, err := os.Stat(book.FilePath)
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(tempDir, fmt.Sprintf("%d_%d.%s", book.ID, info.ModTime().Unix(), format)), nil
+	}
+
 	originalFilename := filepath.Base(book.FilePath)
 	nameWithoutExt := strings.TrimSuffix(originalFilename, filepath.Ext(originalFilename))
-	tempFilename := fmt.Sprintf("%s.%s", nameWithoutExt, req.OutputFormat)
-	outputPath := filepath.Join(tempDir, tempFilename)
+	return filepath.Join(tempDir, fmt.Sprintf("%s.%s", nameWithoutExt, format)), nil
+}
 
-	// Perform conversion
+// runConversion performs a registered conversion once a slot is free and makes the
+// result available for download for convertedFileTTL
+func (h *ConversionHandler) runConversion(ctx context.Context, key string, book models.Book, format, outputPath string) error {
+	// Taken before converting, so a file changed meanwhile leaves the result stale
+	var sourceMtime time.Time
+	if info, err := os.Stat(book.FilePath); err == nil {
+		sourceMtime = info.ModTime()
+	}
+
+	if err := h.acquireSlot(ctx); err != nil {
+		return errConversionCancelled
+	}
+	h.setConversionStatus(key, "running")
 	fmt.Printf("Starting conversion: %s -> %s\n", book.FilePath, outputPath)
-	err = conversion.ConvertEPUBToAZW3(book.FilePath, outputPath)
+	err := h.convert(ctx, book.FilePath, outputPath)
+	h.releaseSlot()
+	if ctx.Err() != nil {
+		fmt.Printf("Conversion cancelled: %s\n", outputPath)
+		os.Remove(outputPath)
+		return errConversionCancelled
+	}
 	if err != nil {
 		fmt.Printf("Conversion failed: %v\n", err)
-		http.Error(w, fmt.Sprintf("Conversion failed: %v", err), http.StatusInternalServerError)
-		return
+		return err
 	}
 	fmt.Printf("Conversion completed successfully\n")
 
 	// Track the temporary file
-	tempFileKey := fmt.Sprintf("%d_%s", req.BookID, req.OutputFormat)
-	tempFiles[tempFileKey] = &TempFileInfo{
-		Path:       outputPath,
-		CreatedAt:  time.Now(),
-		Downloaded: false,
-		BookID:     req.BookID,
-		Format:     req.OutputFormat,
+	createdAt := time.Now()
+	tempFile := &TempFileInfo{
+		Path:        outputPath,
+		CreatedAt:   createdAt,
+		Downloaded:  false,
+		BookID:      book.ID,
+		Format:      format,
+		Persistent:  h.persistent,
+		SourcePath:  book.FilePath,
+		SourceMtime: sourceMtime,
+	}
+	tempFilesMutex.Lock()
+	previous := tempFiles[key]
+	tempFiles[key] = tempFile
+	tempFilesMutex.Unlock()
+
+	if h.persistent {
+		h.saveConversion(tempFile, previous)
+		return nil
 	}
 
 	// Start cleanup timer (remove file after 1 hour if not downloaded)
 	go func() {
-		time.Sleep(1 * time.Hour)
-		if tempFile, exists := tempFiles[tempFileKey]; exists && !tempFile.Downloaded {
+		time.Sleep(convertedFileTTL)
+		tempFilesMutex.Lock()
+		defer tempFilesMutex.Unlock()
+		// A newer conversion of the same book replaces the entry; leave that one alone
+		if tempFile, exists := tempFiles[key]; exists && !tempFile.Downloaded && tempFile.CreatedAt.Equal(createdAt) {
 			os.Remove(tempFile.Path)
-			delete(tempFiles, tempFileKey)
+			delete(tempFiles, key)
 			fmt.Printf("Cleaned up temporary file: %s\n", tempFile.Path)
 		}
 	}()
 
-	// Return success response
-	response := map[string]interface{}{
-		"success":       true,
-		"output_format": req.OutputFormat,
-		"message":       "Conversion completed successfully. File will be available for download for 1 hour.",
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	return nil
 }
 
 // GetConversionStatus returns the status of the conversion service
@@ -145,19 +409,30 @@ func (h *ConversionHandler) GetConversionStatus(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	running, queued := h.conversionCounts()
+
 	status := map[string]interface{}{
+		"running":           running,
+		"queued":            queued,
+		"max_concurrent":    h.maxConcurrent,
+		"conversions":       h.activeConversions(),
 		"available":         true,
 		"supported_formats": []string{"epub"},
 		"output_formats":    []string{"azw3"},
+		"format_info":       conversion.DescribeFormats("epub", "azw3"),
 		"description":       "EPUB to AZW3 conversion using leotaku/mobi library",
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(status)
+	encodeJSON(w, r, status)
 }
 
 // DownloadConvertedBook downloads a converted book
 func (h *ConversionHandler) DownloadConvertedBook(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "POST" && strings.HasSuffix(r.URL.Path, "/cancel") {
+		h.CancelConversion(w, r)
+		return
+	}
 	if r.Method != "GET" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
@@ -179,21 +454,34 @@ func (h *ConversionHandler) DownloadConvertedBook(w http.ResponseWriter, r *http
 		http.Error(w, "Invalid book ID", http.StatusBadRequest)
 		return
 	}
+	if !requireAPIKey(w, r, h.apiKey) {
+		return
+	}
 
 	// Get book details (for validation)
-	_, err = h.db.GetBookByID(bookID)
+	book, err := h.db.GetBookByID(bookID)
 	if err != nil {
-		http.Error(w, "Book not found", http.StatusNotFound)
+		writeBookNotFound(w, r, bookID)
 		return
 	}
 
 	// Check if converted file exists in temp storage
 	tempFileKey := fmt.Sprintf("%d_%s", bookID, format)
+	tempFilesMutex.Lock()
 	tempFile, exists := tempFiles[tempFileKey]
+	var snapshot TempFileInfo
+	if exists {
+		snapshot = *tempFile
+	}
+	tempFilesMutex.Unlock()
 	if !exists {
 		http.Error(w, "Converted file not found. Please convert the book first.", http.StatusNotFound)
 		return
 	}
+	if snapshot.Persistent && !snapshot.usable() {
+		http.Error(w, "The book changed since it was converted. Please convert it again.", http.StatusNotFound)
+		return
+	}
 
 	outputPath := tempFile.Path
 	if _, err := os.Stat(outputPath); os.IsNotExist(err) {
@@ -201,8 +489,9 @@ func (h *ConversionHandler) DownloadConvertedBook(w http.ResponseWriter, r *http
 		return
 	}
 
-	// Set headers for file download
-	filename := filepath.Base(outputPath)
+	// Set headers for file download, naming the file after the book's own file
+	originalFilename := filepath.Base(book.FilePath)
+	filename := fmt.Sprintf("%s.%s", strings.TrimSuffix(originalFilename, filepath.Ext(originalFilename)), format)
 	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
 	w.Header().Set("Content-Type", "application/octet-stream")
 
@@ -216,14 +505,90 @@ func (h *ConversionHandler) DownloadConvertedBook(w http.ResponseWriter, r *http
 
 	// Copy file to response
 	io.Copy(w, file)
+	recordDownload(h.db, bookID, format)
+
+	if snapshot.Persistent {
+		if err := h.db.TouchConversion(bookID, format); err != nil {
+			log.Printf("Failed to update cached conversion of book %d: %v", bookID, err)
+		}
+		return
+	}
 
 	// Mark file as downloaded and schedule cleanup
+	tempFilesMutex.Lock()
 	tempFile.Downloaded = true
+	tempFilesMutex.Unlock()
 	go func() {
 		// Wait a bit to ensure download completes, then clean up
 		time.Sleep(30 * time.Second)
 		os.Remove(outputPath)
-		delete(tempFiles, tempFileKey)
+		tempFilesMutex.Lock()
+		if tempFiles[tempFileKey] == tempFile {
+			delete(tempFiles, tempFileKey)
+		}
+		tempFilesMutex.Unlock()
 		fmt.Printf("Cleaned up downloaded file: %s\n", outputPath)
 	}()
 }
+
+// CancelConversion aborts a queued or running conversion, killing kindlegen and removing
+// the partial output (POST /api/convert/{book_id}/{format}/cancel)
+func (h *ConversionHandler) CancelConversion(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Expected format: /api/convert/{book_id}/{format}/cancel
+	pathParts := strings.Split(r.URL.Path, "/")
+	if len(pathParts) != 6 || pathParts[5] != "cancel" {
+		http.Error(w, "Invalid URL format", http.StatusBadRequest)
+		return
+	}
+
+	bookID, err := strconv.Atoi(pathParts[3])
+	if err != nil {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+	format := pathParts[4]
+	if !h.cancelConversion(fmt.Sprintf("%d_%s", bookID, format)) {
+		http.Error(w, "No conversion in progress for this book and format", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, map[string]interface{}{
+		"book_id": bookID,
+		"format":  format,
+		"status":  "cancelled",
+		"message": "Conversion cancelled",
+	})
+}
+
+// cancelConversion aborts a registered conversion ("{book_id}_{format}") and removes its
+// output, reporting whether there was one to cancel
+func (h *ConversionHandler) cancelConversion(key string) bool {
+	h.activeMutex.Lock()
+	job, exists := h.active[key]
+	if exists {
+		job.Status = "cancelled"
+		job.cancel()
+	}
+	h.activeMutex.Unlock()
+	if !exists {
+		return false
+	}
+
+	// The conversion shares its output path with any earlier cached result, which is gone now too
+	tempFilesMutex.Lock()
+	delete(tempFiles, key)
+	tempFilesMutex.Unlock()
+	os.Remove(job.outputPath)
+	if h.persistent {
+		h.db.DeleteConversion(job.BookID, job.Format)
+	}
+
+	fmt.Printf("Cancelled conversion of book %d to %s\n", job.BookID, job.Format)
+	return true
+}
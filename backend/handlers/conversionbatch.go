@@ -0,0 +1,314 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"fableflow/backend/jobs"
+	"fableflow/backend/models"
+)
+
+// conversionBatch tracks the conversions started together by one batch request, which
+// run as a single "convert_batch" job
+type conversionBatch struct {
+	ID        string // The job's ID
+	Selector  string // "author" or "shelf"
+	Value     string
+	Format    string
+	CreatedAt time.Time
+	Jobs      []batchJob
+}
+
+// batchJob is one book of a conversion batch
+type batchJob struct {
+	JobID  string `json:"job_id"` // "{book_id}_{format}", as used by /api/convert/{book_id}/{format}
+	BookID int    `json:"book_id"`
+	Title  string `json:"title"`
+	Status string `json:"status"` // "queued", "running", "completed", "failed" or "cancelled"
+	Error  string `json:"error,omitempty"`
+}
+
+// skippedBook is a matching book a batch did not convert
+type skippedBook struct {
+	BookID int    `json:"book_id"`
+	Title  string `json:"title"`
+	Reason string `json:"reason"`
+}
+
+// ConvertByAuthor queues conversions of every book by an author
+// (POST /api/convert/by-author with {"author": ..., "output_format": "azw3"})
+func (h *ConversionHandler) ConvertByAuthor(w http.ResponseWriter, r *http.Request) {
+	h.startBatch(w, r, "author")
+}
+
+// ConvertByShelf queues conversions of every book on a shelf, i.e. with a tag
+// (POST /api/convert/by-shelf with {"shelf": ..., "output_format": "azw3"})
+func (h *ConversionHandler) ConvertByShelf(w http.ResponseWriter, r *http.Request) {
+	h.startBatch(w, r, "shelf")
+}
+
+// startBatch queues a conversion for each matching book as one job and responds
+// without waiting for it. Books that cannot be converted, already have a downloadable conversion or
+// are being converted are skipped.
+func (h *ConversionHandler) startBatch(w http.ResponseWriter, r *http.Request, selector string) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Author       string `json:"author"`
+		Shelf        string `json:"shelf"`
+		OutputFormat string `json:"output_format"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.OutputFormat == "" {
+		req.OutputFormat = "azw3"
+	}
+	if req.OutputFormat != "azw3" {
+		http.Error(w, "Only AZW3 conversion is currently supported", http.StatusBadRequest)
+		return
+	}
+
+	var books []models.Book
+	var value string
+	var err error
+	switch selector {
+	case "author":
+		value = strings.TrimSpace(req.Author)
+		if value == "" {
+			http.Error(w, "Author is required", http.StatusBadRequest)
+			return
+		}
+		books, err = h.db.GetBooksByAuthor(value)
+	case "shelf":
+		value = strings.TrimSpace(req.Shelf)
+		if value == "" {
+			http.Error(w, "Shelf is required", http.StatusBadRequest)
+			return
+		}
+		books, err = h.db.GetBooksByTag(value)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(books) == 0 {
+		http.Error(w, fmt.Sprintf("No books found for %s %q", selector, value), http.StatusNotFound)
+		return
+	}
+
+	batch := &conversionBatch{
+		Selector:  selector,
+		Value:     value,
+		Format:    req.OutputFormat,
+		CreatedAt: time.Now(),
+	}
+	skipped := []skippedBook{}
+
+	// Register every conversion before starting the job, so it never sees the slice grow
+	var pending []pendingConversion
+	for _, book := range books {
+		key := fmt.Sprintf("%d_%s", book.ID, req.OutputFormat)
+		if _, problem := conversionProblem(book); problem != "" {
+			skipped = append(skipped, skippedBook{BookID: book.ID, Title: book.Title, Reason: problem})
+			continue
+		}
+		if hasCachedConversion(key) {
+			skipped = append(skipped, skippedBook{BookID: book.ID, Title: book.Title, Reason: "Already converted"})
+			continue
+		}
+		outputPath, err := h.conversionOutputPath(book, req.OutputFormat)
+		if err != nil {
+			skipped = append(skipped, skippedBook{BookID: book.ID, Title: book.Title, Reason: fmt.Sprintf("Failed to create temp directory: %v", err)})
+			continue
+		}
+		ctx, ok := h.startConversion(key, book.ID, req.OutputFormat, outputPath)
+		if !ok {
+			skipped = append(skipped, skippedBook{BookID: book.ID, Title: book.Title, Reason: "Already being converted"})
+			continue
+		}
+
+		batch.Jobs = append(batch.Jobs, batchJob{JobID: key, BookID: book.ID, Title: book.Title, Status: "queued"})
+		pending = append(pending, pendingConversion{ctx: ctx, key: key, book: book, outputPath: outputPath})
+	}
+
+	h.startBatchJob(batch, pending)
+
+	jobIDs := []string{}
+	for _, job := range batch.Jobs {
+		jobIDs = append(jobIDs, job.JobID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	encodeJSON(w, r, map[string]interface{}{
+		"batch_id":      batch.ID,
+		selector:        value,
+		"output_format": batch.Format,
+		"jobs":          jobIDs,
+		"skipped":       skipped,
+		"progress_url":  "/api/convert/batches/" + batch.ID,
+	})
+}
+
+// pendingConversion is a registered conversion of a batch that has yet to run
+type pendingConversion struct {
+	ctx        context.Context
+	key        string
+	book       models.Book
+	outputPath string
+}
+
+// startBatchJob runs the conversions of a batch as one "convert_batch" job, counting a
+// step per finished book. They still wait for conversion slots one by one, so a batch
+// never runs more than conversion.max_concurrent conversions. Cancelling the job
+// cancels every conversion of the batch that has not finished yet.
+func (h *ConversionHandler) startBatchJob(batch *conversionBatch, pending []pendingConversion) {
+	keys := make([]string, len(pending))
+	for i, conversion := range pending {
+		keys[i] = conversion.key
+	}
+
+	description := fmt.Sprintf("Convert books by %s %q to %s", batch.Selector, batch.Value, strings.ToUpper(batch.Format))
+	// batch.ID is set under batchesMutex, which the job takes to record each outcome
+	h.batchesMutex.Lock()
+	job := h.jobs.Start("convert_batch", description, func(ctx context.Context, progress *jobs.Progress) error {
+		progress.SetTotal(len(pending))
+		var wg sync.WaitGroup
+		for i, conversion := range pending {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := h.runCancellableConversion(ctx, conversion.ctx, conversion.key, conversion.book, batch.Format, conversion.outputPath)
+				h.finishBatchConversion(batch, i, err)
+				progress.Step()
+			}()
+		}
+		wg.Wait()
+		return ctx.Err()
+	})
+	batch.ID = job.ID
+	batch.CreatedAt = job.CreatedAt
+	h.pruneBatches()
+	h.batches[batch.ID] = batch
+	h.batchesMutex.Unlock()
+
+	h.finishConversionAfter(job.ID, keys...)
+}
+
+// finishBatchConversion records the outcome of one conversion of a batch
+func (h *ConversionHandler) finishBatchConversion(batch *conversionBatch, index int, err error) {
+	h.batchesMutex.Lock()
+	defer h.batchesMutex.Unlock()
+
+	job := &batch.Jobs[index]
+	switch {
+	case err == nil:
+		job.Status = "completed"
+	case errors.Is(err, context.Canceled):
+		job.Status = "cancelled"
+	default:
+		job.Status = "failed"
+		job.Error = err.Error()
+	}
+}
+
+// pruneBatches drops batches whose job is no longer kept by the job manager; callers
+// must hold batchesMutex
+func (h *ConversionHandler) pruneBatches() {
+	for id := range h.batches {
+		if _, exists := h.jobs.Get(id); !exists {
+			delete(h.batches, id)
+		}
+	}
+}
+
+// GetConversionBatch reports the aggregate progress of a batch conversion
+// (GET /api/convert/batches/{batch_id})
+func (h *ConversionHandler) GetConversionBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// URL format: /api/convert/batches/{batch_id}
+	pathParts := strings.Split(r.URL.Path, "/")
+	if len(pathParts) != 5 || pathParts[4] == "" {
+		http.Error(w, "Invalid URL format", http.StatusBadRequest)
+		return
+	}
+
+	h.batchesMutex.Lock()
+	h.pruneBatches()
+	batch, exists := h.batches[pathParts[4]]
+	var conversions []batchJob
+	if exists {
+		conversions = append([]batchJob{}, batch.Jobs...)
+	}
+	h.batchesMutex.Unlock()
+	if !exists {
+		http.Error(w, "Batch not found", http.StatusNotFound)
+		return
+	}
+	job, _ := h.jobs.Get(batch.ID)
+
+	// Unfinished conversions are only "queued" in the batch; the active list knows which
+	// are running. Those of a job cancelled before it started never ran at all.
+	h.activeMutex.Lock()
+	for i := range conversions {
+		if conversions[i].Status != "queued" {
+			continue
+		}
+		if active, ok := h.active[conversions[i].JobID]; ok && active.Status == "running" {
+			conversions[i].Status = "running"
+		} else if job.Status == jobs.Cancelled {
+			conversions[i].Status = "cancelled"
+		}
+	}
+	h.activeMutex.Unlock()
+
+	counts := map[string]int{"queued": 0, "running": 0, "completed": 0, "failed": 0, "cancelled": 0}
+	for _, conversion := range conversions {
+		counts[conversion.Status]++
+	}
+	finished := counts["completed"] + counts["failed"] + counts["cancelled"]
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, map[string]interface{}{
+		"batch_id":      batch.ID,
+		batch.Selector:  batch.Value,
+		"output_format": batch.Format,
+		"status":        job.Status,
+		"created_at":    batch.CreatedAt,
+		"total":         len(conversions),
+		"queued":        counts["queued"],
+		"running":       counts["running"],
+		"completed":     counts["completed"],
+		"failed":        counts["failed"],
+		"cancelled":     counts["cancelled"],
+		"done":          finished == len(conversions),
+		"jobs":          conversions,
+	})
+}
+
+// hasCachedConversion reports whether a conversion ("{book_id}_{format}") can still be downloaded
+func hasCachedConversion(key string) bool {
+	tempFilesMutex.Lock()
+	tempFile, exists := tempFiles[key]
+	var snapshot TempFileInfo
+	if exists {
+		snapshot = *tempFile
+	}
+	tempFilesMutex.Unlock()
+	return exists && snapshot.usable()
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"fableflow/backend/models"
+)
+
+// EnablePersistentCache keeps converted files in tmp_dir/conversions across downloads and
+// restarts, indexed in the database. A conversion is served until its book's file changes
+// or it is evicted: after maxAge without use, or least recently used first once the cache
+// exceeds maxBytes. Zero disables either limit.
+func (h *ConversionHandler) EnablePersistentCache(maxAge time.Duration, maxBytes int64) error {
+	h.persistent = true
+	h.cacheMaxAge = maxAge
+	h.cacheMaxBytes = maxBytes
+
+	conversions, err := h.db.GetConversions()
+	if err != nil {
+		return fmt.Errorf("failed to load cached conversions: %v", err)
+	}
+
+	loaded := 0
+	tempFilesMutex.Lock()
+	for _, cached := range conversions {
+		book, err := h.db.GetBookByID(cached.BookID)
+		if err != nil {
+			continue // Evicted below
+		}
+		tempFiles[fmt.Sprintf("%d_%s", cached.BookID, cached.Format)] = &TempFileInfo{
+			Path:        cached.Path,
+			CreatedAt:   cached.CreatedAt,
+			BookID:      cached.BookID,
+			Format:      cached.Format,
+			Persistent:  true,
+			SourcePath:  book.FilePath,
+			SourceMtime: cached.SourceMtime,
+		}
+		loaded++
+	}
+	tempFilesMutex.Unlock()
+	log.Printf("Loaded %d cached conversions", loaded)
+
+	h.evictConversions()
+	return nil
+}
+
+// StartCacheJanitor periodically evicts stale and excess persistent conversions
+func (h *ConversionHandler) StartCacheJanitor(interval time.Duration) {
+	if !h.persistent {
+		return
+	}
+	go func() {
+		for range time.Tick(interval) {
+			h.evictConversions()
+		}
+	}()
+}
+
+// saveConversion records a finished persistent conversion and removes the file of the
+// conversion it replaces
+func (h *ConversionHandler) saveConversion(tempFile *TempFileInfo, previous *TempFileInfo) {
+	size := int64(0)
+	if info, err := os.Stat(tempFile.Path); err == nil {
+		size = info.Size()
+	}
+
+	err := h.db.SaveConversion(models.CachedConversion{
+		BookID:      tempFile.BookID,
+		Format:      tempFile.Format,
+		Path:        tempFile.Path,
+		Size:        size,
+		SourceMtime: tempFile.SourceMtime,
+		CreatedAt:   tempFile.CreatedAt,
+		LastUsedAt:  tempFile.CreatedAt,
+	})
+	if err != nil {
+		log.Printf("Failed to save conversion of book %d: %v", tempFile.BookID, err)
+	}
+	if previous != nil && previous.Path != tempFile.Path {
+		os.Remove(previous.Path)
+	}
+
+	h.evictConversions()
+}
+
+// evictConversions removes persistent conversions whose book is gone or changed, whose
+// file is missing, or that were not used within cacheMaxAge, then the least recently
+// used ones until the cache fits in cacheMaxBytes
+func (h *ConversionHandler) evictConversions() {
+	conversions, err := h.db.GetConversions()
+	if err != nil {
+		log.Printf("Failed to list cached conversions: %v", err)
+		return
+	}
+
+	var kept []models.CachedConversion
+	var totalBytes int64
+	for _, cached := range conversions {
+		reason := ""
+		book, err := h.db.GetBookByID(cached.BookID)
+		if err != nil {
+			reason = "book removed"
+		} else if info, err := os.Stat(book.FilePath); err != nil || info.ModTime().Unix() != cached.SourceMtime.Unix() {
+			reason = "book changed"
+		} else if _, err := os.Stat(cached.Path); err != nil {
+			reason = "file missing"
+		} else if h.cacheMaxAge > 0 && time.Since(cached.LastUsedAt) > h.cacheMaxAge {
+			reason = "unused"
+		}
+
+		if reason != "" {
+			h.evictConversion(cached, reason)
+			continue
+		}
+		kept = append(kept, cached)
+		totalBytes += cached.Size
+	}
+
+	if h.cacheMaxBytes <= 0 || totalBytes <= h.cacheMaxBytes {
+		return
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].LastUsedAt.Before(kept[j].LastUsedAt) })
+	for _, cached := range kept {
+		if totalBytes <= h.cacheMaxBytes {
+			break
+		}
+		h.evictConversion(cached, "cache full")
+		totalBytes -= cached.Size
+	}
+}
+
+// evictConversion deletes a persistent conversion's file, row and download entry
+func (h *ConversionHandler) evictConversion(cached models.CachedConversion, reason string) {
+	key := fmt.Sprintf("%d_%s", cached.BookID, cached.Format)
+
+	// A running conversion of the same book is not affected, it writes to its own path
+	tempFilesMutex.Lock()
+	if tempFile, exists := tempFiles[key]; exists && tempFile.Path == cached.Path {
+		delete(tempFiles, key)
+	}
+	tempFilesMutex.Unlock()
+
+	os.Remove(cached.Path)
+	if err := h.db.DeleteConversion(cached.BookID, cached.Format); err != nil {
+		log.Printf("Failed to delete cached conversion of book %d: %v", cached.BookID, err)
+	}
+	log.Printf("Evicted cached %s conversion of book %d (%s)", cached.Format, cached.BookID, reason)
+}
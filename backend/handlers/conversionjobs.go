@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"fableflow/backend/jobs"
+	"fableflow/backend/models"
+)
+
+// startConversionJob runs a registered conversion as a "convert" job, so it is listed
+// in /api/jobs and can be cancelled there as well as through
+// POST /api/convert/{book_id}/{format}/cancel
+func (h *ConversionHandler) startConversionJob(ctx context.Context, key string, book models.Book, format, outputPath string) jobs.Job {
+	description := fmt.Sprintf("Convert %q to %s", book.Title, strings.ToUpper(format))
+	resultURL := fmt.Sprintf("/api/convert/%d/%s", book.ID, format)
+	job := h.jobs.StartWithResult("convert", description, resultURL, func(jobCtx context.Context, progress *jobs.Progress) error {
+		progress.SetTotal(1)
+		err := h.runCancellableConversion(jobCtx, ctx, key, book, format, outputPath)
+		if err == nil {
+			progress.Step()
+		}
+		return err
+	})
+	h.finishConversionAfter(job.ID, key)
+	return job
+}
+
+// runCancellableConversion runs a registered conversion inside a job, stopping it when
+// either the job or the conversion is cancelled. A cancelled conversion is reported as
+// context.Canceled so the job ends up "cancelled" rather than "failed".
+func (h *ConversionHandler) runCancellableConversion(jobCtx, ctx context.Context, key string, book models.Book, format, outputPath string) error {
+	stop := context.AfterFunc(jobCtx, func() { h.cancelConversion(key) })
+	defer stop()
+
+	err := h.runConversion(ctx, key, book, format, outputPath)
+	if err == errConversionCancelled {
+		return context.Canceled
+	}
+	return err
+}
+
+// finishConversionAfter unregisters conversions once the job running them has ended,
+// including a job cancelled before it ever started
+func (h *ConversionHandler) finishConversionAfter(jobID string, keys ...string) {
+	go func() {
+		h.jobs.Wait(jobID)
+		for _, key := range keys {
+			h.finishConversion(key)
+		}
+	}()
+}
+
+// GetConversionJob reports the status of a conversion started by POST /api/convert
+// (GET /api/convert/job/{job_id}). It is a view onto the "convert" job in /api/jobs.
+// kindlegen reports no progress of its own, so progress is 0 while queued, 50 while
+// running and 100 once finished.
+func (h *ConversionHandler) GetConversionJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// URL format: /api/convert/job/{job_id}
+	jobID := strings.TrimPrefix(r.URL.Path, "/api/convert/job/")
+	if jobID == "" || strings.Contains(jobID, "/") {
+		http.Error(w, "Invalid URL format", http.StatusBadRequest)
+		return
+	}
+
+	job, exists := h.jobs.Get(jobID)
+	var bookID int
+	var format string
+	if exists && job.Kind == "convert" {
+		_, err := fmt.Sscanf(job.ResultURL, "/api/convert/%d/%s", &bookID, &format)
+		exists = err == nil
+	}
+	if !exists || job.Kind != "convert" {
+		http.Error(w, "Conversion job not found", http.StatusNotFound)
+		return
+	}
+
+	// A running job may still be waiting for a conversion slot
+	status := job.Status
+	switch status {
+	case jobs.Running:
+		h.activeMutex.Lock()
+		if active, ok := h.active[fmt.Sprintf("%d_%s", bookID, format)]; ok && active.Status == "queued" {
+			status = jobs.Queued
+		}
+		h.activeMutex.Unlock()
+	case jobs.Completed:
+		status = "done"
+	}
+
+	response := map[string]interface{}{
+		"job_id":        job.ID,
+		"book_id":       bookID,
+		"output_format": format,
+		"status":        status,
+		"created_at":    job.CreatedAt,
+	}
+	switch status {
+	case jobs.Queued:
+		response["progress"] = 0
+	case jobs.Running:
+		response["progress"] = 50
+	default:
+		response["progress"] = 100
+		response["finished_at"] = job.FinishedAt
+	}
+	if job.Error != "" {
+		response["error"] = job.Error
+	}
+	if status == "done" {
+		response["download_url"] = job.ResultURL
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, response)
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fableflow/backend/database"
+	"fableflow/backend/jobs"
+	"fableflow/backend/models"
+)
+
+// newTestConversionHandler returns a conversion handler over an in-memory library
+// holding the given books, running its conversions on jobManager. Its conversions
+// copy the EPUB, as kindlegen is not available in tests.
+func newTestConversionHandler(t *testing.T, jobManager *jobs.Manager, books ...models.BookRequest) *ConversionHandler {
+	t.Helper()
+	dm, err := database.NewManager(database.MemoryPath)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(func() { dm.Close() })
+	for _, book := range books {
+		if err := dm.AddBook(book); err != nil {
+			t.Fatalf("AddBook: %v", err)
+		}
+	}
+
+	// Converted files are tracked globally; forget this test's so others convert afresh
+	t.Cleanup(func() {
+		tempFilesMutex.Lock()
+		defer tempFilesMutex.Unlock()
+		for key := range tempFiles {
+			delete(tempFiles, key)
+		}
+	})
+	h := NewConversionHandler(dm, jobManager, t.TempDir(), 1)
+	h.convert = func(ctx context.Context, inputPath, outputPath string) error {
+		data, err := os.ReadFile(inputPath)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(outputPath, data, 0644)
+	}
+	return h
+}
+
+// testEPUB writes an EPUB file to dir for a test book to be converted from
+func testEPUB(t *testing.T, dir, name string) models.BookRequest {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("PK"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return testBook(path)
+}
+
+// getJSON serves a GET request with handler and decodes its JSON response
+func getJSON(t *testing.T, handler http.HandlerFunc, path string) map[string]interface{} {
+	t.Helper()
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", path, nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s: status %d: %s", path, w.Code, w.Body)
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+// convertBook posts a conversion of a book to AZW3 and returns its job ID
+func convertBook(t *testing.T, h *ConversionHandler, body string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ConvertBook(w, httptest.NewRequest("POST", "/api/convert", strings.NewReader(body)))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("POST /api/convert: status %d: %s", w.Code, w.Body)
+	}
+	var response struct {
+		JobID string `json:"job_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response.JobID
+}
+
+// waitForConversions waits until no conversion is registered any more
+func waitForConversions(t *testing.T, h *ConversionHandler) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for len(h.activeConversions()) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("conversions still registered: %+v", h.activeConversions())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConversionRunsAsJob(t *testing.T) {
+	jobManager := jobs.NewManager(1)
+	h := newTestConversionHandler(t, jobManager, testEPUB(t, t.TempDir(), "Title.epub"))
+
+	jobID := convertBook(t, h, `{"book_id": 1, "output_format": "azw3"}`)
+	job, exists := jobManager.Get(jobID)
+	if !exists || job.Kind != "convert" {
+		t.Fatalf("job %q in /api/jobs = %+v, %v; want a convert job", jobID, job, exists)
+	}
+	if job, _ = jobManager.Wait(jobID); job.Status != jobs.Completed || job.Done != 1 {
+		t.Fatalf("job = %+v, want one completed step", job)
+	}
+
+	response := getJSON(t, h.GetConversionJob, "/api/convert/job/"+jobID)
+	if response["status"] != "done" || response["progress"] != 100.0 || response["download_url"] != "/api/convert/1/azw3" {
+		t.Errorf("GET /api/convert/job/%s = %v, want done with a download_url", jobID, response)
+	}
+	if !hasCachedConversion("1_azw3") {
+		t.Error("converted book cannot be downloaded")
+	}
+
+	// Jobs of other kinds are not conversions
+	other := jobManager.Start("scan", "", func(ctx context.Context, progress *jobs.Progress) error { return nil })
+	w := httptest.NewRecorder()
+	h.GetConversionJob(w, httptest.NewRequest("GET", "/api/convert/job/"+other.ID, nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /api/convert/job/%s: status %d, want %d", other.ID, w.Code, http.StatusNotFound)
+	}
+}
+
+func TestCancelConversionJob(t *testing.T) {
+	// Keep the only worker busy so the conversion stays queued
+	jobManager := jobs.NewManager(1)
+	running, release := make(chan struct{}), make(chan struct{})
+	blocker := jobManager.Start("scan", "", func(ctx context.Context, progress *jobs.Progress) error {
+		close(running)
+		<-release
+		return nil
+	})
+	<-running
+	h := newTestConversionHandler(t, jobManager, testEPUB(t, t.TempDir(), "Title.epub"))
+
+	jobID := convertBook(t, h, `{"book_id": 1, "output_format": "azw3"}`)
+	if response := getJSON(t, h.GetConversionJob, "/api/convert/job/"+jobID); response["status"] != "queued" || response["progress"] != 0.0 {
+		t.Errorf("queued job = %v, want queued with no progress", response)
+	}
+
+	if _, err := jobManager.Cancel(jobID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if job, _ := jobManager.Wait(jobID); job.Status != jobs.Cancelled {
+		t.Errorf("job status = %q, want %q", job.Status, jobs.Cancelled)
+	}
+	waitForConversions(t, h)
+	if response := getJSON(t, h.GetConversionJob, "/api/convert/job/"+jobID); response["status"] != "cancelled" {
+		t.Errorf("cancelled job = %v", response)
+	}
+
+	close(release)
+	jobManager.Wait(blocker.ID)
+
+	// Cancelling the job of a running conversion stops the conversion too
+	converting := make(chan struct{})
+	h.convert = func(ctx context.Context, inputPath, outputPath string) error {
+		close(converting)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	jobID = convertBook(t, h, `{"book_id": 1, "output_format": "azw3"}`)
+	<-converting
+	if response := getJSON(t, h.GetConversionJob, "/api/convert/job/"+jobID); response["status"] != "running" || response["progress"] != 50.0 {
+		t.Errorf("running job = %v, want running halfway", response)
+	}
+	if _, err := jobManager.Cancel(jobID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if job, _ := jobManager.Wait(jobID); job.Status != jobs.Cancelled {
+		t.Errorf("job status = %q, want %q", job.Status, jobs.Cancelled)
+	}
+	waitForConversions(t, h)
+	if hasCachedConversion("1_azw3") {
+		t.Error("cancelled conversion can be downloaded")
+	}
+}
+
+func TestConversionBatchRunsAsJob(t *testing.T) {
+	jobManager := jobs.NewManager(1)
+	dir := t.TempDir()
+	missing := testBook(filepath.Join(dir, "Missing.epub"))
+	h := newTestConversionHandler(t, jobManager, testEPUB(t, dir, "First.epub"), testEPUB(t, dir, "Second.epub"), missing)
+
+	w := httptest.NewRecorder()
+	h.ConvertByAuthor(w, httptest.NewRequest("POST", "/api/convert/by-author", strings.NewReader(`{"author": "Author"}`)))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("POST /api/convert/by-author: status %d: %s", w.Code, w.Body)
+	}
+	var started struct {
+		BatchID string   `json:"batch_id"`
+		Jobs    []string `json:"jobs"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &started); err != nil {
+		t.Fatal(err)
+	}
+	if len(started.Jobs) != 2 {
+		t.Fatalf("batch converts %v, want the two books whose file exists", started.Jobs)
+	}
+
+	job, exists := jobManager.Get(started.BatchID)
+	if !exists || job.Kind != "convert_batch" {
+		t.Fatalf("batch %q in /api/jobs = %+v, %v; want a convert_batch job", started.BatchID, job, exists)
+	}
+	if job, _ = jobManager.Wait(started.BatchID); job.Status != jobs.Completed || job.Total != 2 || job.Done != 2 {
+		t.Fatalf("batch job = %+v, want two of two steps completed", job)
+	}
+	waitForConversions(t, h)
+
+	progress := getJSON(t, h.GetConversionBatch, "/api/convert/batches/"+started.BatchID)
+	if progress["status"] != jobs.Completed || progress["completed"] != 2.0 || progress["done"] != true {
+		t.Errorf("batch progress = %v, want both books completed", progress)
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"image"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+)
+
+// GetCoverDebug explains how a book's embedded cover is resolved: the strategy that
+// matched, the path inside the EPUB, the declared media type and whether the image
+// decodes, with a trace of each step taken (GET /api/books/{id}/cover-debug)
+func (h *CoversHandler) GetCoverDebug(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// URL format: /api/books/{id}/cover-debug
+	bookID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+
+	book, err := h.db.GetBookByID(bookID)
+	if err != nil {
+		writeBookNotFound(w, r, bookID)
+		return
+	}
+	if book.Format != "epub" {
+		http.Error(w, "Cover debugging is only available for EPUB files", http.StatusBadRequest)
+		return
+	}
+
+	reader, err := zip.OpenReader(book.FilePath)
+	if err != nil {
+		http.Error(w, "Failed to open EPUB file", http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+
+	resolution, err := resolveEPUBCover(reader)
+	response := map[string]interface{}{
+		"book_id":   book.ID,
+		"file_path": book.FilePath,
+		"found":     err == nil,
+	}
+	if err != nil {
+		response["error"] = err.Error()
+	} else {
+		h.inspectCover(reader, resolution, response)
+	}
+	response["opf_path"] = resolution.OPFPath
+	response["strategy"] = resolution.Strategy
+	response["item_id"] = resolution.ItemID
+	response["path"] = resolution.Path
+	response["media_type"] = resolution.MediaType
+	response["trace"] = resolution.Trace
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, response)
+}
+
+// inspectCover checks that a resolved cover is in the archive and decodes, within the
+// covers.max_image_bytes and covers.max_image_pixels limits, adding what it finds to
+// the response and the trace
+func (h *CoversHandler) inspectCover(reader *zip.ReadCloser, resolution *coverResolution, response map[string]interface{}) {
+	response["in_archive"] = false
+	response["decoded"] = false
+
+	coverFile, err := reader.Open(filepath.ToSlash(resolution.Path))
+	if err != nil {
+		resolution.tracef("Cover image is not in the archive: %v", err)
+		response["decode_error"] = "cover image not found in the EPUB"
+		return
+	}
+	defer coverFile.Close()
+	response["in_archive"] = true
+
+	if info, err := coverFile.Stat(); err == nil {
+		response["size"] = info.Size()
+		resolution.tracef("Cover image is in the archive (%d bytes)", info.Size())
+		if h.maxImageBytes > 0 && info.Size() > h.maxImageBytes {
+			resolution.tracef("Cover image is above the %d byte limit, not decoding it", h.maxImageBytes)
+			response["decode_error"] = errCoverTooLarge.Error()
+			return
+		}
+	}
+
+	imageData, err := io.ReadAll(coverFile)
+	if err != nil {
+		resolution.tracef("Failed to read the cover image: %v", err)
+		response["decode_error"] = err.Error()
+		return
+	}
+	response["detected_type"] = http.DetectContentType(imageData)
+
+	// Check the dimensions before decoding so huge images are never allocated
+	imageConfig, imageFormat, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		resolution.tracef("Failed to decode the cover image: %v", err)
+		response["decode_error"] = err.Error()
+		return
+	}
+	response["image_format"] = imageFormat
+	response["width"] = imageConfig.Width
+	response["height"] = imageConfig.Height
+	if h.maxImagePixels > 0 && imageConfig.Width*imageConfig.Height > h.maxImagePixels {
+		resolution.tracef("Cover image is %dx%d, above the %d pixel limit, not decoding it", imageConfig.Width, imageConfig.Height, h.maxImagePixels)
+		response["decode_error"] = errCoverTooLarge.Error()
+		return
+	}
+	if _, _, err := image.Decode(bytes.NewReader(imageData)); err != nil {
+		resolution.tracef("Failed to decode the cover image: %v", err)
+		response["decode_error"] = err.Error()
+		return
+	}
+	response["decoded"] = true
+	resolution.tracef("Decoded the cover image: %s, %dx%d", imageFormat, imageConfig.Width, imageConfig.Height)
+}
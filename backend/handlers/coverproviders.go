@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fableflow/backend/models"
+)
+
+// Cover sources that can be listed in covers.provider_order
+const (
+	CoverSourceEmbedded    = "embedded"    // The cover image inside the EPUB
+	CoverSourceOpenLibrary = "openlibrary" // Open Library covers API, looked up by ISBN
+	CoverSourceGoogle      = "google"      // Google Books thumbnail, looked up by ISBN
+	CoverSourceCustom      = "custom"      // An image uploaded to covers.custom_directory
+)
+
+// errNoCover is returned when a cover source has no image for a book
+var errNoCover = errors.New("no cover available from this source")
+
+// remoteCoverMissTTL is how long a failed remote lookup is remembered before retrying
+const remoteCoverMissTTL = time.Hour
+
+// maxUploadedCoverBytes limits the size of uploaded custom covers
+const maxUploadedCoverBytes = 10 * 1024 * 1024
+
+// coverHTTPClient is used for remote cover providers and other Open Library lookups
+var coverHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// openLibraryLimiter spaces out requests to Open Library, which asks API clients
+// to stay at around one request per second
+var openLibraryLimiter = &requestLimiter{interval: time.Second}
+
+// requestLimiter lets one request through per interval, delaying callers as needed
+type requestLimiter struct {
+	mutex    sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// Wait blocks until the caller may send its request
+func (l *requestLimiter) Wait() {
+	l.mutex.Lock()
+	now := time.Now()
+	wait := l.next.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	l.next = now.Add(wait + l.interval)
+	l.mutex.Unlock()
+
+	time.Sleep(wait)
+}
+
+// customCoverExtensions are the image types accepted as custom covers
+var customCoverExtensions = []string{".jpg", ".jpeg", ".png", ".gif"}
+
+// coverVersion returns a cheap token identifying the current cover of a book from a source,
+// used to key cached thumbnails. ok is false when the source certainly has no cover.
+func (h *CoversHandler) coverVersion(book models.Book, source string) (string, bool) {
+	switch source {
+	case CoverSourceEmbedded:
+		if !h.hasCover(book.ID) {
+			return "", false
+		}
+		info, err := os.Stat(book.FilePath)
+		if err != nil {
+			return "", false
+		}
+		return strconv.FormatInt(info.ModTime().Unix(), 10), true
+	case CoverSourceCustom:
+		coverPath := h.customCoverPath(book.ID)
+		if coverPath == "" {
+			return "", false
+		}
+		info, err := os.Stat(coverPath)
+		if err != nil {
+			return "", false
+		}
+		return strconv.FormatInt(info.ModTime().Unix(), 10), true
+	case CoverSourceOpenLibrary, CoverSourceGoogle:
+		isbn := cleanCoverISBN(book.ISBN)
+		if isbn == "" || h.recentRemoteMiss(source, isbn) {
+			return "", false
+		}
+		return isbn, true
+	default:
+		return "", false
+	}
+}
+
+// loadCoverFromSource returns the full-size cover image of a book from one source.
+// Remote images are cached so each ISBN is only fetched once.
+func (h *CoversHandler) loadCoverFromSource(book models.Book, source string) ([]byte, error) {
+	switch source {
+	case CoverSourceEmbedded:
+		return h.loadCoverImage(book)
+	case CoverSourceCustom:
+		coverPath := h.customCoverPath(book.ID)
+		if coverPath == "" {
+			return nil, errNoCover
+		}
+		return os.ReadFile(coverPath)
+	case CoverSourceOpenLibrary, CoverSourceGoogle:
+		isbn := cleanCoverISBN(book.ISBN)
+		if isbn == "" || h.recentRemoteMiss(source, isbn) {
+			return nil, errNoCover
+		}
+
+		cacheKey := fmt.Sprintf("remote_%s_%s.img", source, isbn)
+		if data, ok := h.cache.Get(cacheKey); ok {
+			return data, nil
+		}
+
+		var data []byte
+		var err error
+		if source == CoverSourceOpenLibrary {
+			data, err = fetchOpenLibraryCover(isbn)
+		} else {
+			data, err = fetchGoogleBooksCover(isbn)
+		}
+		if err != nil {
+			log.Printf("No %s cover for ISBN %s: %v", source, isbn, err)
+			h.rememberRemoteMiss(source, isbn)
+			return nil, errNoCover
+		}
+
+		if err := h.cache.Put(cacheKey, data); err != nil {
+			log.Printf("Failed to cache %s cover for ISBN %s: %v", source, isbn, err)
+		}
+		return data, nil
+	default:
+		return nil, errNoCover
+	}
+}
+
+// loadCover walks the provider chain and returns the first cover found with its source
+func (h *CoversHandler) loadCover(book models.Book) ([]byte, string, error) {
+	for _, source := range h.providerOrder {
+		if _, ok := h.coverVersion(book, source); !ok {
+			continue
+		}
+		if data, err := h.loadCoverFromSource(book, source); err == nil {
+			return data, source, nil
+		}
+	}
+	return nil, "", errNoCover
+}
+
+// currentCoverVersion identifies the cover a book would currently get from the provider chain
+func (h *CoversHandler) currentCoverVersion(book models.Book) string {
+	for _, source := range h.providerOrder {
+		if version, ok := h.coverVersion(book, source); ok {
+			return source + ":" + version
+		}
+	}
+	return ""
+}
+
+// recentRemoteMiss reports whether a remote source recently had no cover for an ISBN
+func (h *CoversHandler) recentRemoteMiss(source, isbn string) bool {
+	h.missMutex.Lock()
+	defer h.missMutex.Unlock()
+
+	missedAt, ok := h.remoteMisses[source+":"+isbn]
+	if ok && time.Since(missedAt) > remoteCoverMissTTL {
+		delete(h.remoteMisses, source+":"+isbn)
+		return false
+	}
+	return ok
+}
+
+// rememberRemoteMiss records that a remote source has no cover for an ISBN
+func (h *CoversHandler) rememberRemoteMiss(source, isbn string) {
+	h.missMutex.Lock()
+	h.remoteMisses[source+":"+isbn] = time.Now()
+	h.missMutex.Unlock()
+}
+
+// fetchOpenLibraryCover downloads the large Open Library cover for an ISBN
+func fetchOpenLibraryCover(isbn string) ([]byte, error) {
+	// default=false makes Open Library return 404 instead of a blank image
+	url := fmt.Sprintf("https://covers.openlibrary.org/b/isbn/%s-L.jpg?default=false", isbn)
+	openLibraryLimiter.Wait()
+	return fetchCoverImage(url)
+}
+
+// fetchGoogleBooksCover looks up a volume by ISBN and downloads its thumbnail
+func fetchGoogleBooksCover(isbn string) ([]byte, error) {
+	url := fmt.Sprintf("https://www.googleapis.com/books/v1/volumes?q=isbn:%s", isbn)
+	resp, err := coverHTTPClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Google Books API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("google Books API returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Items []struct {
+			VolumeInfo struct {
+				ImageLinks struct {
+					Thumbnail      string `json:"thumbnail"`
+					SmallThumbnail string `json:"smallThumbnail"`
+				} `json:"imageLinks"`
+			} `json:"volumeInfo"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse Google Books response: %v", err)
+	}
+
+	for _, item := range result.Items {
+		imageURL := item.VolumeInfo.ImageLinks.Thumbnail
+		if imageURL == "" {
+			imageURL = item.VolumeInfo.ImageLinks.SmallThumbnail
+		}
+		if imageURL != "" {
+			return fetchCoverImage(strings.Replace(imageURL, "http://", "https://", 1))
+		}
+	}
+	return nil, errNoCover
+}
+
+// fetchCoverImage downloads an image and checks that it decodes
+func fetchCoverImage(url string) ([]byte, error) {
+	resp, err := coverHTTPClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("cover request returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxUploadedCoverBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxUploadedCoverBytes {
+		return nil, errCoverTooLarge
+	}
+	if _, _, err := image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return nil, fmt.Errorf("response is not an image: %v", err)
+	}
+	return data, nil
+}
+
+// cleanCoverISBN strips hyphens and spaces from an ISBN, returning "" when it isn't usable
+func cleanCoverISBN(isbn string) string {
+	clean := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(isbn)))
+	if len(clean) != 10 && len(clean) != 13 {
+		return ""
+	}
+	for _, c := range clean {
+		if (c < '0' || c > '9') && c != 'X' {
+			return ""
+		}
+	}
+	return clean
+}
+
+// customCoverPath returns the uploaded cover of a book, or "" if there is none
+func (h *CoversHandler) customCoverPath(id int) string {
+	if h.customDir == "" {
+		return ""
+	}
+	for _, ext := range customCoverExtensions {
+		coverPath := filepath.Join(h.customDir, strconv.Itoa(id)+ext)
+		if _, err := os.Stat(coverPath); err == nil {
+			return coverPath
+		}
+	}
+	return ""
+}
+
+// UploadCover stores a custom cover image for a book (PUT /api/covers/{id} with the image as body)
+// or removes it (DELETE /api/covers/{id})
+func (h *CoversHandler) UploadCover(w http.ResponseWriter, r *http.Request, book models.Book) {
+	if h.customDir == "" {
+		http.Error(w, "Custom covers are disabled", http.StatusNotFound)
+		return
+	}
+
+	if r.Method == "DELETE" {
+		coverPath := h.customCoverPath(book.ID)
+		if coverPath == "" {
+			http.Error(w, "Book has no custom cover", http.StatusNotFound)
+			return
+		}
+		if err := os.Remove(coverPath); err != nil {
+			http.Error(w, fmt.Sprintf("Failed to remove custom cover: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxUploadedCoverBytes+1))
+	if err != nil {
+		http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxUploadedCoverBytes {
+		http.Error(w, "Cover image is too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	_, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		http.Error(w, "Body is not a supported image (JPEG, PNG or GIF)", http.StatusBadRequest)
+		return
+	}
+	ext := "." + format
+	if format == "jpeg" {
+		ext = ".jpg"
+	}
+
+	if err := os.MkdirAll(h.customDir, 0755); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create custom cover directory: %v", err), http.StatusInternalServerError)
+		return
+	}
+	// Replace any previous upload, which may have a different extension
+	if existing := h.customCoverPath(book.ID); existing != "" {
+		os.Remove(existing)
+	}
+	if err := os.WriteFile(filepath.Join(h.customDir, strconv.Itoa(book.ID)+ext), data, 0644); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save custom cover: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, map[string]interface{}{
+		"message": "Cover uploaded successfully",
+		"book_id": book.ID,
+		"source":  CoverSourceCustom,
+	})
+}
@@ -3,17 +3,33 @@ package handlers
 import (
 	"archive/zip"
 	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
 	"image/jpeg"
+	_ "image/png"
 	"io"
+	"log"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"fableflow/backend/config"
+	"fableflow/backend/covercache"
 	"fableflow/backend/database"
+	"fableflow/backend/models"
+	"fableflow/backend/xmlutil"
 )
 
 // OPF document structures for XML parsing
@@ -33,23 +49,141 @@ type MetaTag struct {
 }
 
 type ManifestItem struct {
-	ID   string `xml:"id,attr"`
-	Href string `xml:"href,attr"`
+	ID        string `xml:"id,attr"`
+	Href      string `xml:"href,attr"`
+	MediaType string `xml:"media-type,attr"`
 }
 
+// errCoverTooLarge is returned when a cover image exceeds the configured decode limits
+var errCoverTooLarge = errors.New("cover image too large to decode")
+
 // CoversHandler handles cover image requests
 type CoversHandler struct {
-	db *database.Manager
+	db             *database.Manager
+	cache          *covercache.Cache
+	jpegQuality    int
+	maxImageBytes  int64
+	maxImagePixels int
+	montageColumns int
+	montageMax     int
+	providerOrder  []string
+	customDir      string
+	embedMaxBytes  int64
+
+	// Results of cover existence checks, invalidated when the book file changes
+	checkMutex   sync.Mutex
+	checkResults map[int]coverCheck
+
+	// Remote lookups that found no cover, keyed by "source:isbn"
+	missMutex    sync.Mutex
+	remoteMisses map[string]time.Time
+}
+
+// coverCheck records whether a book file had an extractable cover
+type coverCheck struct {
+	modTime int64
+	exists  bool
+}
+
+// CoverCheckRequest represents a request to check which books have covers
+type CoverCheckRequest struct {
+	BookIDs []int `json:"book_ids"`
+}
+
+// CoverCheckResponse maps book IDs to whether they have an embedded cover
+type CoverCheckResponse struct {
+	Covers map[int]bool `json:"covers"`
 }
 
-// NewCoversHandler creates a new covers handler
-func NewCoversHandler(db *database.Manager) *CoversHandler {
-	return &CoversHandler{db: db}
+// NewCoversHandler creates a new covers handler.
+// A nil cache disables thumbnail caching.
+func NewCoversHandler(db *database.Manager, cache *covercache.Cache, cfg *config.Config) *CoversHandler {
+	jpegQuality := cfg.Covers.JPEGQuality
+	if jpegQuality < 1 || jpegQuality > 100 {
+		jpegQuality = jpeg.DefaultQuality
+	}
+	return &CoversHandler{
+		db:             db,
+		cache:          cache,
+		jpegQuality:    jpegQuality,
+		maxImageBytes:  cfg.Covers.MaxImageBytes,
+		maxImagePixels: cfg.Covers.MaxImagePixels,
+		montageColumns: cfg.Covers.MontageColumns,
+		montageMax:     cfg.Covers.MontageMaxBooks,
+		providerOrder:  cfg.Covers.ProviderOrder,
+		customDir:      cfg.Covers.CustomDirectory,
+		embedMaxBytes:  cfg.Covers.EmbedMaxBytes,
+		checkResults:   make(map[int]coverCheck),
+		remoteMisses:   make(map[string]time.Time),
+	}
+}
+
+// CheckCovers reports which of the requested books have an extractable embedded cover.
+// Only the OPF is parsed; cover images are never decoded.
+func (h *CoversHandler) CheckCovers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req CoverCheckRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	response := CoverCheckResponse{Covers: make(map[int]bool, len(req.BookIDs))}
+	for _, id := range req.BookIDs {
+		response.Covers[id] = h.hasCover(id)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, response)
+}
+
+// hasCover returns whether a book has an extractable cover, using the cached result when the file is unchanged
+func (h *CoversHandler) hasCover(id int) bool {
+	book, err := h.db.GetBookByID(id)
+	if err != nil || !strings.HasSuffix(strings.ToLower(book.FilePath), ".epub") {
+		return false
+	}
+
+	info, err := os.Stat(book.FilePath)
+	if err != nil {
+		return false
+	}
+	modTime := info.ModTime().Unix()
+
+	h.checkMutex.Lock()
+	cached, ok := h.checkResults[id]
+	h.checkMutex.Unlock()
+	if ok && cached.modTime == modTime {
+		return cached.exists
+	}
+
+	exists := false
+	if reader, err := zip.OpenReader(book.FilePath); err == nil {
+		if coverPath, err := findCoverInOPF(reader); err == nil {
+			for _, file := range reader.File {
+				if file.Name == filepath.ToSlash(coverPath) {
+					exists = true
+					break
+				}
+			}
+		}
+		reader.Close()
+	}
+
+	h.checkMutex.Lock()
+	h.checkResults[id] = coverCheck{modTime: modTime, exists: exists}
+	h.checkMutex.Unlock()
+
+	return exists
 }
 
 // ServeCover serves a book's cover image
 func (h *CoversHandler) ServeCover(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
+	if r.Method != "GET" && r.Method != "HEAD" && r.Method != "PUT" && r.Method != "DELETE" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
@@ -65,17 +199,141 @@ func (h *CoversHandler) ServeCover(w http.ResponseWriter, r *http.Request) {
 	// Get book details
 	book, err := h.db.GetBookByID(id)
 	if err != nil {
-		http.Error(w, "Book not found", http.StatusNotFound)
+		writeBookNotFound(w, r, id)
 		return
 	}
 
-	// Check if it's an EPUB file
-	if !strings.HasSuffix(strings.ToLower(book.FilePath), ".epub") {
-		http.Error(w, "Cover extraction only supported for EPUB files", http.StatusBadRequest)
+	// Uploads and removals of custom covers
+	if r.Method == "PUT" || r.Method == "DELETE" {
+		h.UploadCover(w, r, book)
+		return
+	}
+
+	if r.URL.Query().Get("size") == "thumbnail" {
+		data, source, err := h.thumbnail(book, true)
+		switch {
+		case err == errNoCover:
+			http.Error(w, "Cover not found", http.StatusNotFound)
+		case err == errCoverTooLarge:
+			w.Header().Set("X-Cover-Source", source)
+			h.servePlaceholder(w, r, 200, 280)
+		case err != nil:
+			w.Header().Set("X-Cover-Source", source)
+			http.Error(w, "Failed to generate thumbnail", http.StatusInternalServerError)
+		default:
+			w.Header().Set("Content-Type", "image/jpeg")
+			w.Header().Set("X-Cover-Source", source)
+			serveImage(w, r, data, h.coverModTime(book, source))
+		}
+		return
+	}
+
+	// Walk the provider chain until a source yields an image
+	for _, source := range h.providerOrder {
+		if _, ok := h.coverVersion(book, source); !ok {
+			continue
+		}
+
+		imageData, err := h.loadCoverFromSource(book, source)
+		if err == errCoverTooLarge && source == CoverSourceEmbedded {
+			// Refuse to load oversized images into memory: stream the original instead
+			log.Printf("Cover of book %d is above the %d byte limit", book.ID, h.maxImageBytes)
+			w.Header().Set("X-Cover-Source", source)
+			h.streamEmbeddedCover(w, r, book)
+			return
+		}
+		if err != nil {
+			continue
+		}
+		w.Header().Set("X-Cover-Source", source)
+
+		// Serve full image
+		contentType := http.DetectContentType(imageData)
+		w.Header().Set("Content-Type", contentType)
+		serveImage(w, r, imageData, h.coverModTime(book, source))
 		return
 	}
 
-	// Open the EPUB file
+	http.Error(w, "Cover not found", http.StatusNotFound)
+}
+
+// serveImage writes an image with an ETag derived from its content, answering
+// conditional, range and HEAD requests. A zero modTime omits Last-Modified.
+func serveImage(w http.ResponseWriter, r *http.Request, data []byte, modTime time.Time) {
+	w.Header().Set("ETag", fmt.Sprintf("\"%x\"", sha1.Sum(data)))
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(data))
+}
+
+// coverModTime returns when a cover source's image last changed, or the zero time for
+// online sources
+func (h *CoversHandler) coverModTime(book models.Book, source string) time.Time {
+	var path string
+	switch source {
+	case CoverSourceEmbedded:
+		path = book.FilePath
+	case CoverSourceCustom:
+		path = h.customCoverPath(book.ID)
+	}
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// thumbnail returns a book's JPEG cover thumbnail and the source it came from, walking
+// the provider chain until a source yields an image. Thumbnails are served from and added
+// to the thumbnail cache. Without remote, online sources are only used when cached.
+// errNoCover means no source has a cover, errCoverTooLarge that the cover found is too
+// large to decode.
+func (h *CoversHandler) thumbnail(book models.Book, remote bool) ([]byte, string, error) {
+	for _, source := range h.providerOrder {
+		version, ok := h.coverVersion(book, source)
+		if !ok {
+			continue
+		}
+
+		// Use the cached thumbnail if the source's cover hasn't changed since it was generated
+		cacheKey := fmt.Sprintf("%d_%s_%s_thumb.jpg", book.ID, source, version)
+		if data, ok := h.cache.Get(cacheKey); ok {
+			return data, source, nil
+		}
+		if !remote && (source == CoverSourceOpenLibrary || source == CoverSourceGoogle) {
+			continue
+		}
+
+		imageData, err := h.loadCoverFromSource(book, source)
+		if err == errCoverTooLarge && source == CoverSourceEmbedded {
+			// Refuse to load oversized images into memory
+			log.Printf("Cover of book %d is above the %d byte limit", book.ID, h.maxImageBytes)
+			return nil, source, errCoverTooLarge
+		}
+		if err != nil {
+			continue
+		}
+
+		thumbnailData, _, err := h.generateThumbnail(imageData, "image/jpeg", 200, 280)
+		if err == errCoverTooLarge {
+			log.Printf("Cover of book %d has too many pixels to decode", book.ID)
+			return nil, source, err
+		}
+		if err != nil {
+			return nil, source, err
+		}
+		if err := h.cache.Put(cacheKey, thumbnailData); err != nil {
+			log.Printf("Failed to cache thumbnail for book %d: %v", book.ID, err)
+		}
+		return thumbnailData, source, nil
+	}
+
+	return nil, "", errNoCover
+}
+
+// streamEmbeddedCover copies a book's embedded cover to the response without loading it into memory
+func (h *CoversHandler) streamEmbeddedCover(w http.ResponseWriter, r *http.Request, book models.Book) {
 	reader, err := zip.OpenReader(book.FilePath)
 	if err != nil {
 		http.Error(w, "Failed to open EPUB file", http.StatusInternalServerError)
@@ -83,14 +341,11 @@ func (h *CoversHandler) ServeCover(w http.ResponseWriter, r *http.Request) {
 	}
 	defer reader.Close()
 
-	// Find cover image
-	coverPath, err := h.findCoverInOPF(reader)
+	coverPath, err := findCoverInOPF(reader)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Cover not found: %v", err), http.StatusNotFound)
 		return
 	}
-
-	// Read cover image
 	coverFile, err := reader.Open(coverPath)
 	if err != nil {
 		http.Error(w, "Failed to open cover image", http.StatusInternalServerError)
@@ -98,64 +353,79 @@ func (h *CoversHandler) ServeCover(w http.ResponseWriter, r *http.Request) {
 	}
 	defer coverFile.Close()
 
-	// Read image data
-	imageData, err := io.ReadAll(coverFile)
-	if err != nil {
-		http.Error(w, "Failed to read cover image", http.StatusInternalServerError)
-		return
+	w.Header().Set("Content-Type", epubContentType(coverPath))
+	if info, err := coverFile.Stat(); err == nil {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
 	}
-
-	// Check for size parameter
-	size := r.URL.Query().Get("size")
-	if size == "thumbnail" {
-		// Generate thumbnail
-		thumbnailData, contentType, err := h.generateThumbnail(imageData, "image/jpeg", 200, 280)
-		if err != nil {
-			http.Error(w, "Failed to generate thumbnail", http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-Type", contentType)
-		w.Write(thumbnailData)
+	if r.Method == "HEAD" {
 		return
 	}
+	io.Copy(w, coverFile)
+}
+
+// Strategies resolveEPUBCover uses to find an embedded cover, in the order tried
+const (
+	coverStrategyMeta       = "meta-cover"  // <meta name="cover" content="{manifest id}"/>
+	coverStrategyManifestID = "manifest-id" // First manifest item with "cover" in its ID
+)
+
+// coverResolution explains how the embedded cover of an EPUB was found, or why it wasn't
+type coverResolution struct {
+	OPFPath   string   `json:"opf_path,omitempty"`
+	Strategy  string   `json:"strategy,omitempty"`   // Strategy that matched
+	ItemID    string   `json:"item_id,omitempty"`    // Manifest item of the cover
+	Path      string   `json:"path,omitempty"`       // Path of the cover inside the EPUB
+	MediaType string   `json:"media_type,omitempty"` // Media type the manifest declares
+	Trace     []string `json:"trace"`                // Each step taken, in order
+}
 
-	// Serve full image
-	contentType := http.DetectContentType(imageData)
-	w.Header().Set("Content-Type", contentType)
-	w.Write(imageData)
+// tracef records a step of the cover resolution
+func (c *coverResolution) tracef(format string, args ...interface{}) {
+	c.Trace = append(c.Trace, fmt.Sprintf(format, args...))
 }
 
 // findCoverInOPF finds the cover image path in the OPF file using XML parsing
-func (h *CoversHandler) findCoverInOPF(reader *zip.ReadCloser) (string, error) {
+func findCoverInOPF(reader *zip.ReadCloser) (string, error) {
+	resolution, err := resolveEPUBCover(reader)
+	return resolution.Path, err
+}
+
+// resolveEPUBCover finds the embedded cover of an EPUB, tracing each step. The
+// resolution is returned, as far as it got, even when no cover is found.
+func resolveEPUBCover(reader *zip.ReadCloser) (*coverResolution, error) {
+	resolution := &coverResolution{Trace: []string{}}
+
 	// Find the OPF file
-	var opfPath string
 	for _, file := range reader.File {
 		if strings.HasSuffix(file.Name, ".opf") {
-			opfPath = file.Name
+			resolution.OPFPath = file.Name
 			break
 		}
 	}
-
-	if opfPath == "" {
-		return "", fmt.Errorf("no OPF file found")
+	if resolution.OPFPath == "" {
+		resolution.tracef("No .opf file in the archive")
+		return resolution, fmt.Errorf("no OPF file found")
 	}
+	resolution.tracef("Using OPF file %s", resolution.OPFPath)
 
 	// Read and parse the OPF file
-	opfFile, err := reader.Open(opfPath)
+	opfFile, err := reader.Open(resolution.OPFPath)
 	if err != nil {
-		return "", err
+		resolution.tracef("Failed to open the OPF file: %v", err)
+		return resolution, err
 	}
 	defer opfFile.Close()
 
-	// Parse XML using Go's standard library
 	opfData, err := io.ReadAll(opfFile)
 	if err != nil {
-		return "", err
+		resolution.tracef("Failed to read the OPF file: %v", err)
+		return resolution, err
 	}
 
 	var opf OPFDocument
-	if err := xml.Unmarshal(opfData, &opf); err != nil {
-		return "", fmt.Errorf("failed to parse OPF XML: %v", err)
+	if err := xmlutil.Unmarshal(opfData, &opf); err != nil {
+		resolution.tracef("Failed to parse the OPF file: %v", err)
+		return resolution, fmt.Errorf("failed to parse OPF XML: %v", err)
 	}
 
 	// Step 1: Find cover metadata
@@ -163,57 +433,84 @@ func (h *CoversHandler) findCoverInOPF(reader *zip.ReadCloser) (string, error) {
 	for _, meta := range opf.Metadata.Meta {
 		if meta.Name == "cover" {
 			coverID = meta.Content
-			fmt.Printf("Found cover metadata: <meta name=\"cover\" content=\"%s\"/>\n", coverID)
+			resolution.tracef("Found cover metadata: <meta name=\"cover\" content=\"%s\"/>", coverID)
 			break
 		}
 	}
 
+	var cover *ManifestItem
 	if coverID == "" {
 		// Fallback: look for direct cover references in manifest
-		for _, item := range opf.Manifest.Items {
+		resolution.tracef("No cover metadata, looking for a manifest item with \"cover\" in its ID")
+		for i, item := range opf.Manifest.Items {
 			if item.ID == "cover" || strings.Contains(item.ID, "cover") {
-				fmt.Printf("Found direct cover reference: %s\n", item.Href)
-				// Make path relative to OPF file location
-				opfDir := filepath.Dir(opfPath)
-				if opfDir != "." {
-					return filepath.Join(opfDir, item.Href), nil
-				}
-				return item.Href, nil
+				cover = &opf.Manifest.Items[i]
+				resolution.Strategy = coverStrategyManifestID
+				break
 			}
 		}
-		return "", fmt.Errorf("no cover metadata found in OPF")
+		if cover == nil {
+			resolution.tracef("No manifest item has \"cover\" in its ID")
+			return resolution, fmt.Errorf("no cover metadata found in OPF")
+		}
+	} else {
+		// Step 2: Find manifest item by cover ID
+		for i, item := range opf.Manifest.Items {
+			if item.ID == coverID {
+				cover = &opf.Manifest.Items[i]
+				resolution.Strategy = coverStrategyMeta
+				break
+			}
+		}
+		if cover == nil {
+			resolution.tracef("No manifest item has the ID %q", coverID)
+			return resolution, fmt.Errorf("cover ID '%s' not found in manifest", coverID)
+		}
 	}
+	resolution.ItemID = cover.ID
+	resolution.MediaType = cover.MediaType
+	resolution.tracef("Found cover image in manifest: item %q, href %s, media type %q", cover.ID, cover.Href, cover.MediaType)
 
-	// Step 2: Find manifest item by cover ID
-	var coverPath string
-	for _, item := range opf.Manifest.Items {
-		if item.ID == coverID {
-			coverPath = item.Href
-			fmt.Printf("Found cover image in manifest: %s\n", coverPath)
-			break
-		}
+	// Step 3: Make path relative to OPF file location
+	resolution.Path = cover.Href
+	if opfDir := filepath.Dir(resolution.OPFPath); opfDir != "." {
+		resolution.Path = filepath.Join(opfDir, cover.Href)
 	}
+	resolution.tracef("Resolved cover path: %s", resolution.Path)
+	return resolution, nil
+}
 
-	if coverPath == "" {
-		return "", fmt.Errorf("cover ID '%s' not found in manifest", coverID)
+// generateThumbnail creates a thumbnail version of the image
+func (h *CoversHandler) generateThumbnail(imageData []byte, contentType string, maxWidth, maxHeight int) ([]byte, string, error) {
+	resized, err := h.scaleImage(imageData, maxWidth, maxHeight)
+	if err != nil {
+		return nil, "", err
 	}
 
-	// Step 3: Make path relative to OPF file location
-	opfDir := filepath.Dir(opfPath)
-	if opfDir != "." {
-		coverPath = filepath.Join(opfDir, coverPath)
+	// Encode as JPEG
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: h.jpegQuality}); err != nil {
+		return nil, "", fmt.Errorf("failed to encode thumbnail: %v", err)
 	}
 
-	fmt.Printf("Resolved cover path: %s\n", coverPath)
-	return coverPath, nil
+	return buf.Bytes(), "image/jpeg", nil
 }
 
-// generateThumbnail creates a thumbnail version of the image
-func (h *CoversHandler) generateThumbnail(imageData []byte, contentType string, maxWidth, maxHeight int) ([]byte, string, error) {
+// scaleImage decodes an image and scales it to fit within maxWidth x maxHeight, keeping the aspect ratio
+func (h *CoversHandler) scaleImage(imageData []byte, maxWidth, maxHeight int) (image.Image, error) {
+	// Check the dimensions before decoding so huge images are never allocated
+	imageConfig, _, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	if h.maxImagePixels > 0 && imageConfig.Width*imageConfig.Height > h.maxImagePixels {
+		return nil, errCoverTooLarge
+	}
+
 	// Decode the image
 	img, _, err := image.Decode(bytes.NewReader(imageData))
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to decode image: %v", err)
+		return nil, fmt.Errorf("failed to decode image: %v", err)
 	}
 
 	// Calculate thumbnail dimensions (maintain aspect ratio)
@@ -233,15 +530,228 @@ func (h *CoversHandler) generateThumbnail(imageData []byte, contentType string,
 	newHeight := int(float64(height) * scale)
 
 	// Resize the image
-	resized := h.resizeImage(img, newWidth, newHeight)
+	return h.resizeImage(img, newWidth, newHeight), nil
+}
+
+// Montage tile size in pixels (same aspect ratio as thumbnails)
+const (
+	montageTileWidth  = 120
+	montageTileHeight = 168
+)
+
+// placeholderColor fills placeholder thumbnails and montage tiles without a cover
+var placeholderColor = color.RGBA{R: 0xd9, G: 0xd9, B: 0xd9, A: 0xff}
+
+// bookWithCover is a book list entry with its cover thumbnail as a data URI, or the
+// thumbnail's URL once the embedded covers reach the size cap
+type bookWithCover struct {
+	models.Book
+	Cover string `json:"cover,omitempty"`
+}
+
+// GetBooksWithCovers returns a page of books like GET /api/books, each with a "cover"
+// that is a base64 JPEG thumbnail data URI (GET /api/books?embed_covers=thumbnail), so
+// a page of the catalog renders from one request. Once the embedded thumbnails reach
+// covers.embed_max_bytes, the remaining books get the thumbnail URL instead; books
+// without a cover get neither.
+func (h *CoversHandler) GetBooksWithCovers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.URL.Query().Get("embed_covers") != "thumbnail" {
+		http.Error(w, "Invalid embed_covers, must be: thumbnail", http.StatusBadRequest)
+		return
+	}
+
+	limit, offset, ok := booksPage(w, r)
+	if !ok {
+		return
+	}
+
+	books, total, err := h.db.GetBooksPaginated(limit, offset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	shortenDescriptions(books)
+
+	entries := make([]bookWithCover, 0, len(books))
+	var embedded int64
+	capped := false
+	for _, book := range books {
+		entry := bookWithCover{Book: book}
+		thumbnailURL := fmt.Sprintf("/api/covers/%d?size=thumbnail", book.ID)
+		if capped {
+			entry.Cover = thumbnailURL
+		} else if data, _, err := h.thumbnail(book, false); err == nil {
+			cover := "data:image/jpeg;base64," + base64.StdEncoding.EncodeToString(data)
+			if h.embedMaxBytes > 0 && embedded+int64(len(cover)) > h.embedMaxBytes {
+				capped = true
+				entry.Cover = thumbnailURL
+			} else {
+				embedded += int64(len(cover))
+				entry.Cover = cover
+			}
+		} else if err == errCoverTooLarge {
+			entry.Cover = thumbnailURL // Served as a placeholder
+		}
+		entries = append(entries, entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, map[string]interface{}{
+		"books":  entries,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+// ServeMontage composites the covers of a set of books into a single grid image.
+// Books are selected by author (?author=) or by a comma-separated ID list (?ids=).
+func (h *CoversHandler) ServeMontage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var books []models.Book
+	var err error
+	if author := r.URL.Query().Get("author"); author != "" {
+		books, err = h.db.GetBooksByAuthor(author)
+	} else if idsParam := r.URL.Query().Get("ids"); idsParam != "" {
+		var ids []int
+		for _, idStr := range strings.Split(idsParam, ",") {
+			id, convErr := strconv.Atoi(strings.TrimSpace(idStr))
+			if convErr != nil {
+				http.Error(w, "Invalid book ID", http.StatusBadRequest)
+				return
+			}
+			ids = append(ids, id)
+		}
+		books, err = h.db.GetBooksByIDs(ids)
+	} else {
+		http.Error(w, "Author or ids parameter is required", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(books) == 0 {
+		http.Error(w, "No books found", http.StatusNotFound)
+		return
+	}
+	if h.montageMax > 0 && len(books) > h.montageMax {
+		books = books[:h.montageMax]
+	}
+
+	// The montage changes whenever the selection or one of the covers changes
+	keySource := fmt.Sprintf("%d", h.montageColumns)
+	for _, book := range books {
+		keySource += fmt.Sprintf("|%d:%s", book.ID, h.currentCoverVersion(book))
+	}
+	cacheKey := fmt.Sprintf("montage_%x.jpg", sha1.Sum([]byte(keySource)))
+	if data, ok := h.cache.Get(cacheKey); ok {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(data)
+		return
+	}
+
+	columns := h.montageColumns
+	if columns < 1 {
+		columns = 3
+	}
+	if len(books) < columns {
+		columns = len(books)
+	}
+	rows := (len(books) + columns - 1) / columns
+
+	montage := image.NewRGBA(image.Rect(0, 0, columns*montageTileWidth, rows*montageTileHeight))
+	draw.Draw(montage, montage.Bounds(), &image.Uniform{C: placeholderColor}, image.Point{}, draw.Src)
+
+	for i, book := range books {
+		tile := image.Rect(0, 0, montageTileWidth, montageTileHeight).Add(image.Pt((i%columns)*montageTileWidth, (i/columns)*montageTileHeight))
+
+		imageData, _, err := h.loadCover(book)
+		if err != nil {
+			continue // Leave the placeholder tile
+		}
+		cover, err := h.scaleImage(imageData, montageTileWidth, montageTileHeight)
+		if err != nil {
+			continue
+		}
+
+		// Center the scaled cover in its tile
+		offset := image.Pt((montageTileWidth-cover.Bounds().Dx())/2, (montageTileHeight-cover.Bounds().Dy())/2)
+		draw.Draw(montage, cover.Bounds().Add(tile.Min).Add(offset), cover, cover.Bounds().Min, draw.Src)
+	}
 
-	// Encode as JPEG
 	var buf bytes.Buffer
-	if err := jpeg.Encode(&buf, resized, &jpeg.Options{Quality: 85}); err != nil {
-		return nil, "", fmt.Errorf("failed to encode thumbnail: %v", err)
+	if err := jpeg.Encode(&buf, montage, &jpeg.Options{Quality: h.jpegQuality}); err != nil {
+		http.Error(w, "Failed to encode montage", http.StatusInternalServerError)
+		return
+	}
+	if err := h.cache.Put(cacheKey, buf.Bytes()); err != nil {
+		log.Printf("Failed to cache montage: %v", err)
 	}
 
-	return buf.Bytes(), "image/jpeg", nil
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Write(buf.Bytes())
+}
+
+// loadCoverImage reads a book's embedded cover image, refusing images above the size limit
+func (h *CoversHandler) loadCoverImage(book models.Book) ([]byte, error) {
+	if !strings.HasSuffix(strings.ToLower(book.FilePath), ".epub") {
+		return nil, fmt.Errorf("cover extraction only supported for EPUB files")
+	}
+
+	reader, err := zip.OpenReader(book.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	coverPath, err := findCoverInOPF(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	coverFile, err := reader.Open(coverPath)
+	if err != nil {
+		return nil, err
+	}
+	defer coverFile.Close()
+
+	if info, err := coverFile.Stat(); err == nil && h.maxImageBytes > 0 && info.Size() > h.maxImageBytes {
+		return nil, errCoverTooLarge
+	}
+
+	return io.ReadAll(coverFile)
+}
+
+// servePlaceholder writes a plain placeholder thumbnail for books whose cover cannot be used
+func (h *CoversHandler) servePlaceholder(w http.ResponseWriter, r *http.Request, width, height int) {
+	data, err := h.placeholderThumbnail(width, height)
+	if err != nil {
+		http.Error(w, "Failed to generate placeholder", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	serveImage(w, r, data, time.Time{})
+}
+
+// placeholderThumbnail renders a neutral grey JPEG of the given size
+func (h *CoversHandler) placeholderThumbnail(width, height int) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: placeholderColor}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: h.jpegQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 // resizeImage resizes an image to the specified dimensions
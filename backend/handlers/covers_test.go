@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fableflow/backend/config"
+)
+
+func TestGetBooksWithCoversPages(t *testing.T) {
+	cfg := &config.Config{}
+	books := newTestBooksHandler(t, cfg)
+	for _, path := range []string{"/library/a.epub", "/library/b.epub", "/library/c.epub"} {
+		if err := books.db.AddBook(testBook(path)); err != nil {
+			t.Fatalf("AddBook: %v", err)
+		}
+	}
+	h := NewCoversHandler(books.db, nil, cfg)
+
+	w := httptest.NewRecorder()
+	h.GetBooksWithCovers(w, httptest.NewRequest("GET", "/api/books?embed_covers=thumbnail&limit=2&offset=1", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var page struct {
+		Books  []bookWithCover `json:"books"`
+		Total  int             `json:"total"`
+		Limit  int             `json:"limit"`
+		Offset int             `json:"offset"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Books) != 2 || page.Total != 3 || page.Limit != 2 || page.Offset != 1 {
+		t.Errorf("got %d books, total %d, limit %d, offset %d; want 2, 3, 2, 1", len(page.Books), page.Total, page.Limit, page.Offset)
+	}
+
+	w = httptest.NewRecorder()
+	h.GetBooksWithCovers(w, httptest.NewRequest("GET", "/api/books?embed_covers=thumbnail&limit=0", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("limit=0: status %d, want 400", w.Code)
+	}
+}
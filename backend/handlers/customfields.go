@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Limits on user-defined book fields
+const (
+	maxCustomFieldValueLength = 2000 // Characters per value
+	maxCustomFieldsPerBook    = 50
+)
+
+// customFieldKeyPattern allows short names like "shelf location", "lent_to" or "condition.notes"
+var customFieldKeyPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 _.-]{0,63}$`)
+
+// HandleCustomFields reads and changes a book's custom metadata fields:
+// GET returns them, PUT adds or replaces the fields in a JSON object of strings,
+// and DELETE ?key= removes one field (/api/books/{id}/custom-fields).
+func (h *BooksHandler) HandleCustomFields(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "PUT" && r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// URL format: /api/books/{id}/custom-fields
+	bookID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetBookByID(bookID); err != nil {
+		writeBookNotFound(w, r, bookID)
+		return
+	}
+
+	switch r.Method {
+	case "PUT":
+		var fields map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+			http.Error(w, "Invalid JSON: expected an object of string values", http.StatusBadRequest)
+			return
+		}
+		if err := h.validateCustomFields(bookID, fields); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := h.db.SetCustomFields(bookID, fields); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case "DELETE":
+		key := strings.TrimSpace(r.URL.Query().Get("key"))
+		if key == "" {
+			http.Error(w, "Key parameter is required", http.StatusBadRequest)
+			return
+		}
+		deleted, err := h.db.DeleteCustomField(bookID, key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !deleted {
+			http.Error(w, fmt.Sprintf("Custom field %q not found", key), http.StatusNotFound)
+			return
+		}
+	}
+
+	fields, err := h.db.GetCustomFields(bookID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, fields)
+}
+
+// validateCustomFields checks key names and value sizes, and that the book stays within
+// maxCustomFieldsPerBook once the fields are applied
+func (h *BooksHandler) validateCustomFields(bookID int, fields map[string]string) error {
+	if len(fields) == 0 {
+		return fmt.Errorf("no fields given")
+	}
+
+	existing, err := h.db.GetCustomFields(bookID)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]bool)
+	for key := range existing {
+		keys[strings.ToLower(key)] = true
+	}
+
+	for key, value := range fields {
+		if !customFieldKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid field name %q: use up to 64 letters, digits, spaces, '_', '.' or '-', starting with a letter or digit", key)
+		}
+		if !utf8.ValidString(value) || utf8.RuneCountInString(value) > maxCustomFieldValueLength {
+			return fmt.Errorf("value of %q must be valid text of at most %d characters", key, maxCustomFieldValueLength)
+		}
+		keys[strings.ToLower(key)] = true
+	}
+	if len(keys) > maxCustomFieldsPerBook {
+		return fmt.Errorf("a book can have at most %d custom fields", maxCustomFieldsPerBook)
+	}
+
+	return nil
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"archive/zip"
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"fableflow/backend/epub"
+)
+
+// epubArchives keeps the EPUBs being read open between the reader's asset requests, so
+// a chapter with dozens of images, fonts and stylesheets opens and indexes the archive
+// once instead of once per request. Archives are closed after an idle period, and when
+// the book file changes they are reopened.
+type epubArchives struct {
+	mu          sync.Mutex
+	archives    map[string]*epubArchive // By book file path
+	idle        time.Duration
+	maxRequests int
+	janitor     sync.Once
+}
+
+// epubArchive is an open EPUB shared by concurrent asset requests. The request that
+// adds it to the cache opens it; the others wait for ready. reader, files, encrypted
+// and err are only set before ready is closed.
+type epubArchive struct {
+	ready     chan struct{}
+	err       error // Why the EPUB could not be opened
+	reader    *zip.ReadCloser
+	files     map[string]*zip.File // Entries by name
+	encrypted map[string]string    // Encryption algorithm by entry name, from encryption.xml
+	modTime   time.Time
+	size      int64
+	slots     chan struct{} // Bounds concurrent requests reading from the archive
+	users     int           // Requests holding the archive, guarded by epubArchives.mu
+	lastUsed  time.Time
+	stale     bool // The file changed; close once the last user is done
+}
+
+// newEPUBArchives creates an archive cache closing archives unused for idle and
+// letting at most maxRequests requests read from one book at a time
+func newEPUBArchives(idle time.Duration, maxRequests int) *epubArchives {
+	if maxRequests < 1 {
+		maxRequests = 1
+	}
+	return &epubArchives{
+		archives:    make(map[string]*epubArchive),
+		idle:        idle,
+		maxRequests: maxRequests,
+	}
+}
+
+// acquire returns the open archive of the EPUB at path, opening it if needed, once one
+// of its request slots is free. release must be called when the caller is done with it.
+// Archives are opened without holding mu, so opening a large book does not hold up
+// requests for other books.
+func (c *epubArchives) acquire(ctx context.Context, path string) (archive *epubArchive, release func(), err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	archive = c.archives[path]
+	if archive != nil && (!archive.modTime.Equal(info.ModTime()) || archive.size != info.Size()) {
+		c.retire(path, archive)
+		archive = nil
+	}
+	opening := archive == nil
+	if opening {
+		archive = &epubArchive{
+			ready:    make(chan struct{}),
+			modTime:  info.ModTime(),
+			size:     info.Size(),
+			slots:    make(chan struct{}, c.maxRequests),
+			lastUsed: time.Now(),
+		}
+		c.archives[path] = archive
+	}
+	archive.users++
+	c.mu.Unlock()
+
+	if c.idle > 0 {
+		c.janitor.Do(func() { go c.closeIdle() })
+	}
+
+	done := func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		archive.users--
+		archive.lastUsed = time.Now()
+		if archive.users == 0 && (archive.stale || c.idle <= 0) {
+			if c.archives[path] == archive {
+				delete(c.archives, path)
+			}
+			if archive.reader != nil { // Not when it failed to open
+				archive.reader.Close()
+			}
+		}
+	}
+
+	if opening {
+		archive.err = archive.load(path)
+		if archive.err != nil {
+			// Dropped right away, so the next request tries again
+			c.mu.Lock()
+			if c.archives[path] == archive {
+				delete(c.archives, path)
+			}
+			c.mu.Unlock()
+		}
+		close(archive.ready)
+	} else {
+		select {
+		case <-archive.ready:
+		case <-ctx.Done():
+			done()
+			return nil, nil, ctx.Err()
+		}
+	}
+	if archive.err != nil {
+		done()
+		return nil, nil, archive.err
+	}
+
+	select {
+	case archive.slots <- struct{}{}:
+	case <-ctx.Done():
+		done()
+		return nil, nil, ctx.Err()
+	}
+	return archive, func() {
+		<-archive.slots
+		done()
+	}, nil
+}
+
+// load opens and indexes the EPUB at path
+func (a *epubArchive) load(path string) error {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+
+	files := make(map[string]*zip.File, len(reader.File))
+	for _, file := range reader.File {
+		if _, exists := files[file.Name]; !exists {
+			files[file.Name] = file
+		}
+	}
+
+	// Embedded fonts may be obfuscated; which ones is the same for every request
+	encrypted, err := epub.EncryptedResources(reader.File)
+	if err != nil {
+		log.Printf("Failed to read encryption.xml of %s: %v", path, err)
+	}
+
+	a.reader = reader
+	a.files = files
+	a.encrypted = encrypted
+	return nil
+}
+
+// retire removes an archive from the cache, closing it right away when no request is
+// using it; callers must hold mu
+func (c *epubArchives) retire(path string, archive *epubArchive) {
+	delete(c.archives, path)
+	if archive.users == 0 {
+		// Unused archives are always open: the request opening one is using it
+		archive.reader.Close()
+	} else {
+		archive.stale = true
+	}
+}
+
+// closeIdle periodically closes archives that no request used for the idle period
+func (c *epubArchives) closeIdle() {
+	interval := c.idle / 2
+	if interval < time.Second {
+		interval = time.Second
+	}
+	for range time.Tick(interval) {
+		c.mu.Lock()
+		for path, archive := range c.archives {
+			if archive.users == 0 && time.Since(archive.lastUsed) > c.idle {
+				c.retire(path, archive)
+			}
+		}
+		c.mu.Unlock()
+	}
+}
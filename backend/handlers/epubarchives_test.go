@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// copyEPUB copies the sample EPUB into dir
+func copyEPUB(t *testing.T, dir string) string {
+	t.Helper()
+	data, err := os.ReadFile(sampleEPUB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "book.epub")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEPUBArchivesShareOpenArchive(t *testing.T) {
+	path := copyEPUB(t, t.TempDir())
+	const requests = 20
+	c := newEPUBArchives(time.Minute, requests)
+
+	// Every request holds the archive until all have it, so none can reopen it
+	var wg, holding sync.WaitGroup
+	holding.Add(requests)
+	archives := make([]*epubArchive, requests)
+	errs := make([]error, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			archive, release, err := c.acquire(context.Background(), path)
+			holding.Done()
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			holding.Wait()
+			archives[i] = archive
+			release()
+		}()
+	}
+	wg.Wait()
+
+	for i := range archives {
+		if errs[i] != nil {
+			t.Fatalf("acquire: %v", errs[i])
+		}
+		if archives[i] != archives[0] {
+			t.Fatalf("request %d got its own archive, want all %d to share one", i, requests)
+		}
+	}
+	if archives[0].files["mimetype"] == nil {
+		t.Error("shared archive is not indexed")
+	}
+	if len(c.archives) != 1 || archives[0].users != 0 {
+		t.Errorf("%d archives cached, %d users; want one unused archive", len(c.archives), archives[0].users)
+	}
+
+	// A changed book file is reopened
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	archive, release, err := c.acquire(context.Background(), path)
+	if err != nil {
+		t.Fatalf("acquire after change: %v", err)
+	}
+	release()
+	if archive == archives[0] {
+		t.Error("changed book file was not reopened")
+	}
+}
+
+func TestEPUBArchivesOpenFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.epub")
+	if err := os.WriteFile(path, []byte("not a zip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := newEPUBArchives(time.Minute, 1)
+
+	if _, _, err := c.acquire(context.Background(), path); err == nil {
+		t.Fatal("acquire opened a broken EPUB")
+	}
+	if len(c.archives) != 0 {
+		t.Errorf("broken EPUB stays cached")
+	}
+
+	// Fixing the file makes the next request open it
+	data, err := os.ReadFile(sampleEPUB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_, release, err := c.acquire(context.Background(), path)
+	if err != nil {
+		t.Fatalf("acquire after fixing the file: %v", err)
+	}
+	release()
+}
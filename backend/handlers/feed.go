@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"fableflow/backend/models"
+)
+
+// atomFeed is an Atom (RFC 4287) feed document
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// atomEntry is one book in a feed
+type atomEntry struct {
+	ID        string       `xml:"id"`
+	Title     string       `xml:"title"`
+	Authors   []atomAuthor `xml:"author"`
+	Published string       `xml:"published,omitempty"`
+	Updated   string       `xml:"updated"`
+	Summary   string       `xml:"summary,omitempty"`
+	Links     []atomLink   `xml:"link"`
+}
+
+// atomAuthor is a person credited in a feed or entry
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// atomLink points from a feed or entry to a related resource
+type atomLink struct {
+	Rel    string `xml:"rel,attr"`
+	Href   string `xml:"href,attr"`
+	Type   string `xml:"type,attr,omitempty"`
+	Title  string `xml:"title,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+// GetRecentFeed returns the feed.recent most recently added books as an Atom feed for
+// feed readers (GET /api/feed/recent.atom). Each entry links to the reader, the download
+// and the cover thumbnail.
+func (h *BooksHandler) GetRecentFeed(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	books, err := h.db.GetRecentBooks(h.config.Feed.Recent)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	base := requestBaseURL(r)
+	feed := atomFeed{
+		ID:      "urn:fableflow:feed:recent",
+		Title:   "FableFlow: recently added books",
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Href: base + "/api/feed/recent.atom", Type: "application/atom+xml"},
+			{Rel: "alternate", Href: base + "/", Type: "text/html"},
+		},
+		Author: atomAuthor{Name: "FableFlow"},
+	}
+
+	// The feed is as recent as its most recently added or edited entry
+	var updated time.Time
+	shortenDescriptions(books)
+	for _, book := range books {
+		entry := atomEntry{
+			ID:        fmt.Sprintf("urn:fableflow:book:%d", book.ID),
+			Title:     book.Title,
+			Published: book.AddedAt.UTC().Format(time.RFC3339),
+			Summary:   book.Description,
+			Links: []atomLink{
+				{Rel: "enclosure", Href: fmt.Sprintf("%s/api/download/%d", base, book.ID), Type: bookContentType(book.Format), Title: "Download", Length: book.FileSize},
+				{Rel: "enclosure", Href: fmt.Sprintf("%s/api/covers/%d?size=thumbnail", base, book.ID), Type: "image/jpeg", Title: "Cover"},
+			},
+		}
+		entryUpdated := book.AddedAt
+		if book.UpdatedAt.After(entryUpdated) {
+			entryUpdated = book.UpdatedAt
+		}
+		entry.Updated = entryUpdated.UTC().Format(time.RFC3339)
+		if entryUpdated.After(updated) {
+			updated = entryUpdated
+		}
+		entry.Authors = atomAuthors(book)
+		if book.Format == "epub" {
+			entry.Links = append([]atomLink{{Rel: "alternate", Href: fmt.Sprintf("%s/read/%d", base, book.ID), Type: "text/html", Title: "Read"}}, entry.Links...)
+		} else {
+			entry.Links = append([]atomLink{{Rel: "alternate", Href: fmt.Sprintf("%s/api/download/%d", base, book.ID), Type: bookContentType(book.Format)}}, entry.Links...)
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	if !updated.IsZero() {
+		feed.Updated = updated.UTC().Format(time.RFC3339)
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		log.Printf("Failed to write recent books feed: %v", err)
+	}
+}
+
+// requestBaseURL returns the scheme and host the client used to reach the server,
+// honouring the X-Forwarded-Proto and X-Forwarded-Host headers set by reverse proxies
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+	host := r.Host
+	if forwarded := r.Header.Get("X-Forwarded-Host"); forwarded != "" {
+		host = forwarded
+	}
+	return scheme + "://" + host
+}
+
+// atomAuthors lists everyone credited on a book, falling back to its author column
+func atomAuthors(book models.Book) []atomAuthor {
+	var authors []atomAuthor
+	for _, name := range book.Authors {
+		authors = append(authors, atomAuthor{Name: name})
+	}
+	if len(authors) == 0 && book.Author != "" {
+		authors = []atomAuthor{{Name: book.Author}}
+	}
+	return authors
+}
@@ -1,16 +1,21 @@
 package handlers
 
 import (
-	"encoding/json"
 	"net/http"
+
+	"fableflow/backend/database"
 )
 
 // HealthHandler handles health check requests
-type HealthHandler struct{}
+type HealthHandler struct {
+	readOnly       bool
+	scanDirectory  string
+	apiKeyRequired bool
+}
 
 // NewHealthHandler creates a new health handler
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+func NewHealthHandler(readOnly bool, scanDirectory string, apiKeyRequired bool) *HealthHandler {
+	return &HealthHandler{readOnly: readOnly, scanDirectory: scanDirectory, apiKeyRequired: apiKeyRequired}
 }
 
 // HealthCheck returns the health status of the API
@@ -20,8 +25,13 @@ func (h *HealthHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 		"service":   "fableflow-api",
 		"version":   "1.0.0",
 		"timestamp": "2024-01-01T00:00:00Z", // You can make this dynamic
+		"read_only": h.readOnly,
+		// False while the library volume is missing or empty, e.g. not mounted yet
+		"library_ready": database.LibraryAvailable(h.scanDirectory),
+		// Downloads and book contents need server.api_key
+		"api_key_required": h.apiKeyRequired,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	encodeJSON(w, r, response)
 }
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"fableflow/backend/models"
+)
+
+// homeSection is one group of books on the home page
+type homeSection struct {
+	ID    string        `json:"id"`
+	Title string        `json:"title"`
+	Books []models.Book `json:"books"`
+}
+
+// GetHome returns the home page sections configured under home.sections in one
+// response (GET /api/home). Sections without books, such as continue-reading before
+// any book was opened in the reader, are left out.
+func (h *BooksHandler) GetHome(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	home := h.config.Home
+	sections := []homeSection{}
+	for _, id := range home.Sections {
+		var section homeSection
+		var books []models.Book
+		var err error
+		switch id {
+		case "continue_reading":
+			section.Title = "Continue reading"
+			if home.ContinueReading > 0 {
+				books, err = h.db.GetBooksInProgress(home.ContinueReading)
+			}
+		case "recent":
+			section.Title = "Recently added"
+			if home.Recent > 0 {
+				books, err = h.db.GetRecentBooks(home.Recent)
+			}
+		case "random":
+			section.Title = "Random picks"
+			if home.Random > 0 {
+				books, err = h.db.GetRandomBooks(home.Random)
+			}
+		case "on_this_day":
+			section.Title = "On this day"
+			if home.OnThisDay > 0 {
+				books, err = h.db.GetBooksAddedOnThisDay(time.Now(), home.OnThisDay)
+			}
+		}
+		if err != nil {
+			// One failing section should not take down the whole page
+			log.Printf("Failed to load home section %s: %v", id, err)
+			continue
+		}
+		if len(books) == 0 {
+			continue
+		}
+
+		shortenDescriptions(books)
+		section.ID = id
+		section.Books = books
+		sections = append(sections, section)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, map[string]interface{}{
+		"sections": sections,
+	})
+}
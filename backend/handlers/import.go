@@ -2,8 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"fableflow/backend/importservice"
 )
@@ -28,12 +31,14 @@ type StartImportRequest struct {
 // StartImportResponse represents the response from starting an import
 type StartImportResponse struct {
 	SessionID string `json:"session_id"`
+	JobID     string `json:"job_id"` // Cancel with POST /api/jobs/{job_id}/cancel
 	Message   string `json:"message"`
 }
 
 // ImportStatusResponse represents the import status response
 type ImportStatusResponse struct {
 	SessionID        string   `json:"session_id"`
+	JobID            string   `json:"job_id,omitempty"`
 	Status           string   `json:"status"`
 	TotalFiles       int      `json:"total_files"`
 	ProcessedFiles   int      `json:"processed_files"`
@@ -44,6 +49,8 @@ type ImportStatusResponse struct {
 	Errors           []string `json:"errors"`
 	StartTime        string   `json:"start_time"`
 	EndTime          string   `json:"end_time,omitempty"`
+	// Estimated seconds until a running import finishes, based on its throughput so far
+	EstimatedSecondsRemaining *int `json:"estimated_seconds_remaining,omitempty"`
 }
 
 // StartImport handles starting a new import session
@@ -68,11 +75,48 @@ func (h *ImportHandler) StartImport(w http.ResponseWriter, r *http.Request) {
 
 	response := StartImportResponse{
 		SessionID: session.ID,
+		JobID:     session.JobID,
 		Message:   "Import session started successfully",
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	encodeJSON(w, r, response)
+}
+
+// RetryQuarantine starts a session that runs every quarantined file back through the
+// import pipeline (POST /api/quarantine/retry-all, optionally with {"dry_run": true}).
+// Its progress is reported like an import's, by GET /api/import/status.
+func (h *ImportHandler) RetryQuarantine(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The body is optional
+	var req StartImportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	session, err := h.importService.StartQuarantineRetry(req.DryRun)
+	if err == importservice.ErrNoQuarantineDirectory {
+		http.Error(w, "Quarantine directory not configured", http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	response := StartImportResponse{
+		SessionID: session.ID,
+		JobID:     session.JobID,
+		Message:   "Quarantine retry started successfully",
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, response)
 }
 
 // GetImportStatus handles getting the current import status
@@ -88,6 +132,16 @@ func (h *ImportHandler) GetImportStatus(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// The ETag changes whenever a counter or the status does, so unchanged polls get a 304
+	etag := fmt.Sprintf("\"%s-%s-%d-%d-%d-%d-%d-%d\"", session.ID, session.Status, session.TotalFiles,
+		session.ProcessedFiles, session.ImportedFiles, session.QuarantinedFiles, session.SkippedFiles, len(session.Errors))
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	// Calculate progress percentage
 	progress := 0
 	if session.TotalFiles > 0 {
@@ -96,6 +150,7 @@ func (h *ImportHandler) GetImportStatus(w http.ResponseWriter, r *http.Request)
 
 	response := ImportStatusResponse{
 		SessionID:        session.ID,
+		JobID:            session.JobID,
 		Status:           session.Status,
 		TotalFiles:       session.TotalFiles,
 		ProcessedFiles:   session.ProcessedFiles,
@@ -111,8 +166,26 @@ func (h *ImportHandler) GetImportStatus(w http.ResponseWriter, r *http.Request)
 		response.EndTime = session.EndTime.Format("2006-01-02 15:04:05")
 	}
 
+	// Extrapolate the remaining time from the average time per processed file
+	if session.Status == "running" && session.ProcessedFiles > 0 && session.TotalFiles >= session.ProcessedFiles {
+		perFile := time.Since(session.StartTime) / time.Duration(session.ProcessedFiles)
+		remaining := int((perFile * time.Duration(session.TotalFiles-session.ProcessedFiles)).Seconds())
+		response.EstimatedSecondsRemaining = &remaining
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	encodeJSON(w, r, response)
+}
+
+// etagMatches reports whether an If-None-Match header value matches etag
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
 }
 
 // GetImportLogs handles getting import session logs
@@ -137,7 +210,7 @@ func (h *ImportHandler) GetImportLogs(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	encodeJSON(w, r, response)
 }
 
 // ListImportLogs handles listing available import session logs
@@ -154,8 +227,13 @@ func (h *ImportHandler) ListImportLogs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Ensure we return an empty array instead of null
+	if logs == nil {
+		logs = []map[string]interface{}{}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(logs)
+	encodeJSON(w, r, logs)
 }
 
 // GetImportLog handles getting a specific import session log
@@ -173,6 +251,12 @@ func (h *ImportHandler) GetImportLog(w http.ResponseWriter, r *http.Request) {
 	}
 	sessionID := pathParts[4]
 
+	// /api/import/logs/{id}/tail streams the log instead
+	if len(pathParts) > 5 && pathParts[5] == "tail" {
+		h.tailImportLog(w, r, sessionID)
+		return
+	}
+
 	// Get the specific log
 	log, err := h.importService.GetLog(sessionID)
 	if err != nil {
@@ -181,5 +265,89 @@ func (h *ImportHandler) GetImportLog(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(log)
+	encodeJSON(w, r, log)
+}
+
+// tailImportLog streams an import session's log as server-sent events. Each line is a
+// "log" event carrying a LogEntry; a final "done" event carries the session status.
+// A running session's lines are sent as they are written, a finished session's saved
+// log is sent in full.
+func (h *ImportHandler) tailImportLog(w http.ResponseWriter, r *http.Request, sessionID string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	backlog, entries, unsubscribe, running := h.importService.SubscribeLog(sessionID)
+	var finished *importservice.ImportSession
+	if running {
+		defer unsubscribe()
+	} else {
+		// The current session may have just finished without its log being saved yet
+		if current := h.importService.GetStatus(); current != nil && current.ID == sessionID {
+			finished = current
+		} else if saved, err := h.importService.GetLog(sessionID); err == nil {
+			finished = saved
+		} else {
+			http.Error(w, "Import session not found", http.StatusNotFound)
+			return
+		}
+		backlog = sessionLogEntries(finished)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	for _, entry := range backlog {
+		writeSSE(w, "log", entry)
+	}
+	flusher.Flush()
+
+	if running {
+		for done := false; !done; {
+			select {
+			case entry, open := <-entries:
+				if !open {
+					done = true
+					break
+				}
+				writeSSE(w, "log", entry)
+				flusher.Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+		finished = h.importService.GetStatus()
+	}
+
+	status := "completed"
+	if finished != nil && finished.ID == sessionID {
+		status = finished.Status
+	}
+	writeSSE(w, "done", map[string]string{"session_id": sessionID, "status": status})
+	flusher.Flush()
+}
+
+// sessionLogEntries returns a finished session's log lines. Logs saved before lines were
+// recorded only have their errors.
+func sessionLogEntries(session *importservice.ImportSession) []importservice.LogEntry {
+	if len(session.Log) > 0 || len(session.Errors) == 0 {
+		return session.Log
+	}
+	entries := make([]importservice.LogEntry, 0, len(session.Errors))
+	for _, message := range session.Errors {
+		entries = append(entries, importservice.LogEntry{Time: session.StartTime, Level: "error", Message: message})
+	}
+	return entries
+}
+
+// writeSSE writes one server-sent event with a JSON payload
+func writeSSE(w http.ResponseWriter, event string, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
 }
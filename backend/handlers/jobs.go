@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"fableflow/backend/jobs"
+)
+
+// JobsHandler reports on and cancels background jobs
+type JobsHandler struct {
+	jobs *jobs.Manager
+}
+
+// NewJobsHandler creates a new jobs handler
+func NewJobsHandler(jobManager *jobs.Manager) *JobsHandler {
+	return &JobsHandler{jobs: jobManager}
+}
+
+// ListJobs returns the queued, running and recently finished background jobs, newest
+// first (GET /api/jobs, optionally filtered with ?status= and ?kind=)
+func (h *JobsHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	kind := r.URL.Query().Get("kind")
+	list := []jobs.Job{}
+	counts := map[string]int{jobs.Queued: 0, jobs.Running: 0, jobs.Completed: 0, jobs.Failed: 0, jobs.Cancelled: 0}
+	for _, job := range h.jobs.List() {
+		counts[job.Status]++
+		if (status == "" || job.Status == status) && (kind == "" || job.Kind == kind) {
+			list = append(list, job)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, map[string]interface{}{
+		"workers":   h.jobs.Workers(),
+		"queued":    counts[jobs.Queued],
+		"running":   counts[jobs.Running],
+		"completed": counts[jobs.Completed],
+		"failed":    counts[jobs.Failed],
+		"cancelled": counts[jobs.Cancelled],
+		"jobs":      list,
+	})
+}
+
+// GetJob returns one job (GET /api/jobs/{id}); POST /api/jobs/{id}/cancel is passed on
+// to CancelJob
+func (h *JobsHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/cancel") {
+		h.CancelJob(w, r)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// URL format: /api/jobs/{id}
+	pathParts := strings.Split(r.URL.Path, "/")
+	if len(pathParts) != 4 || pathParts[3] == "" {
+		http.Error(w, "Invalid URL format", http.StatusBadRequest)
+		return
+	}
+
+	job, exists := h.jobs.Get(pathParts[3])
+	if !exists {
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, job)
+}
+
+// CancelJob asks a queued or running job to stop (POST /api/jobs/{id}/cancel). Queued
+// jobs are cancelled right away; running ones stop at their next checkpoint, and jobs
+// that cannot stop halfway, such as scans, run to completion.
+func (h *JobsHandler) CancelJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// URL format: /api/jobs/{id}/cancel
+	pathParts := strings.Split(r.URL.Path, "/")
+	if len(pathParts) != 5 || pathParts[3] == "" {
+		http.Error(w, "Invalid URL format", http.StatusBadRequest)
+		return
+	}
+
+	job, err := h.jobs.Cancel(pathParts[3])
+	switch err {
+	case nil:
+	case jobs.ErrNotFound:
+		http.Error(w, "Job not found", http.StatusNotFound)
+		return
+	case jobs.ErrFinished:
+		http.Error(w, "Job already finished", http.StatusConflict)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	encodeJSON(w, r, job)
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// prettyJSONDefault makes responses indented unless a request asks for ?pretty=false
+var prettyJSONDefault bool
+
+// SetPrettyJSON sets whether JSON responses are indented by default (server.pretty_json)
+func SetPrettyJSON(pretty bool) {
+	prettyJSONDefault = pretty
+}
+
+// encodeJSON writes v as the JSON response body. Output is compact unless ?pretty=true is
+// given or pretty printing is the configured default. r may be nil to use the default.
+func encodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	pretty := prettyJSONDefault
+	if r != nil {
+		if value, err := strconv.ParseBool(r.URL.Query().Get("pretty")); err == nil {
+			pretty = value
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	if pretty {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(v)
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"fableflow/backend/config"
+	"fableflow/backend/importservice"
+	"fableflow/backend/jobs"
+)
+
+// openAPISchema is the part of an OpenAPI schema needed to find its arrays
+type openAPISchema struct {
+	Ref        string                    `json:"$ref"`
+	Type       string                    `json:"type"`
+	Items      *openAPISchema            `json:"items"`
+	Properties map[string]*openAPISchema `json:"properties"`
+	OneOf      []*openAPISchema          `json:"oneOf"`
+}
+
+// openAPIDocument is the part of openapi.json describing GET responses and schemas
+type openAPIDocument struct {
+	Paths map[string]map[string]struct {
+		Responses map[string]struct {
+			Content map[string]struct {
+				Schema *openAPISchema `json:"schema"`
+			} `json:"content"`
+		} `json:"responses"`
+	} `json:"paths"`
+	Components struct {
+		Schemas map[string]*openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+// resolve follows a schema's $ref to the component it names
+func (d *openAPIDocument) resolve(schema *openAPISchema) *openAPISchema {
+	for schema != nil && schema.Ref != "" {
+		schema = d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+// hasArray reports whether a response with schema contains a list
+func (d *openAPIDocument) hasArray(schema *openAPISchema, depth int) bool {
+	schema = d.resolve(schema)
+	if schema == nil || depth > 4 {
+		return false
+	}
+	if schema.Type == "array" {
+		return true
+	}
+	for _, alternative := range schema.OneOf {
+		if d.hasArray(alternative, depth+1) {
+			return true
+		}
+	}
+	for _, property := range schema.Properties {
+		if d.hasArray(property, depth+1) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkArrays reports every list in value that schema declares an array but that
+// came back as null
+func (d *openAPIDocument) checkArrays(t *testing.T, where string, schema *openAPISchema, value interface{}) {
+	t.Helper()
+	schema = d.resolve(schema)
+	if schema == nil {
+		return
+	}
+	if len(schema.OneOf) > 0 {
+		for _, alternative := range schema.OneOf {
+			alternative = d.resolve(alternative)
+			if _, isArray := value.([]interface{}); isArray == (alternative.Type == "array") {
+				d.checkArrays(t, where, alternative, value)
+				return
+			}
+		}
+		t.Errorf("%s = %v, matches no alternative", where, value)
+		return
+	}
+	switch schema.Type {
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			t.Errorf("%s = %v, want an array", where, value)
+			return
+		}
+		for _, item := range items {
+			d.checkArrays(t, where+"[]", schema.Items, item)
+		}
+	default:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for name, property := range schema.Properties {
+			if v, present := object[name]; present {
+				d.checkArrays(t, where+"."+name, property, v)
+			}
+		}
+	}
+}
+
+func TestListEndpointsReturnEmptyArrays(t *testing.T) {
+	var spec openAPIDocument
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+
+	cfg := &config.Config{}
+	cfg.Library.ScanDirectory = t.TempDir()
+	cfg.Library.QuarantineDirectory = t.TempDir()
+	books := newTestBooksHandler(t, cfg)
+	jobManager := jobs.NewManager(1)
+	conversions := newTestConversionHandler(t, jobManager)
+	imports := NewImportHandler(importservice.NewImportService(&importservice.Config{LogDir: t.TempDir()}, jobManager, nil))
+	jobsHandler := NewJobsHandler(jobManager)
+
+	// Every list endpoint, with each variant of its response, as main.go routes it
+	endpoints := []struct {
+		url     string
+		handler http.HandlerFunc
+	}{
+		{"/api/books", books.GetAllBooks},
+		{"/api/home", books.GetHome},
+		{"/api/books/recent", books.GetRecentBooks},
+		{"/api/books/random", books.GetRandomBooks},
+		{"/api/books/path-mismatches", books.GetPathMismatches},
+		{"/api/search?q=nothing", books.SearchBooks},
+		{"/api/authors", books.GetAuthors},
+		{"/api/authors?with_counts=true", books.GetAuthors},
+		{"/api/authors/letter?letter=A", books.GetAuthorsByLetter},
+		{"/api/authors/letters", books.GetAuthorLetters},
+		{"/api/authors/books?author=Nobody", books.GetBooksByAuthor},
+		{"/api/authors/books?author=Nobody&group_by=series", books.GetBooksByAuthor},
+		{"/api/publishers", books.GetPublishers},
+		{"/api/publishers/letter?letter=A", books.GetPublishersByLetter},
+		{"/api/publishers/books?publisher=Nobody", books.GetBooksByPublisher},
+		{"/api/tags", books.GetTags},
+		{"/api/tags?with_counts=true", books.GetTags},
+		{"/api/tags/books?tag=nothing", books.GetBooksByTag},
+		{"/api/titles", books.GetTitles},
+		{"/api/titles?with_counts=true", books.GetTitles},
+		{"/api/titles/letter?letter=A", books.GetTitlesByLetter},
+		{"/api/titles/letter?letter=A&with_counts=true", books.GetTitlesByLetter},
+		{"/api/titles/letters", books.GetTitleLetters},
+		{"/api/titles/books?title=Nothing", books.GetBooksByTitle},
+		{"/api/quarantine", books.GetQuarantineBooks},
+		{"/api/stats/by-year", books.GetBooksByYear},
+		{"/api/stats/downloads", books.GetDownloadStats},
+		{"/api/library/reorganize", books.ReorganizeLibrary},
+		{"/api/convert/status", conversions.GetConversionStatus},
+		{"/api/import/logs/list", imports.ListImportLogs},
+		{"/api/jobs", jobsHandler.ListJobs},
+	}
+	// Without an import session there is no status to list the errors of
+	notFoundWhenEmpty := map[string]bool{"/api/import/status": true}
+
+	tested := make(map[string]bool)
+	for _, endpoint := range endpoints {
+		u, err := url.Parse(endpoint.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tested[u.Path] = true
+		operation, ok := spec.Paths[u.Path]["get"]
+		if !ok {
+			t.Errorf("GET %s is not in openapi.json", u.Path)
+			continue
+		}
+
+		w := httptest.NewRecorder()
+		endpoint.handler(w, httptest.NewRequest("GET", endpoint.url, nil))
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s: status %d: %s", endpoint.url, w.Code, w.Body)
+			continue
+		}
+		var response interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Errorf("GET %s: %v", endpoint.url, err)
+			continue
+		}
+		spec.checkArrays(t, "GET "+endpoint.url, operation.Responses["200"].Content["application/json"].Schema, response)
+	}
+
+	// A new list endpoint without a {parameter} needs an entry above
+	for path, operations := range spec.Paths {
+		operation, ok := operations["get"]
+		if !ok || strings.Contains(path, "{") || tested[path] || notFoundWhenEmpty[path] {
+			continue
+		}
+		if content, ok := operation.Responses["200"].Content["application/json"]; ok && spec.hasArray(content.Schema, 0) {
+			t.Errorf("list endpoint GET %s is not tested", path)
+		}
+	}
+}
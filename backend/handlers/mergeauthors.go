@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"fableflow/backend/metadata"
+)
+
+// MergeAuthorsRequest names the spellings of one person to merge into a single name
+type MergeAuthorsRequest struct {
+	Authors []string `json:"authors"` // e.g. ["Tolkien, J.R.R.", "JRR Tolkien"]
+	Into    string   `json:"into"`    // e.g. "J. R. R. Tolkien"
+}
+
+// authorMerge is the change an author merge makes to one book
+type authorMerge struct {
+	BookID    int      `json:"book_id"`
+	Title     string   `json:"title"`
+	Author    string   `json:"author"`
+	NewAuthor string   `json:"new_author"`
+	Authors   []string `json:"authors"`
+}
+
+// MergeAuthors credits every book by one of several names to a single one, keeping
+// each person's place and role on the book (POST /api/authors/merge). With
+// ?dry_run=true nothing is changed and the response lists the books that would be.
+// Only the library changes: book files keep their metadata and location until they
+// are edited or reorganized.
+func (h *BooksHandler) MergeAuthors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req MergeAuthorsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	into := strings.Join(strings.Fields(req.Into), " ")
+	if into == "" || len(req.Authors) == 0 {
+		http.Error(w, "authors and into are required", http.StatusBadRequest)
+		return
+	}
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+
+	// Names are compared as SplitAuthors writes them, so "Tolkien, J.R.R." also matches
+	var names []string
+	for _, author := range req.Authors {
+		names = append(names, metadata.SplitAuthors(author)...)
+	}
+
+	changes := []authorMerge{}
+	seen := make(map[int]bool)
+	for _, name := range names {
+		books, err := h.db.GetBooksByAuthor(name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, book := range books {
+			if seen[book.ID] {
+				continue
+			}
+			seen[book.ID] = true
+
+			authors := book.Authors
+			if len(authors) == 0 {
+				authors = metadata.SplitAuthors(book.Author)
+			}
+			merged, changed := mergeAuthorNames(authors, names, into)
+			if !changed {
+				continue
+			}
+			changes = append(changes, authorMerge{
+				BookID:    book.ID,
+				Title:     book.Title,
+				Author:    book.Author,
+				NewAuthor: strings.Join(merged, ", "),
+				Authors:   merged,
+			})
+		}
+	}
+
+	updated := 0
+	if !dryRun {
+		for _, change := range changes {
+			roles, err := h.db.GetBookRoles(change.BookID)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			mergedRoles := make(map[string]string)
+			for name, role := range roles {
+				if containsName(names, name) {
+					name = into
+				}
+				mergedRoles[name] = role
+			}
+			if err := h.db.UpdateAuthors(change.BookID, change.NewAuthor, change.Authors, mergedRoles); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			updated++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, map[string]interface{}{
+		"into":    into,
+		"dry_run": dryRun,
+		"matched": len(changes),
+		"updated": updated,
+		"changes": changes,
+	})
+}
+
+// mergeAuthorNames replaces the names in authors that are one of names with into, in
+// place and only once, and reports whether anything changed
+func mergeAuthorNames(authors, names []string, into string) ([]string, bool) {
+	var merged []string
+	changed := false
+	for _, author := range authors {
+		if containsName(names, author) {
+			changed = changed || author != into
+			author = into
+		}
+		if !containsName(merged, author) {
+			merged = append(merged, author)
+		} else {
+			changed = true
+		}
+	}
+	return merged, changed
+}
+
+// containsName reports whether names holds name, ignoring case
+func containsName(names []string, name string) bool {
+	for _, candidate := range names {
+		if strings.EqualFold(candidate, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fableflow/backend/config"
+	"fableflow/backend/models"
+)
+
+func TestMergeAuthors(t *testing.T) {
+	h := newTestBooksHandler(t, &config.Config{})
+	for _, book := range []models.BookRequest{
+		{Title: "The Hobbit", Author: "Tolkien, J.R.R.", FilePath: "/library/hobbit.epub", Format: "epub"},
+		{Title: "The Silmarillion", Author: "JRR Tolkien & Christopher Tolkien", FilePath: "/library/silmarillion.epub", Format: "epub"},
+		{Title: "The History of Middle-earth", Author: "Christopher Tolkien", FilePath: "/library/history.epub", Format: "epub"},
+	} {
+		if err := h.db.AddBook(book); err != nil {
+			t.Fatalf("AddBook: %v", err)
+		}
+	}
+
+	merge := func(query string) map[string]interface{} {
+		t.Helper()
+		body := `{"authors": ["Tolkien, J.R.R.", "JRR Tolkien"], "into": "J. R. R. Tolkien"}`
+		w := httptest.NewRecorder()
+		h.MergeAuthors(w, httptest.NewRequest("POST", "/api/authors/merge"+query, strings.NewReader(body)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+		var response map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response
+	}
+
+	preview := merge("?dry_run=true")
+	if preview["matched"] != 2.0 || preview["updated"] != 0.0 {
+		t.Errorf("dry run matched %v and updated %v, want 2 and 0", preview["matched"], preview["updated"])
+	}
+	if books, _ := h.db.GetBooksByAuthor("J. R. R. Tolkien"); len(books) != 0 {
+		t.Errorf("dry run changed %d books", len(books))
+	}
+
+	result := merge("")
+	if result["updated"] != 2.0 {
+		t.Errorf("updated %v books, want 2", result["updated"])
+	}
+	books, err := h.db.GetBooksByAuthor("J. R. R. Tolkien")
+	if err != nil || len(books) != 2 {
+		t.Fatalf("GetBooksByAuthor = %d books, %v; want 2", len(books), err)
+	}
+	silmarillion, _ := h.db.GetBookByID(2)
+	if silmarillion.Author != "J. R. R. Tolkien, Christopher Tolkien" {
+		t.Errorf("author = %q, want the merged name in the first place", silmarillion.Author)
+	}
+	if history, _ := h.db.GetBookByID(3); history.Author != "Christopher Tolkien" {
+		t.Errorf("unrelated book's author changed to %q", history.Author)
+	}
+}
+
+func TestMergeAuthorNames(t *testing.T) {
+	merged, changed := mergeAuthorNames([]string{"JRR Tolkien", "J.R.R. Tolkien", "Christopher Tolkien"}, []string{"jrr tolkien", "J.R.R. Tolkien"}, "J. R. R. Tolkien")
+	if !changed || strings.Join(merged, "|") != "J. R. R. Tolkien|Christopher Tolkien" {
+		t.Errorf("merged = %q, %v", merged, changed)
+	}
+	if _, changed := mergeAuthorNames([]string{"J. R. R. Tolkien"}, []string{"J. R. R. Tolkien"}, "J. R. R. Tolkien"); changed {
+		t.Error("merging a name into itself reported a change")
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"fableflow/backend/models"
+)
+
+// GetMetadataDiff compares a book's stored and embedded metadata with the best external
+// match (GET /api/books/{id}/metadata-diff). Books with an ISBN are looked up on Google
+// Books; otherwise, or when that fails, the top Open Library search result is used.
+func (h *BooksHandler) GetMetadataDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// URL format: /api/books/{id}/metadata-diff
+	bookID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+
+	book, err := h.db.GetBookByID(bookID)
+	if err != nil {
+		writeBookNotFound(w, r, bookID)
+		return
+	}
+
+	diff := models.MetadataDiff{BookID: book.ID}
+	var external models.MetadataEdit
+	found := false
+
+	if book.ISBN != "" {
+		if result, err := h.lookupGoogleBooks(book.ISBN); err != nil {
+			log.Printf("ISBN lookup for book %d failed, falling back to search: %v", book.ID, err)
+		} else {
+			external = models.MetadataEdit{
+				Title:         fmt.Sprint(result["title"]),
+				Author:        fmt.Sprint(result["author"]),
+				ISBN:          fmt.Sprint(result["isbn"]),
+				Publisher:     fmt.Sprint(result["publisher"]),
+				PublishedDate: fmt.Sprint(result["published_date"]),
+			}
+			diff.Source = "Google Books"
+			diff.MatchedBy = "isbn"
+			found = true
+		}
+	}
+
+	if !found {
+		suggestions, _, err := h.searchOpenLibrary(book.Title, book.Author)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Failed to search metadata: %v", err), http.StatusBadGateway)
+			return
+		}
+		if len(suggestions) == 0 {
+			http.Error(w, "No external match found for this book", http.StatusNotFound)
+			return
+		}
+		best := suggestions[0]
+		external = models.MetadataEdit{
+			Title:     best.Title,
+			Author:    best.Author,
+			ISBN:      best.ISBN,
+			Publisher: best.Publisher,
+		}
+		if best.Year > 0 {
+			external.PublishedDate = strconv.Itoa(best.Year)
+		}
+		diff.Source = best.Source
+		diff.MatchedBy = "search"
+		diff.Confidence = best.Confidence
+	}
+
+	stored := models.MetadataEdit{
+		Title:         book.Title,
+		Author:        book.Author,
+		ISBN:          book.ISBN,
+		Publisher:     book.Publisher,
+		PublishedDate: book.PublishedDate,
+	}
+
+	// The file can disagree with the database when it was changed outside the library
+	var embedded models.MetadataEdit
+	if fileMetadata, err := h.db.Extractor().ExtractMetadata(book.FilePath); err == nil {
+		embedded = models.MetadataEdit{
+			Title:         fileMetadata.Title,
+			Author:        fileMetadata.Author,
+			ISBN:          fileMetadata.ISBN,
+			Publisher:     fileMetadata.Publisher,
+			PublishedDate: fileMetadata.Date,
+		}
+	}
+
+	diff.Suggested = stored
+	fields := []struct {
+		name                       string
+		stored, embedded, external string
+		suggested                  *string
+		match                      func(a, b string) bool
+	}{
+		{"title", stored.Title, embedded.Title, external.Title, &diff.Suggested.Title, textMatches},
+		{"author", stored.Author, embedded.Author, external.Author, &diff.Suggested.Author, textMatches},
+		{"isbn", stored.ISBN, embedded.ISBN, external.ISBN, &diff.Suggested.ISBN, isbnMatches},
+		{"publisher", stored.Publisher, embedded.Publisher, external.Publisher, &diff.Suggested.Publisher, textMatches},
+		{"published_date", stored.PublishedDate, embedded.PublishedDate, external.PublishedDate, &diff.Suggested.PublishedDate, dateMatches},
+	}
+	for _, field := range fields {
+		fieldDiff := models.MetadataFieldDiff{
+			Field:    field.name,
+			Stored:   field.stored,
+			External: field.external,
+			Match:    field.external == "" || field.match(field.stored, field.external),
+		}
+		if field.embedded != field.stored {
+			fieldDiff.Embedded = field.embedded
+		}
+		if !fieldDiff.Match {
+			diff.Mismatches++
+			*field.suggested = field.external
+		}
+		diff.Fields = append(diff.Fields, fieldDiff)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, diff)
+}
+
+// textMatches compares metadata text ignoring case, punctuation and spacing
+func textMatches(a, b string) bool {
+	return comparableText(a) == comparableText(b)
+}
+
+func comparableText(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+// isbnMatches compares ISBNs ignoring hyphens and spaces
+func isbnMatches(a, b string) bool {
+	clean := strings.NewReplacer("-", "", " ", "")
+	return strings.EqualFold(clean.Replace(a), clean.Replace(b))
+}
+
+// dateMatches treats dates as matching when the less precise one is a prefix of the
+// other, so "1818" matches "1818-01-01"
+func dateMatches(a, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == "" || b == "" {
+		return a == b
+	}
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
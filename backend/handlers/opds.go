@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"fableflow/backend/config"
+	"fableflow/backend/database"
+	"fableflow/backend/models"
+)
+
+// OPDS link and media types (OPDS Catalog 1.2)
+const (
+	opdsContentType     = "application/atom+xml;profile=opds-catalog"
+	opdsNavigationType  = "application/atom+xml;profile=opds-catalog;kind=navigation"
+	opdsAcquisitionType = "application/atom+xml;profile=opds-catalog;kind=acquisition"
+	opdsRelAcquisition  = "http://opds-spec.org/acquisition"
+	opdsRelImage        = "http://opds-spec.org/image"
+	opdsRelThumbnail    = "http://opds-spec.org/image/thumbnail"
+)
+
+// OPDSHandler serves the library as an OPDS catalog for reading apps such as KOReader
+// and Thorium
+type OPDSHandler struct {
+	db     *database.Manager
+	recent int
+}
+
+// NewOPDSHandler creates a new OPDS catalog handler
+func NewOPDSHandler(db *database.Manager, cfg *config.Config) *OPDSHandler {
+	return &OPDSHandler{db: db, recent: cfg.Feed.Recent}
+}
+
+// GetRoot returns the navigation feed the catalog starts from (GET /opds/root)
+func (h *OPDSHandler) GetRoot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	base := requestBaseURL(r)
+	now := time.Now().UTC().Format(time.RFC3339)
+	feed := h.newFeed(base, "urn:fableflow:opds:root", "FableFlow", "/opds/root", opdsNavigationType)
+	feed.Updated = now
+	sections := []struct{ id, title, summary, path, kind string }{
+		{"by-author", "By Author", "Books grouped by author", "/opds/authors", opdsNavigationType},
+		{"by-title", "By Title", "All books sorted by title", "/opds", opdsAcquisitionType},
+		{"recent", "Recent", "Recently added books", "/opds/recent", opdsAcquisitionType},
+	}
+	for _, section := range sections {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      "urn:fableflow:opds:" + section.id,
+			Title:   section.title,
+			Updated: now,
+			Summary: section.summary,
+			Links:   []atomLink{{Rel: "subsection", Href: base + section.path, Type: section.kind}},
+		})
+	}
+
+	h.writeFeed(w, feed)
+}
+
+// GetBooks returns every book sorted by title as an acquisition feed, a page at a time
+// (GET /opds?offset=)
+func (h *OPDSHandler) GetBooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	offset, ok := opdsOffset(w, r)
+	if !ok {
+		return
+	}
+
+	books, total, err := h.db.GetBooksPaginated(defaultBooksPageLimit, offset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	base := requestBaseURL(r)
+	feed := h.newFeed(base, "urn:fableflow:opds:by-title", "All books", "/opds", opdsAcquisitionType)
+	if offset > 0 {
+		feed.Links[0].Href = fmt.Sprintf("%s/opds?offset=%d", base, offset)
+	}
+	if offset+len(books) < total {
+		feed.Links = append(feed.Links, atomLink{Rel: "next", Href: fmt.Sprintf("%s/opds?offset=%d", base, offset+len(books)), Type: opdsAcquisitionType})
+	}
+	if offset > 0 {
+		previous := offset - defaultBooksPageLimit
+		if previous < 0 {
+			previous = 0
+		}
+		feed.Links = append(feed.Links, atomLink{Rel: "previous", Href: fmt.Sprintf("%s/opds?offset=%d", base, previous), Type: opdsAcquisitionType})
+	}
+	h.addBookEntries(&feed, base, books)
+
+	h.writeFeed(w, feed)
+}
+
+// GetRecent returns the feed.recent most recently added books as an acquisition feed
+// (GET /opds/recent)
+func (h *OPDSHandler) GetRecent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	books, err := h.db.GetRecentBooks(h.recent)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	base := requestBaseURL(r)
+	feed := h.newFeed(base, "urn:fableflow:opds:recent", "Recently added", "/opds/recent", opdsAcquisitionType)
+	h.addBookEntries(&feed, base, books)
+
+	h.writeFeed(w, feed)
+}
+
+// GetAuthors returns a navigation feed with an entry per author, a page at a time
+// (GET /opds/authors?offset=)
+func (h *OPDSHandler) GetAuthors(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	offset, ok := opdsOffset(w, r)
+	if !ok {
+		return
+	}
+
+	authors, err := h.db.GetAuthorsWithCounts()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	base := requestBaseURL(r)
+	feed := h.newFeed(base, "urn:fableflow:opds:by-author", "By Author", "/opds/authors", opdsNavigationType)
+	feed.Updated = time.Now().UTC().Format(time.RFC3339)
+	if offset > 0 {
+		feed.Links[0].Href = fmt.Sprintf("%s/opds/authors?offset=%d", base, offset)
+	}
+	end := offset + defaultBooksPageLimit
+	if end < len(authors) {
+		feed.Links = append(feed.Links, atomLink{Rel: "next", Href: fmt.Sprintf("%s/opds/authors?offset=%d", base, end), Type: opdsNavigationType})
+	} else {
+		end = len(authors)
+	}
+	if offset > 0 {
+		previous := offset - defaultBooksPageLimit
+		if previous < 0 {
+			previous = 0
+		}
+		feed.Links = append(feed.Links, atomLink{Rel: "previous", Href: fmt.Sprintf("%s/opds/authors?offset=%d", base, previous), Type: opdsNavigationType})
+	}
+
+	for i := offset; i < end; i++ {
+		author := authors[i]
+		summary := fmt.Sprintf("%d books", author.Count)
+		if author.Count == 1 {
+			summary = "1 book"
+		}
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      "urn:fableflow:opds:author:" + url.PathEscape(author.Author),
+			Title:   author.Author,
+			Updated: feed.Updated,
+			Summary: summary,
+			Links: []atomLink{{
+				Rel:  "subsection",
+				Href: base + "/opds/authors/books?author=" + url.QueryEscape(author.Author),
+				Type: opdsAcquisitionType,
+			}},
+		})
+	}
+
+	h.writeFeed(w, feed)
+}
+
+// GetAuthorBooks returns the books of one author as an acquisition feed
+// (GET /opds/authors/books?author=)
+func (h *OPDSHandler) GetAuthorBooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	author := r.URL.Query().Get("author")
+	if author == "" {
+		http.Error(w, "Author parameter is required", http.StatusBadRequest)
+		return
+	}
+
+	books, err := h.db.GetBooksByAuthor(author)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	base := requestBaseURL(r)
+	feed := h.newFeed(base, "urn:fableflow:opds:author:"+url.PathEscape(author), author,
+		"/opds/authors/books?author="+url.QueryEscape(author), opdsAcquisitionType)
+	feed.Links = append(feed.Links, atomLink{Rel: "up", Href: base + "/opds/authors", Type: opdsNavigationType})
+	h.addBookEntries(&feed, base, books)
+
+	h.writeFeed(w, feed)
+}
+
+// newFeed returns a feed with self and start links; path is relative to the server
+func (h *OPDSHandler) newFeed(base, id, title, path, kind string) atomFeed {
+	return atomFeed{
+		ID:      id,
+		Title:   title,
+		Updated: time.Now().UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Href: base + path, Type: kind},
+			{Rel: "start", Href: base + "/opds/root", Type: opdsNavigationType},
+		},
+		Author: atomAuthor{Name: "FableFlow"},
+	}
+}
+
+// addBookEntries adds an acquisition entry per book, with its download and cover links.
+// The feed is as recent as its most recently added or edited entry.
+func (h *OPDSHandler) addBookEntries(feed *atomFeed, base string, books []models.Book) {
+	var updated time.Time
+	shortenDescriptions(books)
+	for _, book := range books {
+		entryUpdated := book.AddedAt
+		if book.UpdatedAt.After(entryUpdated) {
+			entryUpdated = book.UpdatedAt
+		}
+		if entryUpdated.After(updated) {
+			updated = entryUpdated
+		}
+
+		download := fmt.Sprintf("%s/api/download/%d", base, book.ID)
+		if book.Format == "epub" {
+			download += ".epub"
+		}
+		entry := atomEntry{
+			ID:        fmt.Sprintf("urn:fableflow:book:%d", book.ID),
+			Title:     book.Title,
+			Published: book.AddedAt.UTC().Format(time.RFC3339),
+			Updated:   entryUpdated.UTC().Format(time.RFC3339),
+			Summary:   book.Description,
+			Links: []atomLink{
+				{Rel: opdsRelAcquisition, Href: download, Type: bookContentType(book.Format), Length: book.FileSize},
+				{Rel: opdsRelImage, Href: fmt.Sprintf("%s/api/covers/%d", base, book.ID), Type: "image/jpeg"},
+				{Rel: opdsRelThumbnail, Href: fmt.Sprintf("%s/api/covers/%d?size=thumbnail", base, book.ID), Type: "image/jpeg"},
+			},
+		}
+		entry.Authors = atomAuthors(book)
+		feed.Entries = append(feed.Entries, entry)
+	}
+	if !updated.IsZero() {
+		feed.Updated = updated.UTC().Format(time.RFC3339)
+	}
+}
+
+// writeFeed writes a catalog feed document
+func (h *OPDSHandler) writeFeed(w http.ResponseWriter, feed atomFeed) {
+	w.Header().Set("Content-Type", opdsContentType)
+	w.Write([]byte(xml.Header))
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		log.Printf("Failed to write OPDS feed %s: %v", feed.ID, err)
+	}
+}
+
+// opdsOffset reads the offset of a paged feed, responding with 400 when it is invalid
+func opdsOffset(w http.ResponseWriter, r *http.Request) (int, bool) {
+	offsetStr := r.URL.Query().Get("offset")
+	if offsetStr == "" {
+		return 0, true
+	}
+	offset, err := strconv.Atoi(offsetStr)
+	if err != nil || offset < 0 {
+		http.Error(w, "Invalid offset, must be zero or more", http.StatusBadRequest)
+		return 0, false
+	}
+	return offset, true
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"fableflow/backend/conversion"
+)
+
+// Page preview layout, in pixels. Text uses the monospaced preview font.
+const (
+	previewWidth      = 600
+	previewHeight     = 800
+	previewMargin     = 44
+	previewLineHeight = 22
+	previewParaGap    = 8
+
+	// Spine documents with less text than this (title pages, dedications) are skipped
+	previewMinChars = 600
+	// Enough text to fill the page
+	previewMaxChars = 3000
+)
+
+var (
+	previewPaper = color.RGBA{R: 0xfb, G: 0xf8, B: 0xf1, A: 0xff}
+	previewInk   = color.RGBA{R: 0x2b, G: 0x2b, B: 0x2b, A: 0xff}
+	previewMuted = color.RGBA{R: 0x8a, G: 0x84, B: 0x78, A: 0xff}
+)
+
+// ServePreview renders the first page of a book's first substantial chapter as a JPEG
+// (GET /api/books/{id}/preview). Previews are cached like cover thumbnails.
+func (h *CoversHandler) ServePreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// URL format: /api/books/{id}/preview
+	bookID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+
+	book, err := h.db.GetBookByID(bookID)
+	if err != nil {
+		writeBookNotFound(w, r, bookID)
+		return
+	}
+	if book.Format != "epub" {
+		http.Error(w, "Previews are only available for EPUB files", http.StatusBadRequest)
+		return
+	}
+
+	info, err := os.Stat(book.FilePath)
+	if err != nil {
+		http.Error(w, "Book file not found", http.StatusNotFound)
+		return
+	}
+
+	cacheKey := fmt.Sprintf("preview_%d_%d.jpg", book.ID, info.ModTime().Unix())
+	if data, ok := h.cache.Get(cacheKey); ok {
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write(data)
+		return
+	}
+
+	paragraphs, err := conversion.NewEPUBParser().PreviewParagraphs(book.FilePath, previewMinChars, previewMaxChars)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("No preview available: %v", err), http.StatusNotFound)
+		return
+	}
+
+	// Scripts the preview font lacks, such as CJK, would give a page of '?', which is no preview
+	total, unknown := 0, 0
+	for i, paragraph := range paragraphs {
+		text, missing := toGlyphText(paragraph)
+		paragraphs[i] = text
+		total += utf8.RuneCountInString(text)
+		unknown += missing
+	}
+	if total == 0 || unknown*5 > total {
+		http.Error(w, "No preview available: the book's script is not supported", http.StatusUnprocessableEntity)
+		return
+	}
+
+	header, _ := toGlyphText(book.Title)
+	page, err := renderPreviewPage(header, paragraphs)
+	if err != nil {
+		http.Error(w, "Failed to render preview", http.StatusInternalServerError)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, page, &jpeg.Options{Quality: h.jpegQuality}); err != nil {
+		http.Error(w, "Failed to encode preview", http.StatusInternalServerError)
+		return
+	}
+	if err := h.cache.Put(cacheKey, buf.Bytes()); err != nil {
+		log.Printf("Failed to cache preview for book %d: %v", book.ID, err)
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Write(buf.Bytes())
+}
+
+// renderPreviewPage lays out a running header and word-wrapped paragraphs on a page,
+// ending with an ellipsis when the text doesn't fit
+func renderPreviewPage(header string, paragraphs []string) (*image.RGBA, error) {
+	face, err := newGlyphFace()
+	if err != nil {
+		return nil, err
+	}
+	defer face.Close()
+
+	page := image.NewRGBA(image.Rect(0, 0, previewWidth, previewHeight))
+	draw.Draw(page, page.Bounds(), &image.Uniform{C: previewPaper}, image.Point{}, draw.Src)
+
+	charWidth := face.advance
+	lineChars := (previewWidth - 2*previewMargin) / charWidth
+
+	// Running header: the title, centered, above a thin rule
+	if utf8.RuneCountInString(header) > lineChars {
+		header = string([]rune(header)[:lineChars-3]) + "..."
+	}
+	headerX := (previewWidth - utf8.RuneCountInString(header)*charWidth) / 2
+	face.drawText(page, headerX, previewMargin, header, previewMuted)
+	ruleY := previewMargin + previewLineHeight
+	draw.Draw(page, image.Rect(previewMargin, ruleY, previewWidth-previewMargin, ruleY+1), &image.Uniform{C: previewMuted}, image.Point{}, draw.Src)
+
+	y := ruleY + previewLineHeight
+	bottom := previewHeight - previewMargin - face.height
+	for _, paragraph := range paragraphs {
+		lines := wrapText(paragraph, lineChars)
+		for i, line := range lines {
+			if y+previewLineHeight > bottom {
+				// Out of room: mark the cut with an ellipsis
+				face.drawText(page, previewMargin, y, "...", previewInk)
+				return page, nil
+			}
+			x := previewMargin
+			if i == 0 {
+				x += 2 * charWidth // First-line indent
+			}
+			face.drawText(page, x, y, line, previewInk)
+			y += previewLineHeight
+		}
+		y += previewParaGap
+	}
+
+	return page, nil
+}
+
+// wrapText breaks text into lines of at most width characters at spaces, splitting
+// words that are longer than a line. The first line leaves room for a two-character indent.
+func wrapText(text string, width int) []string {
+	var lines []string
+	var line []rune
+	limit := width - 2
+	for _, field := range strings.Fields(text) {
+		word := []rune(field)
+		for len(word) > limit {
+			if len(line) > 0 {
+				lines = append(lines, string(line))
+				line = nil
+				limit = width
+			}
+			lines = append(lines, string(word[:limit]))
+			word = word[limit:]
+			limit = width
+		}
+		switch {
+		case len(line) == 0:
+			line = word
+		case len(line)+1+len(word) <= limit:
+			line = append(append(line, ' '), word...)
+		default:
+			lines = append(lines, string(line))
+			line = word
+			limit = width
+		}
+	}
+	if len(line) > 0 {
+		lines = append(lines, string(line))
+	}
+	return lines
+}
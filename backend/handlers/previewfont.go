@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/text/unicode/norm"
+)
+
+// previewFont is Go Mono. All its glyphs advance by the same width, so preview text
+// is laid out by counting characters.
+var previewFont = mustParseFont(gomono.TTF)
+
+// previewFontSize is the size of preview text, in pixels
+const previewFontSize = 16
+
+// mustParseFont parses an embedded font, which cannot fail
+func mustParseFont(data []byte) *opentype.Font {
+	f, err := opentype.Parse(data)
+	if err != nil {
+		panic(fmt.Sprintf("parsing embedded font: %v", err))
+	}
+	return f
+}
+
+// glyphFace draws preview text. A face cannot be shared between goroutines, so each
+// rendered page gets its own.
+type glyphFace struct {
+	face    font.Face
+	advance int // Width of every character
+	ascent  int // From the top of a line to its baseline
+	height  int // Of a line of text
+}
+
+// newGlyphFace returns the preview font at previewFontSize
+func newGlyphFace() (*glyphFace, error) {
+	face, err := opentype.NewFace(previewFont, &opentype.FaceOptions{Size: previewFontSize, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		return nil, err
+	}
+	advance, _ := face.GlyphAdvance('M')
+	metrics := face.Metrics()
+	return &glyphFace{
+		face:    face,
+		advance: advance.Ceil(),
+		ascent:  metrics.Ascent.Ceil(),
+		height:  metrics.Height.Ceil(),
+	}, nil
+}
+
+// Close releases the face
+func (g *glyphFace) Close() error {
+	return g.face.Close()
+}
+
+// glyphFallbacks spell out punctuation and ligatures in ASCII when the font lacks them
+var glyphFallbacks = map[rune]string{
+	'‘': "'", '’': "'", '“': "\"", '”': "\"",
+	'–': "-", '—': "--", '…': "...",
+	'«': "\"", '»': "\"", 'Æ': "AE", 'æ': "ae",
+	'Œ': "OE", 'œ': "oe", 'ß': "ss",
+}
+
+// hasGlyph reports whether the preview font can draw r
+func hasGlyph(r rune) bool {
+	index, err := previewFont.GlyphIndex(nil, r)
+	return err == nil && index != 0
+}
+
+// toGlyphText prepares text for a preview line, where every character takes up one
+// column. Control and formatting characters are dropped and whitespace becomes spaces.
+// Characters the font lacks are spelled out in ASCII or lose their accents; it returns
+// how many of them had to be replaced with '?'.
+func toGlyphText(text string) (string, int) {
+	text = norm.NFC.String(text)
+
+	var b strings.Builder
+	unknown := 0
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			b.WriteByte(' ')
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r), unicode.Is(unicode.Mn, r):
+			// Invisible, or a combining accent without a precomposed form, which
+			// would take up a column of its own
+		case hasGlyph(r):
+			b.WriteRune(r)
+		case glyphFallbacks[r] != "":
+			b.WriteString(glyphFallbacks[r])
+		default:
+			if base, _ := utf8.DecodeRuneInString(norm.NFD.String(string(r))); base != r && hasGlyph(base) {
+				b.WriteRune(base)
+				continue
+			}
+			b.WriteByte('?')
+			unknown++
+		}
+	}
+	return b.String(), unknown
+}
+
+// drawText draws text with the top-left corner of its line at (x, y), every
+// character advancing by the same whole number of pixels
+func (g *glyphFace) drawText(img *image.RGBA, x, y int, text string, c color.Color) {
+	d := font.Drawer{Dst: img, Src: image.NewUniform(c), Face: g.face}
+	for i, r := range []rune(text) {
+		d.Dot = fixed.P(x+i*g.advance, y+g.ascent)
+		d.DrawString(string(r))
+	}
+}
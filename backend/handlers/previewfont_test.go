@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToGlyphText(t *testing.T) {
+	tests := []struct {
+		name, text, want string
+		unknown          int
+	}{
+		{"ascii", "It was a dark night.", "It was a dark night.", 0},
+		{"accents", "Café naïve, señor", "Café naïve, señor", 0},
+		{"decomposed accents", "Cafe\u0301", "Café", 0},
+		{"cyrillic", "Война и мир", "Война и мир", 0},
+		{"greek", "Οδύσσεια", "Οδύσσεια", 0},
+		{"accents the font lacks", "Ὀδύσσεια", "Οδύσσεια", 0},
+		{"typography", "“Yes”—she said…", "“Yes”—she said…", 0},
+		{"whitespace", "one\ttwo three\nfour", "one two three four", 0},
+		{"control and format", "zero\u200bwidth\x07 bell\u00ad", "zerowidth bell", 0},
+		{"missing glyphs", "東京 Tokyo", "?? Tokyo", 2},
+	}
+	for _, tt := range tests {
+		got, unknown := toGlyphText(tt.text)
+		if got != tt.want || unknown != tt.unknown {
+			t.Errorf("%s: toGlyphText(%q) = %q, %d; want %q, %d", tt.name, tt.text, got, unknown, tt.want, tt.unknown)
+		}
+	}
+}
+
+func TestWrapTextCountsCharacters(t *testing.T) {
+	// Lines are measured in characters, not bytes
+	got := wrapText("Война и мир Толстого", 10)
+	want := []string{"Война и", "мир", "Толстого"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wrapText = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"fableflow/backend/conversion"
+	"fableflow/backend/models"
+)
+
+// maxLocatorLength bounds the reading position a client may store for a book
+const maxLocatorLength = 2048
+
+// spineLocatorPattern matches a locator given as a spine index and a character offset
+// into that spine document, e.g. "spine:3:1200"
+var spineLocatorPattern = regexp.MustCompile(`^spine:(\d+):(\d+)$`)
+
+// saveProgressRequest is the body of POST /api/books/{id}/progress
+type saveProgressRequest struct {
+	Locator string  `json:"locator"`
+	Percent float64 `json:"percent"`
+}
+
+// HandleReadingProgress saves and restores where a book was last read
+// (/api/books/{id}/progress): POST stores a JSON object with a locator (an EPUB CFI)
+// and a percent, GET returns the stored position or 404 when the book was never
+// opened. For a spine locator ("spine:{index}:{offset}") in an EPUB the percent is
+// computed from the character counts of the spine documents, so a multi-file book
+// reports how much of the whole was read.
+func (h *BooksHandler) HandleReadingProgress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// URL format: /api/books/{id}/progress
+	bookID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+
+	book, err := h.db.GetBookByID(bookID)
+	if err != nil {
+		writeBookNotFound(w, r, bookID)
+		return
+	}
+
+	if r.Method == "POST" {
+		var req saveProgressRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		req.Locator = strings.TrimSpace(req.Locator)
+		if req.Locator == "" || len(req.Locator) > maxLocatorLength {
+			http.Error(w, fmt.Sprintf("locator must be between 1 and %d characters", maxLocatorLength), http.StatusBadRequest)
+			return
+		}
+		if math.IsNaN(req.Percent) || req.Percent < 0 || req.Percent > 100 {
+			http.Error(w, "percent must be between 0 and 100", http.StatusBadRequest)
+			return
+		}
+		if percent, ok := h.spinePercent(book, req.Locator); ok {
+			req.Percent = percent
+		}
+		if err := h.db.SaveProgress(bookID, req.Locator, req.Percent); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	progress, err := h.db.GetProgress(bookID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if progress == nil {
+		http.Error(w, fmt.Sprintf("No reading progress saved for book %d", bookID), http.StatusNotFound)
+		return
+	}
+	// Recomputed on each read, since the file may have changed since it was saved
+	if percent, ok := h.spinePercent(book, progress.Locator); ok {
+		progress.Percent = percent
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, progress)
+}
+
+// spinePercent returns the overall percent read at a spine locator of an EPUB, and
+// false for other locators or when the book cannot be read
+func (h *BooksHandler) spinePercent(book models.Book, locator string) (float64, bool) {
+	match := spineLocatorPattern.FindStringSubmatch(locator)
+	if match == nil || book.Format != "epub" {
+		return 0, false
+	}
+	spineIndex, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, false
+	}
+	offset, err := strconv.Atoi(match[2])
+	if err != nil {
+		return 0, false
+	}
+	counts, err := h.getSpineCounts(book)
+	if err != nil {
+		return 0, false
+	}
+	return conversion.ProgressPercent(counts, spineIndex, offset), true
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fableflow/backend/config"
+	"fableflow/backend/conversion"
+	"fableflow/backend/models"
+)
+
+// sampleEPUB is a multi-file EPUB of Frankenstein
+var sampleEPUB = filepath.Join("..", "..", "data", "import", "pg84-images-3.epub")
+
+// saveProgress posts a reading position for a book and returns the stored progress
+func saveProgress(t *testing.T, h *BooksHandler, bookID int, body string) models.ReadingProgress {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", fmt.Sprintf("/api/books/%d/progress", bookID), strings.NewReader(body))
+	r.SetPathValue("id", fmt.Sprint(bookID))
+	h.HandleReadingProgress(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST %s: status %d: %s", body, w.Code, w.Body)
+	}
+	var progress models.ReadingProgress
+	if err := json.Unmarshal(w.Body.Bytes(), &progress); err != nil {
+		t.Fatal(err)
+	}
+	return progress
+}
+
+func TestReadingProgressSpineLocator(t *testing.T) {
+	h := newTestBooksHandler(t, &config.Config{})
+	if err := h.db.AddBook(testBook(sampleEPUB)); err != nil {
+		t.Fatalf("AddBook: %v", err)
+	}
+	counts, err := conversion.NewEPUBParser().SpineCharCounts(sampleEPUB)
+	if err != nil || len(counts) < 3 {
+		t.Fatalf("SpineCharCounts = %v, %v", counts, err)
+	}
+	total, before := 0, 0
+	for i, count := range counts {
+		if i < 2 {
+			before += count
+		}
+		total += count
+	}
+
+	tests := []struct {
+		body string
+		want float64
+	}{
+		{`{"locator": "spine:0:0", "percent": 50}`, 0},
+		{fmt.Sprintf(`{"locator": "spine:2:%d"}`, counts[2]/2), float64(before+counts[2]/2) / float64(total) * 100},
+		{fmt.Sprintf(`{"locator": "spine:%d:0"}`, len(counts)), 100},
+		// Other locators keep the percent the reader sent
+		{`{"locator": "epubcfi(/6/4!/4/2/1:0)", "percent": 12.5}`, 12.5},
+	}
+	for _, tt := range tests {
+		if progress := saveProgress(t, h, 1, tt.body); math.Abs(progress.Percent-tt.want) > 0.001 {
+			t.Errorf("POST %s: percent %v, want %v", tt.body, progress.Percent, tt.want)
+		}
+	}
+
+	// GET computes the percent of a spine locator too
+	saveProgress(t, h, 1, `{"locator": "spine:1:0"}`)
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/api/books/1/progress", nil)
+	r.SetPathValue("id", "1")
+	h.HandleReadingProgress(w, r)
+	var progress models.ReadingProgress
+	if err := json.Unmarshal(w.Body.Bytes(), &progress); err != nil {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if want := float64(counts[0]) / float64(total) * 100; math.Abs(progress.Percent-want) > 0.001 {
+		t.Errorf("GET percent %v, want %v", progress.Percent, want)
+	}
+}
+
+func TestHomeContinueReading(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Home.Sections = []string{"continue_reading"}
+	cfg.Home.ContinueReading = 6
+	h := newTestBooksHandler(t, cfg)
+	for _, path := range []string{"/library/unread.epub", "/library/reading.epub", "/library/finished.epub"} {
+		if err := h.db.AddBook(testBook(path)); err != nil {
+			t.Fatalf("AddBook: %v", err)
+		}
+	}
+	saveProgress(t, h, 2, `{"locator": "epubcfi(/6/8!/4/2/1:0)", "percent": 40}`)
+	saveProgress(t, h, 3, `{"locator": "epubcfi(/6/30!/4/2/1:0)", "percent": 100}`)
+
+	w := httptest.NewRecorder()
+	h.GetHome(w, httptest.NewRequest("GET", "/api/home", nil))
+	var home struct {
+		Sections []homeSection `json:"sections"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &home); err != nil {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	if len(home.Sections) != 1 || len(home.Sections[0].Books) != 1 || home.Sections[0].Books[0].ID != 2 {
+		t.Errorf("sections = %+v, want only the book being read", home.Sections)
+	}
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"fableflow/backend/models"
+)
+
+// globalReaderSettings is the book ID the global reader settings are stored under
+const globalReaderSettings = 0
+
+// fontFamilyPattern allows font names and CSS font lists such as "Georgia, serif" while
+// keeping anything that could break out of the reader's stylesheet out
+var fontFamilyPattern = regexp.MustCompile(`^[A-Za-z0-9 ,'-]{1,100}$`)
+
+// defaultReaderSettings returns the settings used where neither the book nor the global
+// settings set a value
+func defaultReaderSettings() models.ReaderSettings {
+	fontSize, lineHeight, margin := 100, 1.5, 5
+	fontFamily, theme, textAlign, flow := "publisher", "light", "publisher", "paginated"
+	return models.ReaderSettings{
+		FontSize:   &fontSize,
+		FontFamily: &fontFamily,
+		LineHeight: &lineHeight,
+		Margin:     &margin,
+		Theme:      &theme,
+		TextAlign:  &textAlign,
+		Flow:       &flow,
+	}
+}
+
+// HandleReaderSettings reads and changes how the reader lays out one book
+// (/api/books/{id}/reader-settings): GET returns the effective settings and the ones the
+// book overrides, PUT replaces the overrides with a JSON object of settings, and DELETE
+// removes them so the book follows the global settings again.
+func (h *BooksHandler) HandleReaderSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "PUT" && r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// URL format: /api/books/{id}/reader-settings
+	bookID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || bookID <= 0 {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetBookByID(bookID); err != nil {
+		writeBookNotFound(w, r, bookID)
+		return
+	}
+
+	h.serveReaderSettings(w, r, bookID)
+}
+
+// HandleGlobalReaderSettings reads and changes the reader settings every book starts
+// from (/api/reader-settings), with the same methods as the per-book settings
+func (h *BooksHandler) HandleGlobalReaderSettings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "PUT" && r.Method != "DELETE" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	h.serveReaderSettings(w, r, globalReaderSettings)
+}
+
+// serveReaderSettings applies a PUT or DELETE to the settings stored for bookID, then
+// responds with the stored settings merged over the global ones and the defaults
+func (h *BooksHandler) serveReaderSettings(w http.ResponseWriter, r *http.Request, bookID int) {
+	switch r.Method {
+	case "PUT":
+		var settings models.ReaderSettings
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&settings); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid reader settings: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := validateReaderSettings(settings); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, err := json.Marshal(settings)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := h.db.SetReaderSettings(bookID, string(data)); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case "DELETE":
+		if err := h.db.DeleteReaderSettings(bookID); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	settings, updatedAt, err := h.loadReaderSettings(bookID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	effective := defaultReaderSettings()
+	if bookID != globalReaderSettings {
+		global, _, err := h.loadReaderSettings(globalReaderSettings)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		mergeReaderSettings(&effective, global)
+	}
+	mergeReaderSettings(&effective, settings)
+
+	response := map[string]interface{}{
+		"settings":  effective,
+		"overrides": settings,
+	}
+	if bookID != globalReaderSettings {
+		response["book_id"] = bookID
+	}
+	if updatedAt != nil {
+		response["updated_at"] = updatedAt
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, response)
+}
+
+// loadReaderSettings returns the settings stored for bookID, empty when there are none
+func (h *BooksHandler) loadReaderSettings(bookID int) (models.ReaderSettings, *time.Time, error) {
+	var settings models.ReaderSettings
+	data, updatedAt, err := h.db.GetReaderSettings(bookID)
+	if err != nil {
+		return settings, nil, err
+	}
+	if err := json.Unmarshal([]byte(data), &settings); err != nil {
+		return settings, nil, fmt.Errorf("stored reader settings of book %d are invalid: %v", bookID, err)
+	}
+	return settings, updatedAt, nil
+}
+
+// mergeReaderSettings copies the fields set in overrides onto settings
+func mergeReaderSettings(settings *models.ReaderSettings, overrides models.ReaderSettings) {
+	if overrides.FontSize != nil {
+		settings.FontSize = overrides.FontSize
+	}
+	if overrides.FontFamily != nil {
+		settings.FontFamily = overrides.FontFamily
+	}
+	if overrides.LineHeight != nil {
+		settings.LineHeight = overrides.LineHeight
+	}
+	if overrides.Margin != nil {
+		settings.Margin = overrides.Margin
+	}
+	if overrides.Theme != nil {
+		settings.Theme = overrides.Theme
+	}
+	if overrides.TextAlign != nil {
+		settings.TextAlign = overrides.TextAlign
+	}
+	if overrides.Flow != nil {
+		settings.Flow = overrides.Flow
+	}
+}
+
+// validateReaderSettings checks the ranges and allowed values of the fields that are set
+func validateReaderSettings(settings models.ReaderSettings) error {
+	if settings.FontSize != nil && (*settings.FontSize < 50 || *settings.FontSize > 300) {
+		return fmt.Errorf("font_size must be between 50 and 300 (percent)")
+	}
+	if settings.FontFamily != nil && !fontFamilyPattern.MatchString(*settings.FontFamily) {
+		return fmt.Errorf("font_family must be up to 100 letters, digits, spaces, commas, quotes or '-'")
+	}
+	if settings.LineHeight != nil && (*settings.LineHeight < 1 || *settings.LineHeight > 3) {
+		return fmt.Errorf("line_height must be between 1.0 and 3.0")
+	}
+	if settings.Margin != nil && (*settings.Margin < 0 || *settings.Margin > 25) {
+		return fmt.Errorf("margin must be between 0 and 25 (percent)")
+	}
+	if settings.Theme != nil && !oneOf(*settings.Theme, "light", "dark", "sepia") {
+		return fmt.Errorf("theme must be light, dark or sepia")
+	}
+	if settings.TextAlign != nil && !oneOf(*settings.TextAlign, "publisher", "left", "justify") {
+		return fmt.Errorf("text_align must be publisher, left or justify")
+	}
+	if settings.Flow != nil && !oneOf(*settings.Flow, "paginated", "scrolled") {
+		return fmt.Errorf("flow must be paginated or scrolled")
+	}
+	return nil
+}
+
+// oneOf reports whether value is one of allowed
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
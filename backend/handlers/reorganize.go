@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"fableflow/backend/jobs"
+	"fableflow/backend/models"
+)
+
+// ReorganizeRequest represents a request to move library files to their template paths
+type ReorganizeRequest struct {
+	DryRun  bool  `json:"dry_run"`
+	BookIDs []int `json:"book_ids,omitempty"` // Only move these books, e.g. those listed by /api/books/path-mismatches
+}
+
+// ReorganizeMove describes a book file that was (or in a dry run would be) moved
+type ReorganizeMove struct {
+	BookID int    `json:"book_id"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+}
+
+// ReorganizeStatus reports the progress of a library reorganization
+type ReorganizeStatus struct {
+	Status         string           `json:"status"`           // "idle", or the status of the reorganize job
+	JobID          string           `json:"job_id,omitempty"` // The job in /api/jobs, through which the run is cancelled
+	DryRun         bool             `json:"dry_run"`
+	StartTime      *time.Time       `json:"start_time,omitempty"`
+	EndTime        *time.Time       `json:"end_time,omitempty"`
+	TotalBooks     int              `json:"total_books"`
+	ProcessedBooks int              `json:"processed_books"`
+	UnchangedBooks int              `json:"unchanged_books"`
+	Moves          []ReorganizeMove `json:"moves"`
+	Conflicts      []ReorganizeMove `json:"conflicts"`
+	Errors         []string         `json:"errors"`
+}
+
+// ReorganizeLibrary moves every book file to the path library.path_template gives it.
+// POST starts a "reorganize" job (409 while one is queued or running); GET reports the
+// progress of the last one.
+func (h *BooksHandler) ReorganizeLibrary(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" {
+		h.reorganizeMutex.Lock()
+		status := h.reorganizeStatus
+		h.reorganizeMutex.Unlock()
+		if job, exists := h.jobs.Get(status.JobID); exists {
+			status.Status = job.Status
+		}
+		w.Header().Set("Content-Type", "application/json")
+		encodeJSON(w, r, status)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// The body is optional; an empty one starts a real run
+	var req ReorganizeRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+	}
+	if r.URL.Query().Get("dry_run") == "true" {
+		req.DryRun = true
+	}
+
+	h.reorganizeMutex.Lock()
+	if h.jobs.Active(reorganizeJobKind) > 0 {
+		h.reorganizeMutex.Unlock()
+		http.Error(w, "A reorganization is already running", http.StatusConflict)
+		return
+	}
+	description := "Move book files to their library paths"
+	if req.DryRun {
+		description = "Preview moving book files to their library paths"
+	}
+	job := h.jobs.StartWithResult(reorganizeJobKind, description, "/api/library/reorganize", func(ctx context.Context, progress *jobs.Progress) error {
+		return h.runReorganize(ctx, progress, req.DryRun, req.BookIDs)
+	})
+	startTime := job.CreatedAt
+	h.reorganizeStatus = ReorganizeStatus{
+		Status:    jobs.Queued,
+		JobID:     job.ID,
+		DryRun:    req.DryRun,
+		StartTime: &startTime,
+		Moves:     []ReorganizeMove{},
+		Conflicts: []ReorganizeMove{},
+		Errors:    []string{},
+	}
+	h.reorganizeMutex.Unlock()
+
+	response := map[string]interface{}{
+		"message": "Reorganization started",
+		"job_id":  job.ID,
+		"dry_run": req.DryRun,
+	}
+	if len(req.BookIDs) > 0 {
+		response["book_ids"] = req.BookIDs
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	encodeJSON(w, r, response)
+}
+
+// reorganizeJobKind is the kind of the jobs that reorganize the library
+const reorganizeJobKind = "reorganize"
+
+// runReorganize moves misplaced book files one at a time. Each move updates the database
+// immediately, so an interrupted or cancelled run can simply be started again: books
+// already at their target path are left alone. Targets that are occupied are reported as
+// conflicts and skipped. A non-empty bookIDs limits the run to those books.
+func (h *BooksHandler) runReorganize(ctx context.Context, progress *jobs.Progress, dryRun bool, bookIDs []int) (err error) {
+	defer func() { h.finishReorganize(err) }()
+
+	books, err := h.db.GetAllBooks()
+	if err != nil {
+		return fmt.Errorf("failed to load books: %v", err)
+	}
+	if len(bookIDs) > 0 {
+		selected := make(map[int]bool, len(bookIDs))
+		for _, id := range bookIDs {
+			selected[id] = true
+		}
+		var filtered []models.Book
+		for _, book := range books {
+			if selected[book.ID] {
+				filtered = append(filtered, book)
+			}
+		}
+		books = filtered
+	}
+
+	h.reorganizeMutex.Lock()
+	h.reorganizeStatus.Status = jobs.Running
+	h.reorganizeStatus.TotalBooks = len(books)
+	h.reorganizeMutex.Unlock()
+	progress.SetTotal(len(books))
+
+	// Targets claimed during this run, so two books never get the same path
+	claimed := make(map[string]int, len(books))
+
+	for _, book := range books {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		target := h.generateNewFilePath(book.Author, book.Title, book.Format)
+		move := ReorganizeMove{BookID: book.ID, From: book.FilePath, To: target}
+
+		var outcome string
+		if book.FilePath == target {
+			outcome = "unchanged"
+		} else if _, taken := claimed[target]; taken {
+			outcome = "conflict"
+		} else if _, err := os.Stat(book.FilePath); err != nil {
+			outcome = fmt.Sprintf("Book %d: file not found: %s", book.ID, book.FilePath)
+		} else if _, conflict := h.findPathConflict(target, book.FilePath); conflict {
+			outcome = "conflict"
+		} else if dryRun {
+			outcome = "moved"
+		} else if err := h.moveBookFile(book.FilePath, target); err != nil {
+			outcome = fmt.Sprintf("Book %d: %v", book.ID, err)
+		} else if err := h.db.UpdateFilePath(book.ID, target); err != nil {
+			// Put the file back so the database keeps pointing at it
+			if rollbackErr := os.Rename(target, book.FilePath); rollbackErr != nil {
+				log.Printf("Failed to move %s back after database error: %v", target, rollbackErr)
+			}
+			outcome = fmt.Sprintf("Book %d: %v", book.ID, err)
+		} else {
+			outcome = "moved"
+			log.Printf("Reorganized book %d: %s -> %s", book.ID, book.FilePath, target)
+		}
+		if outcome != "conflict" {
+			claimed[target] = book.ID
+		}
+
+		h.reorganizeMutex.Lock()
+		h.reorganizeStatus.ProcessedBooks++
+		switch outcome {
+		case "unchanged":
+			h.reorganizeStatus.UnchangedBooks++
+		case "moved":
+			h.reorganizeStatus.Moves = append(h.reorganizeStatus.Moves, move)
+		case "conflict":
+			h.reorganizeStatus.Conflicts = append(h.reorganizeStatus.Conflicts, move)
+		default:
+			h.reorganizeStatus.Errors = append(h.reorganizeStatus.Errors, outcome)
+		}
+		h.reorganizeMutex.Unlock()
+		progress.Step()
+	}
+	return nil
+}
+
+// finishReorganize records the end of a reorganization, and the error that ended it early
+func (h *BooksHandler) finishReorganize(err error) {
+	h.reorganizeMutex.Lock()
+	defer h.reorganizeMutex.Unlock()
+
+	endTime := time.Now()
+	h.reorganizeStatus.EndTime = &endTime
+	switch {
+	case err == nil:
+		h.reorganizeStatus.Status = jobs.Completed
+	case errors.Is(err, context.Canceled):
+		h.reorganizeStatus.Status = jobs.Cancelled
+	default:
+		h.reorganizeStatus.Status = jobs.Failed
+		log.Printf("Reorganization failed: %v", err)
+		h.reorganizeStatus.Errors = append(h.reorganizeStatus.Errors, err.Error())
+	}
+	log.Printf("Reorganization %s: %d moved, %d unchanged, %d conflicts, %d errors (dry run: %v)",
+		h.reorganizeStatus.Status, len(h.reorganizeStatus.Moves), h.reorganizeStatus.UnchangedBooks,
+		len(h.reorganizeStatus.Conflicts), len(h.reorganizeStatus.Errors), h.reorganizeStatus.DryRun)
+}
+
+// PathMismatch is a book whose file is not where library.path_template puts it, usually
+// because its metadata changed outside the application
+type PathMismatch struct {
+	BookID        int    `json:"book_id"`
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	FilePath      string `json:"file_path"`
+	SuggestedPath string `json:"suggested_path"`
+	Missing       bool   `json:"missing,omitempty"`             // The file is not on disk, so it cannot be moved
+	Conflict      bool   `json:"conflict,omitempty"`            // Something already occupies the suggested path
+	ConflictingID int    `json:"conflicting_book_id,omitempty"` // The book at the suggested path, when it is in the library
+}
+
+// GetPathMismatches lists the books whose file path differs from the one the path
+// template generates from their current metadata (GET /api/books/path-mismatches).
+// Without relocating them the next metadata edit moves such a file unexpectedly; POST
+// /api/library/reorganize with their book_ids moves them to the suggested paths.
+func (h *BooksHandler) GetPathMismatches(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	books, err := h.db.GetAllBooks()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	mismatches := []PathMismatch{}
+	bookIDs := []int{}
+	for _, book := range books {
+		target := h.generateNewFilePath(book.Author, book.Title, book.Format)
+		if book.FilePath == target {
+			continue
+		}
+
+		mismatch := PathMismatch{
+			BookID:        book.ID,
+			Title:         book.Title,
+			Author:        book.Author,
+			FilePath:      book.FilePath,
+			SuggestedPath: target,
+		}
+		if _, err := os.Stat(book.FilePath); err != nil {
+			mismatch.Missing = true
+		} else {
+			mismatch.ConflictingID, mismatch.Conflict = h.findPathConflict(target, book.FilePath)
+		}
+		mismatches = append(mismatches, mismatch)
+		bookIDs = append(bookIDs, book.ID)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, map[string]interface{}{
+		"total":      len(mismatches),
+		"book_ids":   bookIDs,
+		"mismatches": mismatches,
+	})
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fableflow/backend/config"
+	"fableflow/backend/jobs"
+)
+
+// startReorganize posts a reorganization and returns its job ID
+func startReorganize(t *testing.T, h *BooksHandler) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ReorganizeLibrary(w, httptest.NewRequest("POST", "/api/library/reorganize", nil))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("POST /api/library/reorganize: status %d: %s", w.Code, w.Body)
+	}
+	var response struct {
+		JobID string `json:"job_id"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response.JobID
+}
+
+// reorganizeStatus returns the status GET /api/library/reorganize reports
+func reorganizeStatus(t *testing.T, h *BooksHandler) ReorganizeStatus {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ReorganizeLibrary(w, httptest.NewRequest("GET", "/api/library/reorganize", nil))
+	var status ReorganizeStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("GET /api/library/reorganize: status %d: %s", w.Code, w.Body)
+	}
+	return status
+}
+
+func TestReorganizeRunsAsJob(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Library.ScanDirectory = t.TempDir()
+	h := newTestBooksHandler(t, cfg)
+	misplaced := filepath.Join(cfg.Library.ScanDirectory, "misplaced.epub")
+	if err := os.WriteFile(misplaced, []byte("book"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.db.AddBook(testBook(misplaced)); err != nil {
+		t.Fatalf("AddBook: %v", err)
+	}
+
+	jobID := startReorganize(t, h)
+	job, exists := h.jobs.Get(jobID)
+	if !exists || job.Kind != reorganizeJobKind {
+		t.Fatalf("job %q in /api/jobs = %+v, %v; want a reorganize job", jobID, job, exists)
+	}
+	if job, _ = h.jobs.Wait(jobID); job.Status != jobs.Completed || job.Total != 1 || job.Done != 1 {
+		t.Fatalf("job = %+v, want one of one books done", job)
+	}
+
+	status := reorganizeStatus(t, h)
+	target := h.generateNewFilePath("Author", "Title", "epub")
+	if status.Status != jobs.Completed || status.JobID != jobID || len(status.Moves) != 1 || status.Moves[0].To != target {
+		t.Errorf("status = %+v, want the book moved to %s", status, target)
+	}
+	if _, err := os.Stat(target); err != nil {
+		t.Errorf("book not moved: %v", err)
+	}
+}
+
+func TestCancelReorganize(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Library.ScanDirectory = t.TempDir()
+	h := newTestBooksHandler(t, cfg)
+
+	// Keep the only worker busy so the reorganization stays queued
+	running, release := make(chan struct{}), make(chan struct{})
+	blocker := h.jobs.Start("scan", "", func(ctx context.Context, progress *jobs.Progress) error {
+		close(running)
+		<-release
+		return nil
+	})
+	<-running
+	defer func() {
+		close(release)
+		h.jobs.Wait(blocker.ID)
+	}()
+
+	jobID := startReorganize(t, h)
+	if status := reorganizeStatus(t, h); status.Status != jobs.Queued {
+		t.Errorf("status = %q, want %q", status.Status, jobs.Queued)
+	}
+
+	// Only one reorganization is queued or running at a time
+	w := httptest.NewRecorder()
+	h.ReorganizeLibrary(w, httptest.NewRequest("POST", "/api/library/reorganize", nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("second POST: status %d, want %d", w.Code, http.StatusConflict)
+	}
+
+	if _, err := h.jobs.Cancel(jobID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if job, _ := h.jobs.Wait(jobID); job.Status != jobs.Cancelled {
+		t.Errorf("job status = %q, want %q", job.Status, jobs.Cancelled)
+	}
+	if status := reorganizeStatus(t, h); status.Status != jobs.Cancelled {
+		t.Errorf("status = %q, want %q", status.Status, jobs.Cancelled)
+	}
+}
+
+func TestRunReorganizeStopsWhenCancelled(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Library.ScanDirectory = t.TempDir()
+	h := newTestBooksHandler(t, cfg)
+	for _, name := range []string{"first.epub", "second.epub"} {
+		path := filepath.Join(cfg.Library.ScanDirectory, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		book := testBook(path)
+		book.Title = name
+		if err := h.db.AddBook(book); err != nil {
+			t.Fatalf("AddBook: %v", err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	job := h.jobs.Start(reorganizeJobKind, "", func(_ context.Context, progress *jobs.Progress) error {
+		return h.runReorganize(ctx, progress, false, nil)
+	})
+	h.jobs.Wait(job.ID)
+
+	h.reorganizeMutex.Lock()
+	status := h.reorganizeStatus
+	h.reorganizeMutex.Unlock()
+	if status.Status != jobs.Cancelled || status.ProcessedBooks != 0 {
+		t.Errorf("status = %+v, want cancelled before moving a book", status)
+	}
+	for _, name := range []string{"first.epub", "second.epub"} {
+		if _, err := os.Stat(filepath.Join(cfg.Library.ScanDirectory, name)); err != nil {
+			t.Errorf("%s moved after the run was cancelled: %v", name, err)
+		}
+	}
+}
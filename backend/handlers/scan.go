@@ -1,22 +1,33 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"path/filepath"
+	"strings"
 
 	"fableflow/backend/database"
+	"fableflow/backend/importservice"
+	"fableflow/backend/jobs"
 	"fableflow/backend/models"
 )
 
 // ScanHandler handles scan-related HTTP requests
 type ScanHandler struct {
-	db *database.Manager
+	db            *database.Manager
+	scanDirectory string
+	importService *importservice.ImportService
 }
 
-// NewScanHandler creates a new scan handler
-func NewScanHandler(db *database.Manager) *ScanHandler {
-	return &ScanHandler{db: db}
+// NewScanHandler creates a new scan handler.
+// Single-file scans are restricted to scanDirectory. Directory scans and rescans are
+// limited together with imports through the import service's scan tracker.
+func NewScanHandler(db *database.Manager, scanDirectory string, importService *importservice.ImportService) *ScanHandler {
+	return &ScanHandler{db: db, scanDirectory: scanDirectory, importService: importService}
 }
 
 // ScanDirectory starts a scan of the specified directory
@@ -38,21 +49,98 @@ func (h *ScanHandler) ScanDirectory(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Start scan in background
-	go func() {
+	job, err := h.importService.StartScan("scan", "Scan of "+req.Path, func(ctx context.Context, progress *jobs.Progress) error {
 		log.Printf("Starting scan of: %s", req.Path)
-		err := h.db.ScanDirectory(req.Path)
+		err := h.db.ScanDirectory(ctx, req.Path)
 		if err != nil {
 			log.Printf("Error scanning directory: %v", err)
 		} else {
 			log.Printf("Scan completed for: %s", req.Path)
 		}
-	}()
+		return err
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, models.ScanResponse{Status: "scan started", JobID: job.ID})
+}
+
+// ScanFile adds a single file inside the scan directory to the library
+func (h *ScanHandler) ScanFile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req models.ScanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.Path == "" {
+		http.Error(w, "Path is required", http.StatusBadRequest)
+		return
+	}
+
+	path, err := h.resolveScanPath(req.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	book, err := h.db.ScanFile(path)
+	if err == database.ErrDuplicatePath {
+		http.Error(w, "Book already exists in the library", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Error scanning file %s: %v", path, err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(models.ScanResponse{Status: "scan started"})
+	w.WriteHeader(http.StatusCreated)
+	encodeJSON(w, r, book)
 }
 
-// RescanDirectory performs a rescan that adds new books and removes unavailable ones
+// resolveScanPath turns a path (absolute, or relative to the scan directory) into a clean
+// absolute path and rejects anything that resolves outside the scan directory
+func (h *ScanHandler) resolveScanPath(path string) (string, error) {
+	root, err := filepath.Abs(h.scanDirectory)
+	if err != nil {
+		return "", fmt.Errorf("invalid scan directory: %v", err)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	path = filepath.Clean(path)
+
+	// Compare with symlinks resolved so a link can't point outside the library
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid scan directory: %v", err)
+	}
+	realPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("file not found: %s", path)
+	}
+	rel, err := filepath.Rel(realRoot, realPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path must be inside the scan directory")
+	}
+
+	// Store the path under the configured scan directory, like directory scans do
+	return filepath.Join(h.scanDirectory, rel), nil
+}
+
+// RescanDirectory performs a rescan that adds new books and removes unavailable ones.
+// With ?dry_run=true nothing is changed; the response lists the books that would be
+// added, updated and removed instead.
 func (h *ScanHandler) RescanDirectory(w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -70,18 +158,57 @@ func (h *ScanHandler) RescanDirectory(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Starting rescan of: %s", req.Path)
-	added, removed, err := h.db.RescanDirectory(req.Path)
+	dryRun := r.URL.Query().Get("dry_run") == "true"
+	description := "Rescan of " + req.Path
+	if dryRun {
+		description = "Rescan dry run of " + req.Path
+	}
+
+	// The rescan runs as a job so it is listed and counted, but the response waits for it
+	var plan models.RescanPlan
+	var added, removed int
+	err := h.importService.RunScan("rescan", description, func(ctx context.Context, progress *jobs.Progress) error {
+		var err error
+		if dryRun {
+			log.Printf("Previewing rescan of: %s", req.Path)
+			plan, err = h.db.PreviewRescan(ctx, req.Path)
+			added, removed = len(plan.Add), len(plan.Remove)
+		} else {
+			log.Printf("Starting rescan of: %s", req.Path)
+			added, removed, err = h.db.RescanDirectory(ctx, req.Path)
+		}
+		return err
+	})
 	if err != nil {
 		log.Printf("Error rescanning directory: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if errors.Is(err, importservice.ErrScanInProgress) || errors.Is(err, context.Canceled) {
+			status = http.StatusConflict
+		} else if errors.Is(err, database.ErrLibraryUnavailable) {
+			status = http.StatusServiceUnavailable
+		} else if errors.Is(err, database.ErrTooManyRemovals) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	if dryRun {
+		w.Header().Set("Content-Type", "application/json")
+		encodeJSON(w, r, map[string]interface{}{
+			"status":  "rescan dry run completed",
+			"dry_run": true,
+			"add":     plan.Add,
+			"update":  plan.Update,
+			"remove":  plan.Remove,
+		})
 		return
 	}
 
 	log.Printf("Rescan completed for: %s - Added: %d, Removed: %d", req.Path, added, removed)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(models.ScanResponse{
+	encodeJSON(w, r, models.ScanResponse{
 		Status:  "rescan completed",
 		Added:   added,
 		Removed: removed,
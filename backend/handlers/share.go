@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// shareSecret returns the key share links are signed with: share.secret, or a random
+// key for the lifetime of the process when none is configured
+func shareSecret(configured string) []byte {
+	if configured != "" {
+		return []byte(configured)
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("Failed to generate share link secret: %v", err)
+	}
+	return secret
+}
+
+// downloadSignature signs a book ID and expiry time (Unix seconds)
+func (h *BooksHandler) downloadSignature(bookID int, expires int64) string {
+	mac := hmac.New(sha256.New, h.shareSecret)
+	fmt.Fprintf(mac, "download:%d:%d", bookID, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// CreateShareLink returns a download URL for one book that works until it expires,
+// signed so it cannot be changed to another book or a later expiry
+// (POST /api/books/{id}/share)
+func (h *BooksHandler) CreateShareLink(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// URL format: /api/books/{id}/share
+	bookID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Invalid book ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.db.GetBookByID(bookID); err != nil {
+		writeBookNotFound(w, r, bookID)
+		return
+	}
+
+	// Without server.api_key every download is public, so a link would grant nothing
+	if h.config.Server.APIKey == "" {
+		http.Error(w, "Share links need server.api_key to be set: without it, every download is already public", http.StatusConflict)
+		return
+	}
+
+	expiresAt := time.Now().Add(time.Duration(h.config.Share.ExpirySeconds) * time.Second).UTC()
+	expires := expiresAt.Unix()
+	link := fmt.Sprintf("%s/api/download/%d?download=true&expires=%d&signature=%s",
+		requestBaseURL(r), bookID, expires, h.downloadSignature(bookID, expires))
+
+	w.Header().Set("Content-Type", "application/json")
+	encodeJSON(w, r, map[string]interface{}{
+		"book_id":    bookID,
+		"url":        link,
+		"expires_at": expiresAt,
+	})
+}
+
+// checkDownloadAccess lets a download through when it carries the server.api_key or
+// is a valid share link. It reports false after responding with 401 when neither is
+// given, or with 403 when a share link was changed or has expired.
+func (h *BooksHandler) checkDownloadAccess(w http.ResponseWriter, r *http.Request, bookID int) bool {
+	signature := r.URL.Query().Get("signature")
+	expiresStr := r.URL.Query().Get("expires")
+	if signature == "" && expiresStr == "" {
+		return requireAPIKey(w, r, h.config.Server.APIKey)
+	}
+
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || !hmac.Equal([]byte(signature), []byte(h.downloadSignature(bookID, expires))) {
+		http.Error(w, "Invalid share link", http.StatusForbidden)
+		return false
+	}
+	if time.Now().Unix() > expires {
+		http.Error(w, "Share link has expired", http.StatusForbidden)
+		return false
+	}
+	return true
+}
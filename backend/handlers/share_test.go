@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fableflow/backend/config"
+	"fableflow/backend/database"
+	"fableflow/backend/jobs"
+	"fableflow/backend/models"
+)
+
+// newTestBooksHandler returns a books handler over an empty in-memory library
+func newTestBooksHandler(t *testing.T, cfg *config.Config) *BooksHandler {
+	t.Helper()
+	dm, err := database.NewManager(database.MemoryPath)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(func() { dm.Close() })
+	return NewBooksHandler(dm, jobs.NewManager(1), cfg)
+}
+
+// testBook returns an EPUB book request filed at path
+func testBook(path string) models.BookRequest {
+	return models.BookRequest{Title: "Title", Author: "Author", FilePath: path, Format: "epub"}
+}
+
+func TestHasAPIKey(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(r *http.Request)
+		want  bool
+	}{
+		{"none", func(r *http.Request) {}, false},
+		{"header", func(r *http.Request) { r.Header.Set("X-API-Key", "secret") }, true},
+		{"query", func(r *http.Request) { r.URL.RawQuery = "api_key=secret" }, true},
+		{"basic auth password", func(r *http.Request) { r.SetBasicAuth("reader", "secret") }, true},
+		{"wrong header", func(r *http.Request) { r.Header.Set("X-API-Key", "guess") }, false},
+		{"basic auth username", func(r *http.Request) { r.SetBasicAuth("secret", "") }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/download/1", nil)
+			tt.setup(r)
+			if got := hasAPIKey(r, "secret"); got != tt.want {
+				t.Errorf("hasAPIKey = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	if !hasAPIKey(httptest.NewRequest("GET", "/api/download/1", nil), "") {
+		t.Error("hasAPIKey refused a request with no key configured")
+	}
+}
+
+func TestCheckDownloadAccess(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.APIKey = "secret"
+	h := newTestBooksHandler(t, cfg)
+
+	future := time.Now().Add(time.Hour).Unix()
+	past := time.Now().Add(-time.Hour).Unix()
+	link := func(expires int64, signature string) string {
+		return fmt.Sprintf("/api/download/1?expires=%d&signature=%s", expires, signature)
+	}
+	tests := []struct {
+		name string
+		url  string
+		want int
+	}{
+		{"no key", "/api/download/1", http.StatusUnauthorized},
+		{"api key", "/api/download/1?api_key=secret", http.StatusOK},
+		{"share link", link(future, h.downloadSignature(1, future)), http.StatusOK},
+		{"link to another book", link(future, h.downloadSignature(2, future)), http.StatusForbidden},
+		{"later expiry", link(future+60, h.downloadSignature(1, future)), http.StatusForbidden},
+		{"expired link", link(past, h.downloadSignature(1, past)), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			allowed := h.checkDownloadAccess(w, httptest.NewRequest("GET", tt.url, nil), 1)
+			if allowed != (tt.want == http.StatusOK) || w.Code != tt.want {
+				t.Errorf("allowed = %v, status %d; want status %d", allowed, w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateShareLinkNeedsAPIKey(t *testing.T) {
+	for _, key := range []string{"", "secret"} {
+		cfg := &config.Config{}
+		cfg.Server.APIKey = key
+		cfg.Share.ExpirySeconds = 3600
+		h := newTestBooksHandler(t, cfg)
+		if err := h.db.AddBook(testBook("/library/Author/Title.epub")); err != nil {
+			t.Fatalf("AddBook: %v", err)
+		}
+
+		books := http.NewServeMux()
+		books.HandleFunc("/api/books/{id}/share", h.CreateShareLink)
+		w := httptest.NewRecorder()
+		books.ServeHTTP(w, httptest.NewRequest("POST", "/api/books/1/share", nil))
+
+		want := http.StatusOK
+		if key == "" {
+			want = http.StatusConflict
+		}
+		if w.Code != want {
+			t.Errorf("api_key %q: status %d, want %d: %s", key, w.Code, want, w.Body)
+		}
+		if want == http.StatusOK && !strings.Contains(w.Body.String(), "signature=") {
+			t.Errorf("api_key %q: no signed URL in %s", key, w.Body)
+		}
+	}
+}
+
+func TestBookContentsNeedAPIKey(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Server.APIKey = "secret"
+	h := newTestBooksHandler(t, cfg)
+	if err := h.db.AddBook(testBook(sampleEPUB)); err != nil {
+		t.Fatalf("AddBook: %v", err)
+	}
+	books := http.NewServeMux()
+	books.HandleFunc("/api/books/{id}/text", h.GetBookText)
+	books.HandleFunc("/api/books/{id}/chapters", h.GetBookChapters)
+
+	for _, path := range []string{"/api/books/1/text?chapter=0", "/api/books/1/chapters?page=0"} {
+		w := httptest.NewRecorder()
+		books.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("GET %s without the key: status %d, want %d", path, w.Code, http.StatusUnauthorized)
+		}
+
+		w = httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("X-API-Key", "secret")
+		books.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Errorf("GET %s with the key: status %d: %s", path, w.Code, w.Body)
+		}
+	}
+}
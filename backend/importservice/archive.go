@@ -0,0 +1,151 @@
+package importservice
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// isImportArchive reports whether a file is an archive the importer can unpack (.zip, .tar.gz or .tgz)
+func isImportArchive(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+// isEPUBEntry reports whether an archive entry name has an .epub extension, in any case.
+// Entry names always use forward slashes.
+func isEPUBEntry(name string) bool {
+	return strings.EqualFold(path.Ext(name), ".epub")
+}
+
+// extractArchive extracts the EPUBs contained in an archive into destDir and returns their paths.
+// Other entries are ignored. Entries that would land outside destDir are rejected, and extraction
+// fails once more than maxBytes have been written.
+func extractArchive(archivePath, destDir string, maxBytes int64) ([]string, error) {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create extraction directory: %v", err)
+	}
+
+	remaining := maxBytes
+	if strings.HasSuffix(strings.ToLower(archivePath), ".zip") {
+		return extractZip(archivePath, destDir, &remaining)
+	}
+	return extractTarGz(archivePath, destDir, &remaining)
+}
+
+// extractZip extracts the EPUB entries of a zip archive
+func extractZip(archivePath, destDir string, remaining *int64) ([]string, error) {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open zip archive: %v", err)
+	}
+	defer reader.Close()
+
+	var files []string
+	for _, entry := range reader.File {
+		if !entry.Mode().IsRegular() || !isEPUBEntry(entry.Name) {
+			continue
+		}
+
+		target, err := archiveEntryPath(destDir, entry.Name)
+		if err != nil {
+			return files, err
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			return files, fmt.Errorf("failed to read %s: %v", entry.Name, err)
+		}
+		err = writeArchiveEntry(rc, target, remaining)
+		rc.Close()
+		if err != nil {
+			return files, err
+		}
+		files = append(files, target)
+	}
+
+	return files, nil
+}
+
+// extractTarGz extracts the EPUB entries of a gzip-compressed tar archive
+func extractTarGz(archivePath, destDir string, remaining *int64) ([]string, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gzip stream: %v", err)
+	}
+	defer gz.Close()
+
+	var files []string
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return files, fmt.Errorf("failed to read tar archive: %v", err)
+		}
+
+		// Links and devices are never extracted
+		if header.Typeflag != tar.TypeReg || !isEPUBEntry(header.Name) {
+			continue
+		}
+
+		target, err := archiveEntryPath(destDir, header.Name)
+		if err != nil {
+			return files, err
+		}
+		if err := writeArchiveEntry(tr, target, remaining); err != nil {
+			return files, err
+		}
+		files = append(files, target)
+	}
+
+	return files, nil
+}
+
+// archiveEntryPath resolves an entry name inside destDir, rejecting names that escape it (zip slip)
+func archiveEntryPath(destDir, name string) (string, error) {
+	target := filepath.Join(destDir, filepath.FromSlash(name))
+	rel, err := filepath.Rel(destDir, target)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(name) {
+		return "", fmt.Errorf("archive entry %q points outside the extraction directory", name)
+	}
+	return target, nil
+}
+
+// writeArchiveEntry copies one entry to disk, charging its size against the remaining budget
+func writeArchiveEntry(r io.Reader, target string, remaining *int64) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %v", target, err)
+	}
+
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	// Read one byte past the budget so an oversized entry is detected rather than truncated
+	written, err := io.Copy(out, io.LimitReader(r, *remaining+1))
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %v", target, err)
+	}
+	if written > *remaining {
+		return fmt.Errorf("archive exceeds the extraction size limit")
+	}
+	*remaining -= written
+	return nil
+}
@@ -0,0 +1,77 @@
+package importservice
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// archiveEntries are the entries of the test archives; only the EPUBs are extracted
+var archiveEntries = []string{"book.epub", "BOOK2.EPUB", "Shelf/Other.Epub", "notes.txt", "epub", "Shelf/cover.epub.jpg"}
+
+func writeTestZip(t *testing.T, path string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	zw := zip.NewWriter(file)
+	for _, name := range archiveEntries {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(name))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestTarGz(t *testing.T, path string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+	for _, name := range archiveEntries {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(name))}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(name))
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractArchiveMatchesExtensionCase(t *testing.T) {
+	for name, write := range map[string]func(*testing.T, string){"books.zip": writeTestZip, "books.tar.gz": writeTestTarGz} {
+		dir := t.TempDir()
+		archive := filepath.Join(dir, name)
+		write(t, archive)
+
+		dest := filepath.Join(dir, "extracted")
+		files, err := extractArchive(archive, dest, 1<<20)
+		if err != nil {
+			t.Fatalf("%s: extractArchive: %v", name, err)
+		}
+		sort.Strings(files)
+		want := []string{filepath.Join(dest, "BOOK2.EPUB"), filepath.Join(dest, "Shelf", "Other.Epub"), filepath.Join(dest, "book.epub")}
+		if !reflect.DeepEqual(files, want) {
+			t.Errorf("%s: extracted %q, want %q", name, files, want)
+		}
+	}
+}
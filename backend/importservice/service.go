@@ -1,16 +1,23 @@
 package importservice
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"fableflow/backend/epub"
+	"fableflow/backend/fsmode"
+	"fableflow/backend/fswalk"
+	"fableflow/backend/jobs"
 	"fableflow/backend/metadata"
 )
 
@@ -23,13 +30,22 @@ type QuarantinedBook struct {
 	Timestamp      time.Time `json:"timestamp"`
 }
 
+// ImportConflict records how a file whose target already existed in the library was handled
+type ImportConflict struct {
+	FilePath   string `json:"file_path"`
+	TargetPath string `json:"target_path"` // Where the file was imported to, or the existing file it was skipped for
+	Resolution string `json:"resolution"`  // ConflictSkip, ConflictOverwrite or ConflictRename
+}
+
 // ImportSession represents a single import session
 type ImportSession struct {
 	ID               string            `json:"id"`
 	StartTime        time.Time         `json:"start_time"`
 	EndTime          *time.Time        `json:"end_time,omitempty"`
-	Status           string            `json:"status"` // "running", "completed", "failed"
+	Status           string            `json:"status"`           // "running", "completed", "failed", "cancelled"
+	JobID            string            `json:"job_id,omitempty"` // The session's entry in /api/jobs
 	DryRun           bool              `json:"dry_run"`
+	Retry            bool              `json:"retry,omitempty"` // Reprocesses the quarantine directory instead of importing
 	TotalFiles       int               `json:"total_files"`
 	ProcessedFiles   int               `json:"processed_files"`
 	ImportedFiles    int               `json:"imported_files"`
@@ -37,18 +53,56 @@ type ImportSession struct {
 	SkippedFiles     int               `json:"skipped_files"`
 	Errors           []string          `json:"errors"`
 	QuarantinedBooks []QuarantinedBook `json:"quarantined_books,omitempty"`
+	Conflicts        []ImportConflict  `json:"conflicts,omitempty"` // Files whose target already existed, and what was done
+	Log              []LogEntry        `json:"log,omitempty"`       // Info and error lines in order
 	LogPath          string            `json:"log_path"`
 }
 
+// LogEntry is a single info or error line written during an import session
+type LogEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"` // "info" or "error"
+	Message string    `json:"message"`
+}
+
+// logSubscriberBuffer is how many entries a slow log subscriber may fall behind
+// before further entries are dropped for it
+const logSubscriberBuffer = 256
+
+// scanJobKinds are the job kinds limited together by MaxConcurrentScans
+var scanJobKinds = []string{"import", "retry", "scan", "rescan"}
+
+// Import conflict policies control what an import does when a book's target file already
+// exists in the library (library.import_on_conflict)
+const (
+	ConflictSkip      = "skip"      // Keep the existing file and skip the import
+	ConflictOverwrite = "overwrite" // Replace the existing file, refreshing its book
+	ConflictRename    = "rename"    // Import next to it with a numbered suffix, as a new book
+)
+
+// ValidConflictPolicy reports whether policy is one of the import conflict policies
+func ValidConflictPolicy(policy string) bool {
+	return policy == ConflictSkip || policy == ConflictOverwrite || policy == ConflictRename
+}
+
+// ErrScanInProgress is returned when the maximum number of concurrent scans and imports
+// are already running
+var ErrScanInProgress = errors.New("a scan or import is already in progress")
+
+// ErrNoQuarantineDirectory is returned by StartQuarantineRetry when no quarantine directory is configured
+var ErrNoQuarantineDirectory = errors.New("quarantine directory not configured")
+
 // ImportService manages book import operations
 type ImportService struct {
 	config            *Config
 	metadataExtractor *metadata.Extractor
 	currentSession    *ImportSession
+	jobs              *jobs.Manager              // Runs sessions and scans and tracks them as jobs
+	logSubscribers    map[chan LogEntry]struct{} // Receive the running session's new log entries, guarded by sessionMutex
 	sessionMutex      sync.RWMutex
 	logDir            string
 	maxLogs           int
-	onComplete        func() // Callback function called when import completes
+	onComplete        func(ctx context.Context) error // Callback function called when import completes
 }
 
 // Config represents the configuration for the import service
@@ -58,13 +112,36 @@ type Config struct {
 	QuarantineDirectory string
 	LogDir              string
 	MaxLogs             int
+	UnknownAuthorPolicy string
+	PathTemplate        string
+	AuthorDirStyle      string
+	LeadingArticles     []string
+	TmpDir              string
+	MaxArchiveBytes     int64
+	FollowSymlinks      bool
+	SkipHidden          bool     // Leave out names starting with "." when looking for books to import
+	IgnoreNames         []string // Leave out these names, compared case-insensitively
+	MaxConcurrentScans  int      // Scans, rescans and imports allowed to run at once (at least 1)
+	DirMode             os.FileMode
+	FileMode            os.FileMode
+	PreserveMtime       bool                    // Give imported copies the original file's modification time
+	OnConflict          string                  // ConflictSkip, ConflictOverwrite or ConflictRename; empty means skip
+	RefreshBook         func(path string) error // Re-reads the metadata of a library file an import overwrote, if set
+	Extractor           *metadata.Extractor     // Reads the metadata of imported books; a default one when nil
 }
 
-// NewImportService creates a new import service
-func NewImportService(config *Config, onComplete func()) *ImportService {
+// NewImportService creates a new import service that runs its sessions and the scans
+// started through it as jobs of jobManager
+func NewImportService(config *Config, jobManager *jobs.Manager, onComplete func(ctx context.Context) error) *ImportService {
+	extractor := config.Extractor
+	if extractor == nil {
+		extractor = metadata.NewExtractor()
+	}
 	return &ImportService{
 		config:            config,
-		metadataExtractor: metadata.NewExtractor(),
+		metadataExtractor: extractor,
+		jobs:              jobManager,
+		logSubscribers:    make(map[chan LogEntry]struct{}),
 		logDir:            config.LogDir,
 		maxLogs:           config.MaxLogs,
 		onComplete:        onComplete,
@@ -73,6 +150,21 @@ func NewImportService(config *Config, onComplete func()) *ImportService {
 
 // StartImport starts a new import session
 func (s *ImportService) StartImport(dryRun bool) (*ImportSession, error) {
+	return s.startSession("import", dryRun, false)
+}
+
+// StartQuarantineRetry starts a session that runs every EPUB in the quarantine directory
+// back through the import pipeline. Files that now import are moved into the library;
+// the others stay in quarantine with the reason from this session.
+func (s *ImportService) StartQuarantineRetry(dryRun bool) (*ImportSession, error) {
+	if s.config.QuarantineDirectory == "" {
+		return nil, ErrNoQuarantineDirectory
+	}
+	return s.startSession("retry", dryRun, true)
+}
+
+// startSession starts an import or quarantine retry session
+func (s *ImportService) startSession(kind string, dryRun, retry bool) (*ImportSession, error) {
 	s.sessionMutex.Lock()
 	defer s.sessionMutex.Unlock()
 
@@ -80,26 +172,76 @@ func (s *ImportService) StartImport(dryRun bool) (*ImportSession, error) {
 	if s.currentSession != nil && s.currentSession.Status == "running" {
 		return nil, fmt.Errorf("import session already in progress")
 	}
+	if s.runningScans() >= s.maxConcurrentScans() {
+		return nil, ErrScanInProgress
+	}
 
 	// Create new session
-	sessionID := fmt.Sprintf("import_%d", time.Now().Unix())
+	sessionID := fmt.Sprintf("%s_%d", kind, time.Now().Unix())
 	session := &ImportSession{
 		ID:        sessionID,
 		StartTime: time.Now(),
 		Status:    "running",
 		DryRun:    dryRun,
+		Retry:     retry,
 		Errors:    []string{},
 		LogPath:   filepath.Join(s.logDir, fmt.Sprintf("%s.json", sessionID)),
 	}
 
 	s.currentSession = session
 
-	// Start import process in goroutine
-	go s.runImport(session)
+	// Start import process as a background job
+	job := s.jobs.Start(kind, "Session "+sessionID, func(ctx context.Context, progress *jobs.Progress) error {
+		return s.runImport(ctx, session, progress)
+	})
+	session.JobID = job.ID
 
 	return session, nil
 }
 
+// StartScan starts fn as a directory scan or rescan job, failing with ErrScanInProgress
+// when the configured number of scans and imports are already running
+func (s *ImportService) StartScan(kind, description string, fn jobs.Func) (jobs.Job, error) {
+	s.sessionMutex.Lock()
+	defer s.sessionMutex.Unlock()
+
+	if s.runningScans() >= s.maxConcurrentScans() {
+		return jobs.Job{}, ErrScanInProgress
+	}
+	return s.jobs.Start(kind, description, fn), nil
+}
+
+// RunScan is StartScan followed by waiting for the scan, returning its error.
+// A scan cancelled before it started returns context.Canceled.
+func (s *ImportService) RunScan(kind, description string, fn jobs.Func) error {
+	var result error
+	job, err := s.StartScan(kind, description, func(ctx context.Context, progress *jobs.Progress) error {
+		result = fn(ctx, progress)
+		return result
+	})
+	if err != nil {
+		return err
+	}
+
+	if job, _ = s.jobs.Wait(job.ID); result == nil && job.Status == jobs.Cancelled {
+		return context.Canceled
+	}
+	return result
+}
+
+// runningScans counts the queued and running scans and imports; callers must hold
+// sessionMutex so that no other scan is started between counting and starting
+func (s *ImportService) runningScans() int {
+	return s.jobs.Active(scanJobKinds...)
+}
+
+func (s *ImportService) maxConcurrentScans() int {
+	if s.config.MaxConcurrentScans < 1 {
+		return 1
+	}
+	return s.config.MaxConcurrentScans
+}
+
 // GetStatus returns the current import session status
 func (s *ImportService) GetStatus() *ImportSession {
 	s.sessionMutex.RLock()
@@ -114,8 +256,10 @@ func (s *ImportService) GetStatus() *ImportSession {
 	return &session
 }
 
-// runImport performs the actual import process
-func (s *ImportService) runImport(session *ImportSession) {
+// runImport performs the actual import process. It stops between files once ctx is
+// cancelled. Failing to read the source directory fails the job, while problems with
+// single files are only logged.
+func (s *ImportService) runImport(ctx context.Context, session *ImportSession, progress *jobs.Progress) (err error) {
 	defer func() {
 		s.sessionMutex.Lock()
 		if s.currentSession != nil {
@@ -125,57 +269,117 @@ func (s *ImportService) runImport(session *ImportSession) {
 				s.currentSession.Status = "completed"
 			}
 		}
+		// The session's log is complete
+		for ch := range s.logSubscribers {
+			close(ch)
+			delete(s.logSubscribers, ch)
+		}
 		s.sessionMutex.Unlock()
 
 		// Save session log
 		s.saveSessionLog(session)
 
-		// Call completion callback if not a dry run
+		// Call completion callback if not a dry run. The callback rescans the library, so it
+		// runs as a scan job of its own even though the session itself has completed.
 		if !session.DryRun && s.onComplete != nil {
-			s.onComplete()
+			s.jobs.Start("scan", "Library scan after session "+session.ID, func(ctx context.Context, progress *jobs.Progress) error {
+				return s.onComplete(ctx)
+			})
 		}
 	}()
 
 	// Ensure log directory exists
 	if err := os.MkdirAll(s.logDir, 0755); err != nil {
 		s.logError(session, fmt.Sprintf("Failed to create log directory: %v", err))
-		return
+		return err
 	}
 
-	// Scan import directory for EPUB files
-	epubFiles, err := s.scanForEPUBFiles(s.config.ImportDirectory)
+	// Scan import directory for EPUB files and archives
+	sourceDir, sourceName := s.config.ImportDirectory, "import"
+	if session.Retry {
+		sourceDir, sourceName = s.config.QuarantineDirectory, "quarantine"
+	}
+	epubFiles, archives, err := s.scanForImportFiles(sourceDir)
 	if err != nil {
-		s.logError(session, fmt.Sprintf("Failed to scan import directory: %v", err))
-		return
+		s.logError(session, fmt.Sprintf("Failed to scan %s directory: %v", sourceName, err))
+		return err
+	}
+	if session.Retry {
+		archives = nil // Only EPUBs are ever quarantined
+	}
+
+	// Unpack archives into a temporary area so their EPUBs go through the normal pipeline
+	if len(archives) > 0 {
+		extractDir, err := s.createExtractDir()
+		if err != nil {
+			s.logError(session, fmt.Sprintf("Failed to create archive extraction directory: %v", err))
+			return err
+		}
+		defer os.RemoveAll(extractDir)
+
+		for i, archivePath := range archives {
+			extracted, err := extractArchive(archivePath, filepath.Join(extractDir, strconv.Itoa(i)), s.config.MaxArchiveBytes)
+			if err != nil {
+				s.logError(session, fmt.Sprintf("Failed to extract archive %s: %v", archivePath, err))
+				continue
+			}
+			s.logInfo(session, fmt.Sprintf("Extracted %d EPUB file(s) from %s", len(extracted), archivePath))
+			epubFiles = append(epubFiles, extracted...)
+		}
 	}
 
 	s.sessionMutex.Lock()
 	s.currentSession.TotalFiles = len(epubFiles)
 	s.sessionMutex.Unlock()
+	progress.SetTotal(len(epubFiles))
 
 	// Process each EPUB file
 	for _, filePath := range epubFiles {
+		if ctx.Err() != nil {
+			s.logInfo(session, fmt.Sprintf("Cancelled after %d of %d files", session.ProcessedFiles, len(epubFiles)))
+			s.sessionMutex.Lock()
+			s.currentSession.Status = "cancelled"
+			s.sessionMutex.Unlock()
+			return ctx.Err()
+		}
 		s.processFile(session, filePath)
+		progress.Step()
 	}
+	return nil
 }
 
-// scanForEPUBFiles recursively scans a directory for EPUB files
-func (s *ImportService) scanForEPUBFiles(rootPath string) ([]string, error) {
-	var epubFiles []string
+// scanForImportFiles recursively scans a directory for EPUB files and archives containing them
+func (s *ImportService) scanForImportFiles(rootPath string) ([]string, []string, error) {
+	var epubFiles, archives []string
 
-	err := filepath.Walk(rootPath, func(path string, info os.FileInfo, err error) error {
+	walk := fswalk.Options{FollowSymlinks: s.config.FollowSymlinks, SkipHidden: s.config.SkipHidden, IgnoreNames: s.config.IgnoreNames}
+	err := fswalk.Walk(rootPath, walk, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return nil // Skip files we can't access
 		}
 
 		if !info.IsDir() && filepath.Ext(path) == ".epub" {
 			epubFiles = append(epubFiles, path)
+		} else if !info.IsDir() && isImportArchive(path) {
+			archives = append(archives, path)
 		}
 
 		return nil
 	})
 
-	return epubFiles, err
+	return epubFiles, archives, err
+}
+
+// createExtractDir creates a fresh directory under the temp dir for unpacking archives
+func (s *ImportService) createExtractDir() (string, error) {
+	tmpDir := s.config.TmpDir
+	if tmpDir == "" {
+		tmpDir = os.TempDir()
+	}
+	if err := os.MkdirAll(tmpDir, 0755); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(tmpDir, "import_archive_")
 }
 
 // processFile processes a single EPUB file
@@ -183,6 +387,19 @@ func (s *ImportService) processFile(session *ImportSession, filePath string) {
 	// Always increment processed files at the start - this file is being processed
 	s.incrementProcessed(session)
 
+	// Reject structurally broken EPUBs before trying to read their metadata
+	report, err := epub.Validate(filePath)
+	if err != nil {
+		s.logError(session, fmt.Sprintf("Failed to open %s: %v", filePath, err))
+		s.quarantineFile(session, filePath, "invalid epub")
+		return
+	}
+	if !report.Valid {
+		s.logError(session, fmt.Sprintf("Invalid EPUB %s: %s", filePath, strings.Join(report.Errors(), "; ")))
+		s.quarantineFile(session, filePath, "invalid epub")
+		return
+	}
+
 	// Extract metadata
 	bookMetadata, err := s.metadataExtractor.ExtractMetadata(filePath)
 	if err != nil {
@@ -191,6 +408,13 @@ func (s *ImportService) processFile(session *ImportSession, filePath string) {
 		return
 	}
 
+	// Apply the unknown author policy before checking required fields
+	if !s.metadataExtractor.ResolveUnknownAuthor(filePath, bookMetadata, s.config.UnknownAuthorPolicy) {
+		s.logError(session, fmt.Sprintf("Unknown author in %s", filePath))
+		s.quarantineFile(session, filePath, "unknown author")
+		return
+	}
+
 	// Check if we have required metadata
 	if bookMetadata.Title == "" || bookMetadata.Author == "" {
 		s.logError(session, fmt.Sprintf("Missing required metadata (title or author) in %s", filePath))
@@ -199,54 +423,150 @@ func (s *ImportService) processFile(session *ImportSession, filePath string) {
 	}
 
 	// Create target directory structure
-	targetDir := filepath.Join(s.config.ScanDirectory, bookMetadata.Author, bookMetadata.Title)
-	targetFile := filepath.Join(targetDir, fmt.Sprintf("%s - %s.epub", bookMetadata.Title, bookMetadata.Author))
+	targetFile := filepath.Join(s.config.ScanDirectory, metadata.RenderPathTemplate(s.config.PathTemplate, bookMetadata.Author, bookMetadata.Title, "epub", s.config.LeadingArticles, s.config.AuthorDirStyle))
+	targetDir := filepath.Dir(targetFile)
 
-	// Check if file already exists
+	// Resolve a target that is already in the library (library.import_on_conflict)
+	resolution := ""
 	if _, err := os.Stat(targetFile); err == nil {
-		s.logError(session, fmt.Sprintf("File already exists, skipping: %s", targetFile))
-		s.incrementSkipped(session)
-		return
+		resolution = s.config.OnConflict
+		switch resolution {
+		case ConflictOverwrite:
+		case ConflictRename:
+			targetFile = numberedPath(targetFile)
+		default:
+			s.logError(session, fmt.Sprintf("File already exists, skipping: %s", targetFile))
+			s.addConflict(session, filePath, targetFile, ConflictSkip)
+			if session.Retry && !session.DryRun {
+				s.addQuarantinedBook(session, filePath, filePath, "already in library")
+			}
+			s.incrementSkipped(session)
+			return
+		}
+		s.addConflict(session, filePath, targetFile, resolution)
 	}
 
 	if session.DryRun {
 		// Dry run - just log what would happen
-		s.logInfo(session, fmt.Sprintf("Would import: %s -> %s", filePath, targetFile))
+		s.logInfo(session, fmt.Sprintf("Would import%s: %s -> %s", conflictNote(resolution), filePath, targetFile))
 		return
 	}
 
 	// Create target directory
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
+	if err := fsmode.MkdirAll(targetDir, s.config.DirMode); err != nil {
 		s.logError(session, fmt.Sprintf("Failed to create target directory %s: %v", targetDir, err))
 		return
 	}
 
-	// Copy file to target location
-	if err := s.copyFile(filePath, targetFile); err != nil {
+	// Copy file to target location. An overwritten file is only replaced once the copy is
+	// complete, so a failed copy leaves it intact.
+	copyTarget := targetFile
+	if resolution == ConflictOverwrite {
+		copyTarget = targetFile + ".importing"
+	}
+	if err := s.copyFile(filePath, copyTarget); err != nil {
+		os.Remove(copyTarget)
 		s.logError(session, fmt.Sprintf("Failed to copy file %s to %s: %v", filePath, targetFile, err))
 		return
 	}
+	if copyTarget != targetFile {
+		if err := os.Rename(copyTarget, targetFile); err != nil {
+			os.Remove(copyTarget)
+			s.logError(session, fmt.Sprintf("Failed to replace %s: %v", targetFile, err))
+			return
+		}
+	}
+
+	// Keep curated sidecar metadata next to the library copy, where scans read it from
+	if sidecar := s.metadataExtractor.SidecarPath(filePath); sidecar != "" {
+		target := strings.TrimSuffix(targetFile, filepath.Ext(targetFile)) + strings.ToLower(filepath.Ext(sidecar))
+		if err := s.copyFile(sidecar, target); err != nil {
+			s.logError(session, fmt.Sprintf("Failed to copy metadata file %s to %s: %v", sidecar, target, err))
+		}
+	}
+
+	// A retried file leaves the quarantine once it is in the library
+	if session.Retry {
+		if err := os.Remove(filePath); err != nil {
+			s.logError(session, fmt.Sprintf("Failed to remove %s from quarantine: %v", filePath, err))
+		}
+	}
+
+	// The scan after the session may not notice a same-sized copy with an old timestamp
+	if resolution == ConflictOverwrite && s.config.RefreshBook != nil {
+		if err := s.config.RefreshBook(targetFile); err != nil {
+			s.logError(session, fmt.Sprintf("Failed to refresh book %s: %v", targetFile, err))
+		}
+	}
 
-	s.logInfo(session, fmt.Sprintf("Imported: %s -> %s", filePath, targetFile))
+	s.logInfo(session, fmt.Sprintf("Imported%s: %s -> %s", conflictNote(resolution), filePath, targetFile))
 	s.incrementImported(session)
 }
 
-// copyFile copies a file from source to destination
+// conflictNote describes how an existing target was resolved, for log messages
+func conflictNote(resolution string) string {
+	switch resolution {
+	case ConflictOverwrite:
+		return " (overwriting the existing file)"
+	case ConflictRename:
+		return " (renamed, the target already exists)"
+	}
+	return ""
+}
+
+// numberedPath returns the first of "name (2).ext", "name (3).ext", ... next to path
+// that does not exist yet
+func numberedPath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, n, ext)
+		if _, err := os.Stat(candidate); os.IsNotExist(err) {
+			return candidate
+		}
+	}
+}
+
+// addConflict records how a file whose target already existed was handled
+func (s *ImportService) addConflict(session *ImportSession, filePath, targetPath, resolution string) {
+	s.sessionMutex.Lock()
+	defer s.sessionMutex.Unlock()
+	session.Conflicts = append(session.Conflicts, ImportConflict{FilePath: filePath, TargetPath: targetPath, Resolution: resolution})
+}
+
+// copyFile copies a file from source to destination with the configured permissions
 func (s *ImportService) copyFile(src, dst string) error {
+	return CopyFile(src, dst, s.config.FileMode, s.config.PreserveMtime)
+}
+
+// CopyFile copies a file from source to destination, creating it with the given
+// permissions (0 for fsmode.DefaultFileMode). With preserveMtime the copy gets the
+// source's modification time.
+func CopyFile(src, dst string, mode os.FileMode, preserveMtime bool) error {
 	sourceFile, err := os.Open(src)
 	if err != nil {
 		return err
 	}
 	defer sourceFile.Close()
 
-	destFile, err := os.Create(dst)
+	destFile, err := fsmode.Create(dst, mode)
 	if err != nil {
 		return err
 	}
 	defer destFile.Close()
 
-	_, err = destFile.ReadFrom(sourceFile)
-	return err
+	if _, err := destFile.ReadFrom(sourceFile); err != nil {
+		return err
+	}
+
+	if preserveMtime {
+		info, err := sourceFile.Stat()
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(dst, info.ModTime(), info.ModTime())
+	}
+	return nil
 }
 
 // quarantineFile moves a file to the quarantine directory
@@ -257,8 +577,16 @@ func (s *ImportService) quarantineFile(session *ImportSession, filePath, reason
 		return
 	}
 
+	// A retried file is already in quarantine, only its reason changes
+	if session.Retry {
+		s.addQuarantinedBook(session, filePath, filePath, reason)
+		s.logInfo(session, fmt.Sprintf("Still quarantined: %s (reason: %s)", filePath, reason))
+		s.incrementQuarantined(session)
+		return
+	}
+
 	// Ensure quarantine directory exists
-	if err := os.MkdirAll(s.config.QuarantineDirectory, 0755); err != nil {
+	if err := fsmode.MkdirAll(s.config.QuarantineDirectory, s.config.DirMode); err != nil {
 		s.logError(session, fmt.Sprintf("Failed to create quarantine directory: %v", err))
 		return
 	}
@@ -341,14 +669,59 @@ func (s *ImportService) incrementSkipped(session *ImportSession) {
 func (s *ImportService) logError(session *ImportSession, message string) {
 	s.sessionMutex.Lock()
 	s.currentSession.Errors = append(s.currentSession.Errors, message)
+	s.appendLog("error", message)
 	s.sessionMutex.Unlock()
 	log.Printf("[%s] ERROR: %s", session.ID, message)
 }
 
 func (s *ImportService) logInfo(session *ImportSession, message string) {
+	s.sessionMutex.Lock()
+	s.appendLog("info", message)
+	s.sessionMutex.Unlock()
 	log.Printf("[%s] INFO: %s", session.ID, message)
 }
 
+// appendLog records a log line on the current session and hands it to subscribers;
+// callers must hold sessionMutex
+func (s *ImportService) appendLog(level, message string) {
+	entry := LogEntry{Time: time.Now(), Level: level, Message: message}
+	s.currentSession.Log = append(s.currentSession.Log, entry)
+	for ch := range s.logSubscribers {
+		select {
+		case ch <- entry:
+		default:
+			// Subscriber isn't keeping up; it still gets the full log once the session is saved
+		}
+	}
+}
+
+// SubscribeLog returns the log written so far by the running session with the given ID
+// and a channel receiving its new entries, which is closed when the session finishes.
+// ok is false when that session isn't running. unsubscribe must be called when the
+// caller stops reading.
+func (s *ImportService) SubscribeLog(sessionID string) (backlog []LogEntry, entries <-chan LogEntry, unsubscribe func(), ok bool) {
+	s.sessionMutex.Lock()
+	defer s.sessionMutex.Unlock()
+
+	if s.currentSession == nil || s.currentSession.ID != sessionID || s.currentSession.Status != "running" {
+		return nil, nil, nil, false
+	}
+
+	backlog = append([]LogEntry(nil), s.currentSession.Log...)
+	ch := make(chan LogEntry, logSubscriberBuffer)
+	s.logSubscribers[ch] = struct{}{}
+
+	unsubscribe = func() {
+		s.sessionMutex.Lock()
+		if _, subscribed := s.logSubscribers[ch]; subscribed {
+			delete(s.logSubscribers, ch)
+			close(ch)
+		}
+		s.sessionMutex.Unlock()
+	}
+	return backlog, ch, unsubscribe, true
+}
+
 // saveSessionLog saves the session log to disk
 func (s *ImportService) saveSessionLog(session *ImportSession) {
 	// Ensure log directory exists
@@ -376,6 +749,9 @@ func (s *ImportService) saveSessionLog(session *ImportSession) {
 // GetAvailableLogs returns a list of available import session logs
 func (s *ImportService) GetAvailableLogs() ([]map[string]interface{}, error) {
 	files, err := ioutil.ReadDir(s.logDir)
+	if os.IsNotExist(err) {
+		return []map[string]interface{}{}, nil // No import has run yet
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -401,6 +777,7 @@ func (s *ImportService) GetAvailableLogs() ([]map[string]interface{}, error) {
 				"end_time":          session.EndTime,
 				"status":            session.Status,
 				"dry_run":           session.DryRun,
+				"retry":             session.Retry,
 				"total_files":       session.TotalFiles,
 				"imported_files":    session.ImportedFiles,
 				"quarantined_files": session.QuarantinedFiles,
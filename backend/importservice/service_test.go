@@ -0,0 +1,114 @@
+package importservice
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"fableflow/backend/jobs"
+	"fableflow/backend/metadata"
+)
+
+// writeEPUB writes a minimal valid EPUB with the given title and dc:creator to path
+func writeEPUB(t *testing.T, path, title, author string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	files := []struct{ name, data string }{
+		{"mimetype", "application/epub+zip"},
+		{"META-INF/container.xml", `<?xml version="1.0"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles><rootfile full-path="content.opf" media-type="application/oebps-package+xml"/></rootfiles>
+</container>`},
+		{"content.opf", `<?xml version="1.0"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="2.0" unique-identifier="id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="id">` + title + `</dc:identifier>
+    <dc:title>` + title + `</dc:title>
+    <dc:creator>` + author + `</dc:creator>
+  </metadata>
+  <manifest><item id="text" href="text.xhtml" media-type="application/xhtml+xml"/></manifest>
+  <spine><itemref idref="text"/></spine>
+</package>`},
+		{"text.xhtml", `<html xmlns="http://www.w3.org/1999/xhtml"><body><p>Text</p></body></html>`},
+	}
+	writer := zip.NewWriter(file)
+	for _, f := range files {
+		w, err := writer.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(f.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImportFilesUnderPrimaryAuthor(t *testing.T) {
+	books := []struct{ title, author string }{
+		{"Good Omens", "Terry Pratchett &amp; Neil Gaiman"},
+		{"The Talisman", "King, Stephen; Straub, Peter"},
+		{"The Dispossessed", "Le Guin, Ursula K."},
+	}
+	tests := []struct {
+		style string
+		want  []string
+	}{
+		{metadata.AuthorDirAsIs, []string{
+			"Terry Pratchett & Neil Gaiman/Good Omens/Good Omens - Terry Pratchett & Neil Gaiman.epub",
+			"King, Stephen; Straub, Peter/The Talisman/The Talisman - King, Stephen; Straub, Peter.epub",
+			"Le Guin, Ursula K./The Dispossessed/The Dispossessed - Le Guin, Ursula K..epub",
+		}},
+		{metadata.AuthorDirFirst, []string{
+			"Terry Pratchett/Good Omens/Good Omens - Terry Pratchett & Neil Gaiman.epub",
+			"Stephen King/The Talisman/The Talisman - King, Stephen; Straub, Peter.epub",
+			"Ursula K. Le Guin/The Dispossessed/The Dispossessed - Le Guin, Ursula K..epub",
+		}},
+		{metadata.AuthorDirLastFirst, []string{
+			"Pratchett, Terry/Good Omens/Good Omens - Terry Pratchett & Neil Gaiman.epub",
+			"King, Stephen/The Talisman/The Talisman - King, Stephen; Straub, Peter.epub",
+			"Le Guin, Ursula K./The Dispossessed/The Dispossessed - Le Guin, Ursula K..epub",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			importDir, scanDir := t.TempDir(), t.TempDir()
+			for _, book := range books {
+				writeEPUB(t, filepath.Join(importDir, book.title+".epub"), book.title, book.author)
+			}
+			jobManager := jobs.NewManager(1)
+			s := NewImportService(&Config{
+				ImportDirectory: importDir,
+				ScanDirectory:   scanDir,
+				LogDir:          t.TempDir(),
+				PathTemplate:    metadata.DefaultPathTemplate,
+				AuthorDirStyle:  tt.style,
+			}, jobManager, nil)
+
+			session, err := s.StartImport(false)
+			if err != nil {
+				t.Fatalf("StartImport: %v", err)
+			}
+			if job, _ := jobManager.Wait(session.JobID); job.Status != jobs.Completed {
+				t.Fatalf("import job = %+v", job)
+			}
+			if status := s.GetStatus(); status.ImportedFiles != len(books) {
+				t.Fatalf("imported %d of %d books: %v", status.ImportedFiles, len(books), status.Errors)
+			}
+			for _, want := range tt.want {
+				if _, err := os.Stat(filepath.Join(scanDir, filepath.FromSlash(want))); err != nil {
+					t.Errorf("book not filed at %s: %v", want, err)
+				}
+			}
+		})
+	}
+}
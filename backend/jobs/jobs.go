@@ -0,0 +1,276 @@
+// Package jobs runs long background operations such as imports and library scans with
+// bounded concurrency, and keeps track of them so they can be listed and cancelled.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Retention is how long a finished job can still be listed
+const Retention = 24 * time.Hour
+
+// Job statuses
+const (
+	Queued    = "queued"
+	Running   = "running"
+	Completed = "completed"
+	Failed    = "failed"
+	Cancelled = "cancelled"
+)
+
+// ErrNotFound is returned by Cancel for an unknown job ID
+var ErrNotFound = errors.New("job not found")
+
+// ErrFinished is returned by Cancel for a job that is no longer queued or running
+var ErrFinished = errors.New("job already finished")
+
+// Job is a snapshot of one background operation
+type Job struct {
+	ID          string     `json:"id"`
+	Kind        string     `json:"kind"` // e.g. "import", "retry", "scan" or "rescan"
+	Description string     `json:"description,omitempty"`
+	Status      string     `json:"status"`          // "queued", "running", "completed", "failed" or "cancelled"
+	Total       int        `json:"total,omitempty"` // Units of work, when the job knows them
+	Done        int        `json:"done"`
+	Error       string     `json:"error,omitempty"`
+	ResultURL   string     `json:"result_url,omitempty"` // Where the job's result is fetched once it has completed
+	Cancelling  bool       `json:"cancelling,omitempty"` // Cancellation was requested but the job has not stopped yet
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// Func is the work of a job. It should return promptly with ctx.Err() once ctx is
+// cancelled; jobs that cannot stop halfway may ignore ctx and run to completion.
+type Func func(ctx context.Context, progress *Progress) error
+
+// job is a registered job and its controls
+type job struct {
+	Job
+	cancel context.CancelFunc
+	done   chan struct{} // Closed when the job has finished
+}
+
+// Manager runs jobs on a fixed number of workers; jobs started while all workers are
+// busy wait in line
+type Manager struct {
+	mu    sync.Mutex
+	jobs  map[string]*job
+	slots chan struct{} // Semaphore bounding running jobs
+	seq   int
+}
+
+// NewManager creates a manager that runs at most workers jobs at once
+func NewManager(workers int) *Manager {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Manager{
+		jobs:  make(map[string]*job),
+		slots: make(chan struct{}, workers),
+	}
+}
+
+// Workers returns the number of jobs that can run at once
+func (m *Manager) Workers() int {
+	return cap(m.slots)
+}
+
+// Start queues fn as a job of the given kind and returns its initial snapshot without
+// waiting for it to run
+func (m *Manager) Start(kind, description string, fn Func) Job {
+	return m.StartWithResult(kind, description, "", fn)
+}
+
+// StartWithResult is Start for a job whose result, such as a converted book, can be
+// fetched from resultURL once it has completed
+func (m *Manager) StartWithResult(kind, description, resultURL string, fn Func) Job {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m.mu.Lock()
+	m.prune()
+	m.seq++
+	j := &job{
+		Job: Job{
+			ID:          fmt.Sprintf("%s_%d_%d", kind, time.Now().Unix(), m.seq),
+			Kind:        kind,
+			Description: description,
+			Status:      Queued,
+			ResultURL:   resultURL,
+			CreatedAt:   time.Now(),
+		},
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	m.jobs[j.ID] = j
+	snapshot := j.Job
+	m.mu.Unlock()
+
+	go m.run(ctx, j, fn)
+	return snapshot
+}
+
+// run waits for a free worker, then runs the job and records its outcome
+func (m *Manager) run(ctx context.Context, j *job, fn Func) {
+	defer close(j.done)
+	defer j.cancel()
+
+	select {
+	case m.slots <- struct{}{}:
+	case <-ctx.Done():
+		m.finish(j, ctx.Err())
+		return
+	}
+	defer func() { <-m.slots }()
+
+	m.mu.Lock()
+	now := time.Now()
+	j.Status = Running
+	j.StartedAt = &now
+	m.mu.Unlock()
+
+	m.finish(j, m.call(ctx, j, fn))
+}
+
+// call runs a job's work, turning a panic into an error so that one failing job does
+// not take the server down
+func (m *Manager) call(ctx context.Context, j *job, fn Func) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("panic running job %s: %v\n%s", j.ID, recovered, debug.Stack())
+			err = fmt.Errorf("job panicked: %v", recovered)
+		}
+	}()
+	return fn(ctx, &Progress{m: m, j: j})
+}
+
+// finish records how a job ended
+func (m *Manager) finish(j *job, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	j.FinishedAt = &now
+	j.Cancelling = false
+	switch {
+	case err == nil:
+		j.Status = Completed
+	case errors.Is(err, context.Canceled):
+		j.Status = Cancelled
+	default:
+		j.Status = Failed
+		j.Error = err.Error()
+	}
+}
+
+// Wait blocks until the job with the given ID has finished and returns its final snapshot
+func (m *Manager) Wait(id string) (Job, bool) {
+	m.mu.Lock()
+	j, exists := m.jobs[id]
+	m.mu.Unlock()
+	if !exists {
+		return Job{}, false
+	}
+	<-j.done
+	return m.Get(id)
+}
+
+// Cancel asks a queued or running job to stop. A queued job is cancelled right away; a
+// running one stops when its work next checks for cancellation.
+func (m *Manager) Cancel(id string) (Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, exists := m.jobs[id]
+	if !exists {
+		return Job{}, ErrNotFound
+	}
+	if j.Status != Queued && j.Status != Running {
+		return j.Job, ErrFinished
+	}
+	j.Cancelling = true
+	j.cancel()
+	return j.Job, nil
+}
+
+// Get returns a snapshot of the job with the given ID
+func (m *Manager) Get(id string) (Job, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, exists := m.jobs[id]
+	if !exists {
+		return Job{}, false
+	}
+	return j.Job, true
+}
+
+// List returns snapshots of all jobs, newest first
+func (m *Manager) List() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prune()
+	list := make([]Job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		list = append(list, j.Job)
+	}
+	sort.Slice(list, func(i, k int) bool { return list[i].CreatedAt.After(list[k].CreatedAt) })
+	return list
+}
+
+// Active counts the queued and running jobs of the given kinds
+func (m *Manager) Active(kinds ...string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, j := range m.jobs {
+		if j.Status != Queued && j.Status != Running {
+			continue
+		}
+		for _, kind := range kinds {
+			if j.Kind == kind {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// prune drops jobs that finished more than Retention ago; callers must hold mu
+func (m *Manager) prune() {
+	for id, j := range m.jobs {
+		if j.FinishedAt != nil && time.Since(*j.FinishedAt) > Retention {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// Progress lets a running job report how far along it is
+type Progress struct {
+	m *Manager
+	j *job
+}
+
+// SetTotal sets the number of units of work the job has
+func (p *Progress) SetTotal(total int) {
+	p.m.mu.Lock()
+	p.j.Total = total
+	p.m.mu.Unlock()
+}
+
+// Step records that one more unit of work is done
+func (p *Progress) Step() {
+	p.m.mu.Lock()
+	p.j.Done++
+	p.m.mu.Unlock()
+}
@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPanickingJobFails(t *testing.T) {
+	m := NewManager(1)
+	job := m.Start("test", "", func(ctx context.Context, progress *Progress) error {
+		var book map[string]int
+		book["title"] = 1 // Panics: assignment to entry in nil map
+		return nil
+	})
+
+	finished, _ := m.Wait(job.ID)
+	if finished.Status != Failed || finished.Error == "" {
+		t.Fatalf("status = %q, error = %q, want a failed job with an error", finished.Status, finished.Error)
+	}
+
+	// The worker slot is released, so later jobs still run
+	next := m.Start("test", "", func(ctx context.Context, progress *Progress) error { return nil })
+	done := make(chan Job)
+	go func() {
+		job, _ := m.Wait(next.ID)
+		done <- job
+	}()
+	select {
+	case job := <-done:
+		if job.Status != Completed {
+			t.Errorf("next job status = %q, want %q", job.Status, Completed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("next job did not run after a panicking one")
+	}
+}
+
+func TestCancelRunningJob(t *testing.T) {
+	m := NewManager(1)
+	started := make(chan struct{})
+	job := m.Start("test", "", func(ctx context.Context, progress *Progress) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	<-started
+
+	if _, err := m.Cancel(job.ID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if finished, _ := m.Wait(job.ID); finished.Status != Cancelled {
+		t.Errorf("status = %q, want %q", finished.Status, Cancelled)
+	}
+	if _, err := m.Cancel(job.ID); err != ErrFinished {
+		t.Errorf("second Cancel err = %v, want %v", err, ErrFinished)
+	}
+}
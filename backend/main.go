@@ -1,16 +1,26 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
 
 	"fableflow/backend/config"
+	"fableflow/backend/covercache"
 	"fableflow/backend/database"
 	"fableflow/backend/handlers"
 	"fableflow/backend/importservice"
+	"fableflow/backend/jobs"
+
+	"github.com/go-chi/chi/v5"
 )
 
 // corsMiddleware adds CORS headers to responses
@@ -18,7 +28,8 @@ func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-None-Match, X-API-Key")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -29,6 +40,143 @@ func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// readOnlyAllowed are the mutating requests, by method and route, that stay available in
+// read-only mode: POST endpoints that only look data up, share links that only sign a
+// download URL, and saving the reading position and reader settings while reading
+var readOnlyAllowed = map[string]bool{
+	"POST /api/books/batch":               true,
+	"POST /api/books/lookup-isbn":         true,
+	"POST /api/books/search-metadata":     true,
+	"POST /api/covers/check":              true,
+	"POST /api/books/{id}/share":          true,
+	"POST /api/books/{id}/progress":       true,
+	"PUT /api/books/{id}/reader-settings": true,
+	"PUT /api/reader-settings":            true,
+}
+
+// readOnlyRoute returns the method and route of r as keyed in readOnlyAllowed, with the
+// book ID of /api/books/{id}/... paths replaced by {id}
+func readOnlyRoute(r *http.Request) string {
+	route := r.URL.Path
+	if rest, found := strings.CutPrefix(route, "/api/books/"); found {
+		if _, action, found := strings.Cut(rest, "/"); found && !strings.Contains(action, "/") {
+			route = "/api/books/{id}/" + action
+		}
+	}
+	return r.Method + " " + route
+}
+
+// readOnlyMiddleware rejects mutating requests (edits, imports, conversions, scans, deletes)
+// with 403 while allowing browsing, downloads and the reader
+func readOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET", "HEAD", "OPTIONS":
+		default:
+			if !readOnlyAllowed[readOnlyRoute(r)] {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+				http.Error(w, "Server is in read-only mode", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// recoverMiddleware turns a panicking handler into a logged 500 response instead of a dropped connection
+func recoverMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			err := recover()
+			if err == nil {
+				return
+			}
+			if err == http.ErrAbortHandler {
+				panic(err) // Deliberate abort, let net/http handle it
+			}
+
+			log.Printf("panic serving %s %s: %v\n%s", r.Method, r.URL.Path, err, debug.Stack())
+			// If the handler already started the response this only appends to it
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]string{
+				"error": "Internal server error",
+			})
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// waitForLibrary polls until dir exists and is non-empty, so a network or removable volume
+// that mounts after startup is scanned once it appears. It gives up after timeout, or
+// when ctx is cancelled.
+func waitForLibrary(ctx context.Context, dir string, timeout time.Duration) {
+	if database.LibraryAvailable(dir) {
+		return
+	}
+
+	log.Printf("Library directory %s is missing or empty, waiting up to %v for it to be mounted", dir, timeout)
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(2 * time.Second):
+		}
+		if database.LibraryAvailable(dir) {
+			log.Printf("Library directory %s is available", dir)
+			return
+		}
+	}
+	log.Printf("Library directory %s still missing or empty after %v, scanning anyway", dir, timeout)
+}
+
+// bookRoutes routes /api/books, everything under /api/books/{id} and the EPUB
+// contents under /api/epub; handlers read their path parameters with PathValue. Fixed
+// paths such as /api/books/recent win over {id}.
+func bookRoutes(booksHandler *handlers.BooksHandler, coversHandler *handlers.CoversHandler) *chi.Mux {
+	mux := chi.NewRouter()
+	mux.HandleFunc("/api/books", func(w http.ResponseWriter, r *http.Request) {
+		// Inlined cover thumbnails come from the cover cache
+		if r.URL.Query().Get("embed_covers") != "" {
+			coversHandler.GetBooksWithCovers(w, r)
+			return
+		}
+		booksHandler.GetAllBooks(w, r)
+	})
+	// Deleting by filter takes a confirmation token from a first request. It comes after
+	// the route for any method, which would replace it otherwise.
+	mux.Delete("/api/books", booksHandler.BulkDeleteBooks)
+	mux.HandleFunc("/api/books/batch", corsMiddleware(booksHandler.GetBooksBatch))
+	mux.HandleFunc("/api/books/by-uid/*", corsMiddleware(booksHandler.GetBookByUID))
+	mux.HandleFunc("/api/books/recent", corsMiddleware(booksHandler.GetRecentBooks))
+	mux.HandleFunc("/api/books/random", corsMiddleware(booksHandler.GetRandomBooks))
+	mux.HandleFunc("/api/books/lookup-isbn", corsMiddleware(booksHandler.LookupISBN))
+	mux.HandleFunc("/api/books/preview-path", corsMiddleware(booksHandler.PreviewPath))
+	mux.HandleFunc("/api/books/path-mismatches", corsMiddleware(booksHandler.GetPathMismatches))
+	mux.HandleFunc("/api/books/search-metadata", corsMiddleware(booksHandler.SearchMetadata))
+	mux.HandleFunc("/api/books/{id}", booksHandler.GetBookByID)
+	mux.HandleFunc("/api/books/{id}/edit", booksHandler.EditBookMetadata)
+	mux.HandleFunc("/api/books/{id}/custom-fields", booksHandler.HandleCustomFields)
+	mux.HandleFunc("/api/books/{id}/reader-settings", booksHandler.HandleReaderSettings)
+	mux.HandleFunc("/api/books/{id}/share", booksHandler.CreateShareLink)
+	mux.HandleFunc("/api/books/{id}/restore-backup", booksHandler.RestoreBackup)
+	mux.HandleFunc("/api/books/{id}/files", booksHandler.GetBookFiles)
+	mux.HandleFunc("/api/books/{id}/formats", booksHandler.GetBookFormats)
+	mux.HandleFunc("/api/books/{id}/detect-format", booksHandler.DetectBookFormat)
+	mux.HandleFunc("/api/books/{id}/validate", booksHandler.ValidateBook)
+	mux.HandleFunc("/api/books/{id}/metadata-diff", booksHandler.GetMetadataDiff)
+	mux.HandleFunc("/api/books/{id}/progress", booksHandler.HandleReadingProgress)
+	mux.HandleFunc("/api/books/{id}/text", booksHandler.GetBookText)
+	mux.HandleFunc("/api/books/{id}/chapters", booksHandler.GetBookChapters)
+	// Page previews are rendered and cached alongside covers
+	mux.HandleFunc("/api/books/{id}/preview", coversHandler.ServePreview)
+	mux.HandleFunc("/api/books/{id}/cover-debug", coversHandler.GetCoverDebug)
+	mux.HandleFunc("/api/epub/{id}/*", corsMiddleware(booksHandler.ServeEPUBFile))
+	return mux
+}
+
 func main() {
 	// Parse command line flags
 	var configFile string
@@ -53,31 +201,114 @@ func main() {
 		log.Fatal("Failed to create database manager:", err)
 	}
 	defer db.Close()
+	db.SetUnknownAuthorPolicy(cfg.Library.UnknownAuthorPolicy)
+	db.SetUpdateExisting(cfg.Scan.UpdateExisting)
+	db.SetFollowSymlinks(cfg.Library.FollowSymlinks)
+	db.SetScanPDFs(cfg.Library.ScanPDFs)
+	db.SetScanKindle(cfg.Library.ScanKindle)
+	db.SetIgnored(cfg.Library.SkipHidden, cfg.Library.IgnoreNames)
+	db.SetUseFileMtime(cfg.Scan.UseFileMtime)
+	db.SetMaxRemovalPercent(cfg.Scan.MaxRemovalPercent)
+	db.SetCleanMetadata(cfg.Library.CleanMetadata)
+	db.SetFilenamePattern(cfg.Library.FilenamePattern)
+	db.SetSidecarMetadata(cfg.Library.SidecarMetadata)
+	if err := db.SetLeadingArticles(cfg.Library.LeadingArticles); err != nil {
+		log.Printf("Failed to update sort keys: %v", err)
+	}
 
 	// Ensure tmp directory exists and is clean
 	if err := os.MkdirAll(cfg.TmpDir, 0755); err != nil {
 		log.Fatal("Failed to create tmp directory:", err)
 	}
 
+	// Background operations run as jobs on a bounded number of workers
+	jobManager := jobs.NewManager(cfg.Jobs.Workers)
+
 	// Auto-scan if enabled
 	if cfg.Library.AutoScan {
 		log.Printf("Auto-scanning enabled, scanning: %s", cfg.Library.ScanDirectory)
-		go func() {
-			err := db.ScanDirectory(cfg.Library.ScanDirectory)
+		jobManager.Start("scan", "Auto-scan of "+cfg.Library.ScanDirectory, func(ctx context.Context, progress *jobs.Progress) error {
+			waitForLibrary(ctx, cfg.Library.ScanDirectory, time.Duration(cfg.Library.MountWaitSeconds)*time.Second)
+			err := db.ScanDirectory(ctx, cfg.Library.ScanDirectory)
 			if err != nil {
 				log.Printf("Auto-scan error: %v", err)
 			} else {
 				log.Printf("Auto-scan completed")
 			}
-		}()
+			return err
+		})
 	}
 
+	// Read the unique identifiers of EPUBs added before they were stored
+	jobManager.Start("backfill_uids", "Read unique identifiers of existing EPUBs", func(ctx context.Context, progress *jobs.Progress) error {
+		found, err := db.BackfillUIDs()
+		if err != nil {
+			log.Printf("Failed to read EPUB unique identifiers: %v", err)
+		} else if found > 0 {
+			log.Printf("Stored unique identifiers of %d existing books", found)
+		}
+		return err
+	})
+
+	// Read the series of EPUBs added before they were stored
+	jobManager.Start("backfill_series", "Read series of existing EPUBs", func(ctx context.Context, progress *jobs.Progress) error {
+		found, err := db.BackfillSeries(ctx)
+		if err != nil {
+			log.Printf("Failed to read EPUB series: %v", err)
+		} else if found > 0 {
+			log.Printf("Stored series of %d existing books", found)
+		}
+		return err
+	})
+
+	// Check books added before DRM detection
+	jobManager.Start("backfill_drm", "Check existing books for DRM", func(ctx context.Context, progress *jobs.Progress) error {
+		found, err := db.BackfillDRM(ctx)
+		if err != nil {
+			log.Printf("Failed to check existing books for DRM: %v", err)
+		} else if found > 0 {
+			log.Printf("Found %d existing DRM-protected books", found)
+		}
+		return err
+	})
+
+	// Read the publication dates of books added before they were stored
+	jobManager.Start("backfill_published_dates", "Read publication dates of existing books", func(ctx context.Context, progress *jobs.Progress) error {
+		found, err := db.BackfillPublishedDates(ctx)
+		if err != nil {
+			log.Printf("Failed to read publication dates: %v", err)
+		} else if found > 0 {
+			log.Printf("Stored publication years of %d existing books", found)
+		}
+		return err
+	})
+
 	// Create handlers
-	booksHandler := handlers.NewBooksHandler(db, cfg)
-	scanHandler := handlers.NewScanHandler(db)
-	healthHandler := handlers.NewHealthHandler()
-	conversionHandler := handlers.NewConversionHandler(db, cfg.TmpDir)
-	coversHandler := handlers.NewCoversHandler(db)
+	handlers.SetPrettyJSON(cfg.Server.PrettyJSON)
+	if err := handlers.CheckReaderTemplate(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	booksHandler := handlers.NewBooksHandler(db, jobManager, cfg)
+	opdsHandler := handlers.NewOPDSHandler(db, cfg)
+	healthHandler := handlers.NewHealthHandler(cfg.Server.ReadOnly, cfg.Library.ScanDirectory, cfg.Server.APIKey != "")
+	openAPIHandler := handlers.NewOpenAPIHandler()
+	conversionHandler := handlers.NewConversionHandler(db, jobManager, cfg.TmpDir, cfg.Conversion.MaxConcurrent)
+	conversionHandler.RequireAPIKey(cfg.Server.APIKey)
+	if cfg.Conversion.PersistentCache {
+		maxAge := time.Duration(cfg.Conversion.CacheMaxAgeHours) * time.Hour
+		if err := conversionHandler.EnablePersistentCache(maxAge, cfg.Conversion.CacheMaxBytes); err != nil {
+			log.Printf("Persistent conversion cache: %v", err)
+		}
+		conversionHandler.StartCacheJanitor(10 * time.Minute)
+	}
+
+	// Create thumbnail cache (disabled when covers.cache_max_bytes is 0)
+	coverCache, err := covercache.New(filepath.Join(cfg.TmpDir, "covers"), cfg.Covers.CacheMaxBytes, cfg.Covers.CacheMaxEntries)
+	if err != nil {
+		log.Printf("Cover cache disabled: %v", err)
+	}
+	coverCache.StartJanitor(10 * time.Minute)
+	coversHandler := handlers.NewCoversHandler(db, coverCache, cfg)
 
 	// Create import service with scan callback
 	importConfig := &importservice.Config{
@@ -86,58 +317,110 @@ func main() {
 		QuarantineDirectory: cfg.Library.QuarantineDirectory,
 		LogDir:              cfg.LogDir,
 		MaxLogs:             cfg.MaxImportLogs,
+		UnknownAuthorPolicy: cfg.Library.UnknownAuthorPolicy,
+		PathTemplate:        cfg.Library.PathTemplate,
+		AuthorDirStyle:      cfg.Library.AuthorDirStyle,
+		LeadingArticles:     cfg.Library.LeadingArticles,
+		TmpDir:              cfg.TmpDir,
+		MaxArchiveBytes:     cfg.Library.MaxArchiveBytes,
+		FollowSymlinks:      cfg.Library.FollowSymlinks,
+		SkipHidden:          cfg.Library.SkipHidden,
+		IgnoreNames:         cfg.Library.IgnoreNames,
+		MaxConcurrentScans:  cfg.Scan.MaxConcurrent,
+		DirMode:             cfg.LibraryDirMode(),
+		FileMode:            cfg.LibraryFileMode(),
+		PreserveMtime:       cfg.Scan.UseFileMtime,
+		OnConflict:          cfg.Library.ImportOnConflict,
+		RefreshBook:         db.RefreshBookFile,
+		Extractor:           db.Extractor(),
 	}
-	importService := importservice.NewImportService(importConfig, func() {
+	importService := importservice.NewImportService(importConfig, jobManager, func(ctx context.Context) error {
 		// Trigger database scan after import completes
 		log.Println("Import completed, triggering database scan...")
-		if err := db.ScanDirectory(cfg.Library.ScanDirectory); err != nil {
+		err := db.ScanDirectory(ctx, cfg.Library.ScanDirectory)
+		if err != nil {
 			log.Printf("Error scanning directory after import: %v", err)
 		} else {
 			log.Println("Database scan completed successfully")
 		}
+		return err
 	})
 	importHandler := handlers.NewImportHandler(importService)
+	jobsHandler := handlers.NewJobsHandler(jobManager)
+	scanHandler := handlers.NewScanHandler(db, cfg.Library.ScanDirectory, importService)
 
 	// Setup routes
 	http.HandleFunc("/api/health", healthHandler.HealthCheck)
-	http.HandleFunc("/api/books", booksHandler.GetAllBooks)
-	http.HandleFunc("/api/books/", booksHandler.GetBookByID)
-	http.HandleFunc("/api/books/recent", corsMiddleware(booksHandler.GetRecentBooks))
-	http.HandleFunc("/api/books/random", corsMiddleware(booksHandler.GetRandomBooks))
-	http.HandleFunc("/api/books/lookup-isbn", corsMiddleware(booksHandler.LookupISBN))
+	http.HandleFunc("/api/openapi.json", corsMiddleware(openAPIHandler.ServeSpec))
+	books := bookRoutes(booksHandler, coversHandler)
+	http.Handle("/api/books", books)
+	http.Handle("/api/books/", books)
+	http.Handle("/api/epub/", books)
+	http.HandleFunc("/api/home", corsMiddleware(booksHandler.GetHome))
+	http.HandleFunc("/api/feed/recent.atom", corsMiddleware(booksHandler.GetRecentFeed))
+	http.HandleFunc("/opds", corsMiddleware(opdsHandler.GetBooks))
+	http.HandleFunc("/opds/root", corsMiddleware(opdsHandler.GetRoot))
+	http.HandleFunc("/opds/recent", corsMiddleware(opdsHandler.GetRecent))
+	http.HandleFunc("/opds/authors", corsMiddleware(opdsHandler.GetAuthors))
+	http.HandleFunc("/opds/authors/books", corsMiddleware(opdsHandler.GetAuthorBooks))
 	http.HandleFunc("/api/quarantine", corsMiddleware(booksHandler.GetQuarantineBooks))
 	http.HandleFunc("/api/quarantine/edit", corsMiddleware(booksHandler.EditQuarantineBook))
+	http.HandleFunc("/api/quarantine/retry-all", corsMiddleware(importHandler.RetryQuarantine))
 	http.HandleFunc("/api/quarantine/covers/", booksHandler.ServeQuarantineCover)
-	http.HandleFunc("/api/books/search-metadata", corsMiddleware(booksHandler.SearchMetadata))
 	http.HandleFunc("/api/search", booksHandler.SearchBooks)
 	http.HandleFunc("/api/authors", booksHandler.GetAuthors)
 	http.HandleFunc("/api/authors/letter", booksHandler.GetAuthorsByLetter)
+	http.HandleFunc("/api/authors/letters", booksHandler.GetAuthorLetters)
 	http.HandleFunc("/api/authors/books", booksHandler.GetBooksByAuthor)
+	http.HandleFunc("/api/authors/info", corsMiddleware(booksHandler.GetAuthorInfo))
+	http.HandleFunc("/api/authors/merge", corsMiddleware(booksHandler.MergeAuthors))
+	http.HandleFunc("/api/publishers", booksHandler.GetPublishers)
+	http.HandleFunc("/api/publishers/letter", booksHandler.GetPublishersByLetter)
+	http.HandleFunc("/api/publishers/books", booksHandler.GetBooksByPublisher)
+	http.HandleFunc("/api/tags", corsMiddleware(booksHandler.GetTags))
+	http.HandleFunc("/api/tags/books", corsMiddleware(booksHandler.GetBooksByTag))
+	http.HandleFunc("/api/tags/bulk-apply", corsMiddleware(booksHandler.BulkApplyTag))
+	http.HandleFunc("/api/tags/bulk-remove", corsMiddleware(booksHandler.BulkRemoveTag))
+	http.HandleFunc("/api/reader-settings", corsMiddleware(booksHandler.HandleGlobalReaderSettings))
 	http.HandleFunc("/api/titles", booksHandler.GetTitles)
 	http.HandleFunc("/api/titles/letter", booksHandler.GetTitlesByLetter)
+	http.HandleFunc("/api/titles/letters", booksHandler.GetTitleLetters)
 	http.HandleFunc("/api/titles/books", booksHandler.GetBooksByTitle)
 	http.HandleFunc("/api/scan", scanHandler.ScanDirectory)
+	http.HandleFunc("/api/scan/file", corsMiddleware(scanHandler.ScanFile))
 	http.HandleFunc("/read/", booksHandler.ServeReader)
 	http.HandleFunc("/api/rescan", scanHandler.RescanDirectory)
 	http.HandleFunc("/api/download/", booksHandler.DownloadBook)
-	http.HandleFunc("/api/epub/", corsMiddleware(booksHandler.ServeEPUBFile))
 	http.HandleFunc("/api/convert/status", corsMiddleware(conversionHandler.GetConversionStatus))
+	http.HandleFunc("/api/convert/by-author", corsMiddleware(conversionHandler.ConvertByAuthor))
+	http.HandleFunc("/api/convert/by-shelf", corsMiddleware(conversionHandler.ConvertByShelf))
+	http.HandleFunc("/api/convert/batches/", corsMiddleware(conversionHandler.GetConversionBatch))
+	http.HandleFunc("/api/convert/job/", corsMiddleware(conversionHandler.GetConversionJob))
 	http.HandleFunc("/api/convert/", corsMiddleware(conversionHandler.DownloadConvertedBook))
 	http.HandleFunc("/api/convert", corsMiddleware(conversionHandler.ConvertBook))
+	http.HandleFunc("/api/covers/montage", corsMiddleware(coversHandler.ServeMontage))
+	http.HandleFunc("/api/covers/check", corsMiddleware(coversHandler.CheckCovers))
 	http.HandleFunc("/api/covers/", corsMiddleware(coversHandler.ServeCover))
 	http.HandleFunc("/api/import/start", corsMiddleware(importHandler.StartImport))
 	http.HandleFunc("/api/import/status", corsMiddleware(importHandler.GetImportStatus))
 	http.HandleFunc("/api/import/logs/list", corsMiddleware(importHandler.ListImportLogs))
 	http.HandleFunc("/api/import/logs/", corsMiddleware(importHandler.GetImportLog))
 	http.HandleFunc("/api/import/logs", corsMiddleware(importHandler.GetImportLogs))
+	http.HandleFunc("/api/jobs", corsMiddleware(jobsHandler.ListJobs))
+	http.HandleFunc("/api/jobs/", corsMiddleware(jobsHandler.GetJob))
 	http.HandleFunc("/api/library/stats", corsMiddleware(booksHandler.GetLibraryStats))
+	http.HandleFunc("/api/library/reorganize", corsMiddleware(booksHandler.ReorganizeLibrary))
+	http.HandleFunc("/api/library/cleanup-dirs", corsMiddleware(booksHandler.CleanupEmptyDirectories))
+	http.HandleFunc("/api/stats/by-year", corsMiddleware(booksHandler.GetBooksByYear))
+	http.HandleFunc("/api/stats/downloads", corsMiddleware(booksHandler.GetDownloadStats))
 
 	// API-only mode - return JSON response for root
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		// Add CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, If-None-Match, X-API-Key")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -161,5 +444,12 @@ func main() {
 	}())
 	fmt.Println("📖 API is ready to serve requests!")
 
-	log.Fatal(http.ListenAndServe(address, nil))
+	var handler http.Handler = http.DefaultServeMux
+	if cfg.Server.ReadOnly {
+		fmt.Println("🔒 Read-only mode: edits, imports, conversions and deletes are disabled")
+		handler = readOnlyMiddleware(handler)
+	}
+	handler = recoverMiddleware(handler)
+
+	log.Fatal(http.ListenAndServe(address, handler))
 }
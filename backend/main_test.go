@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fableflow/backend/config"
+	"fableflow/backend/database"
+	"fableflow/backend/handlers"
+	"fableflow/backend/jobs"
+	"fableflow/backend/models"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// newTestBookRoutes returns the book routes over an in-memory library holding one book
+func newTestBookRoutes(t *testing.T) *chi.Mux {
+	t.Helper()
+	db, err := database.NewManager(database.MemoryPath)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	book := models.BookRequest{
+		Title:    "Frankenstein",
+		Author:   "Mary Shelley",
+		FilePath: "/library/frankenstein.epub",
+		Format:   "epub",
+		UID:      "http://example.com/books/84",
+	}
+	if err := db.AddBook(book); err != nil {
+		t.Fatalf("AddBook: %v", err)
+	}
+
+	cfg := &config.Config{}
+	return bookRoutes(handlers.NewBooksHandler(db, jobs.NewManager(1), cfg), handlers.NewCoversHandler(db, nil, cfg))
+}
+
+func TestBookRoutes(t *testing.T) {
+	mux := newTestBookRoutes(t)
+	tests := []struct {
+		method, path, pattern string
+	}{
+		{"GET", "/api/books", "/api/books"},
+		{"DELETE", "/api/books", "/api/books"},
+		{"GET", "/api/books/recent", "/api/books/recent"},
+		{"POST", "/api/books/batch", "/api/books/batch"},
+		{"GET", "/api/books/42", "/api/books/{id}"},
+		{"GET", "/api/books/42/progress", "/api/books/{id}/progress"},
+		{"GET", "/api/books/by-uid/urn:uuid:1234", "/api/books/by-uid/*"},
+		{"GET", "/api/books/by-uid/edit", "/api/books/by-uid/*"},
+		{"GET", "/api/epub/42/OEBPS/chapter%201.xhtml", "/api/epub/{id}/*"},
+		{"GET", "/api/books/42/missing", ""},
+	}
+	for _, tt := range tests {
+		if pattern := mux.Find(chi.NewRouteContext(), tt.method, tt.path); pattern != tt.pattern {
+			t.Errorf("%s %s matched %q, want %q", tt.method, tt.path, pattern, tt.pattern)
+		}
+	}
+}
+
+func TestBookRoutesDocumented(t *testing.T) {
+	w := httptest.NewRecorder()
+	handlers.NewOpenAPIHandler().ServeSpec(w, httptest.NewRequest("GET", "/api/openapi.json", nil))
+	var spec struct {
+		Paths map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+
+	// Catch-all routes are documented with a named parameter
+	documented := map[string]string{
+		"/api/books/by-uid/*": "/api/books/by-uid/{uid}",
+		"/api/epub/{id}/*":    "/api/epub/{id}/{path}",
+	}
+	routes := map[string]bool{}
+	chi.Walk(newTestBookRoutes(t), func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		routes[route] = true
+		return nil
+	})
+	for route := range routes {
+		path := route
+		if named, ok := documented[route]; ok {
+			path = named
+		}
+		if spec.Paths[path] == nil {
+			t.Errorf("%s is routed but not in openapi.json", path)
+		}
+	}
+}
+
+func TestBookRoutesPathValues(t *testing.T) {
+	mux := newTestBookRoutes(t)
+	tests := []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{"GET", "/api/books", http.StatusOK, "Frankenstein"},
+		{"DELETE", "/api/books", http.StatusBadRequest, "Exactly one of author"},
+		{"GET", "/api/books/1", http.StatusOK, "Frankenstein"},
+		{"HEAD", "/api/books/1", http.StatusOK, ""},
+		{"GET", "/api/books/2", http.StatusNotFound, ""},
+		{"GET", "/api/books/abc", http.StatusBadRequest, "Invalid book ID"},
+		{"PUT", "/api/books/1", http.StatusMethodNotAllowed, ""},
+		{"GET", "/api/books/1/missing", http.StatusNotFound, ""},
+		// The rest of the path is the UID, slashes included, escaped or not
+		{"GET", "/api/books/by-uid/http://example.com/books/84", http.StatusOK, "Frankenstein"},
+		{"GET", "/api/books/by-uid/http:%2F%2Fexample.com%2Fbooks%2F84", http.StatusOK, "Frankenstein"},
+		{"GET", "/api/books/by-uid/", http.StatusBadRequest, "Invalid unique identifier"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if w.Code != tt.status || !strings.Contains(w.Body.String(), tt.body) {
+			t.Errorf("%s %s: status %d, body %q; want %d containing %q", tt.method, tt.path, w.Code, w.Body, tt.status, tt.body)
+		}
+	}
+}
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	handler := readOnlyMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tests := []struct {
+		method, path string
+		allowed      bool
+	}{
+		{"GET", "/api/books/1", true},
+		{"HEAD", "/api/download/1", true},
+		{"OPTIONS", "/api/books/1/edit", true},
+		{"POST", "/api/books/batch", true},
+		{"POST", "/api/covers/check", true},
+		{"POST", "/api/books/1/share", true},
+		{"POST", "/api/books/1/progress", true},
+		{"PUT", "/api/books/1/reader-settings", true},
+		{"PUT", "/api/reader-settings", true},
+		// The same routes with other methods change the library
+		{"DELETE", "/api/books/1/share", false},
+		{"DELETE", "/api/books/1/progress", false},
+		{"DELETE", "/api/books/batch", false},
+		{"DELETE", "/api/reader-settings", false},
+		{"PUT", "/api/books/1/edit", false},
+		{"DELETE", "/api/books/1", false},
+		{"POST", "/api/books/1/restore-backup", false},
+		{"POST", "/api/epub/1/share", false},
+		{"POST", "/api/books/1/files/progress", false},
+		{"POST", "/api/import/start", false},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+		if allowed := w.Code != http.StatusForbidden; allowed != tt.allowed {
+			t.Errorf("%s %s: status %d, want allowed %v", tt.method, tt.path, w.Code, tt.allowed)
+		}
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	jobManager := jobs.NewManager(1)
+	jobsHandler := handlers.NewJobsHandler(jobManager)
+	var jobID string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		var tempFiles map[string]string
+		tempFiles["key"] = "value" // Panics: assignment to entry in nil map
+	})
+	mux.HandleFunc("/abort", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	mux.HandleFunc("/job", func(w http.ResponseWriter, r *http.Request) {
+		job := jobManager.Start("test", "", func(ctx context.Context, progress *jobs.Progress) error {
+			var book map[string]int
+			book["title"] = 1 // Panics in the job's goroutine, outside any request
+			return nil
+		})
+		jobID = job.ID
+		jobManager.Wait(job.ID)
+	})
+	mux.HandleFunc("/api/jobs/", jobsHandler.GetJob)
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "still up")
+	})
+	server := httptest.NewServer(recoverMiddleware(mux))
+	defer server.Close()
+
+	get := func(path string) (*http.Response, string, error) {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return resp, string(body), err
+	}
+	stillUp := func(after string) {
+		t.Helper()
+		if resp, body, err := get("/ok"); err != nil || resp.StatusCode != http.StatusOK || body != "still up" {
+			t.Fatalf("after %s: GET /ok = %v, %q, %v", after, resp, body, err)
+		}
+	}
+
+	resp, body, err := get("/panic")
+	if err != nil {
+		t.Fatalf("GET /panic: %v", err)
+	}
+	var response map[string]string
+	if resp.StatusCode != http.StatusInternalServerError || json.Unmarshal([]byte(body), &response) != nil || response["error"] == "" {
+		t.Errorf("GET /panic = %d %q, want a 500 with a JSON error", resp.StatusCode, body)
+	}
+	stillUp("a panicking handler")
+
+	// A deliberate abort drops the connection without a response
+	if _, _, err := get("/abort"); err == nil {
+		t.Error("GET /abort got a response, want the connection dropped")
+	}
+	stillUp("an aborted handler")
+
+	if _, _, err := get("/job"); err != nil {
+		t.Fatalf("GET /job: %v", err)
+	}
+	var job jobs.Job
+	if _, body, err := get("/api/jobs/" + jobID); err != nil || json.Unmarshal([]byte(body), &job) != nil {
+		t.Fatalf("GET /api/jobs/%s = %q, %v", jobID, body, err)
+	}
+	if job.Status != jobs.Failed || !strings.Contains(job.Error, "panicked") {
+		t.Errorf("panicking job = %+v, want failed", job)
+	}
+	stillUp("a panicking job")
+}
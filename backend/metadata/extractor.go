@@ -2,13 +2,19 @@ package metadata
 
 import (
 	"archive/zip"
+	"errors"
 	"fmt"
 	"log"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
 	"fableflow/backend/conversion"
+	"fableflow/backend/epub"
+	"fableflow/backend/filemeta"
 )
 
 // Note: OPF and Metadata types are now imported from conversion package
@@ -16,36 +22,71 @@ import (
 // BookMetadata represents extracted book metadata
 type BookMetadata struct {
 	Title       string
-	Author      string
+	Author      string            // First creator, as displayed
+	Authors     []string          // Everyone credited as a creator, as "First Last"
+	Roles       map[string]string // MARC relator code of names in Authors not credited as "aut", e.g. "ill"
 	Publisher   string
 	Language    string
 	Description string
 	ISBN        string
 	Date        string
-	Subject     string
+	Subject     string   // First subject, kept for compatibility
+	Subjects    []string // All subjects (genres), without duplicates
 	Rights      string
+	DRM         bool
+	UID         string    // EPUB package unique identifier
+	Modified    time.Time // EPUB 3 dcterms:modified, zero when absent
+	Series      string    // Series the book belongs to, empty when none
+	SeriesIndex float64   // Position in the series, 0 when unknown
 }
 
+// Unknown author policies control what happens to books without a usable author
+const (
+	UnknownAuthorKeep     = "keep"     // Add the book under the "Unknown" author
+	UnknownAuthorSkip     = "skip"     // Do not add the book (imports quarantine it)
+	UnknownAuthorFilename = "filename" // Try parsing the filename (library.filename_pattern) before giving up
+)
+
+// UnknownAuthor is the placeholder used when no author could be extracted
+const UnknownAuthor = "Unknown"
+
 // Extractor handles metadata extraction from various ebook formats
-type Extractor struct{}
+type Extractor struct {
+	cleanText       bool            // Normalize extracted values with CleanText (library.clean_metadata)
+	sidecarMetadata bool            // Prefer metadata files next to the book (library.sidecar_metadata)
+	filenames       filemeta.Parser // Splits file names of books without usable metadata
+}
 
-// NewExtractor creates a new metadata extractor
+// NewExtractor creates a new metadata extractor that cleans the values it extracts
 func NewExtractor() *Extractor {
-	return &Extractor{}
+	return &Extractor{cleanText: true}
 }
 
-// ExtractMetadata extracts metadata from an ebook file
+// ExtractMetadata extracts metadata from an ebook file, preferring the fields of a
+// sidecar .opf or .json file next to it when library.sidecar_metadata is on
 func (e *Extractor) ExtractMetadata(filePath string) (*BookMetadata, error) {
 	ext := strings.ToLower(filepath.Ext(filePath))
 
+	var metadata *BookMetadata
+	var err error
 	switch ext {
 	case ".epub":
-		return e.extractEPUBMetadata(filePath)
+		metadata, err = e.extractEPUBMetadata(filePath)
 	case ".pdf":
-		return e.extractPDFMetadata(filePath)
+		metadata, err = e.extractPDFMetadata(filePath)
+	case ".mobi", ".azw", ".azw3":
+		metadata, err = e.extractMobiMetadata(filePath)
 	default:
 		return nil, fmt.Errorf("unsupported format: %s", ext)
 	}
+	if err != nil {
+		return nil, err
+	}
+
+	if e.sidecarMetadata {
+		e.applySidecarMetadata(filePath, metadata)
+	}
+	return metadata, nil
 }
 
 // extractEPUBMetadata extracts metadata from EPUB files using smart OPF finding
@@ -72,6 +113,10 @@ func (e *Extractor) extractEPUBMetadata(filePath string) (*BookMetadata, error)
 
 	// Convert to BookMetadata format
 	metadata := e.convertOPFToBookMetadata(opf)
+	metadata.DRM = epub.HasDRM(reader.File)
+	if uid, err := epub.PackageUniqueIdentifier(reader.File); err == nil {
+		metadata.UID = uid
+	}
 
 	// Fallback to filename if no title found
 	if metadata.Title == "" {
@@ -83,43 +128,191 @@ func (e *Extractor) extractEPUBMetadata(filePath string) (*BookMetadata, error)
 	return metadata, nil
 }
 
-// extractPDFMetadata extracts metadata from PDF files
-func (e *Extractor) extractPDFMetadata(filePath string) (*BookMetadata, error) {
-	// For now, PDF metadata extraction is not implemented
-	// Fallback to filename parsing
-	log.Printf("PDF metadata extraction not implemented, using filename parsing for: %s", filePath)
-	return e.ExtractFromFilename(filePath), nil
+// UniqueIdentifier returns the package unique identifier of an EPUB, or "" when it has
+// none or cannot be read
+func UniqueIdentifier(filePath string) string {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return ""
+	}
+	defer reader.Close()
+
+	uid, err := epub.PackageUniqueIdentifier(reader.File)
+	if err != nil {
+		return ""
+	}
+	return uid
 }
 
-// ExtractFromFilename is a fallback method that tries to parse metadata from filename
-func (e *Extractor) ExtractFromFilename(filePath string) *BookMetadata {
-	filename := filepath.Base(filePath)
-	nameWithoutExt := strings.TrimSuffix(filename, filepath.Ext(filename))
+// extractPDFMetadata extracts metadata from the document information dictionary of a
+// PDF. The Subject entry is a summary of the document, so it becomes the description,
+// and Keywords become subjects. Title and author fall back to filename parsing when
+// the PDF has no usable ones.
+func (e *Extractor) extractPDFMetadata(filePath string) (*BookMetadata, error) {
+	info, err := readPDFInfo(filePath)
+	if err != nil {
+		log.Printf("No PDF metadata in %s (%v), using filename parsing", filePath, err)
+		metadata := e.ExtractFromFilename(filePath)
+		metadata.DRM = errors.Is(err, errPDFEncrypted)
+		return metadata, nil
+	}
 
 	metadata := &BookMetadata{
-		Title:  nameWithoutExt,
-		Author: "Unknown",
+		Title:       e.CleanText(info["Title"]),
+		Author:      e.CleanText(info["Author"]),
+		Description: strings.TrimSpace(info["Subject"]),
+		Date:        pdfDate(info["CreationDate"]),
+	}
+	for _, keyword := range strings.FieldsFunc(info["Keywords"], func(r rune) bool { return r == ',' || r == ';' }) {
+		keyword = e.CleanText(keyword)
+		if keyword != "" && !containsFold(metadata.Subjects, keyword) {
+			metadata.Subjects = append(metadata.Subjects, keyword)
+		}
+	}
+	if len(metadata.Subjects) > 0 {
+		metadata.Subject = metadata.Subjects[0]
 	}
 
-	// Try to extract author and title from filename patterns
-	if strings.Contains(nameWithoutExt, " - ") {
-		// Remove any ID suffix (e.g., _1234)
-		baseName := nameWithoutExt
-		if lastUnderscore := strings.LastIndex(nameWithoutExt, "_"); lastUnderscore != -1 {
-			baseName = nameWithoutExt[:lastUnderscore]
+	if !usablePDFTitle(metadata.Title) {
+		fallback := e.ExtractFromFilename(filePath)
+		metadata.Title = fallback.Title
+		if IsUnknownAuthor(metadata.Author) {
+			metadata.Author = fallback.Author
 		}
+	}
+	if IsUnknownAuthor(metadata.Author) {
+		metadata.Author = UnknownAuthor
+	} else {
+		metadata.Authors = SplitAuthors(metadata.Author)
+	}
+
+	log.Printf("Extracted PDF metadata - Title: %s, Author: %s", metadata.Title, metadata.Author)
+	return metadata, nil
+}
 
-		// Split by " - " to get title and author
-		if strings.Contains(baseName, " - ") {
-			parts := strings.SplitN(baseName, " - ", 2)
-			if len(parts) == 2 {
-				metadata.Title = strings.TrimSpace(parts[0])
-				metadata.Author = strings.TrimSpace(parts[1])
+// extractMobiMetadata extracts metadata from the MOBI header and EXTH records of a
+// Kindle book. Old-style MOBI and KF8 (AZW3) files share these headers; the headers
+// of DRM-protected books are not encrypted, so their metadata is read all the same.
+func (e *Extractor) extractMobiMetadata(filePath string) (*BookMetadata, error) {
+	info, err := readMobiInfo(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MOBI header: %v", err)
+	}
+
+	metadata := &BookMetadata{
+		Title:       e.CleanText(info.first(exthTitle)),
+		Publisher:   e.CleanText(info.first(exthPublisher)),
+		Language:    e.CleanText(info.first(exthLanguage)),
+		Description: strings.TrimSpace(info.first(exthDescription)),
+		Date:        e.CleanText(info.first(exthPublishDate)),
+		Rights:      e.CleanText(info.first(exthRights)),
+		DRM:         info.Encrypted,
+	}
+	if metadata.Title == "" {
+		metadata.Title = e.CleanText(info.Title)
+	}
+	for _, author := range info.EXTH[exthAuthor] {
+		for _, name := range SplitAuthors(e.CleanText(author)) {
+			if !containsFold(metadata.Authors, name) {
+				metadata.Authors = append(metadata.Authors, name)
 			}
 		}
 	}
+	metadata.Author = e.CleanText(info.first(exthAuthor))
+	if IsUnknownAuthor(metadata.Author) {
+		metadata.Author = UnknownAuthor
+	}
+	for _, isbn := range info.EXTH[exthISBN] {
+		if isISBN(strings.TrimSpace(isbn)) {
+			metadata.ISBN = cleanISBN(isbn)
+			break
+		}
+	}
+	for _, subject := range info.EXTH[exthSubject] {
+		subject = e.CleanText(subject)
+		if subject != "" && !containsFold(metadata.Subjects, subject) {
+			metadata.Subjects = append(metadata.Subjects, subject)
+		}
+	}
+	if len(metadata.Subjects) > 0 {
+		metadata.Subject = metadata.Subjects[0]
+	}
 
-	return metadata
+	if metadata.Title == "" {
+		filename := filepath.Base(filePath)
+		metadata.Title = strings.TrimSuffix(filename, filepath.Ext(filename))
+	}
+
+	log.Printf("Extracted MOBI metadata - Title: %s, Author: %s", metadata.Title, metadata.Author)
+	return metadata, nil
+}
+
+// unusablePDFTitles are titles that word processors and PDF printers fill in themselves
+var unusablePDFTitles = regexp.MustCompile(`(?i)^(untitled|microsoft (word|powerpoint) - .*|.*\.(docx?|rtf|odt|pdf|indd|tex|dvi|ps|qxd))$`)
+
+// usablePDFTitle reports whether a PDF's Title entry names the book rather than the
+// file it was made from
+func usablePDFTitle(title string) bool {
+	return title != "" && !unusablePDFTitles.MatchString(title)
+}
+
+// SetFilenameParser sets how ExtractFromFilename splits file names
+// (library.filename_pattern)
+func (e *Extractor) SetFilenameParser(p filemeta.Parser) {
+	e.filenames = p
+}
+
+// FilenameParser returns the parser ExtractFromFilename splits file names with
+func (e *Extractor) FilenameParser() filemeta.Parser {
+	return e.filenames
+}
+
+// ExtractFromFilename is a fallback method that parses title and author from the
+// filename, following library.filename_pattern
+func (e *Extractor) ExtractFromFilename(filePath string) *BookMetadata {
+	title, author := e.filenames.Parse(filePath)
+	if author == "" {
+		author = UnknownAuthor
+	}
+
+	return &BookMetadata{
+		Title:  title,
+		Author: author,
+	}
+}
+
+// IsUnknownAuthor reports whether an author is missing or the "Unknown" placeholder
+func IsUnknownAuthor(author string) bool {
+	author = strings.TrimSpace(author)
+	return author == "" || strings.EqualFold(author, UnknownAuthor)
+}
+
+// ResolveUnknownAuthor applies an unknown author policy to extracted metadata.
+// It returns false when the policy says the book should not be added.
+func (e *Extractor) ResolveUnknownAuthor(filePath string, metadata *BookMetadata, policy string) bool {
+	if !IsUnknownAuthor(metadata.Author) {
+		return true
+	}
+
+	switch policy {
+	case UnknownAuthorSkip:
+		return false
+	case UnknownAuthorFilename:
+		fromFilename := e.ExtractFromFilename(filePath)
+		if IsUnknownAuthor(fromFilename.Author) {
+			return true // Nothing better in the filename, keep the placeholder
+		}
+		metadata.Author = fromFilename.Author
+
+		// If the title itself came from the filename, use the parsed title instead
+		filename := filepath.Base(filePath)
+		if metadata.Title == strings.TrimSuffix(filename, filepath.Ext(filename)) {
+			metadata.Title = fromFilename.Title
+		}
+		return true
+	default:
+		return true
+	}
 }
 
 // convertOPFToBookMetadata converts conversion package OPF to BookMetadata
@@ -128,38 +321,257 @@ func (e *Extractor) convertOPFToBookMetadata(opf *conversion.OPF) *BookMetadata
 
 	// Extract metadata from OPF
 	if len(opf.Metadata.Title) > 0 {
-		metadata.Title = strings.TrimSpace(opf.Metadata.Title[0])
+		metadata.Title = e.CleanText(opf.Metadata.Title[0])
 	}
 	if len(opf.Metadata.Creator) > 0 {
-		metadata.Author = strings.TrimSpace(opf.Metadata.Creator[0])
+		metadata.Author = e.CleanText(opf.Metadata.Creator[0].Name)
+	}
+	for _, creator := range opf.Metadata.Creator {
+		role := creatorRole(opf, creator)
+		for _, name := range SplitAuthors(e.CleanText(creator.Name)) {
+			if containsFold(metadata.Authors, name) {
+				continue
+			}
+			metadata.Authors = append(metadata.Authors, name)
+			if role != "" && role != "aut" {
+				if metadata.Roles == nil {
+					metadata.Roles = make(map[string]string)
+				}
+				metadata.Roles[name] = role
+			}
+		}
 	}
 	if len(opf.Metadata.Publisher) > 0 {
-		metadata.Publisher = strings.TrimSpace(opf.Metadata.Publisher[0])
+		metadata.Publisher = e.CleanText(opf.Metadata.Publisher[0])
 	}
 	if len(opf.Metadata.Language) > 0 {
-		metadata.Language = strings.TrimSpace(opf.Metadata.Language[0])
+		metadata.Language = e.CleanText(opf.Metadata.Language[0])
 	}
 	if len(opf.Metadata.Description) > 0 {
 		metadata.Description = strings.TrimSpace(opf.Metadata.Description[0])
 	}
 	if len(opf.Metadata.Date) > 0 {
-		metadata.Date = strings.TrimSpace(opf.Metadata.Date[0])
+		metadata.Date = e.CleanText(opf.Metadata.Date[0])
+	}
+	for _, subject := range opf.Metadata.Subject {
+		subject = e.CleanText(subject)
+		if subject == "" || containsFold(metadata.Subjects, subject) {
+			continue
+		}
+		metadata.Subjects = append(metadata.Subjects, subject)
 	}
-	if len(opf.Metadata.Subject) > 0 {
-		metadata.Subject = strings.TrimSpace(opf.Metadata.Subject[0])
+	if len(metadata.Subjects) > 0 {
+		metadata.Subject = metadata.Subjects[0]
 	}
 	if len(opf.Metadata.Rights) > 0 {
-		metadata.Rights = strings.TrimSpace(opf.Metadata.Rights[0])
+		metadata.Rights = e.CleanText(opf.Metadata.Rights[0])
 	}
+	metadata.Modified = opfModified(opf)
+	metadata.Series, metadata.SeriesIndex = e.opfSeries(opf)
 
 	// Fallback to "Unknown" if no author found
 	if metadata.Author == "" {
-		metadata.Author = "Unknown"
+		metadata.Author = UnknownAuthor
 	}
 
 	return metadata
 }
 
+// opfModified returns the dcterms:modified date of an OPF in UTC, or the zero time
+func opfModified(opf *conversion.OPF) time.Time {
+	for _, meta := range opf.Metadata.Meta {
+		if meta.Property != "dcterms:modified" {
+			continue
+		}
+		value := strings.TrimSpace(meta.Value)
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+			if modified, err := time.Parse(layout, value); err == nil {
+				return modified.UTC()
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// creatorRole returns the lowercased MARC relator code of a creator, from its opf:role
+// attribute or an EPUB 3 role meta refining it, or "" when not given
+func creatorRole(opf *conversion.OPF, creator conversion.Creator) string {
+	if role := strings.TrimSpace(creator.Role); role != "" {
+		return strings.ToLower(role)
+	}
+	if creator.ID == "" {
+		return ""
+	}
+	for _, meta := range opf.Metadata.Meta {
+		if meta.Property == "role" && meta.Refines == "#"+creator.ID {
+			return strings.ToLower(strings.TrimSpace(meta.Value))
+		}
+	}
+	return ""
+}
+
+// opfSeries returns the series of an OPF and the book's position in it, from Calibre's
+// calibre:series and calibre:series_index metadata or an EPUB 3 belongs-to-collection
+// of the series type
+func (e *Extractor) opfSeries(opf *conversion.OPF) (string, float64) {
+	var series, index string
+	for _, meta := range opf.Metadata.Meta {
+		switch meta.Name {
+		case "calibre:series":
+			series = meta.Content
+		case "calibre:series_index":
+			index = meta.Content
+		}
+	}
+
+	if e.CleanText(series) == "" {
+		for _, collection := range opf.Metadata.Meta {
+			if collection.Property != "belongs-to-collection" || e.CleanText(collection.Value) == "" {
+				continue
+			}
+			collectionType, position := "", ""
+			for _, meta := range opf.Metadata.Meta {
+				if collection.ID == "" || meta.Refines != "#"+collection.ID {
+					continue
+				}
+				switch meta.Property {
+				case "collection-type":
+					collectionType = strings.TrimSpace(meta.Value)
+				case "group-position":
+					position = meta.Value
+				}
+			}
+			// Collections without a type are usually series too
+			if collectionType == "" || collectionType == "series" {
+				series, index = collection.Value, position
+				break
+			}
+		}
+	}
+
+	series = e.CleanText(series)
+	if series == "" {
+		return "", 0
+	}
+	seriesIndex, err := strconv.ParseFloat(strings.TrimSpace(index), 64)
+	if err != nil || seriesIndex < 0 {
+		seriesIndex = 0
+	}
+	return series, seriesIndex
+}
+
+// EPUBSeries returns the series of an EPUB and the book's position in it, or "" when it
+// has none or cannot be read
+func (e *Extractor) EPUBSeries(filePath string) (string, float64) {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return "", 0
+	}
+	defer reader.Close()
+
+	parser := conversion.NewEPUBParser()
+	opfFile, err := parser.FindOPFFile(reader)
+	if err != nil {
+		return "", 0
+	}
+	opf, err := parser.ParseOPF(opfFile)
+	if err != nil {
+		return "", 0
+	}
+	return e.opfSeries(opf)
+}
+
+// EPUBModified returns the dcterms:modified date of an EPUB, or the zero time when it has
+// none or cannot be read
+func EPUBModified(filePath string) time.Time {
+	reader, err := zip.OpenReader(filePath)
+	if err != nil {
+		return time.Time{}
+	}
+	defer reader.Close()
+
+	parser := conversion.NewEPUBParser()
+	opfFile, err := parser.FindOPFFile(reader)
+	if err != nil {
+		return time.Time{}
+	}
+	opf, err := parser.ParseOPF(opfFile)
+	if err != nil {
+		return time.Time{}
+	}
+	return opfModified(opf)
+}
+
+// containsFold reports whether values contains s, ignoring case
+func containsFold(values []string, s string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseYear extracts the year from a publication date such as "1997" or "1997-05-01".
+// It returns 0 if the date doesn't start with a four-digit year.
+func ParseYear(date string) int {
+	date = strings.TrimSpace(date)
+	if len(date) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(date[:4])
+	if err != nil || year <= 0 {
+		return 0
+	}
+	return year
+}
+
+// DefaultLeadingArticles are ignored at the start of titles and authors when sorting
+var DefaultLeadingArticles = []string{"the", "a", "an"}
+
+// SortKey returns value without a leading article, so "The Hobbit" sorts as "Hobbit".
+// Articles ending in an apostrophe (e.g. "l'") match without a following space.
+// The value is returned unchanged when stripping would leave nothing.
+func SortKey(value string, articles []string) string {
+	value = strings.TrimSpace(value)
+	for _, article := range articles {
+		article = strings.ToLower(strings.TrimSpace(article))
+		if article == "" {
+			continue
+		}
+		prefix := article + " "
+		if strings.HasSuffix(article, "'") {
+			prefix = article
+		}
+		if len(value) > len(prefix) && strings.EqualFold(value[:len(prefix)], prefix) {
+			if rest := strings.TrimSpace(value[len(prefix):]); rest != "" {
+				return rest
+			}
+		}
+	}
+	return value
+}
+
+// AuthorSortKey is SortKey for an author's name. A leading word followed by an initial
+// ("A J Smith") is a given name, and in a name written surname first ("Le Carré, John")
+// it belongs to the surname, so neither is stripped as an article.
+func AuthorSortKey(author string, articles []string) string {
+	author = strings.TrimSpace(author)
+	if strings.Contains(author, ",") {
+		return author
+	}
+	if words := strings.Fields(author); len(words) > 1 && isInitial(words[1]) {
+		return author
+	}
+	return SortKey(author, articles)
+}
+
+// isInitial reports whether a word of a name is an initial, such as "J" or "J."
+func isInitial(word string) bool {
+	letters := []rune(strings.TrimSuffix(word, "."))
+	return len(letters) == 1 && unicode.IsLetter(letters[0])
+}
+
 // isISBN checks if a string looks like an ISBN number
 func isISBN(identifier string) bool {
 	// Remove common prefixes and clean the string
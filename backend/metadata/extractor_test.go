@@ -0,0 +1,78 @@
+package metadata
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSortKey(t *testing.T) {
+	tests := []struct {
+		value, want string
+	}{
+		{"The Hobbit", "Hobbit"},
+		{"the hobbit", "hobbit"},
+		{"A Tale of Two Cities", "Tale of Two Cities"},
+		{"An Instance of the Fingerpost", "Instance of the Fingerpost"},
+		{"Dune", "Dune"},
+		{"Theodore Boone", "Theodore Boone"},
+		{"Another Country", "Another Country"},
+		{"The", "The"},
+		{"  The Road  ", "Road"},
+	}
+	for _, tt := range tests {
+		if got := SortKey(tt.value, DefaultLeadingArticles); got != tt.want {
+			t.Errorf("SortKey(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+
+	if got := SortKey("L'Étranger", []string{"l'"}); got != "Étranger" {
+		t.Errorf("SortKey with an elided article = %q, want %q", got, "Étranger")
+	}
+	if got := SortKey("The Hobbit", nil); got != "The Hobbit" {
+		t.Errorf("SortKey without articles = %q, want the title unchanged", got)
+	}
+}
+
+func TestAuthorSortKey(t *testing.T) {
+	articles := []string{"the", "a", "an", "le"}
+	tests := []struct {
+		author, want string
+	}{
+		{"The Beatles", "Beatles"},
+		{"Jane Austen", "Jane Austen"},
+		{"A J Smith", "A J Smith"},
+		{"A. J. Smith", "A. J. Smith"},
+		{"A J. Smith", "A J. Smith"},
+		{"Le Carré, John", "Le Carré, John"},
+	}
+	for _, tt := range tests {
+		if got := AuthorSortKey(tt.author, articles); got != tt.want {
+			t.Errorf("AuthorSortKey(%q) = %q, want %q", tt.author, got, tt.want)
+		}
+	}
+
+	// The {initial} of a library path comes from the author's sort key
+	if got := RenderPathTemplate("{initial}/{author}/{title}", "A J Smith", "Stories", "epub", articles, "as_is"); got != "A/A J Smith/Stories.epub" {
+		t.Errorf("RenderPathTemplate = %q, want it filed under A", got)
+	}
+}
+
+func TestExtractEPUBSubjects(t *testing.T) {
+	metadata, err := NewExtractor().ExtractMetadata(filepath.Join("testdata", "subjects.epub"))
+	if err != nil {
+		t.Fatalf("ExtractMetadata: %v", err)
+	}
+	if metadata.Title != "The Time Machine" || metadata.Author != "H. G. Wells" {
+		t.Errorf("read %q by %q", metadata.Title, metadata.Author)
+	}
+
+	// Blank and repeated subjects are dropped, whatever their case
+	want := []string{"Science Fiction", "Time travel -- Fiction", "Dystopias & utopias"}
+	if !reflect.DeepEqual(metadata.Subjects, want) {
+		t.Errorf("Subjects = %q, want %q", metadata.Subjects, want)
+	}
+	if metadata.Subject != want[0] {
+		t.Errorf("Subject = %q, want the first one, %q", metadata.Subject, want[0])
+	}
+}
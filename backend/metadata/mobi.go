@@ -0,0 +1,136 @@
+package metadata
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// EXTH record types read from MOBI/AZW3 headers
+const (
+	exthAuthor      = 100
+	exthPublisher   = 101
+	exthDescription = 103
+	exthISBN        = 104
+	exthSubject     = 105
+	exthPublishDate = 106
+	exthRights      = 109
+	exthTitle       = 503 // Updated title, preferred over the full name
+	exthLanguage    = 524
+)
+
+// mobiEncodingUTF8 is the MOBI header text encoding of UTF-8 books; the other one in
+// use is 1252 (Windows-1252)
+const mobiEncodingUTF8 = 65001
+
+// mobiInfo is the metadata found in the headers of a MOBI/AZW3 file
+type mobiInfo struct {
+	Title     string
+	Encrypted bool
+	EXTH      map[uint32][]string // Values of each EXTH record type, in file order
+}
+
+// readMobiInfo reads the PalmDOC and MOBI headers in the first record of a Palm
+// database (PDB) file and the EXTH records that follow them
+func readMobiInfo(path string) (*mobiInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// PDB header: name, attributes and dates up to the type/creator at 60, the number of
+	// records at 76 and the record list from 78, 8 bytes per record
+	header := make([]byte, 90)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, fmt.Errorf("not a MOBI file: %v", err)
+	}
+	if string(header[60:68]) != "BOOKMOBI" {
+		return nil, errors.New("not a MOBI file")
+	}
+	if binary.BigEndian.Uint16(header[76:78]) < 2 {
+		return nil, errors.New("MOBI file has no records")
+	}
+	start := int64(binary.BigEndian.Uint32(header[78:82]))
+	end := int64(binary.BigEndian.Uint32(header[86:90]))
+	if end <= start {
+		// Some files leave the record list unsorted; the first record is small anyway
+		end = start + 64*1024
+	}
+	if end-start > 1<<20 {
+		end = start + 1<<20
+	}
+	record := make([]byte, end-start)
+	n, err := file.ReadAt(record, start)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return parseMobiRecord(record[:n], strings.TrimRight(string(header[:32]), "\x00"))
+}
+
+// parseMobiRecord parses the first record of a MOBI file. The PDB name is the title of
+// last resort, since it is limited to 31 characters.
+func parseMobiRecord(record []byte, pdbName string) (*mobiInfo, error) {
+	// The 16-byte PalmDOC header has the encryption type at 12, then the MOBI header
+	// follows with its length, the text encoding, the full name and the EXTH flags
+	if len(record) < 0x84 || string(record[16:20]) != "MOBI" {
+		return nil, errors.New("MOBI header not found")
+	}
+	info := &mobiInfo{
+		Encrypted: binary.BigEndian.Uint16(record[12:14]) != 0,
+		EXTH:      make(map[uint32][]string),
+	}
+	headerLength := int(binary.BigEndian.Uint32(record[20:24]))
+	utf8Text := binary.BigEndian.Uint32(record[28:32]) == mobiEncodingUTF8
+	decode := func(value []byte) string {
+		if utf8Text {
+			return string(value)
+		}
+		decoded, err := charmap.Windows1252.NewDecoder().Bytes(value)
+		if err != nil {
+			return string(value)
+		}
+		return string(decoded)
+	}
+
+	nameOffset := int(binary.BigEndian.Uint32(record[0x54:0x58]))
+	nameLength := int(binary.BigEndian.Uint32(record[0x58:0x5c]))
+	if nameOffset > 0 && nameLength > 0 && nameOffset+nameLength <= len(record) {
+		info.Title = decode(record[nameOffset : nameOffset+nameLength])
+	} else {
+		info.Title = pdbName
+	}
+
+	if binary.BigEndian.Uint32(record[0x80:0x84])&0x40 == 0 {
+		return info, nil
+	}
+	exth := record[min(16+headerLength, len(record)):]
+	if len(exth) < 12 || string(exth[:4]) != "EXTH" {
+		return info, nil
+	}
+	count := int(binary.BigEndian.Uint32(exth[8:12]))
+	pos := 12
+	for i := 0; i < count && pos+8 <= len(exth); i++ {
+		recordType := binary.BigEndian.Uint32(exth[pos : pos+4])
+		length := int(binary.BigEndian.Uint32(exth[pos+4 : pos+8]))
+		if length < 8 || pos+length > len(exth) {
+			break
+		}
+		info.EXTH[recordType] = append(info.EXTH[recordType], decode(exth[pos+8:pos+length]))
+		pos += length
+	}
+	return info, nil
+}
+
+// first returns the first value of an EXTH record type, or ""
+func (info *mobiInfo) first(recordType uint32) string {
+	if values := info.EXTH[recordType]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
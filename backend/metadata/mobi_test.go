@@ -0,0 +1,122 @@
+package metadata
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var sampleMobi = filepath.Join("testdata", "sample.mobi")
+
+// sampleMobiRecord returns a copy of the first record of the sample MOBI file
+func sampleMobiRecord(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile(sampleMobi)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end := binary.BigEndian.Uint32(data[78:82]), binary.BigEndian.Uint32(data[86:90])
+	return append([]byte{}, data[start:end]...)
+}
+
+func TestReadMobiInfo(t *testing.T) {
+	info, err := readMobiInfo(sampleMobi)
+	if err != nil {
+		t.Fatalf("readMobiInfo: %v", err)
+	}
+	if info.Title != "Frankenstein" || info.Encrypted {
+		t.Errorf("read %q, encrypted %v; want the full name, unencrypted", info.Title, info.Encrypted)
+	}
+
+	// Repeated records keep their order; Windows-1252 text is decoded
+	want := map[uint32][]string{
+		exthAuthor:      {"Mary Shelley", "René Dupont"},
+		exthPublisher:   {"Fableflow Press"},
+		exthDescription: {"A short book used to check MOBI metadata."},
+		exthISBN:        {"978-0-14-143947-1"},
+		exthSubject:     {"Fiction", "Horror"},
+		exthPublishDate: {"2018-01-01"},
+		exthRights:      {"Public domain"},
+		exthTitle:       {"Frankenstein; or, The Modern Prometheus"},
+		exthLanguage:    {"en"},
+	}
+	if !reflect.DeepEqual(info.EXTH, want) {
+		t.Errorf("EXTH = %q, want %q", info.EXTH, want)
+	}
+
+	if _, err := readMobiInfo(filepath.Join("testdata", "subjects.epub")); err == nil {
+		t.Error("readMobiInfo accepted an EPUB")
+	}
+}
+
+func TestParseMobiRecord(t *testing.T) {
+	tests := []struct {
+		name      string
+		modify    func(record []byte) []byte
+		title     string
+		encrypted bool
+		exth      int
+	}{
+		{"unchanged", func(record []byte) []byte { return record }, "Frankenstein", false, 9},
+		{"encrypted", func(record []byte) []byte {
+			binary.BigEndian.PutUint16(record[12:14], 2)
+			return record
+		}, "Frankenstein", true, 9},
+		{"no full name", func(record []byte) []byte {
+			binary.BigEndian.PutUint32(record[0x54:0x58], 0)
+			return record
+		}, "Short Name", false, 9},
+		{"full name past the record", func(record []byte) []byte {
+			binary.BigEndian.PutUint32(record[0x54:0x58], uint32(len(record)))
+			return record
+		}, "Short Name", false, 9},
+		{"no EXTH flag", func(record []byte) []byte {
+			binary.BigEndian.PutUint32(record[0x80:0x84], 0)
+			return record
+		}, "Frankenstein", false, 0},
+		{"EXTH record past the record", func(record []byte) []byte {
+			// The last record, the language, claims to run past the end
+			language := bytes.LastIndex(record, []byte{0, 0, 0x02, 0x0c})
+			binary.BigEndian.PutUint32(record[language+4:language+8], uint32(len(record)))
+			return record
+		}, "Frankenstein", false, 8},
+	}
+	for _, tt := range tests {
+		info, err := parseMobiRecord(tt.modify(sampleMobiRecord(t)), "Short Name")
+		if err != nil {
+			t.Errorf("%s: parseMobiRecord: %v", tt.name, err)
+			continue
+		}
+		if info.Title != tt.title || info.Encrypted != tt.encrypted || len(info.EXTH) != tt.exth {
+			t.Errorf("%s: read %q, encrypted %v, %d EXTH types; want %q, %v, %d", tt.name, info.Title, info.Encrypted, len(info.EXTH), tt.title, tt.encrypted, tt.exth)
+		}
+	}
+
+	record := sampleMobiRecord(t)
+	copy(record[16:20], "XXXX")
+	if _, err := parseMobiRecord(record, ""); err == nil {
+		t.Error("parseMobiRecord accepted a record without a MOBI header")
+	}
+	if _, err := parseMobiRecord(sampleMobiRecord(t)[:0x80], ""); err == nil {
+		t.Error("parseMobiRecord accepted a truncated header")
+	}
+}
+
+func TestExtractMobiMetadata(t *testing.T) {
+	metadata, err := NewExtractor().ExtractMetadata(sampleMobi)
+	if err != nil {
+		t.Fatalf("ExtractMetadata: %v", err)
+	}
+	if metadata.Title != "Frankenstein; or, The Modern Prometheus" || metadata.Author != "Mary Shelley" {
+		t.Errorf("read %q by %q; want the EXTH title by the first author", metadata.Title, metadata.Author)
+	}
+	if want := []string{"Mary Shelley", "René Dupont"}; !reflect.DeepEqual(metadata.Authors, want) {
+		t.Errorf("Authors = %q, want %q", metadata.Authors, want)
+	}
+	if metadata.ISBN != "9780141439471" || metadata.Language != "en" || metadata.Publisher != "Fableflow Press" {
+		t.Errorf("ISBN %q, language %q, publisher %q", metadata.ISBN, metadata.Language, metadata.Publisher)
+	}
+}
@@ -0,0 +1,179 @@
+package metadata
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultPathTemplate lays books out as Author/Title/Title - Author.ext
+const DefaultPathTemplate = "{author}/{title}/{title} - {author}"
+
+// CleanPathComponent removes characters that are invalid in file and directory names.
+// An empty result becomes "Unknown".
+func CleanPathComponent(s string) string {
+	// Remove or replace invalid characters
+	invalid := []string{"/", "\\", ":", "*", "?", "\"", "<", ">", "|"}
+	result := s
+	for _, char := range invalid {
+		result = strings.ReplaceAll(result, char, "")
+	}
+
+	// Trim whitespace
+	result = strings.TrimSpace(result)
+
+	// Ensure it's not empty
+	if result == "" {
+		result = "Unknown"
+	}
+
+	return result
+}
+
+// RenderPathTemplate builds a book's path relative to the library root from a template.
+// Directories are separated by "/"; the placeholders {author}, {title} and {initial}
+// (the first letter of the author's sort key) are replaced with filesystem-safe values,
+// and ".format" is appended. In directory names {author} and {initial} use the filing
+// author given by authorDirStyle (see FilingAuthor); the file name keeps the author as
+// displayed. An empty template uses DefaultPathTemplate.
+func RenderPathTemplate(template, author, title, format string, articles []string, authorDirStyle string) string {
+	if strings.TrimSpace(template) == "" {
+		template = DefaultPathTemplate
+	}
+
+	cleanAuthor := CleanPathComponent(author)
+	cleanTitle := CleanPathComponent(title)
+	dirAuthor := CleanPathComponent(FilingAuthor(author, authorDirStyle))
+	initial := "#"
+	if r, _ := utf8.DecodeRuneInString(AuthorSortKey(dirAuthor, articles)); unicode.IsLetter(r) {
+		initial = string(unicode.ToUpper(r))
+	}
+	dirReplacer := strings.NewReplacer("{author}", dirAuthor, "{title}", cleanTitle, "{initial}", initial)
+	fileReplacer := strings.NewReplacer("{author}", cleanAuthor, "{title}", cleanTitle, "{initial}", initial)
+
+	var parts []string
+	segments := strings.Split(template, "/")
+	for i, segment := range segments {
+		if i == len(segments)-1 {
+			segment = fileReplacer.Replace(segment)
+		} else {
+			segment = dirReplacer.Replace(segment)
+		}
+		segment = strings.TrimSpace(segment)
+		if segment == "" || segment == "." || segment == ".." {
+			continue
+		}
+		parts = append(parts, segment)
+	}
+	if len(parts) == 0 {
+		parts = []string{cleanTitle}
+	}
+
+	return filepath.Join(parts...) + "." + format
+}
+
+// Author directory styles (library.author_dir_style) control how the author is filed
+// in directory names
+const (
+	AuthorDirAsIs      = "as_is"      // The author exactly as displayed
+	AuthorDirFirst     = "first"      // The first author as "First Last"
+	AuthorDirLastFirst = "last_first" // The first author as "Last, First"
+)
+
+// authorSeparators split an author string listing several people
+var authorSeparators = regexp.MustCompile(`\s*(?:;|&|\band\b|\bwith\b)\s*`)
+
+// surnameParticles may precede a surname, as in "Le Guin, Ursula K." or "van Gogh, Vincent"
+var surnameParticles = map[string]bool{
+	"da": true, "de": true, "del": true, "della": true, "der": true, "des": true, "di": true,
+	"du": true, "la": true, "le": true, "st.": true, "van": true, "von": true, "den": true, "ten": true,
+}
+
+// FilingAuthor derives the author a book is filed under from its displayed author string.
+// For AuthorDirFirst and AuthorDirLastFirst only the first of several authors is used
+// ("A; B", "A & B", "A and B", "A, B"), and a "Last, First" name is recognized and
+// canonicalized. Any other style returns the author unchanged.
+func FilingAuthor(author, style string) string {
+	if style != AuthorDirFirst && style != AuthorDirLastFirst {
+		return author
+	}
+
+	first, last := primaryAuthorName(author)
+	if last == "" {
+		return first
+	}
+	if first == "" {
+		return last
+	}
+	if style == AuthorDirLastFirst {
+		return last + ", " + first
+	}
+	return first + " " + last
+}
+
+// primaryAuthorName splits the first author named in an author string into given names
+// and surname. last is empty when the name can't be split.
+func primaryAuthorName(author string) (first, last string) {
+	author = strings.Join(strings.Fields(author), " ")
+	if people := authorSeparators.Split(author, -1); len(people) > 0 && people[0] != "" {
+		author = people[0]
+	}
+
+	// "Last, First[, Last, First...]" or "First Last, First Last[, ...]"
+	if parts := strings.Split(author, ","); len(parts) > 1 {
+		head := strings.TrimSpace(parts[0])
+		if isSurname(head) {
+			return strings.TrimSpace(parts[1]), head
+		}
+		author = head
+	}
+
+	words := strings.Fields(author)
+	if len(words) < 2 {
+		return author, ""
+	}
+	// Keep particles with the surname: "Ursula K. Le Guin" files under "Le Guin"
+	split := len(words) - 1
+	for split > 1 && surnameParticles[strings.ToLower(words[split-1])] {
+		split--
+	}
+	return strings.Join(words[:split], " "), strings.Join(words[split:], " ")
+}
+
+// SplitAuthors returns the people named in an author string, each as "First Last" and
+// without duplicates. Separators like "A; B", "A & B", "A and B" and "A, B" are recognized,
+// as are "Last, First" names ("Gaiman, Neil; Pratchett, Terry").
+func SplitAuthors(author string) []string {
+	var names []string
+	for _, group := range authorSeparators.Split(strings.Join(strings.Fields(author), " "), -1) {
+		parts := strings.Split(group, ",")
+		for i := 0; i < len(parts); i++ {
+			name := strings.TrimSpace(parts[i])
+			if i+1 < len(parts) && isSurname(name) && strings.TrimSpace(parts[i+1]) != "" {
+				name = strings.TrimSpace(parts[i+1]) + " " + name
+				i++
+			}
+			if name != "" && !containsFold(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// isSurname reports whether the text before a comma looks like a surname rather than a
+// full name: a single word, optionally preceded by particles
+func isSurname(s string) bool {
+	words := strings.Fields(s)
+	if len(words) == 0 {
+		return false
+	}
+	for _, word := range words[:len(words)-1] {
+		if !surnameParticles[strings.ToLower(word)] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,41 @@
+package metadata
+
+import "testing"
+
+func TestFilingAuthor(t *testing.T) {
+	tests := []struct {
+		author, first, lastFirst string
+	}{
+		{"Jane Austen", "Jane Austen", "Austen, Jane"},
+		{"Austen, Jane", "Jane Austen", "Austen, Jane"},
+		{"Terry Pratchett & Neil Gaiman", "Terry Pratchett", "Pratchett, Terry"},
+		{"Terry Pratchett and Neil Gaiman", "Terry Pratchett", "Pratchett, Terry"},
+		{"Stephen King with Peter Straub", "Stephen King", "King, Stephen"},
+		{"King, Stephen; Straub, Peter", "Stephen King", "King, Stephen"},
+		{"Stephen King, Peter Straub", "Stephen King", "King, Stephen"},
+		{"Ursula K. Le Guin", "Ursula K. Le Guin", "Le Guin, Ursula K."},
+		{"Le Guin, Ursula K.", "Ursula K. Le Guin", "Le Guin, Ursula K."},
+		{"van Gogh, Vincent", "Vincent van Gogh", "van Gogh, Vincent"},
+		{"  Jane   Austen ", "Jane Austen", "Austen, Jane"},
+		{"Homer", "Homer", "Homer"},
+	}
+	for _, tt := range tests {
+		if got := FilingAuthor(tt.author, AuthorDirFirst); got != tt.first {
+			t.Errorf("FilingAuthor(%q, first) = %q, want %q", tt.author, got, tt.first)
+		}
+		if got := FilingAuthor(tt.author, AuthorDirLastFirst); got != tt.lastFirst {
+			t.Errorf("FilingAuthor(%q, last_first) = %q, want %q", tt.author, got, tt.lastFirst)
+		}
+		if got := FilingAuthor(tt.author, AuthorDirAsIs); got != tt.author {
+			t.Errorf("FilingAuthor(%q, as_is) = %q, want it unchanged", tt.author, got)
+		}
+	}
+}
+
+func TestRenderPathTemplateFilingAuthor(t *testing.T) {
+	// Directories use the filing author, the file name the author as displayed
+	got := RenderPathTemplate("{initial}/{author}/{title} - {author}", "King, Stephen; Straub, Peter", "The Talisman", "epub", DefaultLeadingArticles, AuthorDirFirst)
+	if want := "S/Stephen King/The Talisman - King, Stephen; Straub, Peter.epub"; got != want {
+		t.Errorf("RenderPathTemplate = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,865 @@
+package metadata
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// errPDFEncrypted is returned for PDFs whose strings are encrypted
+var errPDFEncrypted = errors.New("PDF is encrypted")
+
+// maxPDFStreamBytes limits the decompressed size of a cross-reference or object stream,
+// so that a small compressed stream cannot inflate to gigabytes
+const maxPDFStreamBytes = 32 * 1024 * 1024
+
+// maxPDFNesting limits how deeply arrays and dictionaries may nest
+const maxPDFNesting = 64
+
+// pdfName, pdfString and pdfRef are the PDF object types that need telling apart from
+// plain Go values; numbers are int or float64, arrays []interface{} and dictionaries
+// map[string]interface{}
+type (
+	pdfName   string
+	pdfString string
+	pdfRef    struct{ num, gen int }
+)
+
+// pdfFile reads objects from a PDF through its cross-reference tables
+type pdfFile struct {
+	r        io.ReaderAt
+	size     int64
+	offsets  map[int]int64  // Objects stored directly in the file
+	inStream map[int][2]int // Objects stored in object streams: stream object, index
+	info     *pdfRef        // The document information dictionary
+}
+
+// readPDFInfo returns the text entries of a PDF's document information dictionary
+// (Title, Author, Subject, Keywords, CreationDate, ...). Broken cross-reference
+// tables are tolerated by searching the file for the dictionary instead.
+func readPDFInfo(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return parsePDFInfo(f, stat.Size())
+}
+
+// parsePDFInfo reads the document information dictionary of the size bytes of PDF in r
+func parsePDFInfo(r io.ReaderAt, size int64) (map[string]string, error) {
+	p := &pdfFile{r: r, size: size, offsets: make(map[int]int64), inStream: make(map[int][2]int)}
+	if !bytes.Contains(p.readAt(0, 1024), []byte("%PDF")) {
+		return nil, errors.New("not a PDF file")
+	}
+	encrypted, xrefErr := p.loadXref()
+	if encrypted {
+		return nil, errPDFEncrypted
+	}
+	if p.info == nil {
+		// Without usable cross-references, the last trailer in the file still names it
+		if bytes.Contains(p.readAt(p.size-64*1024, 64*1024), []byte("/Encrypt")) {
+			return nil, errPDFEncrypted
+		}
+		p.info = p.findInfoRef()
+	}
+	if p.info == nil {
+		if xrefErr != nil {
+			return nil, xrefErr
+		}
+		return nil, errors.New("no document information dictionary")
+	}
+
+	value, err := p.resolve(*p.info, 0)
+	if err != nil {
+		return nil, err
+	}
+	dict, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("document information is not a dictionary")
+	}
+
+	info := make(map[string]string)
+	for key, value := range dict {
+		if ref, ok := value.(pdfRef); ok {
+			if value, err = p.resolve(ref, 1); err != nil {
+				continue
+			}
+		}
+		if s, ok := value.(pdfString); ok {
+			info[key] = pdfText([]byte(s))
+		}
+	}
+	return info, nil
+}
+
+// readAt returns up to n bytes at off, fewer at the end of the file. A negative off
+// reads from the start of the file, so readAt(p.size-n, n) is the last n bytes.
+func (p *pdfFile) readAt(off int64, n int) []byte {
+	if off < 0 {
+		n += int(off)
+		off = 0
+	}
+	if n <= 0 || off >= p.size {
+		return nil
+	}
+	if rest := p.size - off; int64(n) > rest {
+		n = int(rest)
+	}
+	buf := make([]byte, n)
+	read, _ := p.r.ReadAt(buf, off)
+	return buf[:read]
+}
+
+var startXrefPattern = regexp.MustCompile(`startxref\s+(\d+)`)
+
+// loadXref reads the cross-reference sections from the last startxref back through
+// /Prev, keeping the newest entry of every object. It reports whether the PDF is
+// encrypted.
+func (p *pdfFile) loadXref() (bool, error) {
+	matches := startXrefPattern.FindAllSubmatch(p.readAt(p.size-2048, 2048), -1)
+	if len(matches) == 0 {
+		return false, errors.New("no startxref")
+	}
+	offset, _ := strconv.ParseInt(string(matches[len(matches)-1][1]), 10, 64)
+
+	pending := []int64{offset}
+	seen := make(map[int64]bool)
+	for len(pending) > 0 {
+		offset, pending = pending[0], pending[1:]
+		if seen[offset] {
+			continue
+		}
+		seen[offset] = true
+
+		var trailer map[string]interface{}
+		var err error
+		if bytes.HasPrefix(bytes.TrimLeft(p.readAt(offset, 16), " \t\r\n"), []byte("xref")) {
+			trailer, err = p.readXrefTable(offset)
+		} else {
+			trailer, err = p.readXrefStream(offset)
+		}
+		if err != nil {
+			return false, err
+		}
+
+		if _, ok := trailer["Encrypt"]; ok {
+			return true, nil
+		}
+		if ref, ok := trailer["Info"].(pdfRef); ok && p.info == nil {
+			p.info = &ref
+		}
+		// Hybrid files list their compressed objects in a stream next to the table
+		if stm, ok := trailer["XRefStm"].(int); ok && stm >= 0 {
+			pending = append(pending, int64(stm))
+		}
+		if prev, ok := trailer["Prev"].(int); ok && prev >= 0 {
+			pending = append(pending, int64(prev))
+		}
+	}
+	return false, nil
+}
+
+// readXrefTable reads a classic "xref" table and returns its trailer dictionary
+func (p *pdfFile) readXrefTable(offset int64) (map[string]interface{}, error) {
+	// Read enough of the file to hold the table and its trailer
+	var data []byte
+	for n := 64 * 1024; ; n *= 2 {
+		data = p.readAt(offset, n)
+		if bytes.Contains(data, []byte("trailer")) || len(data) < n {
+			break
+		}
+	}
+
+	lx := &pdfLexer{data: data}
+	lx.skipSpace()
+	if !lx.keyword("xref") {
+		return nil, errors.New("invalid xref table")
+	}
+	for {
+		lx.skipSpace()
+		if lx.keyword("trailer") {
+			break
+		}
+		start, ok1 := lx.integer()
+		count, ok2 := lx.integer()
+		if !ok1 || !ok2 {
+			return nil, errors.New("invalid xref subsection")
+		}
+		for i := 0; i < count; i++ {
+			entryOffset, ok1 := lx.integer()
+			_, ok2 := lx.integer()
+			lx.skipSpace()
+			if !ok1 || !ok2 || lx.pos >= len(lx.data) {
+				return nil, errors.New("invalid xref entry")
+			}
+			kind := lx.data[lx.pos]
+			lx.pos++
+			if _, known := p.offsets[start+i]; kind == 'n' && !known && entryOffset >= 0 {
+				if _, compressed := p.inStream[start+i]; !compressed {
+					p.offsets[start+i] = int64(entryOffset)
+				}
+			}
+		}
+	}
+
+	value, err := lx.value()
+	if err != nil {
+		return nil, err
+	}
+	trailer, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("invalid trailer")
+	}
+	return trailer, nil
+}
+
+// readXrefStream reads a PDF 1.5 cross-reference stream and returns its dictionary,
+// which doubles as the trailer
+func (p *pdfFile) readXrefStream(offset int64) (map[string]interface{}, error) {
+	dict, data, err := p.readStreamAt(offset)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xref stream: %v", err)
+	}
+
+	// Each field is a big-endian number of at most 7 bytes, so that it fits an int
+	var widths []int
+	if w, ok := dict["W"].([]interface{}); ok {
+		for _, v := range w {
+			n, _ := v.(int)
+			if n < 0 || n > 7 {
+				return nil, errors.New("invalid xref stream widths")
+			}
+			widths = append(widths, n)
+		}
+	}
+	if len(widths) != 3 {
+		return nil, errors.New("invalid xref stream widths")
+	}
+	index := []interface{}{0, dict["Size"]}
+	if i, ok := dict["Index"].([]interface{}); ok {
+		index = i
+	}
+
+	entrySize := widths[0] + widths[1] + widths[2]
+	if entrySize == 0 {
+		return nil, errors.New("invalid xref stream widths")
+	}
+	field := func(entry []byte, from, width int) int {
+		n := 0
+		for _, b := range entry[from : from+width] {
+			n = n<<8 | int(b)
+		}
+		return n
+	}
+	pos := 0
+	for i := 0; i+1 < len(index); i += 2 {
+		start, _ := index[i].(int)
+		count, _ := index[i+1].(int)
+		for j := 0; j < count && pos+entrySize <= len(data); j++ {
+			entry := data[pos : pos+entrySize]
+			pos += entrySize
+
+			kind := 1
+			if widths[0] > 0 {
+				kind = field(entry, 0, widths[0])
+			}
+			num := start + j
+			_, known := p.offsets[num]
+			_, compressed := p.inStream[num]
+			if known || compressed {
+				continue
+			}
+			switch kind {
+			case 1:
+				p.offsets[num] = int64(field(entry, widths[0], widths[1]))
+			case 2:
+				p.inStream[num] = [2]int{field(entry, widths[0], widths[1]), field(entry, widths[0]+widths[1], widths[2])}
+			}
+		}
+	}
+	return dict, nil
+}
+
+var infoRefPattern = regexp.MustCompile(`/Info\s*(\d+)\s+(\d+)\s+R`)
+
+// findInfoRef looks for the /Info entry of the last trailer at the end of the file, or
+// of the first-page trailer of a linearized file at its start
+func (p *pdfFile) findInfoRef() *pdfRef {
+	for _, chunk := range [][]byte{p.readAt(p.size-64*1024, 64*1024), p.readAt(0, 64*1024)} {
+		matches := infoRefPattern.FindAllSubmatch(chunk, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		last := matches[len(matches)-1]
+		num, _ := strconv.Atoi(string(last[1]))
+		gen, _ := strconv.Atoi(string(last[2]))
+		return &pdfRef{num, gen}
+	}
+	return nil
+}
+
+// resolve returns the value of an indirect object
+func (p *pdfFile) resolve(ref pdfRef, depth int) (interface{}, error) {
+	if depth > 4 {
+		return nil, errors.New("too deeply nested references")
+	}
+	if offset, ok := p.offsets[ref.num]; ok {
+		if value, err := p.objectAt(offset, ref.num); err == nil {
+			return value, nil
+		}
+	}
+	if location, ok := p.inStream[ref.num]; ok {
+		return p.compressedObject(location[0], location[1], depth)
+	}
+
+	// The cross-reference data is missing or wrong; find the object itself
+	offset, ok := p.searchObject(ref)
+	if !ok {
+		return nil, fmt.Errorf("object %d not found", ref.num)
+	}
+	return p.objectAt(offset, ref.num)
+}
+
+// objectAt parses the "num gen obj" object at offset and returns its value
+func (p *pdfFile) objectAt(offset int64, num int) (interface{}, error) {
+	lx := &pdfLexer{data: p.readAt(offset, 64*1024)}
+	if n, ok := lx.objectHeader(); !ok || n != num {
+		return nil, fmt.Errorf("object %d not at offset %d", num, offset)
+	}
+	return lx.value()
+}
+
+// readStreamAt parses the stream object at offset, returning its dictionary and
+// decoded data
+func (p *pdfFile) readStreamAt(offset int64) (map[string]interface{}, []byte, error) {
+	lx := &pdfLexer{data: p.readAt(offset, 64*1024)}
+	if _, ok := lx.objectHeader(); !ok {
+		return nil, nil, errors.New("no object header")
+	}
+	value, err := lx.value()
+	if err != nil {
+		return nil, nil, err
+	}
+	dict, ok := value.(map[string]interface{})
+	lx.skipSpace()
+	if !ok || !lx.keyword("stream") {
+		return nil, nil, errors.New("not a stream")
+	}
+	if lx.pos < len(lx.data) && lx.data[lx.pos] == '\r' {
+		lx.pos++
+	}
+	if lx.pos < len(lx.data) && lx.data[lx.pos] == '\n' {
+		lx.pos++
+	}
+
+	start := offset + int64(lx.pos)
+	var raw []byte
+	if length, ok := dict["Length"].(int); ok && length >= 0 {
+		raw = p.readAt(start, length)
+	} else {
+		// An indirect /Length; the data runs up to endstream
+		rest := p.readAt(start, 16*1024*1024)
+		end := bytes.Index(rest, []byte("endstream"))
+		if end < 0 {
+			return nil, nil, errors.New("unterminated stream")
+		}
+		raw = rest[:end]
+	}
+
+	data, err := decodeStream(dict, raw)
+	return dict, data, err
+}
+
+// compressedObject returns object number index of the object stream streamNum
+func (p *pdfFile) compressedObject(streamNum, index, depth int) (interface{}, error) {
+	offset, ok := p.offsets[streamNum]
+	if !ok {
+		if offset, ok = p.searchObject(pdfRef{streamNum, 0}); !ok {
+			return nil, fmt.Errorf("object stream %d not found", streamNum)
+		}
+	}
+	dict, data, err := p.readStreamAt(offset)
+	if err != nil {
+		return nil, err
+	}
+	first, _ := dict["First"].(int)
+	count, _ := dict["N"].(int)
+	if index < 0 || index >= count || first < 0 || first > len(data) {
+		return nil, fmt.Errorf("object %d missing from object stream %d", index, streamNum)
+	}
+
+	// The stream starts with pairs of object numbers and offsets from /First
+	lx := &pdfLexer{data: data[:first]}
+	var objectOffset int
+	for i := 0; i <= index; i++ {
+		_, ok1 := lx.integer()
+		off, ok2 := lx.integer()
+		if !ok1 || !ok2 {
+			return nil, errors.New("invalid object stream header")
+		}
+		objectOffset = off
+	}
+	if objectOffset > len(data)-first {
+		return nil, errors.New("invalid object stream offset")
+	}
+	lx = &pdfLexer{data: data[first+objectOffset:]}
+	return lx.value()
+}
+
+// searchObject scans the file for the last definition of an object, for PDFs whose
+// cross-reference data is broken
+func (p *pdfFile) searchObject(ref pdfRef) (int64, bool) {
+	pattern := regexp.MustCompile(fmt.Sprintf(`(?:^|[^0-9])%d\s+%d\s+obj\b`, ref.num, ref.gen))
+	const chunkSize, overlap = 1024 * 1024, 64
+
+	found, offset := false, int64(0)
+	for start := int64(0); start < p.size; start += chunkSize - overlap {
+		chunk := p.readAt(start, chunkSize)
+		for _, match := range pattern.FindAllIndex(chunk, -1) {
+			at := match[0]
+			if chunk[at] < '0' || chunk[at] > '9' {
+				at++
+			}
+			found, offset = true, start+int64(at)
+		}
+		if len(chunk) < chunkSize {
+			break
+		}
+	}
+	return offset, found
+}
+
+// decodeStream undoes a stream's FlateDecode filter and PNG predictor, the only
+// encoding used for cross-reference and object streams in practice
+func decodeStream(dict map[string]interface{}, raw []byte) ([]byte, error) {
+	filter := dict["Filter"]
+	if filters, ok := filter.([]interface{}); ok && len(filters) == 1 {
+		filter = filters[0]
+	}
+	if filter == nil {
+		return raw, nil
+	}
+	if filter != pdfName("FlateDecode") {
+		return nil, fmt.Errorf("unsupported stream filter %v", filter)
+	}
+
+	zr, err := zlib.NewReader(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(io.LimitReader(zr, maxPDFStreamBytes+1))
+	if err != nil && len(data) == 0 {
+		return nil, err
+	}
+	if len(data) > maxPDFStreamBytes {
+		return nil, errors.New("PDF stream too large")
+	}
+
+	params, _ := dict["DecodeParms"].(map[string]interface{})
+	if predictor, _ := params["Predictor"].(int); predictor >= 10 {
+		columns, _ := params["Columns"].(int)
+		if columns <= 0 {
+			columns = 1
+		}
+		return pngUnpredict(data, columns)
+	}
+	return data, nil
+}
+
+// pngUnpredict reverses the PNG row filters of predicted stream data, one byte per pixel
+func pngUnpredict(data []byte, columns int) ([]byte, error) {
+	if columns > len(data) {
+		return nil, errors.New("invalid predicted stream length")
+	}
+	rowSize := columns + 1
+	if len(data)%rowSize != 0 {
+		return nil, errors.New("invalid predicted stream length")
+	}
+	out := make([]byte, 0, len(data)/rowSize*columns)
+	prev := make([]byte, columns)
+	for row := 0; row < len(data); row += rowSize {
+		filter, line := data[row], append([]byte(nil), data[row+1:row+rowSize]...)
+		for i := range line {
+			var left, upLeft byte
+			if i > 0 {
+				left, upLeft = line[i-1], prev[i-1]
+			}
+			switch filter {
+			case 1: // Sub
+				line[i] += left
+			case 2: // Up
+				line[i] += prev[i]
+			case 3: // Average
+				line[i] += byte((int(left) + int(prev[i])) / 2)
+			case 4: // Paeth
+				line[i] += paeth(left, prev[i], upLeft)
+			}
+		}
+		out = append(out, line...)
+		prev = line
+	}
+	return out, nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	if pa <= pb && pa <= pc {
+		return a
+	}
+	if pb <= pc {
+		return b
+	}
+	return c
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// pdfDocEncoding maps the bytes where PDFDocEncoding differs from Latin-1
+var pdfDocEncoding = map[byte]rune{
+	0x80: '•', 0x81: '†', 0x82: '‡', 0x83: '…', 0x84: '—', 0x85: '–', 0x86: 'ƒ', 0x87: '⁄',
+	0x88: '‹', 0x89: '›', 0x8a: '−', 0x8b: '‰', 0x8c: '„', 0x8d: '“', 0x8e: '”', 0x8f: '‘',
+	0x90: '’', 0x91: '‚', 0x92: '™', 0x93: 'ﬁ', 0x94: 'ﬂ', 0x95: 'Ł', 0x96: 'Œ', 0x97: 'Š',
+	0x98: 'Ÿ', 0x99: 'Ž', 0x9a: 'ı', 0x9b: 'ł', 0x9c: 'œ', 0x9d: 'š', 0x9e: 'ž', 0xa0: '€',
+}
+
+// pdfText decodes a PDF text string: UTF-16BE or UTF-8 with a byte order mark, else
+// PDFDocEncoding. Strings that are valid UTF-8 without a mark, as some producers write,
+// are taken as UTF-8.
+func pdfText(s []byte) string {
+	switch {
+	case len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff:
+		units := make([]uint16, 0, len(s)/2)
+		for i := 2; i+1 < len(s); i += 2 {
+			units = append(units, uint16(s[i])<<8|uint16(s[i+1]))
+		}
+		return string(utf16.Decode(units))
+	case bytes.HasPrefix(s, []byte("\xef\xbb\xbf")):
+		return string(s[3:])
+	case utf8.Valid(s):
+		return string(s)
+	}
+
+	var b strings.Builder
+	for _, c := range s {
+		if r, ok := pdfDocEncoding[c]; ok {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+	return b.String()
+}
+
+// pdfDate converts a PDF date ("D:20051231235959+01'00'") to "2005-12-31", keeping
+// only as much of it as is given. It returns "" for values that are not dates.
+func pdfDate(value string) string {
+	digits := strings.TrimPrefix(strings.TrimSpace(value), "D:")
+	n := 0
+	for n < len(digits) && n < 8 && digits[n] >= '0' && digits[n] <= '9' {
+		n++
+	}
+	switch {
+	case n >= 8:
+		return digits[:4] + "-" + digits[4:6] + "-" + digits[6:8]
+	case n >= 6:
+		return digits[:4] + "-" + digits[4:6]
+	case n >= 4:
+		return digits[:4]
+	}
+	return ""
+}
+
+// pdfLexer parses PDF objects from a buffer
+type pdfLexer struct {
+	data  []byte
+	pos   int
+	depth int // Arrays and dictionaries being parsed
+}
+
+// isPDFSpace reports whether c is PDF whitespace
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+}
+
+// isPDFDelimiter reports whether c ends a name or keyword
+func isPDFDelimiter(c byte) bool {
+	return isPDFSpace(c) || strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+// skipSpace skips whitespace and comments
+func (lx *pdfLexer) skipSpace() {
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		if c == '%' {
+			for lx.pos < len(lx.data) && lx.data[lx.pos] != '\r' && lx.data[lx.pos] != '\n' {
+				lx.pos++
+			}
+			continue
+		}
+		if !isPDFSpace(c) {
+			return
+		}
+		lx.pos++
+	}
+}
+
+// keyword consumes word if it is next
+func (lx *pdfLexer) keyword(word string) bool {
+	end := lx.pos + len(word)
+	if end > len(lx.data) || string(lx.data[lx.pos:end]) != word {
+		return false
+	}
+	if end < len(lx.data) && !isPDFDelimiter(lx.data[end]) {
+		return false
+	}
+	lx.pos = end
+	return true
+}
+
+// token consumes a run of regular characters
+func (lx *pdfLexer) token() string {
+	start := lx.pos
+	for lx.pos < len(lx.data) && !isPDFDelimiter(lx.data[lx.pos]) {
+		lx.pos++
+	}
+	return string(lx.data[start:lx.pos])
+}
+
+// integer consumes a non-negative integer
+func (lx *pdfLexer) integer() (int, bool) {
+	lx.skipSpace()
+	start := lx.pos
+	for lx.pos < len(lx.data) && lx.data[lx.pos] >= '0' && lx.data[lx.pos] <= '9' {
+		lx.pos++
+	}
+	n, err := strconv.Atoi(string(lx.data[start:lx.pos]))
+	return n, err == nil
+}
+
+// objectHeader consumes "num gen obj" and returns num
+func (lx *pdfLexer) objectHeader() (int, bool) {
+	num, ok1 := lx.integer()
+	_, ok2 := lx.integer()
+	lx.skipSpace()
+	return num, ok1 && ok2 && lx.keyword("obj")
+}
+
+// value parses the next object
+func (lx *pdfLexer) value() (interface{}, error) {
+	lx.skipSpace()
+	if lx.pos >= len(lx.data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	if c := lx.data[lx.pos]; c == '[' || (c == '<' && lx.pos+1 < len(lx.data) && lx.data[lx.pos+1] == '<') {
+		if lx.depth >= maxPDFNesting {
+			return nil, errors.New("too deeply nested objects")
+		}
+		lx.depth++
+		defer func() { lx.depth-- }()
+	}
+
+	switch c := lx.data[lx.pos]; {
+	case c == '<' && lx.pos+1 < len(lx.data) && lx.data[lx.pos+1] == '<':
+		lx.pos += 2
+		dict := make(map[string]interface{})
+		for {
+			lx.skipSpace()
+			if lx.pos+1 < len(lx.data) && lx.data[lx.pos] == '>' && lx.data[lx.pos+1] == '>' {
+				lx.pos += 2
+				return dict, nil
+			}
+			key, err := lx.value()
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(pdfName)
+			if !ok {
+				return nil, errors.New("dictionary key is not a name")
+			}
+			if dict[string(name)], err = lx.value(); err != nil {
+				return nil, err
+			}
+		}
+	case c == '<':
+		return lx.hexString()
+	case c == '(':
+		return lx.literalString()
+	case c == '/':
+		lx.pos++
+		return pdfName(decodeName(lx.token())), nil
+	case c == '[':
+		lx.pos++
+		array := []interface{}{}
+		for {
+			lx.skipSpace()
+			if lx.pos < len(lx.data) && lx.data[lx.pos] == ']' {
+				lx.pos++
+				return array, nil
+			}
+			item, err := lx.value()
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, item)
+		}
+	case c == '+' || c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		return lx.number()
+	}
+
+	switch word := lx.token(); word {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	case "":
+		return nil, fmt.Errorf("unexpected %q", lx.data[lx.pos])
+	default:
+		return nil, fmt.Errorf("unexpected keyword %q", word)
+	}
+}
+
+// number parses a number, or an indirect reference "num gen R"
+func (lx *pdfLexer) number() (interface{}, error) {
+	word := lx.token()
+	n, err := strconv.Atoi(word)
+	if err != nil {
+		f, err := strconv.ParseFloat(word, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", word)
+		}
+		return f, nil
+	}
+
+	// Look ahead for "gen R"
+	save := lx.pos
+	if gen, ok := lx.integer(); ok && lx.pos > save {
+		lx.skipSpace()
+		if lx.keyword("R") {
+			return pdfRef{n, gen}, nil
+		}
+	}
+	lx.pos = save
+	return n, nil
+}
+
+// hexString parses "<48656C6C6F>"
+func (lx *pdfLexer) hexString() (interface{}, error) {
+	end := bytes.IndexByte(lx.data[lx.pos:], '>')
+	if end < 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	var digits []byte
+	for _, c := range lx.data[lx.pos+1 : lx.pos+end] {
+		if !isPDFSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	lx.pos += end + 1
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		b, err := strconv.ParseUint(string(digits[2*i:2*i+2]), 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex string")
+		}
+		out[i] = byte(b)
+	}
+	return pdfString(out), nil
+}
+
+// literalString parses "(text)", with balanced parentheses and backslash escapes
+func (lx *pdfLexer) literalString() (interface{}, error) {
+	lx.pos++
+	var out []byte
+	depth := 1
+	for lx.pos < len(lx.data) {
+		c := lx.data[lx.pos]
+		lx.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return pdfString(out), nil
+			}
+		case '\\':
+			if lx.pos >= len(lx.data) {
+				return nil, io.ErrUnexpectedEOF
+			}
+			c = lx.data[lx.pos]
+			lx.pos++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r', '\n':
+				// A backslash at the end of a line continues the string
+				if c == '\r' && lx.pos < len(lx.data) && lx.data[lx.pos] == '\n' {
+					lx.pos++
+				}
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					n := int(c - '0')
+					for i := 0; i < 2 && lx.pos < len(lx.data) && lx.data[lx.pos] >= '0' && lx.data[lx.pos] <= '7'; i++ {
+						n = n*8 + int(lx.data[lx.pos]-'0')
+						lx.pos++
+					}
+					c = byte(n)
+				}
+			}
+		}
+		out = append(out, c)
+	}
+	return nil, io.ErrUnexpectedEOF
+}
+
+// decodeName undoes the "#xx" escapes of a name
+func decodeName(name string) string {
+	if !strings.Contains(name, "#") {
+		return name
+	}
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+2 < len(name) {
+			if c, err := strconv.ParseUint(name[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,155 @@
+package metadata
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadPDFInfo(t *testing.T) {
+	tests := []struct {
+		file string
+		want map[string]string
+	}{
+		{"classic.pdf", map[string]string{
+			"Title":        "Le Petit Prince ✓",
+			"Author":       "Saint-Exupéry, Antoine de",
+			"Subject":      "A (nested) story\nline",
+			"Keywords":     "kids; classics, french",
+			"CreationDate": "D:19430406120000Z",
+		}},
+		// Wrong xref offsets: the objects are found by searching the file
+		{"broken-xref.pdf", map[string]string{
+			"Title":  "Microsoft Word - draft.docx",
+			"Author": "Jane Roe and John Doe",
+		}},
+		// Info dictionary inside a compressed object stream, PNG-predicted xref stream
+		{"objstm.pdf", map[string]string{
+			"Title":  "Compressed Title",
+			"Author": "Zed Author",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			info, err := readPDFInfo(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatalf("readPDFInfo: %v", err)
+			}
+			for key, want := range tt.want {
+				if info[key] != want {
+					t.Errorf("%s = %q, want %q", key, info[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadPDFInfoEncrypted(t *testing.T) {
+	if _, err := readPDFInfo(filepath.Join("testdata", "encrypted.pdf")); err != errPDFEncrypted {
+		t.Errorf("err = %v, want %v", err, errPDFEncrypted)
+	}
+}
+
+// buildPDF lays out numbered objects after a header and appends the cross-reference
+// section xref returns, given the offset of every object and its own offset
+func buildPDF(objects []string, xref func(offsets []int, at int) string) []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.5\n")
+	offsets := []int{0}
+	for i, object := range objects {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	at := b.Len()
+	b.WriteString(xref(offsets, at))
+	fmt.Fprintf(&b, "\nstartxref\n%d\n%%%%EOF\n", at)
+	return b.Bytes()
+}
+
+// xrefStream returns an uncompressed cross-reference stream object numbered num with
+// 1-, 4- and 1-byte fields, entries holding each object's type, field 2 and field 3
+func xrefStream(num int, dict string, entries [][3]int) string {
+	var data []byte
+	for _, entry := range entries {
+		data = append(data, byte(entry[0]), byte(entry[1]>>24), byte(entry[1]>>16), byte(entry[1]>>8), byte(entry[1]), byte(entry[2]))
+	}
+	return fmt.Sprintf("%d 0 obj\n<< /Type /XRef /W [1 4 1] %s /Length %d >>\nstream\n%s\nendstream\nendobj", num, dict, len(data), data)
+}
+
+// objectStreamXref lists object 1 as an object stream holding object 3, the Info dictionary
+func objectStreamXref(offsets []int, at int) string {
+	return xrefStream(2, "/Size 4 /Info 3 0 R", [][3]int{{0, 0, 0}, {1, offsets[1], 0}, {1, at, 0}, {2, 1, 0}})
+}
+
+func TestParsePDFInfoMalformed(t *testing.T) {
+	var bomb bytes.Buffer
+	zw := zlib.NewWriter(&bomb)
+	zw.Write(make([]byte, maxPDFStreamBytes+1024))
+	zw.Close()
+
+	tests := map[string][]byte{
+		"negative xref stream width": buildPDF(nil, func(offsets []int, at int) string {
+			return fmt.Sprintf("1 0 obj\n<< /Type /XRef /Size 2 /W [-1 2 1] /Length 4 >>\nstream\n\x00\x00%c\x00\nendstream\nendobj", at)
+		}),
+		"negative object stream First": buildPDF([]string{
+			"<< /Type /ObjStm /N 1 /First -3 /Length 13 >>\nstream\n3 0 << /Title (x) >>\nendstream",
+		}, objectStreamXref),
+		"object stream offset beyond the data": buildPDF([]string{
+			"<< /Type /ObjStm /N 1 /First 22 /Length 24 >>\nstream\n3 9223372036854775807 42\nendstream",
+		}, objectStreamXref),
+		"inflating object stream": buildPDF([]string{
+			fmt.Sprintf("<< /Type /ObjStm /N 1 /First 4 /Filter /FlateDecode /Length %d >>\nstream\n%s\nendstream", bomb.Len(), bomb.Bytes()),
+		}, objectStreamXref),
+		"deeply nested info": buildPDF([]string{
+			"<< /Title " + strings.Repeat("[", 100000) + " >>",
+		}, func(offsets []int, at int) string {
+			return fmt.Sprintf("xref\n0 2\n0000000000 65535 f \n%010d 00000 n \ntrailer\n<< /Size 2 /Info 1 0 R >>", offsets[1])
+		}),
+		"huge predictor columns": buildPDF(nil, func(offsets []int, at int) string {
+			var data bytes.Buffer
+			zw := zlib.NewWriter(&data)
+			zw.Close()
+			return fmt.Sprintf("1 0 obj\n<< /Type /XRef /Size 2 /W [1 1 1] /Filter /FlateDecode /DecodeParms << /Predictor 12 /Columns 9223372036854775807 >> /Length %d >>\nstream\n%s\nendstream\nendobj", data.Len(), data.Bytes())
+		}),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			info, err := parsePDFInfo(bytes.NewReader(data), int64(len(data)))
+			if err == nil && info["Title"] != "" {
+				t.Errorf("got title %q from a malformed PDF", info["Title"])
+			}
+		})
+	}
+}
+
+func FuzzParsePDFInfo(f *testing.F) {
+	for _, name := range []string{"classic.pdf", "broken-xref.pdf", "encrypted.pdf", "objstm.pdf"} {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		parsePDFInfo(bytes.NewReader(data), int64(len(data)))
+	})
+}
+
+func TestPDFDate(t *testing.T) {
+	tests := map[string]string{
+		"D:20051231235959+01'00'": "2005-12-31",
+		"D:200512":                "2005-12",
+		"2005":                    "2005",
+		"D:20":                    "",
+		"yesterday":               "",
+	}
+	for value, want := range tests {
+		if got := pdfDate(value); got != want {
+			t.Errorf("pdfDate(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package metadata
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fableflow/backend/conversion"
+)
+
+// SetSidecarMetadata sets whether ExtractMetadata looks for metadata stored next to the
+// book (library.sidecar_metadata)
+func (e *Extractor) SetSidecarMetadata(enabled bool) {
+	e.sidecarMetadata = enabled
+}
+
+// sidecarJSON is a metadata .json file. Calibre's field names (comments, pubdate,
+// languages, identifiers) are accepted next to the plain ones.
+type sidecarJSON struct {
+	Title       string            `json:"title"`
+	Author      json.RawMessage   `json:"author"`  // A name or a list of names
+	Authors     json.RawMessage   `json:"authors"` // A list of names, or names joined by " & "
+	Publisher   string            `json:"publisher"`
+	Language    string            `json:"language"`
+	Languages   []string          `json:"languages"`
+	Description string            `json:"description"`
+	Comments    string            `json:"comments"`
+	ISBN        string            `json:"isbn"`
+	Identifiers map[string]string `json:"identifiers"`
+	Date        string            `json:"date"`
+	PubDate     string            `json:"pubdate"`
+	Tags        []string          `json:"tags"`
+	Rights      string            `json:"rights"`
+	Series      string            `json:"series"`
+	SeriesIndex float64           `json:"series_index"`
+}
+
+// applySidecarMetadata merges the metadata of a sidecar file found next to filePath into
+// extracted: fields the sidecar sets win, the others are kept. A broken sidecar is
+// logged and ignored.
+func (e *Extractor) applySidecarMetadata(filePath string, extracted *BookMetadata) {
+	path := e.SidecarPath(filePath)
+	if path == "" {
+		return
+	}
+
+	sidecar, err := e.readSidecar(path)
+	if err != nil {
+		log.Printf("Ignoring sidecar metadata %s: %v", path, err)
+		return
+	}
+
+	if sidecar.Title != "" {
+		extracted.Title = sidecar.Title
+	}
+	if !IsUnknownAuthor(sidecar.Author) {
+		extracted.Author = sidecar.Author
+		extracted.Authors = sidecar.Authors
+		extracted.Roles = sidecar.Roles
+	}
+	if sidecar.Publisher != "" {
+		extracted.Publisher = sidecar.Publisher
+	}
+	if sidecar.Language != "" {
+		extracted.Language = sidecar.Language
+	}
+	if sidecar.Description != "" {
+		extracted.Description = sidecar.Description
+	}
+	if sidecar.ISBN != "" {
+		extracted.ISBN = sidecar.ISBN
+	}
+	if sidecar.Date != "" {
+		extracted.Date = sidecar.Date
+	}
+	if len(sidecar.Subjects) > 0 {
+		extracted.Subjects = sidecar.Subjects
+		extracted.Subject = sidecar.Subjects[0]
+	}
+	if sidecar.Rights != "" {
+		extracted.Rights = sidecar.Rights
+	}
+	if sidecar.Series != "" {
+		extracted.Series = sidecar.Series
+		extracted.SeriesIndex = sidecar.SeriesIndex
+	}
+	log.Printf("Applied sidecar metadata %s - Title: %s, Author: %s", path, extracted.Title, extracted.Author)
+}
+
+// SidecarPath returns the metadata file ExtractMetadata uses for a book: "<name>.opf" or
+// "<name>.json" next to it, or a directory-wide metadata.opf or metadata.json (as Calibre
+// writes) when the directory holds no other book. It returns "" when there is none or
+// sidecar metadata is off.
+func (e *Extractor) SidecarPath(filePath string) string {
+	if !e.sidecarMetadata {
+		return ""
+	}
+
+	dir := filepath.Dir(filePath)
+	base := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+
+	candidates := []string{base + ".opf", base + ".json"}
+	if soleBookInDirectory(dir, base) {
+		candidates = append(candidates, "metadata.opf", "metadata.json")
+	}
+	for _, name := range candidates {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path
+		}
+	}
+	return ""
+}
+
+// bookExtensions are the extensions of book files metadata can be extracted from
+var bookExtensions = map[string]bool{".epub": true, ".pdf": true, ".mobi": true, ".azw": true, ".azw3": true}
+
+// soleBookInDirectory reports whether every book file in dir is named base, so that
+// other formats of the same book share its directory-wide metadata
+func soleBookInDirectory(dir, base string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := strings.ToLower(filepath.Ext(name))
+		if entry.IsDir() || !bookExtensions[ext] {
+			continue
+		}
+		if strings.TrimSuffix(name, filepath.Ext(name)) != base {
+			return false
+		}
+	}
+	return true
+}
+
+// readSidecar parses an .opf or .json metadata file. Fields the file does not set are
+// left empty, and the author is "" rather than the "Unknown" placeholder.
+func (e *Extractor) readSidecar(path string) (*BookMetadata, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".opf") {
+		opf, err := conversion.ParseOPFData(data)
+		if err != nil {
+			return nil, err
+		}
+		sidecar := e.convertOPFToBookMetadata(opf)
+		if len(opf.Metadata.Creator) == 0 {
+			sidecar.Author = ""
+		}
+		for _, identifier := range opf.Metadata.Identifier {
+			if isISBN(strings.TrimSpace(identifier)) {
+				sidecar.ISBN = cleanISBN(identifier)
+				break
+			}
+		}
+		return sidecar, nil
+	}
+
+	var doc sidecarJSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	sidecar := &BookMetadata{
+		Title:       e.CleanText(doc.Title),
+		Publisher:   e.CleanText(doc.Publisher),
+		Language:    e.CleanText(doc.Language),
+		Description: strings.TrimSpace(firstNonEmpty(doc.Description, doc.Comments)),
+		Date:        e.CleanText(firstNonEmpty(doc.Date, doc.PubDate)),
+		Rights:      e.CleanText(doc.Rights),
+		Series:      e.CleanText(doc.Series),
+	}
+	if sidecar.Series != "" && doc.SeriesIndex > 0 {
+		sidecar.SeriesIndex = doc.SeriesIndex
+	}
+	if sidecar.Language == "" && len(doc.Languages) > 0 {
+		sidecar.Language = e.CleanText(doc.Languages[0])
+	}
+	if isbn := firstNonEmpty(doc.ISBN, doc.Identifiers["isbn"]); isbn != "" {
+		sidecar.ISBN = cleanISBN(isbn)
+	}
+
+	var names []string
+	for _, raw := range []json.RawMessage{doc.Authors, doc.Author} {
+		parsed, err := jsonNames(raw)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, parsed...)
+	}
+	for _, name := range names {
+		for _, author := range SplitAuthors(e.CleanText(name)) {
+			if !containsFold(sidecar.Authors, author) {
+				sidecar.Authors = append(sidecar.Authors, author)
+			}
+		}
+	}
+	if len(names) > 0 {
+		sidecar.Author = e.CleanText(names[0])
+	}
+
+	for _, tag := range doc.Tags {
+		tag = e.CleanText(tag)
+		if tag != "" && !containsFold(sidecar.Subjects, tag) {
+			sidecar.Subjects = append(sidecar.Subjects, tag)
+		}
+	}
+	if len(sidecar.Subjects) > 0 {
+		sidecar.Subject = sidecar.Subjects[0]
+	}
+
+	return sidecar, nil
+}
+
+// jsonNames decodes an author field holding either a name or a list of names
+func jsonNames(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil {
+		if strings.TrimSpace(name) == "" {
+			return nil, nil
+		}
+		return []string{name}, nil
+	}
+	var names []string
+	if err := json.Unmarshal(raw, &names); err != nil {
+		return nil, fmt.Errorf("authors must be a string or a list of strings")
+	}
+	return names, nil
+}
+
+// cleanISBN strips the urn:isbn: or isbn: prefix, hyphens and spaces from an ISBN
+func cleanISBN(isbn string) string {
+	clean := strings.TrimSpace(isbn)
+	for _, prefix := range []string{"urn:isbn:", "isbn:"} {
+		if strings.HasPrefix(strings.ToLower(clean), prefix) {
+			clean = clean[len(prefix):]
+		}
+	}
+	return strings.NewReplacer("-", "", " ", "").Replace(clean)
+}
+
+// firstNonEmpty returns the first of values that is not blank
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,55 @@
+package metadata
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractorSidecarMetadata(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "subjects.epub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	book := filepath.Join(dir, "book.epub")
+	if err := os.WriteFile(book, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sidecar := `{"title": "Curated Title", "authors": ["Curated Author"], "series": "Curated Series", "series_index": 2}`
+	if err := os.WriteFile(filepath.Join(dir, "book.json"), []byte(sidecar), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Sidecar files are ignored unless library.sidecar_metadata is on
+	e := NewExtractor()
+	if path := e.SidecarPath(book); path != "" {
+		t.Errorf("SidecarPath = %q with sidecar metadata off", path)
+	}
+	embedded, err := e.ExtractMetadata(book)
+	if err != nil {
+		t.Fatalf("ExtractMetadata: %v", err)
+	}
+	if embedded.Title == "Curated Title" {
+		t.Error("sidecar applied with sidecar metadata off")
+	}
+
+	e.SetSidecarMetadata(true)
+	if path, want := e.SidecarPath(book), filepath.Join(dir, "book.json"); path != want {
+		t.Errorf("SidecarPath = %q, want %q", path, want)
+	}
+	curated, err := e.ExtractMetadata(book)
+	if err != nil {
+		t.Fatalf("ExtractMetadata: %v", err)
+	}
+	if curated.Title != "Curated Title" || curated.Author != "Curated Author" || curated.Series != "Curated Series" || curated.SeriesIndex != 2 {
+		t.Errorf("read %q by %q, %q #%v; want the sidecar's fields", curated.Title, curated.Author, curated.Series, curated.SeriesIndex)
+	}
+	if curated.Language != embedded.Language {
+		t.Errorf("Language = %q, want %q kept from the book", curated.Language, embedded.Language)
+	}
+
+	if other, _ := NewExtractor().ExtractMetadata(book); other == nil || other.Title != embedded.Title {
+		t.Error("turning sidecar metadata on for one extractor changed another")
+	}
+}
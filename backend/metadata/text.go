@@ -0,0 +1,51 @@
+package metadata
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// SetCleanText sets whether the extractor normalizes metadata values with CleanText
+// (library.clean_metadata). When off, values are only trimmed.
+func (e *Extractor) SetCleanText(enabled bool) {
+	e.cleanText = enabled
+}
+
+// CleanText normalizes a metadata value with CleanText, or only trims it when cleaning
+// is off
+func (e *Extractor) CleanText(s string) string {
+	if !e.cleanText {
+		return strings.TrimSpace(s)
+	}
+	return CleanText(s)
+}
+
+// invisibleRunes are format characters that render as nothing but make otherwise equal
+// titles differ, e.g. in directory names. Zero-width (non-)joiners are kept since some
+// scripts and emoji need them.
+var invisibleRunes = map[rune]bool{
+	'\u00ad': true, // Soft hyphen
+	'\u200b': true, // Zero-width space
+	'\u2060': true, // Word joiner
+	'\ufeff': true, // Byte order mark / zero-width no-break space
+}
+
+// CleanText normalizes a single-line metadata value such as a title or author: HTML
+// entities like "&amp;" are decoded, control and zero-width characters removed, and runs
+// of whitespace collapsed to one space
+func CleanText(s string) string {
+	s = html.UnescapeString(s)
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case invisibleRunes[r]:
+			return -1
+		case unicode.IsSpace(r):
+			return ' '
+		case unicode.IsControl(r):
+			return -1
+		}
+		return r
+	}, s)
+	return strings.Join(strings.Fields(s), " ")
+}
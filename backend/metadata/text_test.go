@@ -0,0 +1,49 @@
+package metadata
+
+import "testing"
+
+func TestCleanText(t *testing.T) {
+	tests := []struct {
+		name, value, want string
+	}{
+		{"named entity", "Pride &amp; Prejudice", "Pride & Prejudice"},
+		{"doubled entity", "Tom &amp;amp; Jerry", "Tom &amp; Jerry"},
+		{"decimal entity", "Caf&#233; Society", "Café Society"},
+		{"hex entity", "Na&#xEF;ve", "Naïve"},
+		{"quote entities", "&quot;Quoted&quot; &lt;Title&gt;", `"Quoted" <Title>`},
+		{"zero-width space", "Dra\u200bcula", "Dracula"},
+		{"soft hyphen", "Fran\u00adkenstein", "Frankenstein"},
+		{"byte order mark", "\ufeffEmma", "Emma"},
+		{"word joiner", "Jane\u2060 Eyre", "Jane Eyre"},
+		{"zero-width joiner kept", "Family \U0001F468\u200d\U0001F469", "Family \U0001F468\u200d\U0001F469"},
+		{"control characters", "Moby\x00 Dick\x07", "Moby Dick"},
+		{"padding", "   Middlemarch \t", "Middlemarch"},
+		{"runs of spaces", "The    Time     Machine", "The Time Machine"},
+		{"newlines and tabs", "War\n\tand\r\nPeace", "War and Peace"},
+		{"non-breaking space", "Les\u00a0Misérables", "Les Misérables"},
+		{"everything at once", " \ufeffH. G.\u00a0 Wells &amp;\n Co\u200b ", "H. G. Wells & Co"},
+		{"only invisible", "\u200b\u00ad ", ""},
+	}
+	for _, tt := range tests {
+		if got := CleanText(tt.value); got != tt.want {
+			t.Errorf("%s: CleanText(%q) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestExtractorCleanText(t *testing.T) {
+	value := "  Pride &amp;   Prejudice\u200b\n"
+	e := NewExtractor()
+	if got, want := e.CleanText(value), "Pride & Prejudice"; got != want {
+		t.Errorf("CleanText = %q, want %q", got, want)
+	}
+
+	// With library.clean_metadata off values are only trimmed
+	e.SetCleanText(false)
+	if got, want := e.CleanText(value), "Pride &amp;   Prejudice\u200b"; got != want {
+		t.Errorf("CleanText with cleaning off = %q, want %q", got, want)
+	}
+	if NewExtractor().CleanText(value) != "Pride & Prejudice" {
+		t.Error("turning cleaning off for one extractor changed another")
+	}
+}
@@ -4,27 +4,67 @@ import "time"
 
 // Book represents an ebook in our collection
 type Book struct {
-	ID        int       `json:"id"`
-	Title     string    `json:"title"`
-	Author    string    `json:"author"`
-	FilePath  string    `json:"file_path"`
-	FileSize  int64     `json:"file_size"`
-	Format    string    `json:"format"`
-	ISBN      string    `json:"isbn"`
-	Publisher string    `json:"publisher"`
-	AddedAt   time.Time `json:"added_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID            int               `json:"id"`
+	Title         string            `json:"title"`
+	Author        string            `json:"author"`
+	FilePath      string            `json:"file_path"`
+	FileSize      int64             `json:"file_size"`
+	Format        string            `json:"format"`
+	ISBN          string            `json:"isbn"`
+	Publisher     string            `json:"publisher"`
+	PublishedDate string            `json:"published_date"`
+	Year          int               `json:"year"`
+	DRM           bool              `json:"drm"`
+	Description   string            `json:"description"` // Shortened in list responses, full in book details
+	SortTitle     string            `json:"sort_title"`
+	SortAuthor    string            `json:"sort_author"`
+	UID           string            `json:"uid"`                     // EPUB package unique identifier, stable across devices
+	EPUBModified  *time.Time        `json:"epub_modified,omitempty"` // dcterms:modified from the EPUB, when it has one
+	Series        string            `json:"series,omitempty"`
+	SeriesIndex   float64           `json:"series_index,omitempty"`  // Position in the series, 0 when unknown
+	FileModified  *time.Time        `json:"file_modified,omitempty"` // Modification time of the file; only in book details
+	Authors       []string          `json:"authors,omitempty"`       // Everyone credited, in credit order
+	Roles         map[string]string `json:"roles,omitempty"`         // MARC relator codes of those in Authors not credited as author; only in book details
+	Tags          []string          `json:"tags,omitempty"`
+	CustomFields  map[string]string `json:"custom_fields,omitempty"` // Only in book details
+	Editions      []Edition         `json:"editions,omitempty"`      // Other entries of the same book; only in book details, with library.group_editions
+	AddedAt       time.Time         `json:"added_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// SeriesGroup is a series and the books of it in the library, in series order
+type SeriesGroup struct {
+	Series string `json:"series"`
+	Books  []Book `json:"books"`
+}
+
+// Edition is another library entry of the same book, e.g. the PDF next to an EPUB
+type Edition struct {
+	ID       int    `json:"id"`
+	Format   string `json:"format"`
+	FilePath string `json:"file_path"`
+	FileSize int64  `json:"file_size"`
 }
 
 // BookRequest represents a request to add/update a book
 type BookRequest struct {
-	Title     string `json:"title"`
-	Author    string `json:"author"`
-	FilePath  string `json:"file_path"`
-	FileSize  int64  `json:"file_size"`
-	Format    string `json:"format"`
-	ISBN      string `json:"isbn"`
-	Publisher string `json:"publisher"`
+	Title         string            `json:"title"`
+	Author        string            `json:"author"`
+	FilePath      string            `json:"file_path"`
+	FileSize      int64             `json:"file_size"`
+	Format        string            `json:"format"`
+	ISBN          string            `json:"isbn"`
+	Publisher     string            `json:"publisher"`
+	PublishedDate string            `json:"published_date"`
+	DRM           bool              `json:"-"` // Detected from the file, never taken from clients
+	Description   string            `json:"description"`
+	UID           string            `json:"uid,omitempty"`
+	EPUBModified  *time.Time        `json:"epub_modified,omitempty"`
+	Series        string            `json:"series,omitempty"`
+	SeriesIndex   float64           `json:"series_index,omitempty"`
+	Authors       []string          `json:"authors,omitempty"` // Everyone credited; split from Author when empty
+	Roles         map[string]string `json:"roles,omitempty"`   // MARC relator codes of names in Authors other than "aut"
+	Tags          []string          `json:"tags,omitempty"`
 }
 
 // QuarantineBook represents a book in quarantine with additional quarantine information
@@ -35,6 +75,86 @@ type QuarantineBook struct {
 	QuarantineDate   string `json:"quarantine_date,omitempty"`
 }
 
+// AuthorCount represents an author and the number of books they have in the library
+type AuthorCount struct {
+	Author string `json:"author"`
+	Count  int    `json:"count"`
+}
+
+// PublisherCount represents a publisher and the number of books it has in the library
+type PublisherCount struct {
+	Publisher string `json:"publisher"`
+	Count     int    `json:"count"`
+}
+
+// TagCount represents a tag and the number of books it is applied to
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// TitleCount represents a title and the number of books (editions) sharing it
+type TitleCount struct {
+	Title string `json:"title"`
+	Count int    `json:"count"`
+}
+
+// FormatCount represents the number of downloads of a single format
+type FormatCount struct {
+	Format string `json:"format"`
+	Count  int    `json:"count"`
+}
+
+// BookDownloadCount represents how often a book has been downloaded in any format
+type BookDownloadCount struct {
+	BookID int    `json:"book_id"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	Count  int    `json:"count"`
+}
+
+// YearCount represents the number of books in a single year bucket
+type YearCount struct {
+	Year  int `json:"year"`
+	Count int `json:"count"`
+}
+
+// EPUBFileEntry represents a single file inside an EPUB archive
+type EPUBFileEntry struct {
+	Name           string `json:"name"`
+	Size           int64  `json:"size"`
+	CompressedSize int64  `json:"compressed_size"`
+	MediaType      string `json:"media_type"`
+}
+
+// BookFormat describes a format a book can currently be downloaded in
+type BookFormat struct {
+	Format    string     `json:"format"`
+	Source    string     `json:"source"` // "library" for the stored file, "conversion" for a cached conversion
+	URL       string     `json:"url"`
+	Size      int64      `json:"size"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// From the conversion package's format registry; empty for unknown formats
+	Name        string   `json:"name,omitempty"`
+	Description string   `json:"description,omitempty"`
+	Extensions  []string `json:"extensions,omitempty"`
+	Devices     string   `json:"devices,omitempty"`
+	Lossy       bool     `json:"lossy"`
+}
+
+// CachedConversion is a converted file kept across restarts, valid while the source
+// file's modification time is unchanged
+type CachedConversion struct {
+	BookID      int
+	Format      string
+	Path        string
+	Size        int64
+	SourceMtime time.Time
+	CreatedAt   time.Time
+	LastUsedAt  time.Time
+}
+
 // ScanRequest represents a request to scan a directory
 type ScanRequest struct {
 	Path string `json:"path"`
@@ -45,6 +165,22 @@ type ScanResponse struct {
 	Status  string `json:"status"`
 	Added   int    `json:"added,omitempty"`
 	Removed int    `json:"removed,omitempty"`
+	JobID   string `json:"job_id,omitempty"` // Background scans can be followed in /api/jobs
+}
+
+// RescanAction is a change a rescan makes, or in a dry run would make, to one book
+type RescanAction struct {
+	BookID   int    `json:"book_id,omitempty"` // Not known yet for books to add
+	FilePath string `json:"file_path"`
+	Title    string `json:"title"`
+	Author   string `json:"author"`
+}
+
+// RescanPlan lists the changes a rescan would make without making them
+type RescanPlan struct {
+	Add    []RescanAction `json:"add"`    // New files to add as books
+	Update []RescanAction `json:"update"` // Changed files whose metadata would be re-read
+	Remove []RescanAction `json:"remove"` // Books whose file is gone, to delete from the database
 }
 
 // ErrorResponse represents an error response
@@ -53,6 +189,19 @@ type ErrorResponse struct {
 	Message string `json:"message,omitempty"`
 }
 
+// NotFoundResponse represents a 404 for a single resource, naming the ID that was requested
+type NotFoundResponse struct {
+	Error string `json:"error"`
+	ID    int    `json:"id"`
+}
+
+// PathConflictResponse represents a rejected move because the target path is already taken
+type PathConflictResponse struct {
+	Error             string `json:"error"`
+	TargetPath        string `json:"target_path"`
+	ConflictingBookID int    `json:"conflicting_book_id,omitempty"`
+}
+
 // MetadataSearchRequest represents a request to search for book metadata
 type MetadataSearchRequest struct {
 	Title  string `json:"title"`
@@ -66,6 +215,51 @@ type MetadataSearchResponse struct {
 	Message     string               `json:"message,omitempty"`
 }
 
+// AuthorInfo represents an author's biography and photo from Open Library
+type AuthorInfo struct {
+	Author     string    `json:"author"` // The name that was looked up
+	Found      bool      `json:"found"`
+	Name       string    `json:"name,omitempty"`
+	Key        string    `json:"key,omitempty"` // Open Library author ID, e.g. OL23919A
+	Bio        string    `json:"bio,omitempty"`
+	PhotoURL   string    `json:"photo_url,omitempty"`
+	WorksCount int       `json:"works_count"`
+	BirthDate  string    `json:"birth_date,omitempty"`
+	DeathDate  string    `json:"death_date,omitempty"`
+	TopWork    string    `json:"top_work,omitempty"`
+	Source     string    `json:"source"`
+	FetchedAt  time.Time `json:"fetched_at"`
+}
+
+// MetadataDiff compares a book's metadata with the best match from an external source
+type MetadataDiff struct {
+	BookID     int                 `json:"book_id"`
+	Source     string              `json:"source"`               // Where the external values came from
+	MatchedBy  string              `json:"matched_by"`           // "isbn" or "search"
+	Confidence float64             `json:"confidence,omitempty"` // Match score of a title/author search
+	Fields     []MetadataFieldDiff `json:"fields"`
+	Mismatches int                 `json:"mismatches"`
+	Suggested  MetadataEdit        `json:"suggested"` // Corrected values, ready to send to PUT /api/books/{id}/edit
+}
+
+// MetadataFieldDiff is one field of a metadata comparison
+type MetadataFieldDiff struct {
+	Field    string `json:"field"`
+	Stored   string `json:"stored"`             // Value in the library database
+	Embedded string `json:"embedded,omitempty"` // Value in the book file, when it differs from the stored one
+	External string `json:"external"`
+	Match    bool   `json:"match"` // Ignoring case, punctuation and date precision; true when the source has no value
+}
+
+// MetadataEdit holds the editable metadata fields of a book
+type MetadataEdit struct {
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	ISBN          string `json:"isbn"`
+	Publisher     string `json:"publisher"`
+	PublishedDate string `json:"published_date"`
+}
+
 // MetadataSuggestion represents a suggested metadata from external source
 type MetadataSuggestion struct {
 	Title      string  `json:"title"`
@@ -76,3 +270,23 @@ type MetadataSuggestion struct {
 	Confidence float64 `json:"confidence"`
 	Source     string  `json:"source"`
 }
+
+// ReadingProgress is the last position the reader saved for a book
+type ReadingProgress struct {
+	BookID    int       `json:"book_id"`
+	Locator   string    `json:"locator"` // EPUB CFI or another locator the reader understands
+	Percent   float64   `json:"percent"` // 0-100
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ReaderSettings are the layout preferences of the EPUB reader. Unset fields fall back
+// to the global settings and then to the defaults.
+type ReaderSettings struct {
+	FontSize   *int     `json:"font_size,omitempty"`   // Percent of the book's own size, 50-300
+	FontFamily *string  `json:"font_family,omitempty"` // "publisher" keeps the book's fonts
+	LineHeight *float64 `json:"line_height,omitempty"` // 1.0-3.0
+	Margin     *int     `json:"margin,omitempty"`      // Percent of the page width on each side, 0-25
+	Theme      *string  `json:"theme,omitempty"`       // "light", "dark" or "sepia"
+	TextAlign  *string  `json:"text_align,omitempty"`  // "publisher", "left" or "justify"
+	Flow       *string  `json:"flow,omitempty"`        // "paginated" or "scrolled"
+}
@@ -0,0 +1,49 @@
+// Package xmlutil decodes the XML files of EPUBs, which are not always clean UTF-8.
+package xmlutil
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+// utf8BOM is the byte order mark some EPUB tools prepend to UTF-8 XML files
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// xmlEncodingPattern matches the encoding attribute of an XML declaration
+var xmlEncodingPattern = regexp.MustCompile(`^<\?xml[^>]*encoding=["']([^"']+)["']`)
+
+// Unmarshal decodes XML content that may not be clean UTF-8.
+// A leading BOM is stripped and the encoding declared in the XML prolog
+// (e.g. ISO-8859-1) is honored. Files that claim UTF-8 (or declare nothing)
+// but are not valid UTF-8 are decoded as Windows-1252, the usual culprit.
+func Unmarshal(content []byte, v interface{}) error {
+	content = bytes.TrimPrefix(content, utf8BOM)
+
+	declared := ""
+	if match := xmlEncodingPattern.FindSubmatch(bytes.TrimSpace(content)); match != nil {
+		declared = strings.ToLower(string(match[1]))
+	}
+
+	if !utf8.Valid(content) && (declared == "" || declared == "utf-8" || declared == "utf8") {
+		reader, err := charset.NewReaderLabel("windows-1252", bytes.NewReader(content))
+		if err != nil {
+			return err
+		}
+		decoder := xml.NewDecoder(reader)
+		// The content is already transcoded, so ignore whatever the prolog claims
+		decoder.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+			return input, nil
+		}
+		return decoder.Decode(v)
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.CharsetReader = charset.NewReaderLabel
+	return decoder.Decode(v)
+}
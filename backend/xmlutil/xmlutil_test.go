@@ -0,0 +1,41 @@
+package xmlutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// opfMetadata is the part of an OPF package document the fixtures differ in
+type opfMetadata struct {
+	Title     string `xml:"metadata>title"`
+	Creator   string `xml:"metadata>creator"`
+	Publisher string `xml:"metadata>publisher"`
+}
+
+func TestUnmarshal(t *testing.T) {
+	tests := []struct {
+		file      string
+		publisher string
+	}{
+		{"bom.opf", "Société des Éditions «Lumière»"},
+		{"iso-8859-1.opf", "Société des Éditions «Lumière»"},
+		{"mislabelled-windows-1252.opf", "Société des Éditions “Lumière”"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var opf opfMetadata
+			if err := Unmarshal(data, &opf); err != nil {
+				t.Fatalf("Unmarshal: %v", err)
+			}
+			want := opfMetadata{Title: "Les Misérables", Creator: "Victor Hugo", Publisher: tt.publisher}
+			if opf != want {
+				t.Errorf("Unmarshal = %+v, want %+v", opf, want)
+			}
+		})
+	}
+}
@@ -8,6 +8,7 @@ export FF_BACKEND_ADDR="${FF_BACKEND_ADDR:-http://backend:8080}"
 export FF_SCAN_DIR="${FF_SCAN_DIR:-/ebooks}"
 export FF_IMPORT_DIR="${FF_IMPORT_DIR:-/import}"
 export FF_QUARANTINE_DIR="${FF_QUARANTINE_DIR:-/quarantine}"
+export FF_TRASH_DIR="${FF_TRASH_DIR:-/trash}"
 export FF_TMP_DIR="${FF_TMP_DIR:-/tmp}"
 export FF_LOG_DIR="${FF_LOG_DIR:-/logs}"
 export FF_DATABASE_PATH="${FF_DATABASE_PATH:-/database/ebooks.db}"
@@ -74,9 +74,55 @@ function fableFlowApp() {
         // Initialize the application
         init() {
             this.initializeDarkMode();
+            this.initializeAPIKey();
             this.loadRecentBooks();
         },
 
+        // Ask for the API key once when the server needs one for downloads
+        async initializeAPIKey() {
+            try {
+                const health = await (await fetch('/api/health')).json();
+                if (health.api_key_required && !localStorage.getItem('apiKey')) {
+                    const key = prompt('This server needs an API key to download books:');
+                    if (key) localStorage.setItem('apiKey', key);
+                }
+            } catch (error) {
+                console.error('Health check failed:', error);
+            }
+        },
+
+        // Headers carrying the stored API key; it is never put in a URL, where it
+        // would end up in access logs and the browser history
+        apiKeyHeaders() {
+            const key = localStorage.getItem('apiKey');
+            return key ? { 'X-API-Key': key } : {};
+        },
+
+        downloadUrl(bookId) {
+            return '/api/download/' + bookId;
+        },
+
+        // Downloads a file, sending the API key in a header
+        async download(url) {
+            try {
+                const response = await fetch(url, { headers: this.apiKeyHeaders() });
+                if (!response.ok) {
+                    throw new Error((await response.text()) || response.statusText);
+                }
+                const disposition = response.headers.get('Content-Disposition') || '';
+                const encoded = disposition.match(/filename\*=UTF-8''([^;]+)/);
+                const plain = disposition.match(/filename="([^"]*)"/);
+                const link = document.createElement('a');
+                link.href = URL.createObjectURL(await response.blob());
+                link.download = encoded ? decodeURIComponent(encoded[1]) : (plain ? plain[1] : url.split('/').pop());
+                link.click();
+                setTimeout(() => URL.revokeObjectURL(link.href), 0);
+            } catch (error) {
+                console.error('Download failed:', error);
+                this.showToast(`Download failed: ${error.message}`);
+            }
+        },
+
         // Initialize dark mode from localStorage
         initializeDarkMode() {
             const savedDarkMode = localStorage.getItem('darkMode');
@@ -486,10 +532,13 @@ function fableFlowApp() {
                 }
                 
                 const result = await response.json();
+                if (!result.cached) {
+                    await this.waitForConversion(result.job_id);
+                }
                 this.showToast(`Conversion completed! File will be available for download for 1 hour.`);
                 
                 // Automatically download the converted file
-                window.open(`/api/convert/${bookId}/${format}`, '_blank');
+                await this.download(`/api/convert/${bookId}/${format}`);
                 
             } catch (error) {
                 console.error('Conversion error:', error);
@@ -499,6 +548,20 @@ function fableFlowApp() {
             }
         },
 
+        // Polls a queued conversion until it finishes, failing when it did not succeed
+        async waitForConversion(jobId) {
+            for (;;) {
+                await new Promise(resolve => setTimeout(resolve, 2000));
+                const response = await fetch(`/api/convert/job/${jobId}`);
+                if (!response.ok) throw new Error('Conversion job was lost');
+
+                const job = await response.json();
+                if (job.status === 'done') return job;
+                if (job.status === 'failed') throw new Error(job.error || 'Conversion failed');
+                if (job.status === 'cancelled') throw new Error('Conversion was cancelled');
+            }
+        },
+
         async checkConversionStatus() {
             try {
                 const response = await fetch('/api/convert/status');